@@ -0,0 +1,168 @@
+// @@
+// @ Author       : Eacher
+// @ Date         : 2026-10-15 09:33:15
+// @ LastEditTime : 2026-10-22 16:33:37
+// @ LastEditors  : Eacher
+// @ --------------------------------------------------------------------------------<
+// @ Description  : 滑动窗口内的事件统计
+// @ --------------------------------------------------------------------------------<
+// @ FilePath     : /inotify/analytics.go
+// @@
+package inotify
+
+import (
+	"sort"
+	"sync"
+	"time"
+	"expvar"
+)
+
+// PathCount 文件与窗口内的事件数
+type PathCount struct {
+	Path 	string
+	Count 	int
+}
+
+// AnalyticsStats 窗口内的统计结果
+type AnalyticsStats struct {
+	Window 	time.Duration
+	Events 	int
+	// 每秒事件数，按 GetEventName 分类
+	Rate 	map[string]float64
+	// 事件最多的文件，按 Count 从大到小
+	Top 	[]PathCount
+	// 窗口内涉及的不同文件数
+	Unique 	int
+}
+
+// 按秒分桶
+type bucket struct {
+	sec 	int64
+	events 	int
+	ops 	map[string]int
+	paths 	map[string]int
+}
+
+// Analytics 按秒分桶统计最近 window 内的事件
+type Analytics struct {
+	mutex 	sync.Mutex
+	buckets []bucket
+	now 	func() time.Time
+	// 最近一次 Observe 的秒
+	last 	int64
+	detectors []*Detector
+}
+
+func NewAnalytics(window time.Duration) *Analytics {
+	n := int((window + time.Second - 1) / time.Second)
+	if n < 1 {
+		n = 1
+	}
+	return &Analytics{buckets: make([]bucket, n), now: time.Now}
+}
+
+// Analytics 统计该 Watcher 之后读到的事件(已通过过滤)，Watcher 未初始化时返回 nil
+func (w *Watcher) Analytics(window time.Duration) *Analytics {
+	if !w.initialized() {
+		return nil
+	}
+	a := NewAnalytics(window)
+	w.observe(a.Observe)
+	return a
+}
+
+// SetClock 替换分桶使用的时钟，用于回放记录的事件或测试，nil 时为 time.Now
+func (a *Analytics) SetClock(now func() time.Time) {
+	if now == nil {
+		now = time.Now
+	}
+	a.mutex.Lock()
+	a.now = now
+	a.mutex.Unlock()
+}
+
+// Observe 记录一个事件
+func (a *Analytics) Observe(e Event) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	sec := a.now().Unix()
+	if sec > a.last && len(a.detectors) > 0 {
+		a.rotate(sec)
+	}
+	a.last = sec
+	b := &a.buckets[sec%int64(len(a.buckets))]
+	if b.sec != sec {
+		*b = bucket{sec: sec, ops: make(map[string]int), paths: make(map[string]int)}
+	}
+	op := e.GetEventName()
+	b.events++
+	b.ops[op]++
+	b.paths[e.FileName]++
+	for _, d := range a.detectors {
+		d.check(time.Unix(sec, 0), op, b.ops[op])
+	}
+}
+
+// rotate 进入新的一秒，把上一秒及中间没有事件的秒交给 detectors 学习
+func (a *Analytics) rotate(sec int64) {
+	var ops map[string]int
+	if b := &a.buckets[a.last%int64(len(a.buckets))]; a.last != 0 && b.sec == a.last {
+		ops = b.ops
+	}
+	idle := sec - a.last - 1
+	if a.last == 0 || idle > 60 {
+		idle = 60
+	}
+	for _, d := range a.detectors {
+		if ops != nil {
+			d.learn(ops)
+		}
+		for i := int64(0); i < idle; i++ {
+			d.learn(nil)
+		}
+	}
+}
+
+// Stats 当前窗口的统计，top 为返回的最热文件数
+func (a *Analytics) Stats(top int) AnalyticsStats {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	sec := a.now().Unix()
+	st := AnalyticsStats{Window: time.Duration(len(a.buckets))*time.Second, Rate: make(map[string]float64)}
+	paths := make(map[string]int)
+	for i := range a.buckets {
+		b := &a.buckets[i]
+		if b.ops == nil || sec-b.sec >= int64(len(a.buckets)) {
+			continue
+		}
+		st.Events += b.events
+		for op, n := range b.ops {
+			st.Rate[op] += float64(n)
+		}
+		for path, n := range b.paths {
+			paths[path] += n
+		}
+	}
+	for op := range st.Rate {
+		st.Rate[op] /= st.Window.Seconds()
+	}
+	st.Unique = len(paths)
+	for path, n := range paths {
+		st.Top = append(st.Top, PathCount{Path: path, Count: n})
+	}
+	sort.Slice(st.Top, func(i, j int) bool {
+		if st.Top[i].Count != st.Top[j].Count {
+			return st.Top[i].Count > st.Top[j].Count
+		}
+		return st.Top[i].Path < st.Top[j].Path
+	})
+	if top >= 0 && len(st.Top) > top {
+		st.Top = st.Top[:top]
+	}
+	return st
+}
+
+// Publish 以 name 发布到 expvar，内容为 Stats(10)
+func (a *Analytics) Publish(name string) {
+	expvar.Publish(name, expvar.Func(func() interface{} { return a.Stats(10) }))
+}
@@ -0,0 +1,108 @@
+// @@
+// @ Author       : Eacher
+// @ Date         : 2026-10-15 10:37:18
+// @ LastEditTime : 2026-10-15 11:17:42
+// @ LastEditors  : Eacher
+// @ --------------------------------------------------------------------------------<
+// @ Description  : 基于 EWMA 的事件风暴检测(大量删除、rename 等)
+// @ --------------------------------------------------------------------------------<
+// @ FilePath     : /inotify/anomaly.go
+// @@
+package inotify
+
+import (
+	"math"
+	"time"
+)
+
+// Anomaly 某类事件在一秒内的数量明显偏离基线
+type Anomaly struct {
+	Time 	time.Time
+	Op 		string
+	// 当前这一秒的事件数
+	Count 	int
+	Mean 	float64
+	StdDev 	float64
+	// (Count - Mean) / StdDev
+	Score 	float64
+}
+
+type ewma struct {
+	mean 	float64
+	vari 	float64
+	n 		int
+	fired 	int64
+}
+
+// Detector 每类事件按秒计数，用指数加权的均值与方差作为基线
+type Detector struct {
+	// 平滑系数，越大基线变化越快
+	Alpha 		float64
+	// 超过基线多少个标准差视为异常
+	Threshold 	float64
+	// 每秒少于 MinCount 个事件不视为异常
+	MinCount 	int
+	// 前 Warmup 秒只学习不报警
+	Warmup 		int
+	// 在新的 goroutine 中调用，每类事件每秒最多一次
+	OnAnomaly 	func(Anomaly)
+
+	ops 		map[string]*ewma
+}
+
+func NewDetector(threshold float64, onAnomaly func(Anomaly)) *Detector {
+	return &Detector{Alpha: 0.1, Threshold: threshold, MinCount: 10, Warmup: 30, OnAnomaly: onAnomaly, ops: make(map[string]*ewma)}
+}
+
+// Detect 在 Analytics 统计的事件上运行 d
+func (a *Analytics) Detect(d *Detector) {
+	a.mutex.Lock()
+	a.detectors = append(a.detectors, d)
+	a.mutex.Unlock()
+}
+
+// learn 一秒结束后更新基线
+func (d *Detector) learn(ops map[string]int) {
+	for op, v := range d.ops {
+		v.update(float64(ops[op]), d.Alpha)
+	}
+	for op, n := range ops {
+		if _, ok := d.ops[op]; !ok {
+			v := &ewma{}
+			v.update(float64(n), d.Alpha)
+			d.ops[op] = v
+		}
+	}
+}
+
+// check 当前这一秒 op 已有 count 个事件
+func (d *Detector) check(now time.Time, op string, count int) {
+	v := d.ops[op]
+	if v == nil {
+		v = &ewma{}
+		d.ops[op] = v
+	}
+	if count < d.MinCount || v.n < d.Warmup || v.fired == now.Unix() {
+		return
+	}
+	// 标准差至少为 1，避免静默基线上的少量事件被放大
+	std := math.Max(math.Sqrt(v.vari), 1)
+	if score := (float64(count) - v.mean) / std; score >= d.Threshold {
+		v.fired = now.Unix()
+		if d.OnAnomaly != nil {
+			go d.OnAnomaly(Anomaly{Time: now, Op: op, Count: count, Mean: v.mean, StdDev: std, Score: score})
+		}
+	}
+}
+
+func (v *ewma) update(x, alpha float64) {
+	if v.n == 0 {
+		v.mean = x
+	} else {
+		diff := x - v.mean
+		incr := alpha * diff
+		v.mean += incr
+		v.vari = (1 - alpha) * (v.vari + diff*incr)
+	}
+	v.n++
+}
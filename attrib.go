@@ -0,0 +1,55 @@
+// @@
+// @ Author       : Eacher
+// @ Date         : 2026-10-20 17:45:49
+// @ LastEditTime : 2026-10-21 09:29:34
+// @ LastEditors  : Eacher
+// @ --------------------------------------------------------------------------------<
+// @ Description  : IN_ATTRIB 前后的属性
+// @ --------------------------------------------------------------------------------<
+// @ FilePath     : /inotify/attrib.go
+// @@
+package inotify
+
+import (
+	"time"
+	"io/fs"
+)
+
+// FileAttr 文件的属性，windows 上 Ino、Uid、Gid、Atime、Ctime 为零值
+type FileAttr struct {
+	Ino 	uint64
+	Mode 	fs.FileMode
+	Uid 	uint32
+	Gid 	uint32
+	Size 	int64
+	Atime 	time.Time
+	Mtime 	time.Time
+	Ctime 	time.Time
+}
+
+// AttribChange WithAttribDiff 时 IN_ATTRIB 事件的 Event.Attrib，Before 为缓存中上一次的属性，After 为交给调用者前 lstat 的属性
+type AttribChange struct {
+	Before 	FileAttr
+	After 	FileAttr
+}
+
+// Changed 变化了的属性: "mode"、"owner"、"size"、"atime"、"mtime"，ctime 每次都会变化，不包含
+func (c *AttribChange) Changed() []string {
+	var changed []string
+	if c.Before.Mode != c.After.Mode {
+		changed = append(changed, "mode")
+	}
+	if c.Before.Uid != c.After.Uid || c.Before.Gid != c.After.Gid {
+		changed = append(changed, "owner")
+	}
+	if c.Before.Size != c.After.Size {
+		changed = append(changed, "size")
+	}
+	if !c.Before.Atime.Equal(c.After.Atime) {
+		changed = append(changed, "atime")
+	}
+	if !c.Before.Mtime.Equal(c.After.Mtime) {
+		changed = append(changed, "mtime")
+	}
+	return changed
+}
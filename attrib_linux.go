@@ -0,0 +1,92 @@
+// @@
+// @ Author       : Eacher
+// @ Date         : 2026-10-20 17:45:49
+// @ LastEditTime : 2026-10-21 09:29:34
+// @ LastEditors  : Eacher
+// @ --------------------------------------------------------------------------------<
+// @ Description  : 缓存监听路径的属性，IN_ATTRIB 时与之前的属性比较
+// @ --------------------------------------------------------------------------------<
+// @ FilePath     : /inotify/attrib_linux.go
+// @@
+package inotify
+
+import (
+	"os"
+	"time"
+	"path/filepath"
+	"golang.org/x/sys/unix"
+)
+
+// WithAttribDiff IN_ATTRIB 事件的 Event.Attrib 为属性(权限、所有者、时间)变化前后的值。
+// 添加监听时缓存该路径与目录中文件的属性，之后由交给调用者的事件更新；
+// 添加监听之后出现、且没有监听 IN_CREATE 等事件而不在缓存中的文件，第一次 IN_ATTRIB 的 Attrib 为 nil
+func WithAttribDiff() Option {
+	return func(w *Watcher) {
+		w.attrs = make(map[string]FileAttr)
+	}
+}
+
+// attrOf lstat path 的属性
+func attrOf(path string) (FileAttr, bool) {
+	var st unix.Stat_t
+	if unix.Lstat(path, &st) != nil {
+		return FileAttr{}, false
+	}
+	// fs.FileMode 与 st.Mode 的类型位不同，只保留权限与特殊位
+	mode := os.FileMode(st.Mode&0777)
+	if st.Mode&unix.S_ISUID != 0 {
+		mode |= os.ModeSetuid
+	}
+	if st.Mode&unix.S_ISGID != 0 {
+		mode |= os.ModeSetgid
+	}
+	if st.Mode&unix.S_ISVTX != 0 {
+		mode |= os.ModeSticky
+	}
+	if st.Mode&unix.S_IFMT == unix.S_IFDIR {
+		mode |= os.ModeDir
+	}
+	return FileAttr{Ino: st.Ino, Mode: mode, Uid: st.Uid, Gid: st.Gid, Size: st.Size, Atime: time.Unix(st.Atim.Unix()), Mtime: time.Unix(st.Mtim.Unix()), Ctime: time.Unix(st.Ctim.Unix())}, true
+}
+
+// seedAttrs 将新添加的监听 path 与目录中文件的属性放入 attrs，调用者需持有 mutex
+func seedAttrs(attrs map[string]FileAttr, path string, isDir bool) {
+	path = filepath.Clean(path)
+	if a, ok := attrOf(path); ok {
+		attrs[path] = a
+	}
+	if !isDir {
+		return
+	}
+	entries, _ := os.ReadDir(path)
+	for _, entry := range entries {
+		name := filepath.Join(path, entry.Name())
+		if a, ok := attrOf(name); ok {
+			attrs[name] = a
+		}
+	}
+}
+
+// attrib 由交给调用者的事件更新缓存，IN_ATTRIB 时设置 e.Attrib，调用者需持有 mutex
+func (w *Watcher) attrib(e *Event) {
+	path := e.Path()
+	if path == "" || e.Raw&IN_Q_OVERFLOW != 0 {
+		return
+	}
+	// 目录自身的事件以分隔符结尾
+	path = filepath.Clean(path)
+	switch {
+	case e.Raw&(IN_DELETE|IN_DELETE_SELF|IN_MOVED_FROM) != 0:
+		delete(w.attrs, path)
+	case e.Raw&(IN_ATTRIB|IN_CREATE|IN_MOVED_TO|IN_MODIFY|IN_CLOSE_WRITE) != 0:
+		after, ok := attrOf(path)
+		if !ok {
+			delete(w.attrs, path)
+			return
+		}
+		if before, cached := w.attrs[path]; cached && e.Raw&IN_ATTRIB != 0 {
+			e.Attrib = &AttribChange{Before: before, After: after}
+		}
+		w.attrs[path] = after
+	}
+}
@@ -0,0 +1,199 @@
+// @@
+// @ Author       : Eacher
+// @ Date         : 2026-10-18 10:40:05
+// @ LastEditTime : 2026-10-21 16:28:48
+// @ LastEditors  : Eacher
+// @ --------------------------------------------------------------------------------<
+// @ Description  : Watcher 使用的 inotify 接口，默认为内核的 inotify fd，测试时可替换为 fakes.Backend
+// @ --------------------------------------------------------------------------------<
+// @ FilePath     : /inotify/backend_linux.go
+// @@
+package inotify
+
+import (
+	"os"
+	"sync"
+	"time"
+	"errors"
+	"unsafe"
+	"golang.org/x/sys/unix"
+)
+
+// WatchBackend Watcher 添加、移除监听与读取事件的接口，与内核 inotify 的语义相同:
+// ReadEvents 以内核相同的格式(unix.InotifyEvent 后跟以 NUL 填充的名字)写入 buf，没有事件时返回 unix.EAGAIN，
+// buf 放不下下一个事件时返回 unix.EINVAL；移除的监听之后要返回 IN_IGNORED 事件。
+// Add、Remove 的 errno 可以包装为 *os.SyscallError 等，Watcher 用 errors.Is 判断。
+// Fd 在有事件可读时变为可读，用于 epoll 边缘触发或 WithExternalLoop
+type WatchBackend interface {
+	// Add 同 inotify_add_watch，同一路径返回相同的 wd，isDir 为 path 是否为目录
+	Add(path string, mask uint32) (wd int, isDir bool, err error)
+	// Remove 同 inotify_rm_watch
+	Remove(wd int) error
+	ReadEvents(buf []byte) (int, error)
+	Fd() int
+	Close() error
+}
+
+// WithBackend 使用 b 代替内核的 inotify fd，Watcher 关闭时关闭 b。
+// AddRecursiveWatch、WithRescan 仍会读取真实的文件系统
+func WithBackend(b WatchBackend) Option {
+	return func(w *Watcher) {
+		w.backend = b
+	}
+}
+
+// BackendType NewWatcher 使用的内置后端，WithBackend 设置时不使用
+type BackendType int
+
+const (
+	// BackendInotify 内核的 inotify，默认
+	BackendInotify BackendType = iota
+	// BackendFanotify fanotify，需要 CAP_SYS_ADMIN，只有 IN_ACCESS、IN_MODIFY、IN_CLOSE、IN_OPEN 事件，其他事件不会产生
+	BackendFanotify
+	// BackendPoll 按 WithPollInterval 对比监听路径的 stat，不需要内核支持，没有 IN_OPEN、IN_ACCESS、IN_CLOSE，rename 为 DELETE 与 CREATE
+	BackendPoll
+	// BackendAuto 依次尝试 inotify、fanotify、轮询，使用第一个可用的
+	BackendAuto
+)
+
+// WithBackendType 选择内置的后端，WithEventFilter、AddRecursiveWatch、WithModifyDedup 等与后端无关
+func WithBackendType(t BackendType) Option {
+	return func(w *Watcher) {
+		w.backendType = t
+	}
+}
+
+// newBackend 创建 t 的后端，per 为 WithInstancePool 的每个实例的监听数量，flags 为 WithFdFlags 的 inotify_init1 标志
+func newBackend(t BackendType, interval time.Duration, per, flags int) (WatchBackend, error) {
+	switch t {
+	case BackendFanotify:
+		return newFanBackend()
+	case BackendPoll:
+		return newPoller(interval)
+	case BackendAuto:
+		if k, err := newBackend(BackendInotify, interval, per, flags); err == nil {
+			return k, nil
+		}
+		if f, err := newFanBackend(); err == nil {
+			return f, nil
+		}
+		return newPoller(interval)
+	}
+	if per > 0 {
+		return newKernelPool(per, flags)
+	}
+	return newKernel(flags)
+}
+
+// recordQueue 以内核的格式排队的事件，有事件时 efd 可读，用于不直接读取 inotify fd 的后端
+type recordQueue struct {
+	mutex 	sync.Mutex
+	efd 	int
+	queue 	[]byte
+	closed 	bool
+}
+
+func newRecordQueue() (*recordQueue, error) {
+	efd, err := unix.Eventfd(0, unix.EFD_CLOEXEC|unix.EFD_NONBLOCK)
+	if err != nil {
+		return nil, os.NewSyscallError("eventfd", err)
+	}
+	return &recordQueue{efd: efd}, nil
+}
+
+// push 加入一个事件，名字与内核相同以 NUL 填充对齐，调用者需持有 mutex
+func (q *recordQueue) push(wd int, mask, cookie uint32, name string) {
+	size := 0
+	if name != "" {
+		size = (len(name)/unix.SizeofInotifyEvent + 1)*unix.SizeofInotifyEvent
+	}
+	start := len(q.queue)
+	q.queue = append(q.queue, make([]byte, unix.SizeofInotifyEvent+size)...)
+	*(*unix.InotifyEvent)(unsafe.Pointer(&q.queue[start])) = unix.InotifyEvent{Wd: int32(wd), Mask: mask, Cookie: cookie, Len: uint32(size)}
+	copy(q.queue[start+unix.SizeofInotifyEvent:], name)
+	var one [8]byte
+	*(*uint64)(unsafe.Pointer(&one[0])) = 1
+	unix.Write(q.efd, one[:])
+}
+
+// read 同 ReadEvents，调用者需持有 mutex
+func (q *recordQueue) read(buf []byte) (int, error) {
+	if q.closed {
+		return 0, unix.EBADF
+	}
+	if len(q.queue) == 0 {
+		// 清空计数，下一个事件再次通知
+		var counter [8]byte
+		unix.Read(q.efd, counter[:])
+		return 0, unix.EAGAIN
+	}
+	n := 0
+	for n < len(q.queue) {
+		event := (*unix.InotifyEvent)(unsafe.Pointer(&q.queue[n]))
+		size := unix.SizeofInotifyEvent + int(event.Len)
+		if n+size > len(buf) {
+			break
+		}
+		n += size
+	}
+	if n == 0 {
+		return 0, unix.EINVAL
+	}
+	copy(buf, q.queue[:n])
+	q.queue = q.queue[n:]
+	return n, nil
+}
+
+// kernel 内核的 inotify fd
+type kernel int
+
+// newKernel flags 为 inotify_init1 的标志，边缘触发需要读到 EAGAIN，只有外部循环可以不含 IN_NONBLOCK
+func newKernel(flags int) (kernel, error) {
+	fd, err := unix.InotifyInit1(flags)
+	if err != nil {
+		// EMFILE 为达到 max_user_instances
+		return -1, os.NewSyscallError("inotify_init1", err)
+	}
+	return kernel(fd), nil
+}
+
+func (k kernel) Add(path string, mask uint32) (int, bool, error) {
+	info, err := os.Stat(path)
+	if info == nil {
+		// 与 inotify_add_watch 相同返回 ENOENT、ENOTDIR 等
+		if errno, ok := errors.Unwrap(err).(unix.Errno); ok {
+			return -1, false, os.NewSyscallError("inotify_add_watch", errno)
+		}
+		return -1, false, ErrNotExist
+	}
+	wd, err := unix.InotifyAddWatch(int(k), path, mask)
+	return wd, info.IsDir(), os.NewSyscallError("inotify_add_watch", err)
+}
+
+// watchLimited err 是否为 inotify_add_watch 达到 max_user_watches 的 ENOSPC
+func watchLimited(err error) bool {
+	return errors.Is(err, unix.ENOSPC)
+}
+
+func (k kernel) Remove(wd int) error {
+	_, err := unix.InotifyRmWatch(int(k), uint32(wd))
+	return os.NewSyscallError("inotify_rm_watch", err)
+}
+
+func (k kernel) ReadEvents(buf []byte) (int, error) {
+	n, err := unix.Read(int(k), buf)
+	switch err {
+	case nil, unix.EAGAIN, unix.EINTR, unix.EINVAL:
+		// 读取循环按这些 errno 处理，不分配
+		return n, err
+	}
+	return n, os.NewSyscallError("read", err)
+}
+
+func (k kernel) Fd() int {
+	return int(k)
+}
+
+func (k kernel) Close() error {
+	return os.NewSyscallError("close", unix.Close(int(k)))
+}
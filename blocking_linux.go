@@ -0,0 +1,57 @@
+// @@
+// @ Author       : Eacher
+// @ Date         : 2026-10-21 16:31:05
+// @ LastEditTime : 2026-10-22 13:38:08
+// @ LastEditors  : Eacher
+// @ --------------------------------------------------------------------------------<
+// @ Description  : 在调用者的 goroutine 中阻塞读取 inotify fd，不使用 epoll 与后台 goroutine
+// @ --------------------------------------------------------------------------------<
+// @ FilePath     : /inotify/blocking_linux.go
+// @@
+package inotify
+
+import (
+	"os"
+	"golang.org/x/sys/unix"
+)
+
+// WithBlockingRead 同 WithExternalLoop，inotify fd 不含 IN_NONBLOCK，ReadEvents 在调用者的 goroutine 中阻塞读取。
+// 缓存中没有事件时每次调用只 read(2) 一次，读到的事件多于 buf 时留到下一次调用返回，不再读取。
+// 阻塞读取时不持有 mutex，AddWatch 等方法可以在其他 goroutine 调用，Close 使阻塞的 ReadEvents 返回 ErrClosed。
+// 只有 inotify 后端阻塞，WithInstancePool、fanotify、轮询后端没有事件时返回 0
+func WithBlockingRead() Option {
+	return func(w *Watcher) {
+		w.external, w.blocking = true, true
+	}
+}
+
+// readBlocking 释放 mutex 后阻塞读取到 readBuf，返回时重新持有 mutex，调用者需持有 reader 与 mutex
+func (w *Watcher) readBlocking() (int, error) {
+	w.reading = true
+	w.mutex.Unlock()
+	n, err := w.backend.ReadEvents(w.readBuf)
+	w.mutex.Lock()
+	w.reading = false
+	return n, err
+}
+
+// fill 把 readLeft 移入缓存，readBuf 与 eventBuffer 一样大，只在缓存为空时调用，调用者需持有 reader 与 mutex
+func (w *Watcher) fill() []newDir {
+	w.compact()
+	start := w.bufferItem
+	w.bufferItem += uint32(copy(w.eventBuffer[start:], w.readLeft))
+	w.readLeft = nil
+	return w.received(start)
+}
+
+// interrupt 使阻塞在 read(2) 的 ReadEvents 返回: 添加后立即移除一个监听，内核排入 IN_IGNORED，调用者需持有 mutex。
+// IN_MASK_ADD 不改变已有监听的 mask，Close 之后不再需要该监听
+func (w *Watcher) interrupt() {
+	k, ok := w.backend.(kernel)
+	if !ok {
+		return
+	}
+	if wd, err := unix.InotifyAddWatch(int(k), os.DevNull, unix.IN_MASK_ADD|unix.IN_DELETE_SELF); err == nil {
+		unix.InotifyRmWatch(int(k), uint32(wd))
+	}
+}
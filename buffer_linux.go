@@ -0,0 +1,41 @@
+// @@
+// @ Author       : Eacher
+// @ Date         : 2026-10-22 12:36:05
+// @ LastEditTime : 2026-10-22 13:38:08
+// @ LastEditors  : Eacher
+// @ --------------------------------------------------------------------------------<
+// @ Description  : 读取 inotify 事件的缓存大小
+// @ --------------------------------------------------------------------------------<
+// @ FilePath     : /inotify/buffer_linux.go
+// @@
+package inotify
+
+import (
+	"golang.org/x/sys/unix"
+)
+
+// 默认的缓存大小，高水位为 MAX_ITEM
+const defaultBufferSize = unix.SizeofInotifyEvent*25
+
+// 至少放得下一个文件名最长的事件，man 7 inotify 建议的最小值
+const minBufferSize = unix.SizeofInotifyEvent+unix.NAME_MAX+1
+
+// WithReadBuffer 读取事件的缓存为 bytes 字节，默认 400 字节；文件名很长或事件很多时可以设为 4096+NAME_MAX+1 或更大，
+// 一次 read(2) 读到更多事件。小于 SizeofInotifyEvent+NAME_MAX+1 时使用该值，WithBlockingRead 的缓存同样大小
+func WithReadBuffer(bytes int) Option {
+	return func(w *Watcher) {
+		if bytes < minBufferSize {
+			bytes = minBufferSize
+		}
+		w.bufferSize = bytes
+	}
+}
+
+// highWater 缓存中超过该字节数时不再读取，先整理或按 Backpressure 处理。为缓存大小的 4/5，默认时即 MAX_ITEM，
+// 至少为 minBufferSize，缓存很小时一个文件名最长的事件不会被当作已满丢弃
+func (w *Watcher) highWater() uint32 {
+	if n := len(w.eventBuffer)*4/5; n > minBufferSize {
+		return uint32(n)
+	}
+	return minBufferSize
+}
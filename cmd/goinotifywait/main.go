@@ -0,0 +1,98 @@
+//go:build linux
+// +build linux
+
+// @@
+// @ Author       : Eacher
+// @ Date         : 2026-10-17 09:16:51
+// @ LastEditTime : 2026-10-24 16:04:04
+// @ LastEditors  : Eacher
+// @ --------------------------------------------------------------------------------<
+// @ Description  : 类似 inotifywait 的命令行工具，等待并输出事件
+// @ --------------------------------------------------------------------------------<
+// @ FilePath     : /inotify/cmd/goinotifywait/main.go
+// @@
+package main
+
+import (
+	"os"
+	"fmt"
+	"flag"
+	"time"
+	"syscall"
+	"os/signal"
+	"path/filepath"
+	"github.com/20yyq/inotify"
+	"github.com/20yyq/inotify/internal/eventname"
+	"github.com/20yyq/inotify/internal/inotifywait"
+)
+
+func main() {
+	os.Exit(run())
+}
+
+func run() int {
+	var (
+		mask 		eventname.Flag
+		monitor 	bool
+		recursive 	bool
+		quiet 		bool
+		timeout 	int
+		format 		string
+		timefmt 	string
+	)
+	fs := flag.NewFlagSet("goinotifywait", flag.ContinueOnError)
+	fs.Var(&mask, "e", "listen for the given events, repeatable or comma separated (default all)")
+	fs.Var(&mask, "event", "same as -e")
+	fs.BoolVar(&monitor, "m", false, "keep listening instead of exiting after the first event")
+	fs.BoolVar(&monitor, "monitor", false, "same as -m")
+	fs.BoolVar(&recursive, "r", false, "watch directories recursively")
+	fs.BoolVar(&recursive, "recursive", false, "same as -r")
+	fs.BoolVar(&quiet, "q", false, "do not print the watch setup messages")
+	fs.BoolVar(&quiet, "quiet", false, "same as -q")
+	fs.IntVar(&timeout, "t", 0, "exit with code 2 when no event arrives within the given seconds")
+	fs.IntVar(&timeout, "timeout", 0, "same as -t")
+	fs.StringVar(&format, "format", "%w %e %f", "output format: %w watched path, %f file name, %e events, %T time, %% percent")
+	fs.StringVar(&timefmt, "timefmt", time.RFC3339, "time layout used by %T")
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: goinotifywait [flags] path...")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(os.Args[1:]); err != nil {
+		return inotifywait.ExitError
+	}
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return inotifywait.ExitError
+	}
+
+	w, err := inotify.NewWatcher()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "goinotifywait:", err)
+		return inotifywait.ExitError
+	}
+	defer w.Close()
+	watched := make(map[string]bool)
+	for _, path := range fs.Args() {
+		if path, err = filepath.Abs(path); err == nil {
+			if recursive {
+				err = w.AddRecursiveWatch(path, mask.Mask())
+			} else {
+				err = w.AddWatch(path, mask.Mask())
+			}
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "goinotifywait: %s: %s\n", path, err)
+			return inotifywait.ExitError
+		}
+		watched[path] = true
+	}
+	if !quiet {
+		fmt.Fprintln(os.Stderr, "Watches established.")
+	}
+
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, os.Interrupt, syscall.SIGTERM)
+	wt := &inotifywait.Waiter{Mask: mask.Mask(), Monitor: monitor, Timeout: time.Duration(timeout)*time.Second}
+	wt.Print = func(e inotify.Event) { fmt.Println(inotifywait.Render(format, timefmt, watched, e)) }
+	return wt.Wait(w, sig)
+}
@@ -0,0 +1,128 @@
+//go:build linux
+// +build linux
+
+// @@
+// @ Author       : Eacher
+// @ Date         : 2026-10-17 09:57:30
+// @ LastEditTime : 2026-10-24 16:54:07
+// @ LastEditors  : Eacher
+// @ --------------------------------------------------------------------------------<
+// @ Description  : 类似 inotifywatch 的命令行工具，统计一段时间内各路径的事件数量
+// @ --------------------------------------------------------------------------------<
+// @ FilePath     : /inotify/cmd/goinotifywatch/main.go
+// @@
+package main
+
+import (
+	"os"
+	"fmt"
+	"flag"
+	"time"
+	"errors"
+	"syscall"
+	"os/signal"
+	"path/filepath"
+	"github.com/20yyq/inotify"
+	"github.com/20yyq/inotify/internal/eventname"
+	"github.com/20yyq/inotify/internal/inotifywatch"
+)
+
+func main() {
+	if err := run(); err != nil {
+		fmt.Fprintln(os.Stderr, "goinotifywatch:", err)
+		os.Exit(1)
+	}
+}
+
+func run() error {
+	var (
+		mask 		eventname.Flag
+		recursive 	bool
+		files 		bool
+		zero 		bool
+		timeout 	int
+		by 			string
+		ascending 	bool
+	)
+	fs := flag.NewFlagSet("goinotifywatch", flag.ExitOnError)
+	fs.Var(&mask, "e", "count only the given events, repeatable or comma separated (default all)")
+	fs.Var(&mask, "event", "same as -e")
+	fs.BoolVar(&recursive, "r", false, "watch directories recursively")
+	fs.BoolVar(&recursive, "recursive", false, "same as -r")
+	fs.BoolVar(&files, "files", false, "count per file instead of per directory")
+	fs.BoolVar(&zero, "z", false, "print columns for events that never happened")
+	fs.IntVar(&timeout, "t", 0, "count for the given seconds, 0 until interrupted")
+	fs.IntVar(&timeout, "timeout", 0, "same as -t")
+	fs.StringVar(&by, "sort", "total", "sort rows by total or by an event name")
+	fs.BoolVar(&ascending, "a", false, "sort ascending instead of descending")
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: goinotifywatch [flags] path...")
+		fs.PrintDefaults()
+	}
+	fs.Parse(os.Args[1:])
+	if fs.NArg() == 0 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	column, err := inotifywatch.Column(by)
+	if err != nil {
+		return err
+	}
+
+	w, err := inotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	defer w.Close()
+	watched := make(map[string]bool)
+	for _, path := range fs.Args() {
+		if path, err = filepath.Abs(path); err == nil {
+			if recursive {
+				err = w.AddRecursiveWatch(path, mask.Mask())
+			} else {
+				err = w.AddWatch(path, mask.Mask())
+			}
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		watched[path] = true
+	}
+	fmt.Fprintln(os.Stderr, "Establishing watches... finished.")
+
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, os.Interrupt, syscall.SIGTERM)
+	var deadline <-chan time.Time
+	if timeout > 0 {
+		timer := time.NewTimer(time.Duration(timeout)*time.Second)
+		defer timer.Stop()
+		deadline = timer.C
+	}
+	stats := inotifywatch.New(watched, files)
+loop:
+	for {
+		select {
+		case <-sig:
+			break loop
+		case <-deadline:
+			break loop
+		default:
+		}
+		e, ok, err := w.WaitEventTimeout(time.Millisecond*200)
+		if err != nil {
+			if errors.Is(err, inotify.ErrClosed) {
+				return err
+			}
+			fmt.Fprintln(os.Stderr, "goinotifywatch:", err)
+			continue
+		}
+		if !ok || e.Raw&mask.Mask() == 0 {
+			continue
+		}
+		stats.Add(e)
+	}
+	if !stats.Print(os.Stdout, column, ascending, zero) {
+		fmt.Fprintln(os.Stderr, "No events occurred.")
+	}
+	return nil
+}
@@ -0,0 +1,84 @@
+//go:build linux
+// +build linux
+
+// @@
+// @ Author       : Eacher
+// @ Date         : 2026-10-24 14:16:21
+// @ LastEditTime : 2026-10-24 15:35:56
+// @ LastEditors  : Eacher
+// @ --------------------------------------------------------------------------------<
+// @ Description  : archive 子命令，归档的路径与复制见 internal/archive
+// @ --------------------------------------------------------------------------------<
+// @ FilePath     : /inotify/cmd/inotify/archive.go
+// @@
+package main
+
+import (
+	"os"
+	"fmt"
+	"flag"
+	"time"
+	"errors"
+	"syscall"
+	"os/signal"
+	"github.com/20yyq/inotify"
+	"github.com/20yyq/inotify/internal/archive"
+)
+
+func runArchive(args []string) error {
+	fs := flag.NewFlagSet("archive", flag.ExitOnError)
+	dest := fs.String("dest", "", "archive directory")
+	fs.Parse(args)
+	if *dest == "" || fs.NArg() == 0 {
+		fs.Usage()
+		return errors.New("--dest and at least one path are required")
+	}
+	a, err := archive.New(*dest)
+	if err != nil {
+		return err
+	}
+
+	w, err := inotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	defer w.Close()
+	for _, path := range fs.Args() {
+		if path, err = a.AddRoot(path); err != nil {
+			return err
+		}
+		if err = w.AddWatch(path, inotify.IN_CLOSE_WRITE); err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+	}
+
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, os.Interrupt, syscall.SIGTERM)
+	for {
+		select {
+		case <-sig:
+			return nil
+		default:
+		}
+		e, ok, err := w.WaitEventTimeout(time.Millisecond*500)
+		if err == inotify.ErrClosed {
+			return err
+		}
+		// ErrDropped 等错误不影响后面的事件
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "archive:", err)
+			continue
+		}
+		if !ok || e.Raw&inotify.IN_CLOSE_WRITE == 0 {
+			continue
+		}
+		to, err := a.Archive(e.FileName, time.Now())
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "archive", e.FileName, err)
+			continue
+		}
+		if to != "" {
+			fmt.Println(e.FileName, "->", to)
+		}
+	}
+}
@@ -0,0 +1,42 @@
+//go:build linux
+// +build linux
+
+// @@
+// @ Author       : Eacher
+// @ Date         : 2026-10-24 12:17:54
+// @ LastEditTime : 2026-10-24 14:16:21
+// @ LastEditors  : Eacher
+// @ --------------------------------------------------------------------------------<
+// @ Description  : daemon 子命令，配置与动作见 internal/daemon
+// @ --------------------------------------------------------------------------------<
+// @ FilePath     : /inotify/cmd/inotify/daemon.go
+// @@
+package main
+
+import (
+	"os"
+	"flag"
+	"errors"
+	"syscall"
+	"os/signal"
+	"github.com/20yyq/inotify/internal/daemon"
+	"github.com/20yyq/inotify/internal/systemd"
+)
+
+func runDaemon(args []string) error {
+	fs := flag.NewFlagSet("daemon", flag.ExitOnError)
+	file := fs.String("config", "", "JSON config file")
+	dryRun := fs.Bool("dry-run", false, "print the expanded exec commands instead of running them")
+	fs.Parse(args)
+	if *file == "" {
+		fs.Usage()
+		return errors.New("--config is required")
+	}
+	out := systemd.NewStream(systemd.Listeners())
+	done := make(chan struct{})
+	defer close(done)
+	go systemd.Watchdog(done)
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, os.Interrupt, syscall.SIGTERM, syscall.SIGHUP)
+	return daemon.Run(*file, *dryRun, sig, out.Write, func(state string) { systemd.Notify(state) })
+}
@@ -0,0 +1,56 @@
+//go:build linux
+// +build linux
+
+// @@
+// @ Author       : Eacher
+// @ Date         : 2026-10-14 13:10:12
+// @ LastEditTime : 2026-10-24 15:35:56
+// @ LastEditors  : Eacher
+// @ --------------------------------------------------------------------------------<
+// @ Description  : inotify 命令行工具
+// @ --------------------------------------------------------------------------------<
+// @ FilePath     : /inotify/cmd/inotify/main.go
+// @@
+package main
+
+import (
+	"os"
+	"fmt"
+)
+
+type command struct {
+	name 	string
+	usage 	string
+	run 	func(args []string) error
+}
+
+var commands = []command{
+	{name: "archive", usage: "archive --dest dir path...    copy files into dest/<time>/ on CLOSE_WRITE", run: runArchive},
+	{name: "run", usage: "run [flags] path... -- cmd    rerun cmd on changes, restarting it if still running", run: run},
+	{name: "daemon", usage: "daemon --config file.json     run the watches and actions in the config, SIGHUP reloads", run: runDaemon},
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: inotify <command> [flags] path...")
+	for _, c := range commands {
+		fmt.Fprintln(os.Stderr, "  inotify", c.usage)
+	}
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+	for _, c := range commands {
+		if c.name == os.Args[1] {
+			if err := c.run(os.Args[2:]); err != nil {
+				fmt.Fprintln(os.Stderr, "inotify", c.name+":", err)
+				os.Exit(1)
+			}
+			return
+		}
+	}
+	usage()
+	os.Exit(2)
+}
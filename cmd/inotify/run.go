@@ -0,0 +1,113 @@
+//go:build linux
+// +build linux
+
+// @@
+// @ Author       : Eacher
+// @ Date         : 2026-10-17 18:58:51
+// @ LastEditTime : 2026-10-21 12:46:23
+// @ LastEditors  : Eacher
+// @ --------------------------------------------------------------------------------<
+// @ Description  : 与 entr 相同，文件变化后运行命令
+// @ --------------------------------------------------------------------------------<
+// @ FilePath     : /inotify/cmd/inotify/run.go
+// @@
+package main
+
+import (
+	"os"
+	"fmt"
+	"flag"
+	"errors"
+	"syscall"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"github.com/20yyq/inotify"
+	"github.com/20yyq/inotify/runner"
+	"github.com/20yyq/inotify/internal/eventname"
+)
+
+// 未指定 -e 时的事件，与编辑器保存、新建、删除、rename 对应
+const runMask = inotify.IN_CLOSE_WRITE|inotify.IN_CREATE|inotify.IN_DELETE|inotify.IN_MOVE
+
+func run(args []string) error {
+	fs := flag.NewFlagSet("run", flag.ExitOnError)
+	var mask eventname.Flag
+	fs.Var(&mask, "e", "events to watch, comma separated (default close_write,create,delete,move)")
+	recursive := fs.Bool("r", false, "watch directories recursively")
+	pattern := fs.String("p", "", "only file names matching the pattern trigger the command")
+	ignoreCase := fs.Bool("i", false, "match the -p pattern case-insensitively")
+	debounce := fs.Duration("debounce", runner.DefaultDebounce, "wait for events to stop before running")
+	queue := fs.Bool("queue", false, "wait for the running command to exit instead of restarting it")
+	now := fs.Bool("s", false, "run the command once at start")
+	dryRun := fs.Bool("dry-run", false, "print the expanded command instead of running it")
+	fs.Parse(args)
+	paths, command := fs.Args(), []string(nil)
+	for i, arg := range paths {
+		if arg == "--" {
+			paths, command = paths[:i], paths[i+1:]
+			break
+		}
+	}
+	if len(paths) == 0 || len(command) == 0 {
+		fs.Usage()
+		return errors.New("usage: inotify run [flags] path... -- command [args]")
+	}
+	if _, err := filepath.Match(*pattern, ""); err != nil {
+		return err
+	}
+	if _, err := (&runner.Rule{Command: command}).Expand(inotify.Event{}); err != nil {
+		return err
+	}
+
+	w, err := inotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	defer w.Close()
+	flags := runMask
+	if mask != 0 {
+		flags = mask.Mask()
+	}
+	for _, path := range paths {
+		if *recursive {
+			err = w.AddRecursiveWatch(path, flags)
+		} else {
+			err = w.AddWatch(path, flags)
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+	}
+
+	r := runner.NewRunner(command...)
+	r.Debounce, r.DryRun = *debounce, *dryRun
+	r.Rule().Mask, r.Rule().Pattern, r.Rule().IgnoreCase = flags, *pattern, *ignoreCase
+	if *queue {
+		r.Rule().Policy = runner.Queue
+	}
+	r.OnExit = func(_ *runner.Rule, _ inotify.Event, err error) {
+		// 被重新运行结束的命令不输出
+		var exit *exec.ExitError
+		if err != nil && !(errors.As(err, &exit) && !exit.Exited()) {
+			fmt.Fprintln(os.Stderr, "inotify run:", err)
+		}
+	}
+	defer r.Stop()
+	if *now {
+		r.Run()
+	}
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, os.Interrupt, syscall.SIGTERM)
+	for {
+		select {
+		case <-sig:
+			return nil
+		case e, ok := <-w.Events():
+			if !ok {
+				return inotify.ErrClosed
+			}
+			r.Dispatch(e)
+		}
+	}
+}
@@ -0,0 +1,82 @@
+// @@
+// @ Author       : Eacher
+// @ Date         : 2026-10-15 11:17:42
+// @ LastEditTime : 2026-10-19 15:39:37
+// @ LastEditors  : Eacher
+// @ --------------------------------------------------------------------------------<
+// @ Description  : 在读取者中合并重复事件
+// @ --------------------------------------------------------------------------------<
+// @ FilePath     : /inotify/coalesce.go
+// @@
+package inotify
+
+import (
+	"time"
+)
+
+// 超过该数量时清理已安静的记录
+const dedupPrune = 1024
+
+// CoalesceKey WithModifyDedup、WithRateLimit 合并事件时的分组，key 相同的事件才会合并
+type CoalesceKey func(Event) string
+
+var (
+	// ByPath 每个文件一组，默认
+	ByPath CoalesceKey = func(e Event) string { return e.FileName }
+	// ByPathOp 每个文件的每种 Op 一组，如同一文件的 Write 与 Chmod 分别合并
+	ByPathOp CoalesceKey = func(e Event) string { return e.FileName + "\x00" + e.Op.String() }
+)
+
+// WithCoalesceKey 设置 WithModifyDedup、WithRateLimit 合并事件的分组，与 Option 的顺序无关。
+// key 在读取者中持有锁调用，不能再调用该 Watcher 的方法
+func WithCoalesceKey(key CoalesceKey) Option {
+	return func(w *Watcher) {
+		w.coalesceKey = key
+	}
+}
+
+// key e 合并时的分组
+func (w *Watcher) key(e Event) string {
+	if w.coalesceKey != nil {
+		return w.coalesceKey(e)
+	}
+	return e.FileName
+}
+
+type modifyDedup struct {
+	w 		*Watcher
+	quiet 	time.Duration
+	// 每组最近一次 MODIFY 的时间
+	last 	map[string]time.Time
+}
+
+// WithModifyDedup 同一文件连续的 MODIFY 只保留第一个，直到该文件出现其他事件(如 CLOSE_WRITE)
+// 或距上一次 MODIFY 超过 quiet。大文件复制时可以把上千个 MODIFY 合并为一个，分组由 WithCoalesceKey 设置
+func WithModifyDedup(quiet time.Duration) Option {
+	return func(w *Watcher) {
+		d := &modifyDedup{w: w, quiet: quiet, last: make(map[string]time.Time)}
+		WithEventFilter(d.filter)(w)
+	}
+}
+
+func (d *modifyDedup) filter(e Event) bool {
+	key := d.w.key(e)
+	if e.Raw != IN_MODIFY {
+		delete(d.last, key)
+		return true
+	}
+	now := time.Now()
+	last, ok := d.last[key]
+	d.last[key] = now
+	if ok && now.Sub(last) < d.quiet {
+		return false
+	}
+	if len(d.last) > dedupPrune {
+		for name, t := range d.last {
+			if now.Sub(t) >= d.quiet {
+				delete(d.last, name)
+			}
+		}
+	}
+	return true
+}
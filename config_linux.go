@@ -0,0 +1,140 @@
+//go:build linux
+// +build linux
+
+// @@
+// @ Author       : Eacher
+// @ Date         : 2026-10-17 17:18:56
+// @ LastEditTime : 2026-10-17 17:53:55
+// @ LastEditors  : Eacher
+// @ --------------------------------------------------------------------------------<
+// @ Description  : 配置文件热加载，处理原子替换(rename)保存与符号链接切换
+// @ --------------------------------------------------------------------------------<
+// @ FilePath     : /inotify/config_linux.go
+// @@
+package inotify
+
+import (
+	"os"
+	"sync"
+	"time"
+	"bytes"
+	"crypto/sha256"
+	"path/filepath"
+)
+
+// 配置文件所在目录的最后一个事件之后等待的时间，编辑器保存时通常产生多个事件
+const configDebounce = time.Millisecond*100
+
+// 监听配置文件所在的目录而不是文件本身，rename 替换后原文件的监听会失效
+const configMask = IN_CREATE|IN_DELETE|IN_CLOSE_WRITE|IN_MOVED_FROM|IN_MOVED_TO|IN_ATTRIB|IN_MODIFY
+
+// ConfigWatcher WatchConfig 返回的监听
+type ConfigWatcher struct {
+	path 	string
+	decode 	func([]byte) error
+	w 		*Watcher
+
+	mutex 	sync.Mutex
+	sum 	[sha256.Size]byte
+	// 当前监听的目录: path 所在目录与符号链接指向的文件所在目录
+	dirs 	map[string]bool
+	err 	error
+	done 	chan struct{}
+}
+
+// WatchConfig 读取 path 并调用 decode，之后每当 path 的内容变化时再次读取并调用 decode，内容没有变化(如 touch)时不调用。
+// 编辑器的原子保存(写入临时文件后 rename)、符号链接切换(如 Kubernetes ConfigMap)也能正确处理。
+// 第一次读取或 decode 失败时返回错误，之后的错误由 Err 返回
+func WatchConfig(path string, decode func([]byte) error) (*ConfigWatcher, error) {
+	var err error
+	if path, err = filepath.Abs(path); err != nil {
+		return nil, err
+	}
+	c := &ConfigWatcher{path: path, decode: decode, dirs: make(map[string]bool), done: make(chan struct{})}
+	if c.w, err = NewWatcher(); err != nil {
+		return nil, err
+	}
+	if err = c.watchDirs(); err == nil {
+		_, err = c.reload()
+	}
+	if err != nil {
+		c.w.Close()
+		return nil, err
+	}
+	go c.loop()
+	return c, nil
+}
+
+// watchDirs 监听 path 所在目录以及符号链接解析后的文件所在目录
+func (c *ConfigWatcher) watchDirs() error {
+	dirs := []string{filepath.Dir(c.path)}
+	if real, err := filepath.EvalSymlinks(c.path); err == nil {
+		dirs = append(dirs, filepath.Dir(real))
+	}
+	for _, dir := range dirs {
+		if c.dirs[dir] {
+			continue
+		}
+		if err := c.w.AddWatch(dir, configMask|IN_ONLYDIR); err != nil {
+			return err
+		}
+		c.dirs[dir] = true
+	}
+	return nil
+}
+
+// reload 内容变化时调用 decode，changed 表示内容是否变化
+func (c *ConfigWatcher) reload() (changed bool, err error) {
+	data, err := os.ReadFile(c.path)
+	if err != nil {
+		return false, err
+	}
+	sum := sha256.Sum256(data)
+	c.mutex.Lock()
+	changed = !bytes.Equal(sum[:], c.sum[:])
+	c.sum = sum
+	c.mutex.Unlock()
+	if changed {
+		err = c.decode(data)
+	}
+	return changed, err
+}
+
+func (c *ConfigWatcher) loop() {
+	defer close(c.done)
+	var timer <-chan time.Time
+	for {
+		select {
+		case _, ok := <-c.w.Events():
+			if !ok {
+				return
+			}
+			// 目录中的任何变化都重新检查，内容没有变化时不会调用 decode
+			timer = time.After(configDebounce)
+		case <-timer:
+			timer = nil
+			err := c.watchDirs()
+			if _, rerr := c.reload(); rerr != nil || err == nil {
+				err = rerr
+			}
+			// 文件暂时不存在(删除后重新创建之间)时保留错误，不清除原来的内容
+			c.mutex.Lock()
+			c.err = err
+			c.mutex.Unlock()
+		}
+	}
+}
+
+// Err 最后一次读取或 decode 的错误，成功或内容没有变化时为 nil
+func (c *ConfigWatcher) Err() error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.err
+}
+
+// Close 停止监听，返回时不会再调用 decode
+func (c *ConfigWatcher) Close() error {
+	err := c.w.Close()
+	<-c.done
+	return err
+}
@@ -0,0 +1,78 @@
+// @@
+// @ Author       : Eacher
+// @ Date         : 2026-10-21 14:41:14
+// @ LastEditTime : 2026-10-21 15:16:38
+// @ LastEditors  : Eacher
+// @ --------------------------------------------------------------------------------<
+// @ Description  : 解析从任意 inotify fd 读到的数据，不需要 Watcher
+// @ --------------------------------------------------------------------------------<
+// @ FilePath     : /inotify/decoder_linux.go
+// @@
+package inotify
+
+import (
+	"io"
+	"bytes"
+	"unsafe"
+	"golang.org/x/sys/unix"
+)
+
+// Decoder 依次解析 buf 中的 inotify_event，只读取 buf，不检查 Wd 与 Mask 的取值。
+// 不完整的事件(短读或数据错误，如 Len 超出 buf)不会越界访问，Next 返回 io.ErrUnexpectedEOF，Buffered 为剩下的数据
+type Decoder struct {
+	buf 	[]byte
+	offset 	int
+}
+
+// NewDecoder 解析 buf，如 unix.Read(fd, buf) 读到的 buf[:n]
+func NewDecoder(buf []byte) *Decoder {
+	return &Decoder{buf: buf}
+}
+
+// Reset 重用 d 解析新的 buf
+func (d *Decoder) Reset(buf []byte) {
+	d.buf, d.offset = buf, 0
+}
+
+// Next 下一个事件，RawEvent.Name 引用 buf 中的数据，buf 被重用前有效。
+// 所有事件都已取出时返回 io.EOF，剩下的数据不是完整的事件时返回 io.ErrUnexpectedEOF，之后一直返回该错误
+func (d *Decoder) Next() (RawEvent, error) {
+	rest := d.buf[d.offset:]
+	if len(rest) == 0 {
+		return RawEvent{}, io.EOF
+	}
+	if len(rest) < unix.SizeofInotifyEvent {
+		return RawEvent{}, io.ErrUnexpectedEOF
+	}
+	event := (*unix.InotifyEvent)(unsafe.Pointer(&rest[0]))
+	// 与 len 比较前不做加法，Len 接近 uint32 上限时也不会溢出
+	if uint64(event.Len) > uint64(len(rest)-unix.SizeofInotifyEvent) {
+		return RawEvent{}, io.ErrUnexpectedEOF
+	}
+	end := unix.SizeofInotifyEvent+int(event.Len)
+	d.offset += end
+	return RawEvent{Wd: event.Wd, Mask: event.Mask, Cookie: event.Cookie, Len: event.Len, Name: rest[unix.SizeofInotifyEvent:end:end]}, nil
+}
+
+// Buffered 还未取出的数据，Next 返回 io.ErrUnexpectedEOF 后为不完整的事件，可与下一次读到的数据拼接后重新解析
+func (d *Decoder) Buffered() []byte {
+	return d.buf[d.offset:]
+}
+
+// Base 名字中第一个 NUL 之前的部分，监听自身的事件为空
+func (r RawEvent) Base() string {
+	if i := bytes.IndexByte(r.Name, 0); i >= 0 {
+		return string(r.Name[:i])
+	}
+	return string(r.Name)
+}
+
+// whole buf 开头的完整事件的长度
+func whole(buf []byte) int {
+	d := Decoder{buf: buf}
+	for {
+		if _, err := d.Next(); err != nil {
+			return d.offset
+		}
+	}
+}
@@ -0,0 +1,476 @@
+// @@
+// @ Author       : Eacher
+// @ Date         : 2026-10-23 15:07:11
+// @ LastEditTime : 2026-10-23 15:58:17
+// @ LastEditors  : Eacher
+// @ --------------------------------------------------------------------------------<
+// @ Description  : 实验性的 eBPF 后端，由 syscalls 的 tracepoint 得到所有进程的文件操作，不受 inotify 监听数量与挂载命名空间的限制
+// @ --------------------------------------------------------------------------------<
+// @ FilePath     : /inotify/ebpf/ebpf_linux.go
+// @@
+package ebpf
+
+import (
+	"os"
+	"sync"
+	"errors"
+	"strconv"
+	"strings"
+	"unsafe"
+	"path/filepath"
+
+	"golang.org/x/sys/unix"
+)
+
+// fds 超过该数量时清空，之后的 write 由 /proc/<pid>/fd 得到路径
+const maxFds = 4096
+
+// devino 文件的设备与 inode，与路径所在的挂载命名空间无关
+type devino struct {
+	dev 	uint64
+	ino 	uint64
+}
+
+// entry 目录 dir 中名为 name 的文件
+type entry struct {
+	dir 	devino
+	name 	string
+}
+
+// target 系统调用操作的文件，id 为文件的 devino，文件已不存在时为零
+type target struct {
+	entry
+	id 		devino
+	isDir 	bool
+}
+
+type watch struct {
+	path 	string
+	mask 	uint32
+	isDir 	bool
+	id 		devino
+	at 		entry
+}
+
+// Backend eBPF 实现的 inotify.WatchBackend，需要 CAP_SYS_ADMIN(或 CAP_BPF 与 CAP_PERFMON)与挂载的 tracefs，用法:
+//
+//	b, err := ebpf.NewBackend()
+//	w, err := inotify.NewWatcher(inotify.WithBackend(b))
+//
+// 监听按 devino 匹配，容器内进程的操作经 /proc/<pid>/root 对应到监听的目录。只有 IN_CREATE、IN_MODIFY、IN_DELETE、IN_MOVE、
+// IN_DELETE_SELF、IN_MOVE_SELF 事件；以 O_CREAT 打开已存在的文件同样产生 IN_CREATE；相对路径与 write 在读取事件时由 /proc
+// 得到路径，进程已退出时丢失(open 时记录的 fd 除外)。本进程的操作不产生事件
+type Backend struct {
+	mutex 		sync.Mutex
+	ring 		*ringbuf
+	fds 		[]int
+	efd 		int
+	epfd 		int
+	queue 		[]byte
+	closed 		bool
+	next 		int
+	cookie 		uint32
+	mntns 		string
+	watches 	map[int]*watch
+	byID 		map[devino]int
+	byEntry 	map[entry]int
+	opened 		map[[2]int32]target
+}
+
+// NewBackend 加载 tracepoint 程序，没有 tracefs 或权限不足时返回错误
+func NewBackend() (*Backend, error) {
+	dir := tracefs()
+	if dir == "" {
+		return nil, errors.New("The tracefs not mounted at /sys/kernel/tracing")
+	}
+	b := &Backend{efd: -1, epfd: -1, next: 1, watches: make(map[int]*watch), byID: make(map[devino]int), byEntry: make(map[entry]int), opened: make(map[[2]int32]target)}
+	b.mntns, _ = os.Readlink("/proc/self/ns/mnt")
+	if err := b.load(dir); err != nil {
+		b.release()
+		return nil, err
+	}
+	return b, nil
+}
+
+// load 创建 map 与程序并挂载到每个存在的系统调用，之后创建 epoll
+func (b *Backend) load(dir string) error {
+	var err error
+	if b.ring, err = newRingbuf(); err != nil {
+		return err
+	}
+	zero, err := newMap(unix.BPF_MAP_TYPE_ARRAY, 4, recordSize, 1)
+	if err != nil {
+		return err
+	}
+	b.fds = append(b.fds, zero)
+	pending, err := newMap(mapTypeLRUHash, 8, recordSize, pendingEntries)
+	if err != nil {
+		return err
+	}
+	b.fds = append(b.fds, pending)
+	exit, attached := -1, 0
+next:
+	for _, p := range probes {
+		enter, off, err := tracepoint(dir, "sys_enter_"+p.event)
+		if err != nil {
+			continue
+		}
+		leave, xoff, err := tracepoint(dir, "sys_exit_"+p.event)
+		if err != nil {
+			continue
+		}
+		for _, name := range []string{p.dfd, p.name, p.flags, p.dfd2, p.name2} {
+			if _, ok := off[name]; name != "" && !ok {
+				continue next
+			}
+		}
+		if exit < 0 {
+			if exit, err = loadProg(exitProg(xoff["ret"], pending, b.ring.fd)); err != nil {
+				return err
+			}
+			b.fds = append(b.fds, exit)
+		}
+		prog, err := loadProg(enterProg(p, off, os.Getpid(), zero, pending))
+		if err != nil {
+			return err
+		}
+		b.fds = append(b.fds, prog)
+		// 先挂载 sys_exit，sys_enter 记录的调用都会被取出
+		for _, tp := range []struct{ id uint64; prog int }{{leave, exit}, {enter, prog}} {
+			link, err := attach(tp.id, tp.prog)
+			if err != nil {
+				return err
+			}
+			b.fds = append(b.fds, link)
+		}
+		attached++
+	}
+	if attached == 0 {
+		return errors.New("The syscalls tracepoints not found in " + dir)
+	}
+	if b.efd, err = unix.Eventfd(0, unix.EFD_CLOEXEC|unix.EFD_NONBLOCK); err != nil {
+		return os.NewSyscallError("eventfd", err)
+	}
+	if b.epfd, err = unix.EpollCreate1(unix.EPOLL_CLOEXEC); err != nil {
+		return os.NewSyscallError("epoll_create1", err)
+	}
+	for _, fd := range []int{b.ring.fd, b.efd} {
+		if err = unix.EpollCtl(b.epfd, unix.EPOLL_CTL_ADD, fd, &unix.EpollEvent{Fd: int32(fd), Events: unix.EPOLLIN}); err != nil {
+			return os.NewSyscallError("epoll_ctl", err)
+		}
+	}
+	return nil
+}
+
+// release 关闭 perf 事件、程序与 map，程序在最后一个 fd 关闭后卸载
+func (b *Backend) release() {
+	for i := len(b.fds)-1; i >= 0; i-- {
+		unix.Close(b.fds[i])
+	}
+	if b.ring != nil {
+		b.ring.close()
+	}
+	if b.efd >= 0 {
+		unix.Close(b.efd)
+	}
+	if b.epfd >= 0 {
+		unix.Close(b.epfd)
+	}
+}
+
+// stat path 的 devino，follow 为 false 时不跟随符号链接
+func stat(path string, follow bool) (devino, bool, error) {
+	var st unix.Stat_t
+	var err error
+	if follow {
+		err = os.NewSyscallError("stat", unix.Stat(path, &st))
+	} else {
+		err = os.NewSyscallError("lstat", unix.Lstat(path, &st))
+	}
+	return devino{dev: st.Dev, ino: st.Ino}, st.Mode&unix.S_IFMT == unix.S_IFDIR, err
+}
+
+func (b *Backend) Add(path string, mask uint32) (int, bool, error) {
+	path = filepath.Clean(path)
+	id, isDir, err := stat(path, mask&unix.IN_DONT_FOLLOW == 0)
+	if err != nil {
+		return -1, false, err
+	}
+	if mask&unix.IN_ONLYDIR != 0 && !isDir {
+		return -1, false, unix.ENOTDIR
+	}
+	var at entry
+	if path != "/" {
+		if at.dir, _, err = stat(filepath.Dir(path), true); err != nil {
+			return -1, false, err
+		}
+		at.name = filepath.Base(path)
+	}
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	if b.closed {
+		return -1, false, unix.EBADF
+	}
+	events := mask&(unix.IN_ALL_EVENTS|unix.IN_ONESHOT)
+	// 与 inotify 相同，同一个 inode 返回相同的 wd
+	wd, ok := b.byID[id]
+	if ok {
+		if mask&unix.IN_MASK_CREATE != 0 {
+			return -1, false, unix.EEXIST
+		}
+		if mask&unix.IN_MASK_ADD != 0 {
+			events |= b.watches[wd].mask
+		}
+		delete(b.byEntry, b.watches[wd].at)
+	} else {
+		wd = b.next
+		b.next++
+	}
+	b.watches[wd] = &watch{path: path, mask: events, isDir: isDir, id: id, at: at}
+	b.byID[id], b.byEntry[at] = wd, wd
+	return wd, isDir, nil
+}
+
+func (b *Backend) Remove(wd int) error {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	if _, ok := b.watches[wd]; !ok {
+		return unix.EINVAL
+	}
+	b.drop(wd)
+	return nil
+}
+
+// drop 移除监听并产生 IN_IGNORED，调用者需持有 mutex
+func (b *Backend) drop(wd int) {
+	ws := b.watches[wd]
+	delete(b.byID, ws.id)
+	if b.byEntry[ws.at] == wd {
+		delete(b.byEntry, ws.at)
+	}
+	delete(b.watches, wd)
+	b.push(wd, unix.IN_IGNORED, 0, "")
+}
+
+// push 以内核的格式加入一个事件，名字以 NUL 填充对齐，调用者需持有 mutex
+func (b *Backend) push(wd int, mask, cookie uint32, name string) {
+	size := 0
+	if name != "" {
+		size = (len(name)/unix.SizeofInotifyEvent + 1)*unix.SizeofInotifyEvent
+	}
+	start := len(b.queue)
+	b.queue = append(b.queue, make([]byte, unix.SizeofInotifyEvent+size)...)
+	*(*unix.InotifyEvent)(unsafe.Pointer(&b.queue[start])) = unix.InotifyEvent{Wd: int32(wd), Mask: mask, Cookie: cookie, Len: uint32(size)}
+	copy(b.queue[start+unix.SizeofInotifyEvent:], name)
+	var one [8]byte
+	*(*uint64)(unsafe.Pointer(&one[0])) = 1
+	unix.Write(b.efd, one[:])
+}
+
+// ReadEvents 取出 ringbuf 中所有的 record，转换为 inotify 的格式后与 IN_IGNORED 一起交给 Watcher
+func (b *Backend) ReadEvents(buf []byte) (int, error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	if b.closed {
+		return 0, unix.EBADF
+	}
+	b.ring.consume(b.translate)
+	if len(b.queue) == 0 {
+		// 清空计数，下一个事件再次通知
+		var counter [8]byte
+		unix.Read(b.efd, counter[:])
+		return 0, unix.EAGAIN
+	}
+	n := 0
+	for n < len(b.queue) {
+		event := (*unix.InotifyEvent)(unsafe.Pointer(&b.queue[n]))
+		size := unix.SizeofInotifyEvent + int(event.Len)
+		if n+size > len(buf) {
+			break
+		}
+		n += size
+	}
+	if n == 0 {
+		return 0, unix.EINVAL
+	}
+	copy(buf, b.queue[:n])
+	b.queue = b.queue[n:]
+	return n, nil
+}
+
+// cstring 以 NUL 结尾的名字
+func cstring(b []byte) string {
+	if n := strings.IndexByte(string(b), 0); n >= 0 {
+		return string(b[:n])
+	}
+	return string(b)
+}
+
+// translate 把一个 record 交给匹配的监听，调用者需持有 mutex
+func (b *Backend) translate(rec []byte) {
+	if len(b.watches) == 0 {
+		return
+	}
+	at := func(off int) int32 { return *(*int32)(unsafe.Pointer(&rec[off])) }
+	op, pid, dfd, flags, ret := uint32(at(offOp)), at(offPid), at(offDfd), uint32(at(offFlags)), at(offRet)
+	name := cstring(rec[offName:offName2])
+	switch op {
+	case opOpen, opCreat:
+		t, ok := b.resolve(pid, dfd, name)
+		if !ok {
+			return
+		}
+		if len(b.opened) >= maxFds {
+			b.opened = make(map[[2]int32]target)
+		}
+		b.opened[[2]int32{pid, ret}] = t
+		if op == opCreat || flags&unix.O_CREAT != 0 {
+			b.deliver(t.entry, unix.IN_CREATE, 0)
+		}
+	case opMkdir:
+		if t, ok := b.resolve(pid, dfd, name); ok {
+			b.deliver(t.entry, unix.IN_CREATE|unix.IN_ISDIR, 0)
+		}
+	case opWrite:
+		t, ok := b.opened[[2]int32{pid, dfd}]
+		if !ok {
+			if t, ok = b.fdTarget(pid, dfd); !ok {
+				return
+			}
+		}
+		b.deliver(t.entry, unix.IN_MODIFY, 0)
+		if wd, ok := b.byID[t.id]; ok && t.id != (devino{}) {
+			b.notify(wd, unix.IN_MODIFY, 0, "")
+		}
+	case opUnlink, opRmdir:
+		t, ok := b.resolve(pid, dfd, name)
+		if !ok {
+			return
+		}
+		mask := uint32(unix.IN_DELETE)
+		if op == opRmdir || flags&unix.AT_REMOVEDIR != 0 {
+			mask |= unix.IN_ISDIR
+		}
+		b.deliver(t.entry, mask, 0)
+		if wd, ok := b.byEntry[t.entry]; ok {
+			b.notify(wd, unix.IN_DELETE_SELF, 0, "")
+			if _, ok = b.watches[wd]; ok {
+				b.drop(wd)
+			}
+		}
+	case opRename:
+		to, ok := b.resolve(pid, at(offDfd2), cstring(rec[offName2:]))
+		if !ok {
+			return
+		}
+		from, ok := b.resolve(pid, dfd, name)
+		if !ok {
+			return
+		}
+		mask := uint32(0)
+		if to.isDir {
+			mask = unix.IN_ISDIR
+		}
+		b.cookie++
+		b.deliver(from.entry, unix.IN_MOVED_FROM|mask, b.cookie)
+		b.deliver(to.entry, unix.IN_MOVED_TO|mask, b.cookie)
+		if wd, ok := b.byEntry[from.entry]; ok {
+			b.notify(wd, unix.IN_MOVE_SELF, 0, "")
+			if ws, ok := b.watches[wd]; ok {
+				delete(b.byEntry, ws.at)
+				ws.at, b.byEntry[to.entry] = to.entry, wd
+			}
+		}
+	}
+}
+
+// deliver 交给监听 e.dir 目录的监听，调用者需持有 mutex
+func (b *Backend) deliver(e entry, mask, cookie uint32) {
+	if wd, ok := b.byID[e.dir]; ok && b.watches[wd].isDir {
+		b.notify(wd, mask, cookie, e.name)
+	}
+}
+
+// notify 按监听的 mask 过滤，IN_ONESHOT 的监听在第一个事件后移除，调用者需持有 mutex
+func (b *Backend) notify(wd int, mask, cookie uint32, name string) {
+	if ws := b.watches[wd]; ws.mask&mask&unix.IN_ALL_EVENTS != 0 {
+		b.push(wd, mask, cookie, name)
+		if ws.mask&unix.IN_ONESHOT != 0 {
+			b.drop(wd)
+		}
+	}
+}
+
+// root pid 的根目录在本进程中的路径，与本进程相同时为空；不同的挂载命名空间经 /proc/<pid>/root 访问
+func (b *Backend) root(proc string) string {
+	if ns, err := os.Readlink(proc + "/ns/mnt"); err == nil && ns != b.mntns {
+		return proc + "/root"
+	}
+	if r, err := os.Readlink(proc + "/root"); err == nil && r != "/" {
+		return r
+	}
+	return ""
+}
+
+// resolve 由系统调用的 dfd 与 name 得到所在目录的 devino，相对路径需要进程的工作目录或 dfd
+func (b *Backend) resolve(pid, dfd int32, name string) (target, bool) {
+	if name == "" {
+		return target{}, false
+	}
+	proc := "/proc/" + strconv.Itoa(int(pid))
+	if !filepath.IsAbs(name) {
+		link := proc + "/cwd"
+		if dfd != unix.AT_FDCWD {
+			link = proc + "/fd/" + strconv.Itoa(int(dfd))
+		}
+		base, err := os.Readlink(link)
+		if err != nil || !filepath.IsAbs(base) {
+			return target{}, false
+		}
+		name = filepath.Join(base, name)
+	}
+	if name = filepath.Clean(name); name == "/" {
+		return target{}, false
+	}
+	root := b.root(proc)
+	dir, _, err := stat(root+filepath.Dir(name), true)
+	if err != nil {
+		return target{}, false
+	}
+	t := target{entry: entry{dir: dir, name: filepath.Base(name)}}
+	t.id, t.isDir, err = stat(root+name, false)
+	if err != nil {
+		t.id, t.isDir = devino{}, false
+	}
+	return t, true
+}
+
+// fdTarget write 的 fd 不是 open 时记录的，由 /proc/<pid>/fd 得到路径
+func (b *Backend) fdTarget(pid, fd int32) (target, bool) {
+	link := "/proc/" + strconv.Itoa(int(pid)) + "/fd/" + strconv.Itoa(int(fd))
+	path, err := os.Readlink(link)
+	if err != nil || !filepath.IsAbs(path) {
+		// pipe:[...]、socket:[...] 等
+		return target{}, false
+	}
+	t, ok := b.resolve(pid, unix.AT_FDCWD, strings.TrimSuffix(path, " (deleted)"))
+	if ok {
+		t.id, t.isDir, _ = stat(link, true)
+	}
+	return t, ok
+}
+
+func (b *Backend) Fd() int {
+	return b.epfd
+}
+
+func (b *Backend) Close() error {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	if b.closed {
+		return unix.EBADF
+	}
+	b.closed = true
+	b.release()
+	return nil
+}
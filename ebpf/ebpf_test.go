@@ -0,0 +1,104 @@
+//go:build linux
+// +build linux
+
+// @@
+// @ Author       : Eacher
+// @ Date         : 2026-10-23 15:07:11
+// @ LastEditTime : 2026-10-23 15:58:17
+// @ LastEditors  : Eacher
+// @ --------------------------------------------------------------------------------<
+// @ Description  : eBPF 后端测试，本进程的操作不产生事件，文件操作在子进程中进行
+// @ --------------------------------------------------------------------------------<
+// @ FilePath     : /inotify/ebpf/ebpf_test.go
+// @@
+package ebpf_test
+
+import (
+	"os"
+	"time"
+	"os/exec"
+	"testing"
+	"path/filepath"
+	"github.com/20yyq/inotify"
+	"github.com/20yyq/inotify/ebpf"
+)
+
+// TestMain EBPF_TEST_DIR 设置时作为子进程在该目录中操作文件
+func TestMain(m *testing.M) {
+	if dir := os.Getenv("EBPF_TEST_DIR"); dir != "" {
+		os.WriteFile(filepath.Join(dir, "a"), []byte("1"), 0644)
+		os.Rename(filepath.Join(dir, "a"), filepath.Join(dir, "b"))
+		os.Mkdir(filepath.Join(dir, "c"), 0755)
+		os.Remove(filepath.Join(dir, "b"))
+		os.Remove(filepath.Join(dir, "c"))
+		os.Remove(filepath.Join(dir, "missing"))
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+func TestBackend(t *testing.T) {
+	b, err := ebpf.NewBackend()
+	if err != nil {
+		t.Skip("NewBackend", err)
+	}
+	w, err := inotify.NewWatcher(inotify.WithBackend(b))
+	if err != nil {
+		t.Fatal("NewWatcher", err)
+	}
+	defer w.Close()
+	dir := t.TempDir()
+	if err = w.AddWatch(dir, inotify.IN_CREATE|inotify.IN_MODIFY|inotify.IN_DELETE|inotify.IN_MOVE); err != nil {
+		t.Fatal("AddWatch", err)
+	}
+	cmd := exec.Command(os.Args[0], "-test.run=^$")
+	cmd.Env = append(os.Environ(), "EBPF_TEST_DIR="+dir)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatal("helper process", err, string(out))
+	}
+	// 失败的系统调用(删除目录时 unlinkat 的 EISDIR、不存在的文件)不产生事件
+	want := []struct {
+		name 	string
+		mask 	uint32
+	}{
+		{"a", inotify.IN_CREATE},
+		{"a", inotify.IN_MODIFY},
+		{"a", inotify.IN_MOVED_FROM},
+		{"b", inotify.IN_MOVED_TO},
+		{"c", inotify.IN_CREATE|inotify.IN_ISDIR},
+		{"b", inotify.IN_DELETE},
+		{"c", inotify.IN_DELETE|inotify.IN_ISDIR},
+	}
+	var cookie uint32
+	for i, ev := range want {
+		e, ok, err := w.WaitEventTimeout(time.Second*2)
+		if !ok || err != nil {
+			t.Fatal("WaitEventTimeout", i, ok, err)
+		}
+		if e.FileName != filepath.Join(dir, ev.name) || e.Raw&^inotify.IN_ISDIR != ev.mask&^inotify.IN_ISDIR || e.IsDir() != (ev.mask&inotify.IN_ISDIR != 0) {
+			t.Fatalf("event %d %s %#x, want %s %#x", i, e.FileName, e.Raw, ev.name, ev.mask)
+		}
+		if ev.mask == inotify.IN_MOVED_FROM {
+			cookie = e.Cookie
+		} else if ev.mask == inotify.IN_MOVED_TO && (e.Cookie == 0 || e.Cookie != cookie) {
+			t.Fatal("rename cookie", cookie, e.Cookie)
+		}
+	}
+	if e, ok, _ := w.WaitEventTimeout(time.Millisecond*200); ok {
+		t.Fatal("unexpected event", e.FileName, e.Raw)
+	}
+	// 监听的文件被删除时目录的监听为 DELETE，文件的监听为 DELETE_SELF
+	file := filepath.Join(dir, "d")
+	os.WriteFile(file, nil, 0644)
+	if err = w.AddWatch(file, inotify.IN_DELETE_SELF); err != nil {
+		t.Fatal("AddWatch", err)
+	}
+	if out, err := exec.Command("rm", file).CombinedOutput(); err != nil {
+		t.Fatal("rm", err, string(out))
+	}
+	for _, mask := range []uint32{inotify.IN_DELETE, inotify.IN_DELETE_SELF} {
+		if e, ok, err := w.WaitEventTimeout(time.Second*2); !ok || err != nil || e.FileName != file || e.Raw != mask {
+			t.Fatal("remove watched file", e.FileName, e.Raw, ok, err)
+		}
+	}
+}
@@ -0,0 +1,10 @@
+module github.com/20yyq/inotify/ebpf
+
+go 1.19
+
+require (
+	github.com/20yyq/inotify v0.0.0
+	golang.org/x/sys v0.15.0
+)
+
+replace github.com/20yyq/inotify => ../
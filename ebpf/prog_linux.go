@@ -0,0 +1,447 @@
+// @@
+// @ Author       : Eacher
+// @ Date         : 2026-10-23 15:07:11
+// @ LastEditTime : 2026-10-23 15:58:17
+// @ LastEditors  : Eacher
+// @ --------------------------------------------------------------------------------<
+// @ Description  : 不依赖 BPF 编译器与加载器，直接生成 tracepoint 程序的字节码，通过 bpf(2) 加载到 syscalls 的 tracepoint
+// @ --------------------------------------------------------------------------------<
+// @ FilePath     : /inotify/ebpf/prog_linux.go
+// @@
+package ebpf
+
+import (
+	"os"
+	"bufio"
+	"errors"
+	"strconv"
+	"strings"
+	"unsafe"
+	"sync/atomic"
+
+	"golang.org/x/sys/unix"
+)
+
+// record 的布局，sys_enter 时写入 pending，sys_exit 返回值不小于 0 时复制到 ringbuf
+const (
+	offOp 		= 0
+	offPid 		= 4
+	offDfd 		= 8
+	offDfd2 	= 12
+	offFlags 	= 16
+	offRet 		= 20
+	offName 	= 24
+	offName2 	= offName+nameSize
+	nameSize 	= 256
+	recordSize 	= offName2+nameSize
+)
+
+// record 的操作
+const (
+	opOpen uint32 = iota+1
+	opCreat
+	opMkdir
+	opWrite
+	opUnlink
+	opRmdir
+	opRename
+)
+
+// helper 函数的编号，见 include/uapi/linux/bpf.h
+const (
+	fnMapLookupElem 	= 1
+	fnMapUpdateElem 	= 2
+	fnMapDeleteElem 	= 3
+	fnGetCurrentPidTgid = 14
+	fnProbeReadKernel 	= 113
+	fnProbeReadUserStr 	= 114
+	fnRingbufReserve 	= 131
+	fnRingbufSubmit 	= 132
+)
+
+const (
+	mapTypeLRUHash 		= 9
+	ringbufBusy 		= 1<<31
+	ringbufDiscard 		= 1<<30
+	ringbufHeader 		= 8
+	ringbufSize 		= 1<<18
+	pendingEntries 		= 10240
+)
+
+// probe 一个 syscalls 的 tracepoint，字段名见 events/syscalls/sys_enter_*/format，空为该系统调用没有
+type probe struct {
+	event 	string
+	op 		uint32
+	dfd 	string
+	name 	string
+	flags 	string
+	dfd2 	string
+	name2 	string
+}
+
+// probes 不存在的 tracepoint(如 arm64 没有 open、rename)跳过；openat2 的 flags 在用户内存的 open_how 中，不支持
+var probes = []probe{
+	{event: "openat", op: opOpen, dfd: "dfd", name: "filename", flags: "flags"},
+	{event: "open", op: opOpen, name: "filename", flags: "flags"},
+	{event: "creat", op: opCreat, name: "pathname"},
+	{event: "mkdirat", op: opMkdir, dfd: "dfd", name: "pathname"},
+	{event: "mkdir", op: opMkdir, name: "pathname"},
+	{event: "write", op: opWrite, dfd: "fd"},
+	{event: "pwrite64", op: opWrite, dfd: "fd"},
+	{event: "writev", op: opWrite, dfd: "fd"},
+	{event: "unlinkat", op: opUnlink, dfd: "dfd", name: "pathname", flags: "flag"},
+	{event: "unlink", op: opUnlink, name: "pathname"},
+	{event: "rmdir", op: opRmdir, name: "pathname"},
+	{event: "renameat2", op: opRename, dfd: "olddfd", name: "oldname", dfd2: "newdfd", name2: "newname"},
+	{event: "renameat", op: opRename, dfd: "olddfd", name: "oldname", dfd2: "newdfd", name2: "newname"},
+	{event: "rename", op: opRename, name: "oldname", name2: "newname"},
+}
+
+// field tracepoint 的字段写入 record 的偏移
+type field struct {
+	name 	string
+	off 	int16
+}
+
+// insn struct bpf_insn
+type insn struct {
+	code 	uint8
+	regs 	uint8
+	off 	int16
+	imm 	int32
+}
+
+// asm 生成字节码，跳转到 label 的偏移在 done 时计算
+type asm struct {
+	insns 	[]insn
+	labels 	map[string]int
+	jumps 	map[int]string
+}
+
+func newAsm() *asm {
+	return &asm{labels: make(map[string]int), jumps: make(map[int]string)}
+}
+
+func (a *asm) emit(code uint8, dst, src uint8, off int16, imm int32) {
+	a.insns = append(a.insns, insn{code: code, regs: src<<4|dst, off: off, imm: imm})
+}
+
+func (a *asm) mov(dst, src uint8) {
+	a.emit(unix.BPF_ALU64|unix.BPF_MOV|unix.BPF_X, dst, src, 0, 0)
+}
+
+func (a *asm) movImm(dst uint8, imm int32) {
+	a.emit(unix.BPF_ALU64|unix.BPF_MOV|unix.BPF_K, dst, 0, 0, imm)
+}
+
+func (a *asm) alu(op uint8, dst uint8, imm int32) {
+	a.emit(unix.BPF_ALU64|op|unix.BPF_K, dst, 0, 0, imm)
+}
+
+func (a *asm) load(size uint8, dst, src uint8, off int16) {
+	a.emit(unix.BPF_LDX|size|unix.BPF_MEM, dst, src, off, 0)
+}
+
+func (a *asm) store(size uint8, dst, src uint8, off int16) {
+	a.emit(unix.BPF_STX|size|unix.BPF_MEM, dst, src, off, 0)
+}
+
+func (a *asm) storeImm(size uint8, dst uint8, off int16, imm int32) {
+	a.emit(unix.BPF_ST|size|unix.BPF_MEM, dst, 0, off, imm)
+}
+
+// loadMap 两条指令的 ld_imm64，imm 为 map 的 fd
+func (a *asm) loadMap(dst uint8, fd int) {
+	a.emit(unix.BPF_LD|unix.BPF_DW|unix.BPF_IMM, dst, unix.BPF_PSEUDO_MAP_FD, 0, int32(fd))
+	a.emit(0, 0, 0, 0, 0)
+}
+
+func (a *asm) call(fn int32) {
+	a.emit(unix.BPF_JMP|unix.BPF_CALL, 0, 0, 0, fn)
+}
+
+func (a *asm) jump(op uint8, dst uint8, imm int32, label string) {
+	a.jumps[len(a.insns)] = label
+	a.emit(unix.BPF_JMP|op|unix.BPF_K, dst, 0, 0, imm)
+}
+
+func (a *asm) label(name string) {
+	a.labels[name] = len(a.insns)
+}
+
+func (a *asm) done() []insn {
+	for i, label := range a.jumps {
+		a.insns[i].off = int16(a.labels[label]-i-1)
+	}
+	return a.insns
+}
+
+// key 当前线程的 pid_tgid 写入栈上的 r10-8，作为 pending 的 key
+func (a *asm) key() {
+	a.call(fnGetCurrentPidTgid)
+	a.store(unix.BPF_DW, 10, 0, -8)
+}
+
+// enterProg sys_enter 的程序: 跳过 self 进程的调用，open 只记录写或创建，record 写入 pending[pid_tgid]。
+// ringbuf 预留的内存不能作为 map 的值，record 先以 zero 的值插入 pending 再原地填写
+func enterProg(p probe, off map[string]int16, self int, zero, pending int) []insn {
+	a := newAsm()
+	a.mov(6, 1)
+	a.key()
+	a.alu(unix.BPF_RSH, 0, 32)
+	a.jump(unix.BPF_JEQ, 0, int32(self), "out")
+	a.mov(7, 0)
+	if p.op == opOpen {
+		a.load(unix.BPF_W, 1, 6, off[p.flags])
+		a.alu(unix.BPF_AND, 1, unix.O_WRONLY|unix.O_RDWR|unix.O_CREAT)
+		a.jump(unix.BPF_JEQ, 1, 0, "out")
+	}
+	a.storeImm(unix.BPF_W, 10, -12, 0)
+	a.loadMap(1, zero)
+	a.mov(2, 10)
+	a.alu(unix.BPF_ADD, 2, -12)
+	a.call(fnMapLookupElem)
+	a.jump(unix.BPF_JEQ, 0, 0, "out")
+	a.mov(3, 0)
+	a.loadMap(1, pending)
+	a.mov(2, 10)
+	a.alu(unix.BPF_ADD, 2, -8)
+	a.movImm(4, 0)
+	a.call(fnMapUpdateElem)
+	a.jump(unix.BPF_JNE, 0, 0, "out")
+	a.loadMap(1, pending)
+	a.mov(2, 10)
+	a.alu(unix.BPF_ADD, 2, -8)
+	a.call(fnMapLookupElem)
+	a.jump(unix.BPF_JEQ, 0, 0, "out")
+	a.mov(8, 0)
+	a.storeImm(unix.BPF_W, 8, offOp, int32(p.op))
+	a.store(unix.BPF_W, 8, 7, offPid)
+	for _, f := range []field{{p.dfd, offDfd}, {p.dfd2, offDfd2}} {
+		if f.name == "" {
+			a.storeImm(unix.BPF_W, 8, f.off, unix.AT_FDCWD)
+			continue
+		}
+		a.load(unix.BPF_W, 1, 6, off[f.name])
+		a.store(unix.BPF_W, 8, 1, f.off)
+	}
+	if p.flags != "" {
+		a.load(unix.BPF_W, 1, 6, off[p.flags])
+		a.store(unix.BPF_W, 8, 1, offFlags)
+	}
+	for _, f := range []field{{p.name, offName}, {p.name2, offName2}} {
+		if f.name == "" {
+			continue
+		}
+		a.mov(1, 8)
+		a.alu(unix.BPF_ADD, 1, int32(f.off))
+		a.movImm(2, nameSize)
+		a.load(unix.BPF_DW, 3, 6, off[f.name])
+		a.call(fnProbeReadUserStr)
+	}
+	a.label("out")
+	a.movImm(0, 0)
+	a.emit(unix.BPF_JMP|unix.BPF_EXIT, 0, 0, 0, 0)
+	return a.done()
+}
+
+// exitProg sys_exit 的程序: 取出 pending[pid_tgid]，返回值不小于 0 时与返回值一起写入 ringbuf
+func exitProg(ret int16, pending, ring int) []insn {
+	a := newAsm()
+	a.mov(6, 1)
+	a.key()
+	a.loadMap(1, pending)
+	a.mov(2, 10)
+	a.alu(unix.BPF_ADD, 2, -8)
+	a.call(fnMapLookupElem)
+	a.jump(unix.BPF_JEQ, 0, 0, "out")
+	a.mov(7, 0)
+	a.load(unix.BPF_DW, 9, 6, ret)
+	a.jump(unix.BPF_JSLT, 9, 0, "delete")
+	a.loadMap(1, ring)
+	a.movImm(2, recordSize)
+	a.movImm(3, 0)
+	a.call(fnRingbufReserve)
+	a.jump(unix.BPF_JEQ, 0, 0, "delete")
+	a.mov(8, 0)
+	a.mov(1, 8)
+	a.movImm(2, recordSize)
+	a.mov(3, 7)
+	a.call(fnProbeReadKernel)
+	a.store(unix.BPF_W, 8, 9, offRet)
+	a.mov(1, 8)
+	a.movImm(2, 0)
+	a.call(fnRingbufSubmit)
+	a.label("delete")
+	a.loadMap(1, pending)
+	a.mov(2, 10)
+	a.alu(unix.BPF_ADD, 2, -8)
+	a.call(fnMapDeleteElem)
+	a.label("out")
+	a.movImm(0, 0)
+	a.emit(unix.BPF_JMP|unix.BPF_EXIT, 0, 0, 0, 0)
+	return a.done()
+}
+
+func bpf(cmd int, attr unsafe.Pointer, size uintptr) (int, error) {
+	fd, _, errno := unix.Syscall(unix.SYS_BPF, uintptr(cmd), uintptr(attr), size)
+	if errno != 0 {
+		return -1, errno
+	}
+	return int(fd), nil
+}
+
+// mapAttr bpf_attr 的 BPF_MAP_CREATE 部分
+type mapAttr struct {
+	mapType 	uint32
+	keySize 	uint32
+	valueSize 	uint32
+	maxEntries 	uint32
+	flags 		uint32
+}
+
+func newMap(mapType, keySize, valueSize, maxEntries uint32) (int, error) {
+	attr := mapAttr{mapType: mapType, keySize: keySize, valueSize: valueSize, maxEntries: maxEntries}
+	fd, err := bpf(unix.BPF_MAP_CREATE, unsafe.Pointer(&attr), unsafe.Sizeof(attr))
+	return fd, os.NewSyscallError("bpf", err)
+}
+
+// progAttr bpf_attr 的 BPF_PROG_LOAD 部分
+type progAttr struct {
+	progType 	uint32
+	insnCnt 	uint32
+	insns 		uint64
+	license 	uint64
+	logLevel 	uint32
+	logSize 	uint32
+	logBuf 		uint64
+	kernVersion uint32
+	flags 		uint32
+}
+
+// loadProg 校验失败时返回 verifier 的日志
+func loadProg(insns []insn) (int, error) {
+	license := []byte("GPL\x00")
+	log := make([]byte, 1<<16)
+	attr := progAttr{progType: unix.BPF_PROG_TYPE_TRACEPOINT, insnCnt: uint32(len(insns)), insns: uint64(uintptr(unsafe.Pointer(&insns[0]))),
+		license: uint64(uintptr(unsafe.Pointer(&license[0]))), logLevel: 1, logSize: uint32(len(log)), logBuf: uint64(uintptr(unsafe.Pointer(&log[0])))}
+	fd, err := bpf(unix.BPF_PROG_LOAD, unsafe.Pointer(&attr), unsafe.Sizeof(attr))
+	if err == unix.EACCES || err == unix.EINVAL {
+		if n := strings.IndexByte(string(log), 0); n > 0 {
+			return -1, errors.New("The BPF program rejected by the verifier: " + strings.TrimSpace(string(log[:n])))
+		}
+	}
+	return fd, os.NewSyscallError("bpf", err)
+}
+
+// tracefs 挂载的目录，没有挂载时返回空
+func tracefs() string {
+	for _, dir := range []string{"/sys/kernel/tracing", "/sys/kernel/debug/tracing"} {
+		if _, err := os.Stat(dir + "/events/syscalls"); err == nil {
+			return dir
+		}
+	}
+	return ""
+}
+
+// tracepoint events/syscalls/event 的 id 与字段的偏移
+func tracepoint(dir, event string) (uint64, map[string]int16, error) {
+	b, err := os.ReadFile(dir + "/events/syscalls/" + event + "/id")
+	if err != nil {
+		return 0, nil, err
+	}
+	id, err := strconv.ParseUint(strings.TrimSpace(string(b)), 10, 64)
+	if err != nil {
+		return 0, nil, err
+	}
+	f, err := os.Open(dir + "/events/syscalls/" + event + "/format")
+	if err != nil {
+		return 0, nil, err
+	}
+	defer f.Close()
+	off := make(map[string]int16)
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		// field:const char * filename;	offset:24;	size:8;	signed:0;
+		parts := strings.Split(strings.TrimSpace(s.Text()), ";")
+		if len(parts) < 2 || !strings.HasPrefix(parts[0], "field:") || !strings.HasPrefix(strings.TrimSpace(parts[1]), "offset:") {
+			continue
+		}
+		decl := strings.Fields(parts[0])
+		n, err := strconv.Atoi(strings.TrimPrefix(strings.TrimSpace(parts[1]), "offset:"))
+		if err == nil && len(decl) > 0 {
+			off[strings.TrimLeft(decl[len(decl)-1], "*")] = int16(n)
+		}
+	}
+	return id, off, s.Err()
+}
+
+// attach 在 cpu 0 打开 tracepoint 的 perf 事件并挂载程序，tracepoint 的程序对所有 CPU 生效
+func attach(id uint64, prog int) (int, error) {
+	attr := unix.PerfEventAttr{Type: unix.PERF_TYPE_TRACEPOINT, Size: uint32(unsafe.Sizeof(unix.PerfEventAttr{})), Config: id, Sample: 1, Wakeup: 1}
+	fd, err := unix.PerfEventOpen(&attr, -1, 0, -1, unix.PERF_FLAG_FD_CLOEXEC)
+	if err != nil {
+		return -1, os.NewSyscallError("perf_event_open", err)
+	}
+	if err = unix.IoctlSetInt(fd, unix.PERF_EVENT_IOC_SET_BPF, prog); err == nil {
+		err = unix.IoctlSetInt(fd, unix.PERF_EVENT_IOC_ENABLE, 0)
+	}
+	if err != nil {
+		unix.Close(fd)
+		return -1, os.NewSyscallError("ioctl", err)
+	}
+	return fd, nil
+}
+
+// ringbuf BPF_MAP_TYPE_RINGBUF 的用户态消费者，数据区映射两次，跨越末尾的 record 也是连续的
+type ringbuf struct {
+	fd 			int
+	consumer 	[]byte
+	producer 	[]byte
+	data 		[]byte
+}
+
+func newRingbuf() (*ringbuf, error) {
+	fd, err := newMap(unix.BPF_MAP_TYPE_RINGBUF, 0, 0, ringbufSize)
+	if err != nil {
+		return nil, err
+	}
+	page := os.Getpagesize()
+	r := &ringbuf{fd: fd}
+	if r.consumer, err = unix.Mmap(fd, 0, page, unix.PROT_READ|unix.PROT_WRITE, unix.MAP_SHARED); err == nil {
+		if r.producer, err = unix.Mmap(fd, int64(page), page+2*ringbufSize, unix.PROT_READ, unix.MAP_SHARED); err != nil {
+			unix.Munmap(r.consumer)
+		}
+	}
+	if err != nil {
+		unix.Close(fd)
+		return nil, os.NewSyscallError("mmap", err)
+	}
+	r.data = r.producer[page:]
+	return r, nil
+}
+
+// consume 依次处理已提交的 record，直到没有新的 record。更新消费位置后再次检查，之后提交的 record 会唤醒 epoll
+func (r *ringbuf) consume(fn func(rec []byte)) {
+	cons := (*uint64)(unsafe.Pointer(&r.consumer[0]))
+	prod := (*uint64)(unsafe.Pointer(&r.producer[0]))
+	pos := atomic.LoadUint64(cons)
+	for pos < atomic.LoadUint64(prod) {
+		hdr := atomic.LoadUint32((*uint32)(unsafe.Pointer(&r.data[pos&(ringbufSize-1)])))
+		if hdr&ringbufBusy != 0 {
+			break
+		}
+		n := uint64(hdr&^(ringbufBusy|ringbufDiscard))
+		if start := pos&(ringbufSize-1)+ringbufHeader; hdr&ringbufDiscard == 0 && n >= recordSize {
+			fn(r.data[start:start+n])
+		}
+		pos += (n+ringbufHeader+7)&^7
+		atomic.StoreUint64(cons, pos)
+	}
+}
+
+func (r *ringbuf) close() {
+	unix.Munmap(r.producer)
+	unix.Munmap(r.consumer)
+	unix.Close(r.fd)
+}
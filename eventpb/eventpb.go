@@ -0,0 +1,243 @@
+// @@
+// @ Author       : Eacher
+// @ Date         : 2026-10-17 13:22:28
+// @ LastEditTime : 2026-10-24 10:04:25
+// @ LastEditors  : Eacher
+// @ --------------------------------------------------------------------------------<
+// @ Description  : event.proto 的类型与 inotify.Event 的转换，按长度前缀读写事件流
+// @ --------------------------------------------------------------------------------<
+// @ FilePath     : /inotify/eventpb/eventpb.go
+// @@
+package eventpb
+
+import (
+	"io"
+	"time"
+	"bufio"
+	"errors"
+	"encoding/binary"
+	"github.com/20yyq/inotify"
+)
+
+// Message event.proto 中的消息
+type Message interface {
+	Marshal() []byte
+	Unmarshal(data []byte) error
+}
+
+// Event 对应 event.proto 的 Event
+type Event struct {
+	Path 			string
+	Op 				uint32
+	RawMask 		uint32
+	Cookie 			uint32
+	IsDir 			bool
+	TimeUnixNano 	int64
+	Seq 			uint64
+	Group 			string
+	Count 			uint32
+}
+
+// FromEvent Data 无法跨进程传递，不会编码
+func FromEvent(e inotify.Event) *Event {
+	m := &Event{Path: e.Path(), Op: uint32(e.Op), RawMask: e.Raw, Cookie: e.Cookie, IsDir: e.IsDir(), Seq: e.Seq, Group: e.Group, Count: uint32(e.Count)}
+	if !e.Time.IsZero() {
+		m.TimeUnixNano = e.Time.UnixNano()
+	}
+	return m
+}
+
+// Event 转换为 inotify.Event，IsDir 为 true 时 Raw 包含 IN_ISDIR
+func (m *Event) Event() inotify.Event {
+	e := inotify.Event{FileName: m.Path, Op: inotify.Op(m.Op), Raw: m.RawMask, Cookie: m.Cookie, Seq: m.Seq, Group: m.Group, Count: int(m.Count)}
+	if m.IsDir {
+		e.Raw |= inotify.IN_ISDIR
+	}
+	if m.TimeUnixNano != 0 {
+		e.Time = time.Unix(0, m.TimeUnixNano)
+	}
+	return e
+}
+
+func (m *Event) Marshal() []byte {
+	var b encoder
+	b.string(1, m.Path)
+	b.varint(2, uint64(m.Op))
+	b.varint(3, uint64(m.RawMask))
+	b.varint(4, uint64(m.Cookie))
+	b.bool(5, m.IsDir)
+	b.varint(6, uint64(m.TimeUnixNano))
+	b.varint(7, m.Seq)
+	b.string(8, m.Group)
+	b.varint(9, uint64(m.Count))
+	return b
+}
+
+func (m *Event) Unmarshal(data []byte) error {
+	*m = Event{}
+	return decode(data, func(field, wire int, v uint64, body []byte) error {
+		switch field {
+		case 1:
+			m.Path = string(body)
+		case 2:
+			m.Op = uint32(v)
+		case 3:
+			m.RawMask = uint32(v)
+		case 4:
+			m.Cookie = uint32(v)
+		case 5:
+			m.IsDir = v != 0
+		case 6:
+			m.TimeUnixNano = int64(v)
+		case 7:
+			m.Seq = v
+		case 8:
+			m.Group = string(body)
+		case 9:
+			m.Count = uint32(v)
+		}
+		return nil
+	})
+}
+
+// Watch 对应 event.proto 的 Watch
+type Watch struct {
+	Path 		string
+	Flags 		uint32
+	Group 		string
+	Recursive 	bool
+}
+
+func (m *Watch) Marshal() []byte {
+	var b encoder
+	b.string(1, m.Path)
+	b.varint(2, uint64(m.Flags))
+	b.string(3, m.Group)
+	b.bool(4, m.Recursive)
+	return b
+}
+
+func (m *Watch) Unmarshal(data []byte) error {
+	*m = Watch{}
+	return decode(data, func(field, wire int, v uint64, body []byte) error {
+		switch field {
+		case 1:
+			m.Path = string(body)
+		case 2:
+			m.Flags = uint32(v)
+		case 3:
+			m.Group = string(body)
+		case 4:
+			m.Recursive = v != 0
+		}
+		return nil
+	})
+}
+
+// EventBatch 对应 event.proto 的 EventBatch
+type EventBatch struct {
+	Events 	[]*Event
+}
+
+func (m *EventBatch) Marshal() []byte {
+	var b encoder
+	for _, e := range m.Events {
+		b.bytes(1, e.Marshal())
+	}
+	return b
+}
+
+func (m *EventBatch) Unmarshal(data []byte) error {
+	*m = EventBatch{}
+	return decode(data, func(field, wire int, v uint64, body []byte) error {
+		if field == 1 && wire == wireBytes {
+			e := &Event{}
+			if err := e.Unmarshal(body); err != nil {
+				return err
+			}
+			m.Events = append(m.Events, e)
+		}
+		return nil
+	})
+}
+
+// Empty 对应 event.proto 的 Empty
+type Empty struct{}
+
+func (m *Empty) Marshal() []byte {
+	return nil
+}
+
+func (m *Empty) Unmarshal(data []byte) error {
+	return decode(data, func(field, wire int, v uint64, body []byte) error { return nil })
+}
+
+// EventsRequest 对应 event.proto 的 EventsRequest
+type EventsRequest struct {
+	Paths 	[]string
+}
+
+func (m *EventsRequest) Marshal() []byte {
+	var b encoder
+	for _, p := range m.Paths {
+		b.bytes(1, []byte(p))
+	}
+	return b
+}
+
+func (m *EventsRequest) Unmarshal(data []byte) error {
+	*m = EventsRequest{}
+	return decode(data, func(field, wire int, v uint64, body []byte) error {
+		if field == 1 && wire == wireBytes {
+			m.Paths = append(m.Paths, string(body))
+		}
+		return nil
+	})
+}
+
+// 单个消息的长度上限，防止错误的长度前缀分配过多内存
+const maxMessageSize = 4 << 20
+
+// Writer 写入以 varint 长度为前缀的消息流，与其他语言 writeDelimitedTo 的格式相同
+type Writer struct {
+	w 	io.Writer
+}
+
+func NewWriter(w io.Writer) *Writer {
+	return &Writer{w: w}
+}
+
+func (w *Writer) Write(m Message) error {
+	data := m.Marshal()
+	buf := binary.AppendUvarint(make([]byte, 0, binary.MaxVarintLen64+len(data)), uint64(len(data)))
+	_, err := w.w.Write(append(buf, data...))
+	return err
+}
+
+// Reader 读取 Writer 写入的消息流
+type Reader struct {
+	r 	*bufio.Reader
+}
+
+func NewReader(r io.Reader) *Reader {
+	return &Reader{r: bufio.NewReader(r)}
+}
+
+// Read 流结束时返回 io.EOF
+func (r *Reader) Read(m Message) error {
+	n, err := binary.ReadUvarint(r.r)
+	if err != nil {
+		return err
+	}
+	if n > maxMessageSize {
+		return errors.New("The protobuf message too large")
+	}
+	data := make([]byte, n)
+	if _, err = io.ReadFull(r.r, data); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return err
+	}
+	return m.Unmarshal(data)
+}
@@ -0,0 +1,101 @@
+// @@
+// @ Author       : Eacher
+// @ Date         : 2026-10-17 13:22:28
+// @ LastEditTime : 2026-10-17 14:04:17
+// @ LastEditors  : Eacher
+// @ --------------------------------------------------------------------------------<
+// @ Description  : protobuf 编码格式的最小实现，只支持 event.proto 用到的类型
+// @ --------------------------------------------------------------------------------<
+// @ FilePath     : /inotify/eventpb/wire.go
+// @@
+package eventpb
+
+import (
+	"errors"
+	"encoding/binary"
+)
+
+// wire type
+const (
+	wireVarint 	= 0
+	wireFixed64 = 1
+	wireBytes 	= 2
+	wireFixed32 = 5
+)
+
+var errTruncated = errors.New("The protobuf message truncated")
+
+type encoder []byte
+
+func (b *encoder) tag(field, wire int) {
+	*b = binary.AppendUvarint(*b, uint64(field)<<3|uint64(wire))
+}
+
+// varint proto3 中 0 值不编码
+func (b *encoder) varint(field int, v uint64) {
+	if v != 0 {
+		b.tag(field, wireVarint)
+		*b = binary.AppendUvarint(*b, v)
+	}
+}
+
+func (b *encoder) bool(field int, v bool) {
+	if v {
+		b.varint(field, 1)
+	}
+}
+
+func (b *encoder) bytes(field int, v []byte) {
+	b.tag(field, wireBytes)
+	*b = binary.AppendUvarint(*b, uint64(len(v)))
+	*b = append(*b, v...)
+}
+
+func (b *encoder) string(field int, v string) {
+	if v != "" {
+		b.bytes(field, []byte(v))
+	}
+}
+
+// decode 依次读出每个字段，varint 字段的值为 v，bytes 字段的值为 data，未知字段跳过
+func decode(data []byte, f func(field, wire int, v uint64, data []byte) error) error {
+	for len(data) > 0 {
+		key, n := binary.Uvarint(data)
+		if n <= 0 {
+			return errTruncated
+		}
+		data = data[n:]
+		field, wire := int(key>>3), int(key&7)
+		var v uint64
+		var body []byte
+		switch wire {
+		case wireVarint:
+			if v, n = binary.Uvarint(data); n <= 0 {
+				return errTruncated
+			}
+		case wireFixed64:
+			if n = 8; len(data) < n {
+				return errTruncated
+			}
+			v = binary.LittleEndian.Uint64(data)
+		case wireFixed32:
+			if n = 4; len(data) < n {
+				return errTruncated
+			}
+			v = uint64(binary.LittleEndian.Uint32(data))
+		case wireBytes:
+			l, m := binary.Uvarint(data)
+			if m <= 0 || uint64(len(data)-m) < l {
+				return errTruncated
+			}
+			body, n = data[m:m+int(l)], m+int(l)
+		default:
+			return errors.New("The protobuf wire type unsupported")
+		}
+		data = data[n:]
+		if err := f(field, wire, v, body); err != nil {
+			return err
+		}
+	}
+	return nil
+}
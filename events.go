@@ -0,0 +1,116 @@
+// @@
+// @ Author       : Eacher
+// @ Date         : 2026-10-15 15:04:58
+// @ LastEditTime : 2026-10-24 12:17:54
+// @ LastEditors  : Eacher
+// @ --------------------------------------------------------------------------------<
+// @ Description  : channel 方式读取事件
+// @ --------------------------------------------------------------------------------<
+// @ FilePath     : /inotify/events.go
+// @@
+package inotify
+
+import "sync"
+
+// Dispatch 每个 goroutine 的队列长度
+const dispatchQueue = 64
+
+// Events 第一次调用 Events 或 Errors 时启动 goroutine 读取事件，Close 后关闭。
+// 使用后不要再调用 WaitEvent 等方法，否则事件会被分走
+func (w *Watcher) Events() <-chan Event {
+	if !w.initialized() {
+		c := make(chan Event)
+		close(c)
+		return c
+	}
+	w.pumpOnce.Do(w.startPump)
+	return w.events
+}
+
+// Errors 读取事件时的错误，如 ErrDropped 与读取 inotify fd 的 *os.SyscallError，收到 IN_Q_OVERFLOW 事件时为 ErrOverflow(事件仍由 Events 交给调用者)，
+// 没有读取时新的错误被丢弃，Close 后关闭
+func (w *Watcher) Errors() <-chan error {
+	if !w.initialized() {
+		c := make(chan error)
+		close(c)
+		return c
+	}
+	w.pumpOnce.Do(w.startPump)
+	return w.errs
+}
+
+func (w *Watcher) startPump() {
+	w.events, w.errs = make(chan Event), make(chan error, 1)
+	go w.pump()
+}
+
+func (w *Watcher) pump() {
+	defer close(w.errs)
+	defer close(w.events)
+	for {
+		e, err := w.next()
+		if err == ErrClosed {
+			return
+		}
+		if err != nil {
+			select {
+			case w.errs <- err:
+			default:
+			}
+			continue
+		}
+		if e.Raw&IN_Q_OVERFLOW != 0 {
+			select {
+			case w.errs <- ErrOverflow:
+			default:
+			}
+		}
+		select {
+		case w.events <- e:
+		case <-w.closed():
+			return
+		}
+	}
+}
+
+// Dispatch 启动 n 个 goroutine 调用 handle，事件按所属监听的 wd 固定分给其中一个，同一监听的事件按顺序处理，
+// 不同监听的事件在多个核上并发处理。阻塞直到 Close 并等待所有 handle 返回，之后返回 ErrClosed。
+// 读取、解析与过滤仍在调用 Dispatch 的 goroutine 中进行，只有 handle 并发。
+// 顺序按监听而不是按路径保证：同一路径被两个监听覆盖(如目录与其中的文件)时事件可能由两个 goroutine 处理，
+// 没有 wd 的注入事件(如递归监听扫描新目录时合成的 Create)都由第一个 goroutine 处理。
+// 与 Events 相同，使用后不要再调用 WaitEvent 等方法；某个 goroutine 的 handle 阻塞时读取同样暂停，缓存满后按 Backpressure 处理
+func (w *Watcher) Dispatch(n int, handle func(Event)) error {
+	if !w.initialized() {
+		return ErrNotInitialized
+	}
+	if n < 1 {
+		n = 1
+	}
+	shards := make([]chan Event, n)
+	var wg sync.WaitGroup
+	for i := range shards {
+		shards[i] = make(chan Event, dispatchQueue)
+		wg.Add(1)
+		go func(c chan Event) {
+			defer wg.Done()
+			for e := range c {
+				handle(e)
+			}
+		}(shards[i])
+	}
+	defer wg.Wait()
+	for {
+		e, err := w.next()
+		if err == ErrClosed {
+			for _, c := range shards {
+				close(c)
+			}
+			return err
+		}
+		if err != nil {
+			// ErrDropped 等由 Seq 不连续发现
+			continue
+		}
+		shards[e.wd%uint32(n)] <- e
+	}
+}
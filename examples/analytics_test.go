@@ -0,0 +1,135 @@
+//go:build linux
+// +build linux
+
+// @@
+// @ Author       : Eacher
+// @ Date         : 2026-10-22 15:40:12
+// @ LastEditTime : 2026-10-22 17:42:47
+// @ LastEditors  : Eacher
+// @ --------------------------------------------------------------------------------<
+// @ Description  : 滑动窗口统计与异常检测测试，时钟由 SetClock 控制
+// @ --------------------------------------------------------------------------------<
+// @ FilePath     : /inotify/examples/analytics_test.go
+// @@
+package inotify_test
+
+import (
+	"time"
+	"testing"
+	"github.com/20yyq/inotify"
+)
+
+func TestAnalyticsNotInitialized(t *testing.T) {
+	var w *inotify.Watcher
+	if w.Analytics(time.Second) != nil {
+		t.Fatal("Analytics on nil Watcher")
+	}
+	if (&inotify.Watcher{}).Analytics(time.Second) != nil {
+		t.Fatal("Analytics on zero Watcher")
+	}
+}
+
+func TestAnalyticsWindow(t *testing.T) {
+	now := time.Unix(1000, 0)
+	a := inotify.NewAnalytics(3*time.Second)
+	a.SetClock(func() time.Time { return now })
+	create, remove := inotify.Event{FileName: "/a", Raw: inotify.IN_CREATE}, inotify.Event{FileName: "/b", Raw: inotify.IN_DELETE}
+	a.Observe(create)
+	a.Observe(create)
+	a.Observe(remove)
+	st := a.Stats(-1)
+	if st.Window != 3*time.Second || st.Events != 3 || st.Unique != 2 || st.Rate["CREATE"] != 2.0/3 || st.Rate["DELETE"] != 1.0/3 {
+		t.Fatalf("Stats %+v", st)
+	}
+	if len(st.Top) != 2 || st.Top[0] != (inotify.PathCount{Path: "/a", Count: 2}) || st.Top[1] != (inotify.PathCount{Path: "/b", Count: 1}) {
+		t.Fatal("Top", st.Top)
+	}
+	if st = a.Stats(1); len(st.Top) != 1 || st.Top[0].Path != "/a" {
+		t.Fatal("Stats(1)", st.Top)
+	}
+	// 窗口的最后一秒仍包含 1000 秒的事件
+	now = time.Unix(1002, 0)
+	a.Observe(remove)
+	if st = a.Stats(-1); st.Events != 4 || len(st.Top) != 2 || st.Top[0].Count != 2 || st.Top[1].Count != 2 {
+		t.Fatalf("Stats at 1002 %+v", st)
+	}
+	// 1000 秒已离开窗口
+	now = time.Unix(1003, 0)
+	if st = a.Stats(-1); st.Events != 1 || st.Unique != 1 || st.Top[0] != (inotify.PathCount{Path: "/b", Count: 1}) {
+		t.Fatalf("Stats at 1003 %+v", st)
+	}
+	// 1003 秒与 1000 秒共用同一个桶，重新开始计数
+	a.Observe(inotify.Event{FileName: "/c", Raw: inotify.IN_MODIFY})
+	if st = a.Stats(-1); st.Events != 2 || st.Unique != 2 || st.Rate["CREATE"] != 0 || st.Rate["MODIFY"] != 1.0/3 {
+		t.Fatalf("Stats after rollover %+v", st)
+	}
+	now = time.Unix(1010, 0)
+	if st = a.Stats(-1); st.Events != 0 || st.Unique != 0 || len(st.Top) != 0 {
+		t.Fatalf("Stats after window %+v", st)
+	}
+}
+
+func TestDetector(t *testing.T) {
+	anomalies := make(chan inotify.Anomaly, 10)
+	// 每次新建 Analytics 与 Detector，burst 在第 sec 秒产生 n 个 DELETE
+	detector := func() func(sec int64, n int) {
+		now := time.Unix(100, 0)
+		a := inotify.NewAnalytics(time.Minute)
+		a.SetClock(func() time.Time { return now })
+		d := inotify.NewDetector(3, func(an inotify.Anomaly) { anomalies <- an })
+		d.Warmup = 3
+		a.Detect(d)
+		return func(sec int64, n int) {
+			now = time.Unix(sec, 0)
+			for i := 0; i < n; i++ {
+				a.Observe(inotify.Event{FileName: "/a", Raw: inotify.IN_DELETE})
+			}
+		}
+	}
+	expect := func(fired bool) inotify.Anomaly {
+		select {
+		case an := <-anomalies:
+			if !fired {
+				t.Fatalf("unexpected anomaly %+v", an)
+			}
+			return an
+		case <-time.After(100*time.Millisecond):
+			if fired {
+				t.Fatal("anomaly not fired")
+			}
+		}
+		return inotify.Anomaly{}
+	}
+	// 预热期间只学习
+	burst := detector()
+	burst(100, 2)
+	burst(101, 2)
+	burst(102, 50)
+	expect(false)
+
+	// 基线为每秒 2 个
+	burst = detector()
+	for sec := int64(100); sec < 105; sec++ {
+		burst(sec, 2)
+	}
+	expect(false)
+	// 少于 MinCount 不报警，达到时超过阈值，同一秒只报警一次
+	burst(105, 9)
+	expect(false)
+	burst(105, 20)
+	an := expect(true)
+	if an.Op != "DELETE" || an.Count != 10 || an.Time.Unix() != 105 || an.Mean != 2 || an.Score < 3 {
+		t.Fatalf("Anomaly %+v", an)
+	}
+	expect(false)
+	// 恢复正常后不再报警，基线随风暴升高
+	for sec := int64(106); sec < 110; sec++ {
+		burst(sec, 12)
+	}
+	expect(false)
+	// 新的一秒再次出现风暴
+	burst(110, 100)
+	if an = expect(true); an.Time.Unix() != 110 {
+		t.Fatalf("Anomaly %+v", an)
+	}
+}
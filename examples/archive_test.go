@@ -0,0 +1,85 @@
+//go:build linux
+// +build linux
+
+// @@
+// @ Author       : Eacher
+// @ Date         : 2026-10-24 14:16:21
+// @ LastEditTime : 2026-10-24 15:35:56
+// @ LastEditors  : Eacher
+// @ --------------------------------------------------------------------------------<
+// @ Description  : inotify archive 的归档路径与复制测试
+// @ --------------------------------------------------------------------------------<
+// @ FilePath     : /inotify/examples/archive_test.go
+// @@
+package inotify_test
+
+import (
+	"os"
+	"time"
+	"testing"
+	"path/filepath"
+	"github.com/20yyq/inotify/internal/archive"
+)
+
+func TestArchiveTarget(t *testing.T) {
+	base := t.TempDir()
+	root, file, dest := filepath.Join(base, "etc"), filepath.Join(base, "app.conf"), filepath.Join(base, "etc", "backup")
+	a, err := archive.New(dest)
+	if err != nil {
+		t.Fatal("New", err)
+	}
+	a.AddRoot(root)
+	a.AddRoot(file)
+	at := time.Date(2026, 10, 24, 14, 16, 21, 123456789, time.Local)
+	stamp := filepath.Join(dest, "20261024-141621.123")
+	tests := []struct {
+		name 	string
+		to 		string
+		ok 		bool
+	}{
+		{filepath.Join(root, "nginx.conf"), filepath.Join(stamp, "etc", "nginx.conf"), true},
+		{file, filepath.Join(stamp, "app.conf"), true},
+		// 名字以 .. 开头的文件仍在监听的目录中
+		{filepath.Join(root, "..swp"), filepath.Join(stamp, "etc", "..swp"), true},
+		// 不在任何监听的根之下的文件只保留文件名
+		{filepath.Join(base, "etc2", "a"), filepath.Join(stamp, "a"), true},
+		// --dest 在监听的目录中时，归档本身被跳过
+		{filepath.Join(dest, "20261024-141621.000", "etc", "nginx.conf"), "", false},
+	}
+	for _, tt := range tests {
+		if to, ok := a.Target(tt.name, at); to != tt.to || ok != tt.ok {
+			t.Fatal("Target", tt.name, to, ok)
+		}
+	}
+}
+
+func TestArchiveCopy(t *testing.T) {
+	root := t.TempDir()
+	a, err := archive.New(filepath.Join(root, "backup"))
+	if err != nil {
+		t.Fatal("New", err)
+	}
+	a.AddRoot(root)
+	name := filepath.Join(root, "a.txt")
+	os.WriteFile(name, []byte("v1"), 0600)
+	at := time.Now()
+	to, err := a.Archive(name, at)
+	if err != nil {
+		t.Fatal("Archive", err)
+	}
+	data, _ := os.ReadFile(to)
+	info, _ := os.Stat(to)
+	if string(data) != "v1" || info.Mode().Perm() != 0600 || filepath.Dir(filepath.Dir(to)) != filepath.Join(root, "backup", at.Format(archive.Layout)) {
+		t.Fatal("archived", to, string(data), info.Mode())
+	}
+	// 同一时刻的归档不覆盖
+	if _, err = a.Archive(name, at); err == nil {
+		t.Fatal("overwrite archive")
+	}
+	if to, err = a.Archive(to, at); to != "" || err != nil {
+		t.Fatal("archive inside dest", to, err)
+	}
+	if _, err = a.Archive(root, time.Now()); err == nil {
+		t.Fatal("archive directory")
+	}
+}
@@ -0,0 +1,175 @@
+//go:build linux
+// +build linux
+
+// @@
+// @ Author       : Eacher
+// @ Date         : 2026-10-20 09:37:31
+// @ LastEditTime : 2026-10-20 12:41:41
+// @ LastEditors  : Eacher
+// @ --------------------------------------------------------------------------------<
+// @ Description  : 内置后端测试，fanotify 需要 CAP_SYS_ADMIN
+// @ --------------------------------------------------------------------------------<
+// @ FilePath     : /inotify/examples/backend_test.go
+// @@
+package inotify_test
+
+import (
+	"os"
+	"time"
+	"testing"
+	"strconv"
+	"path/filepath"
+	"github.com/20yyq/inotify"
+)
+
+// nextEvent 等待 name 的下一个事件
+func nextEvent(t *testing.T, w *inotify.Watcher, name string) inotify.Event {
+	t.Helper()
+	for deadline := time.Now().Add(time.Second*2); time.Now().Before(deadline); {
+		e, ok, err := w.WaitEventTimeout(time.Until(deadline))
+		if err != nil {
+			t.Fatal("WaitEventTimeout", err)
+		}
+		if ok && e.FileName == name {
+			return e
+		}
+	}
+	t.Fatal("no event", name)
+	return inotify.Event{}
+}
+
+func TestPollBackend(t *testing.T) {
+	dir := t.TempDir()
+	w, err := inotify.NewWatcher(inotify.WithBackendType(inotify.BackendPoll), inotify.WithPollInterval(time.Millisecond*20))
+	if err != nil {
+		t.Fatal("NewWatcher", err)
+	}
+	defer w.Close()
+	if err = w.AddWatch(filepath.Join(dir, "none"), inotify.IN_CREATE); err == nil {
+		t.Fatal("AddWatch not exist")
+	}
+	if err = w.AddWatch(dir, inotify.IN_CREATE|inotify.IN_MODIFY|inotify.IN_DELETE|inotify.IN_DELETE_SELF); err != nil {
+		t.Fatal("AddWatch", err)
+	}
+	name := filepath.Join(dir, "a")
+	os.WriteFile(name, nil, 0644)
+	if e := nextEvent(t, w, name); e.Raw != inotify.IN_CREATE {
+		t.Fatal("CREATE", e.GetEventName())
+	}
+	os.WriteFile(name, []byte("x"), 0644)
+	if e := nextEvent(t, w, name); e.Raw != inotify.IN_MODIFY {
+		t.Fatal("MODIFY", e.GetEventName())
+	}
+	os.Mkdir(filepath.Join(dir, "sub"), 0755)
+	if e := nextEvent(t, w, filepath.Join(dir, "sub")); !e.IsDir() || e.Op != inotify.Create {
+		t.Fatal("mkdir", e.GetEventName(), e.IsDir())
+	}
+	os.Remove(name)
+	if e := nextEvent(t, w, name); e.Raw != inotify.IN_DELETE {
+		t.Fatal("DELETE", e.GetEventName())
+	}
+	// 监听的目录被删除后移除监听
+	os.RemoveAll(dir)
+	nextEvent(t, w, dir+string(os.PathSeparator))
+	for deadline := time.Now().Add(time.Second); w.Stats().Watches != 0 && time.Now().Before(deadline); {
+		w.TryEvent()
+		time.Sleep(time.Millisecond*10)
+	}
+	if n := w.Stats().Watches; n != 0 {
+		t.Fatal("Watches", n)
+	}
+}
+
+func TestFanotifyBackend(t *testing.T) {
+	w, err := inotify.NewWatcher(inotify.WithBackendType(inotify.BackendFanotify))
+	if err != nil {
+		t.Skip("fanotify needs CAP_SYS_ADMIN", err)
+	}
+	defer w.Close()
+	dir := t.TempDir()
+	if err = w.AddWatch(dir, inotify.IN_CLOSE_WRITE|inotify.IN_CREATE); err != nil {
+		t.Fatal("AddWatch", err)
+	}
+	name := filepath.Join(dir, "a")
+	os.WriteFile(name, []byte("x"), 0644)
+	if e := nextEvent(t, w, name); e.Raw != inotify.IN_CLOSE_WRITE || e.Op != inotify.Write {
+		t.Fatal("CLOSE_WRITE", e.GetEventName())
+	}
+	// 移除后与 inotify 相同收到 IN_IGNORED
+	if err = w.RemoveWatch(dir); err != nil {
+		t.Fatal("RemoveWatch", err)
+	}
+	for deadline := time.Now().Add(time.Second); w.Stats().Watches != 0 && time.Now().Before(deadline); {
+		w.TryEvent()
+		time.Sleep(time.Millisecond*10)
+	}
+	if n := w.Stats().Watches; n != 0 {
+		t.Fatal("Watches", n)
+	}
+}
+
+func TestBackendAuto(t *testing.T) {
+	w, err := inotify.NewWatcher(inotify.WithBackendType(inotify.BackendAuto))
+	if err != nil {
+		t.Fatal("NewWatcher", err)
+	}
+	defer w.Close()
+	dir := t.TempDir()
+	if err = w.AddWatch(dir, inotify.IN_CREATE); err != nil {
+		t.Fatal("AddWatch", err)
+	}
+	os.WriteFile(filepath.Join(dir, "a"), nil, 0644)
+	nextEvent(t, w, filepath.Join(dir, "a"))
+}
+
+func TestInstancePool(t *testing.T) {
+	fds := func() int {
+		entries, _ := os.ReadDir("/proc/self/fd")
+		return len(entries)
+	}
+	before := fds()
+	w, err := inotify.NewWatcher(inotify.WithInstancePool(2))
+	if err != nil {
+		t.Fatal("NewWatcher", err)
+	}
+	defer w.Close()
+	root := t.TempDir()
+	if err = w.AddRecursiveWatch(root, inotify.IN_CREATE); err != nil {
+		t.Fatal("AddRecursiveWatch", err)
+	}
+	// 同一目录再次添加仍在原来的实例中
+	if err = w.AddWatch(root, inotify.IN_CREATE); err != nil {
+		t.Fatal("AddWatch", err)
+	}
+	dirs := []string{root}
+	for i := 0; i < 5; i++ {
+		dir := filepath.Join(dirs[len(dirs)-1], strconv.Itoa(i))
+		os.Mkdir(dir, 0755)
+		nextEvent(t, w, dir)
+		dirs = append(dirs, dir)
+	}
+	// 6 个监听分在 3 个实例中
+	if n := fds()-before; n < 4 {
+		t.Fatal("fds", n)
+	}
+	for _, dir := range dirs {
+		name := filepath.Join(dir, "a")
+		os.WriteFile(name, nil, 0644)
+		if e := nextEvent(t, w, name); e.Raw != inotify.IN_CREATE {
+			t.Fatal("CREATE", e.GetEventName())
+		}
+	}
+	if n := w.Stats().Watches; n != len(dirs) {
+		t.Fatal("Watches", n)
+	}
+	if err = w.RemoveWatch(dirs[3]); err != nil {
+		t.Fatal("RemoveWatch", err)
+	}
+	for deadline := time.Now().Add(time.Second); w.Stats().Watches != len(dirs)-1 && time.Now().Before(deadline); {
+		w.TryEvent()
+		time.Sleep(time.Millisecond*10)
+	}
+	if n := w.Stats().Watches; n != len(dirs)-1 {
+		t.Fatal("Watches after RemoveWatch", n)
+	}
+}
@@ -0,0 +1,175 @@
+//go:build linux
+// +build linux
+
+// @@
+// @ Author       : Eacher
+// @ Date         : 2026-10-15 18:34:08
+// @ LastEditTime : 2026-10-19 18:11:26
+// @ LastEditors  : Eacher
+// @ --------------------------------------------------------------------------------<
+// @ Description  : 事件读取性能测试 go test -bench . -run ^$ ./examples
+// @ --------------------------------------------------------------------------------<
+// @ FilePath     : /inotify/examples/bench_test.go
+// @@
+package inotify_test
+
+import (
+	"os"
+	"time"
+	"runtime"
+	"testing"
+	"strconv"
+	"path/filepath"
+	"github.com/20yyq/inotify"
+)
+
+func benchWatcher(b *testing.B, opts ...inotify.Option) (*inotify.Watcher, *os.File) {
+	dir := b.TempDir()
+	w, err := inotify.NewWatcher(opts...)
+	if err != nil {
+		b.Fatal("NewWatcher", err)
+	}
+	b.Cleanup(func() { w.Close() })
+	if err = w.AddWatch(dir, inotify.IN_MODIFY); err != nil {
+		b.Fatal("AddWatch", err)
+	}
+	f, err := os.Create(filepath.Join(dir, "a"))
+	if err != nil {
+		b.Fatal("Create", err)
+	}
+	b.Cleanup(func() { f.Close() })
+	return w, f
+}
+
+// BenchmarkEvent 每次写入后读取一个事件
+func BenchmarkEvent(b *testing.B) {
+	w, f := benchWatcher(b)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		f.Write([]byte("x"))
+		if _, ok, err := w.WaitEventTimeout(time.Second); !ok || err != nil {
+			b.Fatal("WaitEventTimeout", ok, err)
+		}
+	}
+}
+
+// BenchmarkBurst 连续写入时读取者的吞吐量，Block 不丢弃事件，两个文件交替写入避免内核合并相邻的相同事件
+func BenchmarkBurst(b *testing.B) {
+	w, f := benchWatcher(b, inotify.WithBackpressure(inotify.Block))
+	g, err := os.Create(f.Name()+".b")
+	if err != nil {
+		b.Fatal("Create", err)
+	}
+	defer g.Close()
+	storm(b, func(i int) {
+		if i%2 == 0 {
+			f.Write([]byte("x"))
+		} else {
+			g.Write([]byte("x"))
+		}
+	}, func() (bool, error) {
+		_, ok, err := w.WaitEventTimeout(time.Second)
+		return ok, err
+	})
+}
+
+// BenchmarkBurstInto 同 BenchmarkBurst，使用 WaitEventInto
+func BenchmarkBurstInto(b *testing.B) {
+	w, f := benchWatcher(b, inotify.WithBackpressure(inotify.Block))
+	g, err := os.Create(f.Name()+".b")
+	if err != nil {
+		b.Fatal("Create", err)
+	}
+	defer g.Close()
+	var e inotify.Event
+	storm(b, func(i int) {
+		if i%2 == 0 {
+			f.Write([]byte("x"))
+		} else {
+			g.Write([]byte("x"))
+		}
+	}, func() (bool, error) {
+		return w.WaitEventInto(&e, time.Second)
+	})
+}
+
+// BenchmarkCreateStorm 连续新建文件，每个 op 为一个 CREATE，新建后立即删除，DELETE 没有监听
+func BenchmarkCreateStorm(b *testing.B) {
+	dir := b.TempDir()
+	w, err := inotify.NewWatcher(inotify.WithBackpressure(inotify.Block))
+	if err != nil {
+		b.Fatal("NewWatcher", err)
+	}
+	defer w.Close()
+	if err = w.AddWatch(dir, inotify.IN_CREATE); err != nil {
+		b.Fatal("AddWatch", err)
+	}
+	storm(b, func(i int) {
+		name := filepath.Join(dir, strconv.Itoa(i))
+		if f, err := os.Create(name); err == nil {
+			f.Close()
+			os.Remove(name)
+		}
+	}, func() (bool, error) {
+		_, ok, err := w.WaitEventTimeout(time.Second)
+		return ok, err
+	})
+}
+
+// storm 在另一个 goroutine 中 b.N 次调用 produce 产生事件，同时调用 consume 读取，直到读到 b.N 个事件或超时
+func storm(b *testing.B, produce func(int), consume func() (bool, error)) {
+	// 限制未读取的事件数量，避免内核队列溢出
+	credit := make(chan struct{}, 1024)
+	b.ReportAllocs()
+	b.ResetTimer()
+	go func() {
+		for i := 0; i < b.N; i++ {
+			credit <- struct{}{}
+			produce(i)
+		}
+	}()
+	n := 0
+	for n < b.N {
+		ok, err := consume()
+		if err != nil && err != inotify.ErrDropped {
+			b.Fatal("consume", err)
+		}
+		if !ok {
+			break
+		}
+		<-credit
+		n++
+	}
+	b.StopTimer()
+	b.ReportMetric(float64(n)/float64(b.N), "events/op")
+	b.ReportMetric(float64(runtime.NumGoroutine()), "goroutines")
+}
+
+// BenchmarkFullBuffer 缓存已满时逐个取出事件，每个 op 为 25 个事件，取出的开销不应随缓存中剩余的事件数量增长
+func BenchmarkFullBuffer(b *testing.B) {
+	w, err := inotify.NewWatcher(inotify.WithInjection())
+	if err != nil {
+		b.Fatal("NewWatcher", err)
+	}
+	defer w.Close()
+	// 没有名字的事件最多，缓存能放下 25 个
+	const n = 25
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		// 只计算取出的时间
+		b.StopTimer()
+		for j := 0; j < n; j++ {
+			if err := w.Inject(inotify.Event{Raw: inotify.IN_Q_OVERFLOW}); err != nil {
+				b.Fatal("Inject", err)
+			}
+		}
+		b.StartTimer()
+		for j := 0; j < n; j++ {
+			if _, ok := w.TryEvent(); !ok {
+				b.Fatal("TryEvent", j)
+			}
+		}
+	}
+}
@@ -0,0 +1,281 @@
+//go:build linux
+// +build linux
+
+// @@
+// @ Author       : Eacher
+// @ Date         : 2026-10-14 10:17:45
+// @ LastEditTime : 2026-10-24 18:26:56
+// @ LastEditors  : Eacher
+// @ --------------------------------------------------------------------------------<
+// @ Description  : Close 重复调用、并发关闭与并发修改监听测试，建议 go test -race 运行
+// @ --------------------------------------------------------------------------------<
+// @ FilePath     : /inotify/examples/close_test.go
+// @@
+package inotify_test
+
+import (
+	"os"
+	"sync"
+	"context"
+	"time"
+	"testing"
+	"strconv"
+	"path/filepath"
+	"github.com/20yyq/inotify"
+)
+
+func TestCloseTwice(t *testing.T) {
+	w, err := inotify.NewWatcher()
+	if err != nil {
+		t.Fatal("NewWatcher", err)
+	}
+	w.Close()
+	w.Close()
+	if err = w.AddWatch(t.TempDir(), inotify.IN_CREATE); err != inotify.ErrClosed {
+		t.Fatal("AddWatch after Close", err)
+	}
+	if _, err = w.WaitEvent(); err != inotify.ErrClosed {
+		t.Fatal("WaitEvent after Close", err)
+	}
+}
+
+// 没有事件时 Close 由 eventfd 立即唤醒 EpollWait，WaitEvent 返回 ErrClosed
+func TestCloseIdle(t *testing.T) {
+	w, err := inotify.NewWatcher()
+	if err != nil {
+		t.Fatal("NewWatcher", err)
+	}
+	if err = w.AddWatch(t.TempDir(), inotify.IN_CREATE); err != nil {
+		t.Fatal("AddWatch", err)
+	}
+	if err = w.Healthy(); err != nil {
+		t.Fatal("Healthy", err)
+	}
+	errc := make(chan error, 1)
+	go func() {
+		_, err := w.WaitEvent()
+		errc <- err
+	}()
+	time.Sleep(time.Millisecond*50)
+	start := time.Now()
+	w.Close()
+	select {
+	case err = <-errc:
+		if err != inotify.ErrClosed {
+			t.Fatal("WaitEvent", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("WaitEvent not woken by Close")
+	}
+	if d := time.Since(start); d > time.Millisecond*200 {
+		t.Fatal("Close took", d)
+	}
+}
+
+func TestWatcherContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	w, err := inotify.NewWatcherContext(ctx)
+	if err != nil {
+		cancel()
+		t.Fatal("NewWatcherContext", err)
+	}
+	if err = w.AddWatch(t.TempDir(), inotify.IN_CREATE); err != nil {
+		t.Fatal("AddWatch", err)
+	}
+	events := w.Events()
+	errc := make(chan error, 1)
+	go func() {
+		_, err := w.WaitEvent()
+		errc <- err
+	}()
+	cancel()
+	select {
+	case err = <-errc:
+		if err != inotify.ErrClosed {
+			t.Fatal("WaitEvent", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("WaitEvent not woken by ctx")
+	}
+	for range events {
+	}
+	if _, err = inotify.NewWatcherContext(ctx); err != context.Canceled {
+		t.Fatal("NewWatcherContext after cancel", err)
+	}
+}
+
+// 事件持续产生时并发 AddWatch、WaitEvent 与 Close
+func TestCloseUnderLoad(t *testing.T) {
+	dir := t.TempDir()
+	w, err := inotify.NewWatcher()
+	if err != nil {
+		t.Fatal("NewWatcher", err)
+	}
+	if err = w.AddWatch(dir, inotify.IN_CREATE|inotify.IN_DELETE); err != nil {
+		t.Fatal("AddWatch", err)
+	}
+	var wg sync.WaitGroup
+	stop := make(chan struct{})
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				ws, err := w.WaitEvent()
+				if err == inotify.ErrClosed {
+					return
+				}
+				ws.GetEventName()
+			}
+		}()
+	}
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			name := filepath.Join(dir, strconv.Itoa(i))
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				os.WriteFile(name, nil, 0644)
+				os.Remove(name)
+				if err := w.AddWatch(dir, inotify.IN_MODIFY); err != nil && err != inotify.ErrClosed {
+					t.Error("AddWatch", err)
+				}
+			}
+		}(i)
+	}
+	time.Sleep(time.Millisecond*200)
+	var closer sync.WaitGroup
+	for i := 0; i < 3; i++ {
+		closer.Add(1)
+		go func() {
+			defer closer.Done()
+			w.Close()
+		}()
+	}
+	closer.Wait()
+	close(stop)
+
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second*5):
+		t.Fatal("WaitEvent not woken by Close")
+	}
+}
+
+func TestConcurrentWatches(t *testing.T) {
+	// default 为不带任何 Option 的 AddWatch、RemoveWatch
+	for name, opts := range map[string][]inotify.Option{"default": nil, "pending": {inotify.WithPendingWatches()}, "pool": {inotify.WithInstancePool(2)}} {
+		t.Run(name, func(t *testing.T) {
+			w, err := inotify.NewWatcher(opts...)
+			if err != nil {
+				t.Fatal("NewWatcher", err)
+			}
+			defer w.Close()
+			concurrentWatches(t, w)
+		})
+	}
+}
+
+func concurrentWatches(t *testing.T, w *inotify.Watcher) {
+	const dirs = 8
+	root := t.TempDir()
+	if err := w.AddRecursiveWatch(root, inotify.IN_CREATE); err != nil {
+		t.Fatal("AddRecursiveWatch", err)
+	}
+	for d := 0; d < dirs; d++ {
+		os.Mkdir(filepath.Join(root, strconv.Itoa(d)), 0755)
+	}
+	var wg sync.WaitGroup
+	stop := make(chan struct{})
+	loop := func(f func(i int)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; ; i++ {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				f(i)
+			}
+		}()
+	}
+	// 并发添加、修改、移除监听时事件照常读取
+	for g := 0; g < 4; g++ {
+		g := g
+		loop(func(i int) {
+			dir := filepath.Join(root, strconv.Itoa((g+i)%dirs))
+			switch i%5 {
+			case 0:
+				w.AddWatch(dir, inotify.IN_CREATE|inotify.IN_DELETE)
+			case 1:
+				w.AddWatchGroup(dir, "g"+strconv.Itoa(g), inotify.IN_MODIFY)
+			case 2:
+				w.UpdateWatch(dir, inotify.IN_DELETE)
+			case 3:
+				if f, err := w.WatchFile(filepath.Join(dir, "f"), inotify.IN_CLOSE_WRITE); err == nil {
+					f.Close()
+				}
+			default:
+				w.RemoveWatch(dir)
+			}
+		})
+	}
+	for g := 0; g < 2; g++ {
+		g := g
+		loop(func(i int) {
+			name := filepath.Join(root, strconv.Itoa(i%dirs), "w"+strconv.Itoa(g))
+			os.WriteFile(name, nil, 0644)
+			os.Remove(name)
+		})
+	}
+	loop(func(int) {
+		w.WaitEventTimeout(time.Millisecond*10)
+		w.Stats()
+		w.Healthy()
+	})
+	loop(func(int) {
+		w.TryEvent()
+	})
+	time.Sleep(time.Millisecond*300)
+	close(stop)
+	wg.Wait()
+}
+
+func TestNilWatcher(t *testing.T) {
+	for _, w := range []*inotify.Watcher{nil, {}} {
+		if err := w.AddWatch(t.TempDir(), inotify.IN_CREATE); err != inotify.ErrNotInitialized {
+			t.Fatal("AddWatch", err)
+		}
+		if _, err := w.WaitEvent(); err != inotify.ErrNotInitialized {
+			t.Fatal("WaitEvent", err)
+		}
+		if _, _, err := w.WaitEventTimeout(time.Millisecond); err != inotify.ErrNotInitialized {
+			t.Fatal("WaitEventTimeout", err)
+		}
+		if _, ok := w.TryEvent(); ok {
+			t.Fatal("TryEvent")
+		}
+		if err := w.PauseGroup("a"); err != inotify.ErrNotInitialized {
+			t.Fatal("PauseGroup", err)
+		}
+		if err := w.ResumeGroup("a"); err != inotify.ErrNotInitialized {
+			t.Fatal("ResumeGroup", err)
+		}
+		if err := w.RemoveGroup("a"); err != inotify.ErrNotInitialized {
+			t.Fatal("RemoveGroup", err)
+		}
+		w.Close()
+	}
+}
@@ -0,0 +1,165 @@
+//go:build linux
+// +build linux
+
+// @@
+// @ Author       : Eacher
+// @ Date         : 2026-10-24 12:17:54
+// @ LastEditTime : 2026-10-24 13:35:24
+// @ LastEditors  : Eacher
+// @ --------------------------------------------------------------------------------<
+// @ Description  : inotify daemon 配置加载、事件分发与 SIGHUP 重新加载测试
+// @ --------------------------------------------------------------------------------<
+// @ FilePath     : /inotify/examples/daemon_test.go
+// @@
+package inotify_test
+
+import (
+	"os"
+	"io"
+	"sync"
+	"time"
+	"strings"
+	"syscall"
+	"testing"
+	"net/http"
+	"path/filepath"
+	"net/http/httptest"
+	"github.com/20yyq/inotify"
+	"github.com/20yyq/inotify/internal/daemon"
+)
+
+func writeConfig(t *testing.T, file, data string) {
+	if err := os.WriteFile(file, []byte(data), 0644); err != nil {
+		t.Fatal("WriteFile", err)
+	}
+}
+
+func TestDaemonLoad(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "inotify.json")
+	if _, err := daemon.Load(file, false); err == nil {
+		t.Fatal("missing config")
+	}
+	bad := []string{
+		`{"watches": [`,
+		`{"watches": []}`,
+		`{"watches": [{"actions": [{"log": true}]}]}`,
+		`{"watches": [{"path": "` + dir + `", "events": ["bogus"], "actions": [{"log": true}]}]}`,
+		`{"watches": [{"path": "` + dir + `", "pattern": "[", "actions": [{"log": true}]}]}`,
+		`{"watches": [{"path": "` + dir + `", "actions": [{"exec": "echo {{.Nope}}"}]}]}`,
+		`{"watches": [{"path": "` + dir + `", "actions": [{"exec": ["true"], "policy": "later"}]}]}`,
+		`{"watches": [{"path": "` + dir + `", "actions": [{"webhook": "http://127.0.0.1/", "interval": "soon"}]}]}`,
+		`{"watches": [{"path": "` + dir + `", "actions": [{}]}]}`,
+		`{"watches": [{"path": "` + filepath.Join(dir, "missing") + `", "actions": [{"log": true}]}]}`,
+	}
+	for _, data := range bad {
+		writeConfig(t, file, data)
+		if d, err := daemon.Load(file, false); err == nil {
+			d.Stop()
+			t.Fatal("invalid config loaded", data)
+		}
+	}
+	writeConfig(t, file, `{"watches": [{"path": "`+dir+`", "recursive": true, "events": ["close_write"],
+		"actions": [{"exec": "echo {{.Path}}", "policy": "queue"}, {"log": true}]}]}`)
+	d, err := daemon.Load(file, true)
+	if err != nil {
+		t.Fatal("Load", err)
+	}
+	d.Stop()
+}
+
+func TestDaemonHandle(t *testing.T) {
+	var mutex sync.Mutex
+	var bodies []string
+	srv := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		mutex.Lock()
+		bodies = append(bodies, string(data))
+		mutex.Unlock()
+	}))
+	defer srv.Close()
+	dir, other := t.TempDir(), t.TempDir()
+	file := filepath.Join(dir, "inotify.json")
+	writeConfig(t, file, `{"watches": [{"path": "`+dir+`", "events": ["close_write"], "pattern": "*.conf",
+		"actions": [{"webhook": "`+srv.URL+`", "interval": "1h"}]}]}`)
+	d, err := daemon.Load(file, false)
+	if err != nil {
+		t.Fatal("Load", err)
+	}
+	tests := []struct {
+		e 		inotify.Event
+		matched bool
+	}{
+		{inotify.Event{FileName: filepath.Join(dir, "a.conf"), Raw: inotify.IN_CLOSE_WRITE}, true},
+		{inotify.Event{FileName: filepath.Join(dir, "a.txt"), Raw: inotify.IN_CLOSE_WRITE}, false},
+		{inotify.Event{FileName: filepath.Join(dir, "b.conf"), Raw: inotify.IN_CREATE}, false},
+		{inotify.Event{FileName: filepath.Join(other, "c.conf"), Raw: inotify.IN_CLOSE_WRITE}, false},
+		{inotify.Event{FileName: dir + "x/d.conf", Raw: inotify.IN_CLOSE_WRITE}, false},
+	}
+	for _, tt := range tests {
+		if matched := d.Handle(tt.e); matched != tt.matched {
+			t.Fatal("Handle", tt.e.FileName, tt.e.Raw, matched)
+		}
+	}
+	// Stop 发送剩余的通知
+	d.Stop()
+	mutex.Lock()
+	defer mutex.Unlock()
+	if len(bodies) != 1 || !strings.Contains(bodies[0], "a.conf") || strings.Contains(bodies[0], "a.txt") || strings.Contains(bodies[0], "c.conf") {
+		t.Fatal("webhook", bodies)
+	}
+}
+
+func TestDaemonReload(t *testing.T) {
+	first, second := t.TempDir(), t.TempDir()
+	file := filepath.Join(t.TempDir(), "inotify.json")
+	config := func(dir string) string {
+		return `{"watches": [{"path": "` + dir + `", "events": ["close_write"], "actions": [{"exec": ["true"]}]}]}`
+	}
+	writeConfig(t, file, config(first))
+	sig, matched, states := make(chan os.Signal), make(chan string, 10), make(chan string, 10)
+	done := make(chan error, 1)
+	go func() {
+		done <- daemon.Run(file, true, sig, func(line string) { matched <- line }, func(state string) { states <- state })
+	}()
+	wait := func(want string) {
+		select {
+		case s := <-states:
+			if s != want {
+				t.Fatal("state", s, want)
+			}
+		case <-time.After(time.Second*2):
+			t.Fatal("state timeout", want)
+		}
+	}
+	expect := func(dir, name string) {
+		os.WriteFile(filepath.Join(dir, name), nil, 0644)
+		select {
+		case line := <-matched:
+			if !strings.HasSuffix(line, filepath.Join(dir, name)) {
+				t.Fatal("matched", line)
+			}
+		case <-time.After(time.Second*2):
+			t.Fatal("no event for", dir, name)
+		}
+	}
+	wait("READY=1")
+	expect(first, "a")
+	// 新配置有误时继续使用旧配置
+	writeConfig(t, file, `{"watches": `)
+	sig <- syscall.SIGHUP
+	wait("RELOADING=1")
+	wait("READY=1")
+	expect(first, "b")
+	writeConfig(t, file, config(second))
+	sig <- syscall.SIGHUP
+	wait("RELOADING=1")
+	wait("READY=1")
+	os.WriteFile(filepath.Join(first, "c"), nil, 0644)
+	expect(second, "d")
+	sig <- syscall.SIGTERM
+	wait("STOPPING=1")
+	if err := <-done; err != nil {
+		t.Fatal("Run", err)
+	}
+}
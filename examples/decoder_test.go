@@ -0,0 +1,104 @@
+//go:build linux
+// +build linux
+
+// @@
+// @ Author       : Eacher
+// @ Date         : 2026-10-21 14:41:14
+// @ LastEditTime : 2026-10-21 15:16:38
+// @ LastEditors  : Eacher
+// @ --------------------------------------------------------------------------------<
+// @ Description  : Decoder 测试，go test -fuzz FuzzDecoder ./examples 运行随机数据
+// @ --------------------------------------------------------------------------------<
+// @ FilePath     : /inotify/examples/decoder_test.go
+// @@
+package inotify_test
+
+import (
+	"io"
+	"os"
+	"testing"
+	"path/filepath"
+	"golang.org/x/sys/unix"
+	"github.com/20yyq/inotify"
+)
+
+// kernelRecords 由内核产生的 CREATE 与 DELETE 事件的原始数据
+func kernelRecords(t testing.TB) []byte {
+	fd, err := unix.InotifyInit1(unix.IN_CLOEXEC|unix.IN_NONBLOCK)
+	if err != nil {
+		t.Fatal("InotifyInit1", err)
+	}
+	defer unix.Close(fd)
+	dir := t.TempDir()
+	if _, err = unix.InotifyAddWatch(fd, dir, unix.IN_CREATE|unix.IN_DELETE); err != nil {
+		t.Fatal("InotifyAddWatch", err)
+	}
+	os.WriteFile(filepath.Join(dir, "a"), nil, 0644)
+	os.Remove(filepath.Join(dir, "a"))
+	buf := make([]byte, 4096)
+	n, err := unix.Read(fd, buf)
+	if err != nil {
+		t.Fatal("Read", err)
+	}
+	return buf[:n]
+}
+
+func TestDecoder(t *testing.T) {
+	buf := kernelRecords(t)
+	d := inotify.NewDecoder(buf)
+	for _, mask := range []uint32{inotify.IN_CREATE, inotify.IN_DELETE} {
+		r, err := d.Next()
+		if err != nil || r.Mask != mask || r.Base() != "a" || int(r.Len) != len(r.Name) {
+			t.Fatal("Next", r, err)
+		}
+	}
+	if _, err := d.Next(); err != io.EOF {
+		t.Fatal("EOF", err)
+	}
+	// 短读: 第二个事件只有一部分
+	d.Reset(buf[:len(buf)-3])
+	d.Next()
+	if _, err := d.Next(); err != io.ErrUnexpectedEOF || len(d.Buffered()) != len(buf)/2-3 {
+		t.Fatal("short read", err, len(d.Buffered()))
+	}
+	// 拼接剩下的数据后完整
+	d.Reset(append(append([]byte(nil), d.Buffered()...), buf[len(buf)-3:]...))
+	if r, err := d.Next(); err != nil || r.Mask != inotify.IN_DELETE {
+		t.Fatal("resumed", r, err)
+	}
+}
+
+func FuzzDecoder(f *testing.F) {
+	buf := kernelRecords(f)
+	f.Add(buf)
+	f.Add(buf[:len(buf)-1])
+	f.Add(buf[:unix.SizeofInotifyEvent-1])
+	// Len 为 0xffffffff
+	f.Add(append(append([]byte(nil), buf[:12]...), 0xff, 0xff, 0xff, 0xff))
+	f.Fuzz(func(t *testing.T, data []byte) {
+		d := inotify.NewDecoder(data)
+		n := 0
+		for {
+			r, err := d.Next()
+			if err == io.EOF {
+				if n != len(data) {
+					t.Fatal("EOF before the end", n, len(data))
+				}
+				return
+			}
+			if err == io.ErrUnexpectedEOF {
+				if rest := len(d.Buffered()); n+rest != len(data) || rest == 0 {
+					t.Fatal("Buffered", n, rest, len(data))
+				}
+				if _, err = d.Next(); err != io.ErrUnexpectedEOF {
+					t.Fatal("Next after ErrUnexpectedEOF", err)
+				}
+				return
+			}
+			if err != nil || int(r.Len) != len(r.Name) {
+				t.Fatal("Next", r.Len, len(r.Name), err)
+			}
+			n += unix.SizeofInotifyEvent+int(r.Len)
+		}
+	})
+}
@@ -0,0 +1,79 @@
+//go:build linux
+// +build linux
+
+// @@
+// @ Author       : Eacher
+// @ Date         : 2026-10-17 13:22:28
+// @ LastEditTime : 2026-10-17 14:04:17
+// @ LastEditors  : Eacher
+// @ --------------------------------------------------------------------------------<
+// @ Description  : protobuf 编码测试
+// @ --------------------------------------------------------------------------------<
+// @ FilePath     : /inotify/examples/eventpb_test.go
+// @@
+package inotify_test
+
+import (
+	"io"
+	"time"
+	"bytes"
+	"testing"
+	"github.com/20yyq/inotify"
+	"github.com/20yyq/inotify/eventpb"
+)
+
+func TestEventPBWire(t *testing.T) {
+	// 与 protoc 生成的代码编码结果相同: path="/a" op=1 raw_mask=0x100 seq=1
+	m := &eventpb.Event{Path: "/a", Op: 1, RawMask: 0x100, Seq: 1}
+	want := []byte{0x0a, 0x02, '/', 'a', 0x10, 0x01, 0x18, 0x80, 0x02, 0x38, 0x01}
+	if got := m.Marshal(); !bytes.Equal(got, want) {
+		t.Fatalf("Marshal % x, want % x", got, want)
+	}
+	// 未知字段(field 15)跳过
+	var e eventpb.Event
+	if err := e.Unmarshal(append(want, 0x78, 0x05)); err != nil || e != *m {
+		t.Fatal("Unmarshal", e, err)
+	}
+	if err := e.Unmarshal(want[:3]); err == nil {
+		t.Fatal("Unmarshal truncated")
+	}
+}
+
+func TestEventPBStream(t *testing.T) {
+	now := time.Now()
+	events := []inotify.Event{
+		{FileName: "/tmp/a", Op: inotify.Create, Raw: inotify.IN_CREATE|inotify.IN_ISDIR, Time: now, Seq: 1, Group: "g"},
+		{FileName: "/tmp/b", Op: inotify.Rename, Raw: inotify.IN_MOVED_FROM, Cookie: 7, Seq: 2, Count: 3},
+	}
+	var buf bytes.Buffer
+	w := eventpb.NewWriter(&buf)
+	batch := &eventpb.EventBatch{}
+	for _, e := range events {
+		batch.Events = append(batch.Events, eventpb.FromEvent(e))
+	}
+	if err := w.Write(batch); err != nil {
+		t.Fatal("Write", err)
+	}
+	if err := w.Write(&eventpb.Watch{Path: "/tmp", Flags: inotify.IN_CREATE, Recursive: true}); err != nil {
+		t.Fatal("Write", err)
+	}
+	r := eventpb.NewReader(&buf)
+	var got eventpb.EventBatch
+	if err := r.Read(&got); err != nil || len(got.Events) != 2 {
+		t.Fatal("Read batch", got, err)
+	}
+	for i, m := range got.Events {
+		e := m.Event()
+		if e.FileName != events[i].FileName || e.Op != events[i].Op || e.Raw != events[i].Raw || e.Cookie != events[i].Cookie ||
+			e.Seq != events[i].Seq || e.Group != events[i].Group || e.Count != events[i].Count || !e.Time.Equal(events[i].Time) {
+			t.Fatal("event", i, e, events[i])
+		}
+	}
+	var watch eventpb.Watch
+	if err := r.Read(&watch); err != nil || watch.Path != "/tmp" || !watch.Recursive || watch.Flags != inotify.IN_CREATE {
+		t.Fatal("Read watch", watch, err)
+	}
+	if err := r.Read(&watch); err != io.EOF {
+		t.Fatal("Read end", err)
+	}
+}
@@ -0,0 +1,226 @@
+//go:build linux
+// +build linux
+
+// @@
+// @ Author       : Eacher
+// @ Date         : 2026-10-15 17:59:19
+// @ LastEditTime : 2026-10-21 18:11:50
+// @ LastEditors  : Eacher
+// @ --------------------------------------------------------------------------------<
+// @ Description  : 外部事件循环与 RegisterFd 测试
+// @ --------------------------------------------------------------------------------<
+// @ FilePath     : /inotify/examples/external_test.go
+// @@
+package inotify_test
+
+import (
+	"os"
+	"time"
+	"syscall"
+	"testing"
+	"path/filepath"
+	"golang.org/x/sys/unix"
+	"github.com/20yyq/inotify"
+)
+
+func TestExternalLoop(t *testing.T) {
+	w, err := inotify.NewWatcher(inotify.WithExternalLoop())
+	if err != nil {
+		t.Fatal("NewWatcher", err)
+	}
+	dir := t.TempDir()
+	if err = w.AddWatch(dir, inotify.IN_CREATE); err != nil {
+		t.Fatal("AddWatch", err)
+	}
+	epfd, err := syscall.EpollCreate1(syscall.EPOLL_CLOEXEC)
+	if err != nil {
+		t.Fatal("EpollCreate1", err)
+	}
+	defer syscall.Close(epfd)
+	if err = syscall.EpollCtl(epfd, syscall.EPOLL_CTL_ADD, w.Fd(), &syscall.EpollEvent{Fd: int32(w.Fd()), Events: syscall.EPOLLIN}); err != nil {
+		t.Fatal("EpollCtl", err)
+	}
+	buf := make([]inotify.Event, 2)
+	if n, err := w.ReadEvents(buf); n != 0 || err != nil {
+		t.Fatal("ReadEvents without event", n, err)
+	}
+	for i := 0; i < 3; i++ {
+		os.WriteFile(filepath.Join(dir, string(rune('a'+i))), nil, 0644)
+	}
+	events := make([]syscall.EpollEvent, 1)
+	if n, err := syscall.EpollWait(epfd, events, 1000); n != 1 || err != nil {
+		t.Fatal("EpollWait", n, err)
+	}
+	var names []string
+	for len(names) < 3 {
+		n, err := w.ReadEvents(buf)
+		if err != nil || n == 0 {
+			t.Fatal("ReadEvents", n, err)
+		}
+		for _, e := range buf[:n] {
+			names = append(names, filepath.Base(e.FileName))
+		}
+	}
+	if names[0] != "a" || names[1] != "b" || names[2] != "c" {
+		t.Fatal("ReadEvents events", names)
+	}
+	w.Close()
+	if _, err := w.ReadEvents(buf); err != inotify.ErrClosed || w.Fd() != -1 {
+		t.Fatal("ReadEvents after Close", err, w.Fd())
+	}
+}
+
+func TestFdFlags(t *testing.T) {
+	cloexec := func(w *inotify.Watcher) bool {
+		flags, err := unix.FcntlInt(uintptr(w.Fd()), unix.F_GETFD, 0)
+		if err != nil {
+			t.Fatal("F_GETFD", err)
+		}
+		return flags&unix.FD_CLOEXEC != 0
+	}
+	w, err := inotify.NewWatcher(inotify.WithExternalLoop())
+	if err != nil {
+		t.Fatal("NewWatcher", err)
+	}
+	if !cloexec(w) {
+		t.Fatal("default flags without FD_CLOEXEC")
+	}
+	w.Close()
+	// 阻塞的 fd，继承到子进程
+	if w, err = inotify.NewWatcher(inotify.WithExternalLoop(), inotify.WithFdFlags(0, 0)); err != nil {
+		t.Fatal("NewWatcher", err)
+	}
+	defer w.Close()
+	if cloexec(w) {
+		t.Fatal("WithFdFlags(0, 0) with FD_CLOEXEC")
+	}
+	dir := t.TempDir()
+	if err = w.AddWatch(dir, inotify.IN_CREATE); err != nil {
+		t.Fatal("AddWatch", err)
+	}
+	go func() {
+		time.Sleep(50*time.Millisecond)
+		os.WriteFile(filepath.Join(dir, "a"), nil, 0644)
+	}()
+	buf := make([]inotify.Event, 4)
+	if n, err := w.ReadEvents(buf); n != 1 || err != nil || filepath.Base(buf[0].FileName) != "a" {
+		t.Fatal("blocking ReadEvents", n, err)
+	}
+}
+
+func TestBlockingRead(t *testing.T) {
+	w, err := inotify.NewWatcher(inotify.WithBlockingRead())
+	if err != nil {
+		t.Fatal("NewWatcher", err)
+	}
+	if flags, err := unix.FcntlInt(uintptr(w.Fd()), unix.F_GETFL, 0); err != nil || flags&unix.O_NONBLOCK != 0 {
+		t.Fatal("F_GETFL", flags, err)
+	}
+	dir := t.TempDir()
+	if err = w.AddWatch(dir, inotify.IN_CREATE); err != nil {
+		t.Fatal("AddWatch", err)
+	}
+	type result struct {
+		n 	int
+		err error
+	}
+	buf := make([]inotify.Event, 4)
+	read := func() chan result {
+		ch := make(chan result, 1)
+		go func() {
+			n, err := w.ReadEvents(buf)
+			ch <- result{n, err}
+		}()
+		return ch
+	}
+	ch := read()
+	time.Sleep(50*time.Millisecond)
+	// 阻塞读取时不持有锁
+	other := t.TempDir()
+	if err = w.AddWatch(other, inotify.IN_CREATE); err != nil {
+		t.Fatal("AddWatch while reading", err)
+	}
+	os.WriteFile(filepath.Join(other, "a"), nil, 0644)
+	select {
+	case r := <-ch:
+		if r.n != 1 || r.err != nil || filepath.Base(buf[0].FileName) != "a" {
+			t.Fatal("ReadEvents", r.n, r.err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("ReadEvents timeout")
+	}
+	// Close 使阻塞的 ReadEvents 返回
+	ch = read()
+	time.Sleep(50*time.Millisecond)
+	closed := make(chan error, 1)
+	go func() { closed <- w.Close() }()
+	select {
+	case r := <-ch:
+		if r.n != 0 || r.err != inotify.ErrClosed {
+			t.Fatal("ReadEvents after Close", r.n, r.err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("ReadEvents not interrupted by Close")
+	}
+	if err = <-closed; err != nil || w.Fd() != -1 {
+		t.Fatal("Close", err, w.Fd())
+	}
+}
+
+func TestRegisterFd(t *testing.T) {
+	w, err := inotify.NewWatcher()
+	if err != nil {
+		t.Fatal("NewWatcher", err)
+	}
+	defer w.Close()
+	efd, err := unix.Eventfd(0, unix.EFD_CLOEXEC|unix.EFD_NONBLOCK)
+	if err != nil {
+		t.Fatal("Eventfd", err)
+	}
+	defer unix.Close(efd)
+	dir := t.TempDir()
+	got := make(chan uint64, 4)
+	err = w.RegisterFd(efd, unix.EPOLLIN, func(fd int, events uint32) {
+		var b [8]byte
+		if _, err := unix.Read(fd, b[:]); err != nil || events&unix.EPOLLIN == 0 {
+			t.Error("handler", events, err)
+		}
+		// handler 中可以调用 Watcher 的方法
+		if err := w.AddWatch(dir, inotify.IN_CREATE); err != nil {
+			t.Error("AddWatch in handler", err)
+		}
+		got <- uint64(b[0])
+	})
+	if err != nil {
+		t.Fatal("RegisterFd", err)
+	}
+	if err = w.RegisterFd(w.Fd(), unix.EPOLLIN, func(int, uint32) {}); err == nil {
+		t.Fatal("RegisterFd inotify fd")
+	}
+	unix.Write(efd, []byte{3, 0, 0, 0, 0, 0, 0, 0})
+	select {
+	case v := <-got:
+		if v != 3 {
+			t.Fatal("eventfd value", v)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("handler not called")
+	}
+	// 文件事件与 fd 共用同一个循环
+	os.WriteFile(filepath.Join(dir, "a"), nil, 0644)
+	if e, ok, err := w.WaitEventTimeout(time.Second); !ok || err != nil || filepath.Base(e.FileName) != "a" {
+		t.Fatal("WaitEventTimeout", e, err)
+	}
+	if err = w.UnregisterFd(efd); err != nil {
+		t.Fatal("UnregisterFd", err)
+	}
+	if err = w.UnregisterFd(efd); err == nil {
+		t.Fatal("UnregisterFd twice")
+	}
+	unix.Write(efd, []byte{1, 0, 0, 0, 0, 0, 0, 0})
+	select {
+	case v := <-got:
+		t.Fatal("handler after UnregisterFd", v)
+	case <-time.After(100*time.Millisecond):
+	}
+}
@@ -0,0 +1,170 @@
+//go:build linux
+// +build linux
+
+// @@
+// @ Author       : Eacher
+// @ Date         : 2026-10-18 10:40:05
+// @ LastEditTime : 2026-10-23 11:25:17
+// @ LastEditors  : Eacher
+// @ --------------------------------------------------------------------------------<
+// @ Description  : 内存中的 WatchBackend 测试
+// @ --------------------------------------------------------------------------------<
+// @ FilePath     : /inotify/examples/fakes_test.go
+// @@
+package inotify_test
+
+import (
+	"sync"
+	"time"
+	"errors"
+	"syscall"
+	"testing"
+	"github.com/20yyq/inotify"
+	"github.com/20yyq/inotify/fakes"
+)
+
+func TestFakeBackend(t *testing.T) {
+	b, err := fakes.NewBackend()
+	if err != nil {
+		t.Fatal("NewBackend", err)
+	}
+	w, err := inotify.NewWatcher(inotify.WithBackend(b))
+	if err != nil {
+		t.Fatal("NewWatcher", err)
+	}
+	defer w.Close()
+	// 路径不存在于文件系统中
+	if err = w.AddWatch("/no/such/dir", inotify.IN_CREATE|inotify.IN_MOVE|inotify.IN_DELETE_SELF); err != nil {
+		t.Fatal("AddWatch", err)
+	}
+	next := func() inotify.Event {
+		e, ok, err := w.WaitEventTimeout(time.Second)
+		if !ok || err != nil {
+			t.Fatal("WaitEventTimeout", ok, err)
+		}
+		return e
+	}
+	if b.Emit("/no/such/dir/a", inotify.IN_CREATE) != 1 || b.Emit("/no/such/dir/a", inotify.IN_MODIFY) != 0 {
+		t.Fatal("Emit")
+	}
+	if e := next(); e.FileName != "/no/such/dir/a" || e.Op != inotify.Create || e.Seq != 1 {
+		t.Fatal("create", e)
+	}
+	b.File("/no/such/dir/a")
+	b.Rename("/no/such/dir/a", "/no/such/dir/b")
+	from, to := next(), next()
+	if from.Raw != inotify.IN_MOVED_FROM || to.Raw != inotify.IN_MOVED_TO || to.FileName != "/no/such/dir/b" || from.Cookie == 0 || from.Cookie != to.Cookie {
+		t.Fatal("rename", from, to)
+	}
+	b.Overflow()
+	if e := next(); e.Raw != inotify.IN_Q_OVERFLOW {
+		t.Fatal("overflow", e)
+	}
+	b.Emit("/no/such/dir", inotify.IN_DELETE_SELF)
+	if e := next(); e.Raw != inotify.IN_DELETE_SELF {
+		t.Fatal("delete self", e)
+	}
+	if e := next(); e.Raw != inotify.IN_IGNORED || len(b.Watches()) != 0 {
+		t.Fatal("ignored", e, b.Watches())
+	}
+}
+
+func TestWatchDescriptorReuse(t *testing.T) {
+	b, err := fakes.NewBackend()
+	if err != nil {
+		t.Fatal("NewBackend", err)
+	}
+	b.ReuseDescriptors()
+	w, err := inotify.NewWatcher(inotify.WithBackend(b))
+	if err != nil {
+		t.Fatal("NewWatcher", err)
+	}
+	defer w.Close()
+	next := func(mask uint32, name string, data any) {
+		e, ok, err := w.WaitEventTimeout(time.Second)
+		if !ok || err != nil || e.Raw != mask || e.FileName != name || e.Data != data {
+			t.Fatal("event", e, ok, err, mask, name, data)
+		}
+	}
+	if err = w.AddWatchData("/a", inotify.IN_CREATE, "a"); err != nil {
+		t.Fatal("AddWatchData", err)
+	}
+	var prev any = "a"
+	for i := 0; i < 100; i++ {
+		old, path := "/a", "/b"
+		if i%2 == 1 {
+			old, path = path, old
+		}
+		// 旧监听的 IN_IGNORED 还未读到时新监听已得到相同的 wd，新监听没有 data 时不能继承旧的
+		w.RemoveWatch(old)
+		var data any
+		if i%3 == 0 {
+			err = w.AddWatch(path, inotify.IN_CREATE)
+		} else {
+			data = path
+			err = w.AddWatchData(path, inotify.IN_CREATE, data)
+		}
+		if err != nil {
+			t.Fatal("AddWatch", err)
+		}
+		if ws := b.Watches(); len(ws) != 1 {
+			t.Fatal("wd not reused", ws)
+		}
+		b.Emit(path+"/f", inotify.IN_CREATE)
+		next(inotify.IN_IGNORED, old+"/", prev)
+		next(inotify.IN_CREATE, path+"/f", data)
+		if err = w.RemoveWatch(old); err == nil {
+			t.Fatal("old path still watched", i)
+		}
+		if s := w.Stats(); s.Watches != 1 {
+			t.Fatal("Watches", s.Watches)
+		}
+		prev = data
+	}
+}
+
+// failingBackend 下一次 ReadEvents 返回 err
+type failingBackend struct {
+	*fakes.Backend
+	mutex 	sync.Mutex
+	err 	error
+}
+
+func (b *failingBackend) ReadEvents(buf []byte) (int, error) {
+	b.mutex.Lock()
+	err := b.err
+	b.err = nil
+	b.mutex.Unlock()
+	if err != nil {
+		return 0, err
+	}
+	return b.Backend.ReadEvents(buf)
+}
+
+func TestReadError(t *testing.T) {
+	fb, err := fakes.NewBackend()
+	if err != nil {
+		t.Fatal("NewBackend", err)
+	}
+	b := &failingBackend{Backend: fb, err: syscall.EIO}
+	w, err := inotify.NewWatcher(inotify.WithBackend(b))
+	if err != nil {
+		t.Fatal("NewWatcher", err)
+	}
+	defer w.Close()
+	if err = w.AddWatch("/no/such/dir", inotify.IN_CREATE); err != nil {
+		t.Fatal("AddWatch", err)
+	}
+	// 读取的错误交给读取者，不输出到 stdout
+	b.Emit("/no/such/dir/a", inotify.IN_CREATE)
+	if _, ok, err := w.WaitEventTimeout(time.Second); ok || !errors.Is(err, syscall.EIO) {
+		t.Fatal("WaitEventTimeout", ok, err)
+	}
+	// 之后的读取正常，未读取的事件没有丢失
+	b.Emit("/no/such/dir/b", inotify.IN_CREATE)
+	for _, name := range []string{"/no/such/dir/a", "/no/such/dir/b"} {
+		if e, ok, err := w.WaitEventTimeout(time.Second); !ok || err != nil || e.FileName != name {
+			t.Fatal("WaitEventTimeout", name, ok, err)
+		}
+	}
+}
@@ -0,0 +1,163 @@
+//go:build linux
+// +build linux
+
+// @@
+// @ Author       : Eacher
+// @ Date         : 2026-10-22 14:17:17
+// @ LastEditTime : 2026-10-24 17:33:19
+// @ LastEditors  : Eacher
+// @ --------------------------------------------------------------------------------<
+// @ Description  : io/fs 适配测试
+// @ --------------------------------------------------------------------------------<
+// @ FilePath     : /inotify/examples/fs_test.go
+// @@
+package inotify_test
+
+import (
+	"os"
+	"time"
+	"testing"
+	"strconv"
+	"testing/fstest"
+	"path/filepath"
+	"github.com/20yyq/inotify"
+)
+
+func TestWatchFS(t *testing.T) {
+	dir := t.TempDir()
+	f, err := inotify.NewFS(dir)
+	if err != nil {
+		t.Fatal("NewFS", err)
+	}
+	defer f.Close()
+	w, err := inotify.NewWatcher()
+	if err != nil {
+		t.Fatal("NewWatcher", err)
+	}
+	defer w.Close()
+	// os.DirFS 等没有对应目录的 fs.FS
+	if err = w.WatchFS(os.DirFS(dir), "."); err == nil {
+		t.Fatal("WatchFS os.DirFS")
+	}
+	if err = w.WatchFS(fstest.MapFS{}, "."); err == nil {
+		t.Fatal("WatchFS fstest.MapFS")
+	}
+	if err = w.WatchFS(f, "../x"); err == nil {
+		t.Fatal("WatchFS invalid path")
+	}
+	if err = w.WatchFS(f, "."); err != nil {
+		t.Fatal("WatchFS", err)
+	}
+	os.Mkdir(filepath.Join(dir, "a"), 0755)
+	if e, ok, err := w.WaitEventTimeout(time.Second); !ok || err != nil || e.FileName != filepath.Join(dir, "a") || !e.IsDir() {
+		t.Fatal("WaitEventTimeout", e.FileName, ok, err)
+	}
+}
+
+func TestDirFS(t *testing.T) {
+	dir := t.TempDir()
+	os.Mkdir(filepath.Join(dir, "a"), 0755)
+	os.WriteFile(filepath.Join(dir, "a", "b"), []byte("1"), 0644)
+	// 相对路径的目录转为绝对路径
+	wd, _ := os.Getwd()
+	defer os.Chdir(wd)
+	os.Chdir(dir)
+	fsys := inotify.DirFS(".")
+	if fsys.OSDir() != dir {
+		t.Fatal("OSDir", fsys.OSDir())
+	}
+	os.Chdir(wd)
+	// 与 os.DirFS 相同的读取
+	if err := fstest.TestFS(inotify.DirFS(dir), "a/b"); err != nil {
+		t.Fatal("TestFS", err)
+	}
+	w, err := inotify.NewWatcher()
+	if err != nil {
+		t.Fatal("NewWatcher", err)
+	}
+	defer w.Close()
+	if err = w.WatchFS(fsys, "a"); err != nil {
+		t.Fatal("WatchFS", err)
+	}
+	os.WriteFile(filepath.Join(dir, "a", "c"), nil, 0644)
+	if e, ok, err := w.WaitEventTimeout(time.Second); !ok || err != nil || e.FileName != filepath.Join(dir, "a", "c") {
+		t.Fatal("WaitEventTimeout", e.FileName, ok, err)
+	}
+}
+
+func TestFSCache(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "a"), []byte("1"), 0644)
+	f, err := inotify.NewFS(dir)
+	if err != nil {
+		t.Fatal("NewFS", err)
+	}
+	defer f.Close()
+	if _, err = inotify.NewFS(filepath.Join(dir, "a")); err == nil {
+		t.Fatal("NewFS not a directory")
+	}
+	list, err := f.ReadDir(".")
+	if err != nil || len(list) != 1 || list[0].Name() != "a" {
+		t.Fatal("ReadDir", list, err)
+	}
+	os.Mkdir(filepath.Join(dir, "b"), 0755)
+	if e, err := f.WaitEvent(); err != nil || e.Name != "b" || e.Mask&inotify.IN_CREATE == 0 {
+		t.Fatal("WaitEvent", e, err)
+	}
+	// 事件之后缓存已失效
+	if list, err = f.ReadDir("."); err != nil || len(list) != 2 || list[1].Name() != "b" {
+		t.Fatal("ReadDir after CREATE", list, err)
+	}
+	// 子目录首次读取时开始监听
+	if list, err = f.ReadDir("b"); err != nil || len(list) != 0 {
+		t.Fatal("ReadDir b", list, err)
+	}
+	os.WriteFile(filepath.Join(dir, "b", "c"), []byte("2"), 0644)
+	for {
+		e, err := f.WaitEvent()
+		if err != nil {
+			t.Fatal("WaitEvent", err)
+		}
+		if e.Name == "b/c" && e.Mask&inotify.IN_CLOSE_WRITE != 0 {
+			break
+		}
+	}
+	if list, err = f.ReadDir("b"); err != nil || len(list) != 1 || list[0].Name() != "c" {
+		t.Fatal("ReadDir b after CREATE", list, err)
+	}
+	if b, err := f.ReadFile("b/c"); err != nil || string(b) != "2" {
+		t.Fatal("ReadFile", string(b), err)
+	}
+	os.WriteFile(filepath.Join(dir, "a"), []byte("3"), 0644)
+	if b, err := f.ReadFile("a"); err != nil || string(b) != "3" {
+		t.Fatal("ReadFile after write", string(b), err)
+	}
+	f.Close()
+	for {
+		if _, err := f.WaitEvent(); err == inotify.ErrClosed {
+			break
+		}
+	}
+}
+
+func TestFSEventsOverflow(t *testing.T) {
+	dir := t.TempDir()
+	f, err := inotify.NewFS(dir)
+	if err != nil {
+		t.Fatal("NewFS", err)
+	}
+	defer f.Close()
+	if _, err = f.ReadDir("."); err != nil {
+		t.Fatal("ReadDir", err)
+	}
+	for i := 0; i < 15; i++ {
+		os.Mkdir(filepath.Join(dir, strconv.Itoa(i)), 0755)
+	}
+	time.Sleep(100*time.Millisecond)
+	// 只保留最近 10 个事件
+	for i := 5; i < 15; i++ {
+		if e, err := f.WaitEvent(); err != nil || e.Name != strconv.Itoa(i) {
+			t.Fatal("WaitEvent", i, e, err)
+		}
+	}
+}
@@ -1,7 +1,7 @@
 // @@
 // @ Author       : Eacher
 // @ Date         : 2023-02-20 08:50:39
-// @ LastEditTime : 2023-02-28 10:10:49
+// @ LastEditTime : 2026-10-14 09:41:39
 // @ LastEditors  : Eacher
 // @ --------------------------------------------------------------------------------<
 // @ Description  : Linux inotify 使用例子
@@ -11,12 +11,16 @@
 package inotify_test
 
 import (
+	"runtime"
 	"testing"
 	"time"
 	"github.com/20yyq/inotify"
 )
 
 func TestLinux(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("linux only")
+	}
 	w, err := inotify.NewWatcher()
 	if err != nil {
 		t.Log("NewWatcher err", err)
@@ -24,7 +28,10 @@ func TestLinux(t *testing.T) {
 	}
 	// err = w.AddWatch(`/mnt/veryark/develop_serial/test.go`, inotify.IN_DELETE|inotify.IN_CREATE|inotify.IN_MODIFY|inotify.IN_ATTRIB|inotify.IN_CLOSE_WRITE)
 	err = w.AddWatch(`/mnt/veryark/develop_serial`, inotify.IN_OPEN|inotify.IN_DELETE|inotify.IN_CREATE|inotify.IN_MODIFY|inotify.IN_ATTRIB|inotify.IN_CLOSE_WRITE|inotify.IN_CLOSE|inotify.IN_DELETE_SELF|inotify.IN_MOVED_FROM|inotify.IN_MOVED_TO|inotify.IN_MOVE|inotify.IN_MOVE_SELF)
-	t.Log("start", err)
+	if err != nil {
+		t.Skip("AddWatch", err)
+	}
+	t.Log("start")
 	for {
 		ws, err := w.WaitEvent()
 		if err != nil {
@@ -34,10 +41,12 @@ func TestLinux(t *testing.T) {
 		}
 		t.Log("WaitEvent:", ws.Mask, ws.FileName, ws.GetEventName())
 	}
-	t.Log("end")
 }
 
 func TestWindows(t *testing.T) {
+	if runtime.GOOS != "windows" {
+		t.Skip("windows only")
+	}
 	w, err := inotify.NewWatcher()
 	if err != nil {
 		t.Log("NewWatcher err", err)
@@ -45,7 +54,10 @@ func TestWindows(t *testing.T) {
 	}
 	err = w.AddWatch(`E:\tmp`, inotify.IN_DELETE|inotify.IN_CREATE|inotify.IN_MODIFY|inotify.IN_ATTRIB|inotify.IN_CLOSE_WRITE)
 	// err = w.AddWatch(`E:\tmp\adapter.js`, 0)
-	t.Log("start", err)
+	if err != nil {
+		t.Skip("AddWatch", err)
+	}
+	t.Log("start")
 	for {
 		e, err := w.WaitEvent()
 		if err != nil {
@@ -56,5 +68,4 @@ func TestWindows(t *testing.T) {
 		// err = w.AddWatch(`E:\tmp`, inotify.IN_DELETE|inotify.IN_ATTRIB)
 		t.Log("WaitEvent:", e.Mask, e.FileName, e.GetEventName())
 	}
-	t.Log("end")
 }
\ No newline at end of file
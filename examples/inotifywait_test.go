@@ -0,0 +1,101 @@
+//go:build linux
+// +build linux
+
+// @@
+// @ Author       : Eacher
+// @ Date         : 2026-10-24 15:35:56
+// @ LastEditTime : 2026-10-24 16:04:04
+// @ LastEditors  : Eacher
+// @ --------------------------------------------------------------------------------<
+// @ Description  : goinotifywait 的 --format 输出与退出码测试
+// @ --------------------------------------------------------------------------------<
+// @ FilePath     : /inotify/examples/inotifywait_test.go
+// @@
+package inotify_test
+
+import (
+	"os"
+	"time"
+	"syscall"
+	"testing"
+	"path/filepath"
+	"github.com/20yyq/inotify"
+	"github.com/20yyq/inotify/internal/inotifywait"
+)
+
+func TestInotifywaitRender(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "watched.txt")
+	os.WriteFile(file, nil, 0644)
+	watched := map[string]bool{dir: true, file: true}
+	at := time.Date(2026, 10, 24, 15, 35, 56, 0, time.UTC)
+	tests := []struct {
+		format 	string
+		e 		inotify.Event
+		want 	string
+	}{
+		{"%w %e %f", inotify.Event{FileName: filepath.Join(dir, "a"), Raw: inotify.IN_CREATE}, dir + "/ CREATE a"},
+		{"%w|%f|%e", inotify.Event{FileName: filepath.Join(dir, "d"), Raw: inotify.IN_CREATE|inotify.IN_ISDIR}, dir + "/|d|CREATE,ISDIR"},
+		// 监听的目录与文件本身的事件 %f 为空，目录以分隔符结尾
+		{"%w|%f", inotify.Event{FileName: dir, Raw: inotify.IN_ATTRIB}, dir + "/|"},
+		{"%w|%f", inotify.Event{FileName: file, Raw: inotify.IN_CLOSE_WRITE}, file + "|"},
+		// %T 为事件的时间
+		{"%T %f", inotify.Event{FileName: filepath.Join(dir, "a"), Raw: inotify.IN_MODIFY, Time: at}, "2026-10-24T15:35:56Z a"},
+		{"100%% %x %", inotify.Event{FileName: filepath.Join(dir, "a"), Raw: inotify.IN_MODIFY}, "100% %x %"},
+	}
+	for _, tt := range tests {
+		if got := inotifywait.Render(tt.format, time.RFC3339, watched, tt.e); got != tt.want {
+			t.Fatalf("Render(%q) = %q, want %q", tt.format, got, tt.want)
+		}
+	}
+}
+
+func TestInotifywaitExit(t *testing.T) {
+	newWatcher := func(dir string) *inotify.Watcher {
+		w, err := inotify.NewWatcher()
+		if err != nil {
+			t.Fatal("NewWatcher", err)
+		}
+		t.Cleanup(func() { w.Close() })
+		if err = w.AddWatch(dir, inotify.IN_ALL_EVENTS); err != nil {
+			t.Fatal("AddWatch", err)
+		}
+		return w
+	}
+	// 没有事件时超时返回 2
+	dir := t.TempDir()
+	wt := &inotifywait.Waiter{Mask: inotify.IN_ALL_EVENTS, Timeout: time.Millisecond*100, Print: func(inotify.Event) {}}
+	if code := wt.Wait(newWatcher(dir), nil); code != inotifywait.ExitTimeout {
+		t.Fatal("timeout", code)
+	}
+	// Mask 之外的事件不算，收到第一个事件后返回 0
+	w := newWatcher(dir)
+	var got []inotify.Event
+	wt = &inotifywait.Waiter{Mask: inotify.IN_CLOSE_WRITE, Timeout: time.Second*2, Print: func(e inotify.Event) { got = append(got, e) }}
+	os.WriteFile(filepath.Join(dir, "a"), nil, 0644)
+	if code := wt.Wait(w, nil); code != inotifywait.ExitEvent || len(got) != 1 || got[0].Raw != inotify.IN_CLOSE_WRITE {
+		t.Fatal("event", code, got)
+	}
+	// Monitor 时直到信号返回 0
+	stop := make(chan os.Signal, 1)
+	got = nil
+	wt.Monitor, wt.Print = true, func(e inotify.Event) {
+		if got = append(got, e); len(got) == 2 {
+			stop <- syscall.SIGTERM
+		}
+	}
+	os.WriteFile(filepath.Join(dir, "b"), nil, 0644)
+	os.WriteFile(filepath.Join(dir, "c"), nil, 0644)
+	if code := wt.Wait(w, stop); code != inotifywait.ExitEvent || len(got) != 2 {
+		t.Fatal("monitor", code, len(got))
+	}
+	// Watcher 关闭时返回 1
+	go func() {
+		time.Sleep(time.Millisecond*50)
+		w.Close()
+	}()
+	wt.Timeout = 0
+	if code := wt.Wait(w, nil); code != inotifywait.ExitError {
+		t.Fatal("closed", code)
+	}
+}
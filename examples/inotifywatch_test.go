@@ -0,0 +1,83 @@
+//go:build linux
+// +build linux
+
+// @@
+// @ Author       : Eacher
+// @ Date         : 2026-10-24 16:04:04
+// @ LastEditTime : 2026-10-24 16:54:07
+// @ LastEditors  : Eacher
+// @ --------------------------------------------------------------------------------<
+// @ Description  : goinotifywatch 按路径与事件的统计表测试
+// @ --------------------------------------------------------------------------------<
+// @ FilePath     : /inotify/examples/inotifywatch_test.go
+// @@
+package inotify_test
+
+import (
+	"strings"
+	"testing"
+	"github.com/20yyq/inotify"
+	"github.com/20yyq/inotify/internal/inotifywatch"
+)
+
+// inotifywatchEvents 监听目录 /w(递归)与文件 /f.txt 时的事件
+var inotifywatchEvents = []inotify.Event{
+	{FileName: "/w/a", Raw: inotify.IN_CREATE},
+	{FileName: "/w/a", Raw: inotify.IN_MODIFY},
+	{FileName: "/w/a", Raw: inotify.IN_CLOSE_WRITE},
+	{FileName: "/w/sub/b", Raw: inotify.IN_MODIFY},
+	{FileName: "/w/d", Raw: inotify.IN_CREATE|inotify.IN_ISDIR},
+	{FileName: "/w/", Raw: inotify.IN_ATTRIB},
+	{FileName: "/f.txt", Raw: inotify.IN_MODIFY},
+}
+
+func inotifywatchTable(t *testing.T, files bool, by string, ascending, zero bool) string {
+	st := inotifywatch.New(map[string]bool{"/w": true, "/f.txt": true}, files)
+	for _, e := range inotifywatchEvents {
+		st.Add(e)
+	}
+	column, err := inotifywatch.Column(by)
+	if err != nil {
+		t.Fatal("Column", err)
+	}
+	var b strings.Builder
+	if !st.Print(&b, column, ascending, zero) {
+		t.Fatal("no events")
+	}
+	return b.String()
+}
+
+func TestInotifywatchStats(t *testing.T) {
+	// 按目录统计，按 total 降序，只输出发生过的事件列，ISDIR 不单独计数
+	want := `total  modify  attrib  close_write  create  filename
+5      1       1       1            2       /w
+1      1       0       0            0       /f.txt
+1      1       0       0            0       /w/sub
+`
+	if got := inotifywatchTable(t, false, "total", false, false); got != want {
+		t.Fatalf("per directory\n%s", got)
+	}
+	// 按文件统计，按 create 升序，相同时按路径
+	want = `total  modify  attrib  close_write  create  filename
+1      1       0       0            0       /f.txt
+1      0       1       0            0       /w
+1      1       0       0            0       /w/sub/b
+3      1       0       1            1       /w/a
+1      0       0       0            1       /w/d
+`
+	if got := inotifywatchTable(t, true, "create", true, false); got != want {
+		t.Fatalf("per file\n%s", got)
+	}
+	// -z 输出所有事件列
+	got := inotifywatchTable(t, false, "total", false, true)
+	if lines := strings.Split(got, "\n"); !strings.HasPrefix(lines[0], "total  access  modify") || strings.Contains(lines[0], "isdir") ||
+		!strings.HasPrefix(lines[1], "5      0       1       1") {
+		t.Fatalf("zero columns\n%s", got)
+	}
+	if _, err := inotifywatch.Column("bogus"); err == nil {
+		t.Fatal("unknown sort column")
+	}
+	if inotifywatch.New(nil, false).Print(&strings.Builder{}, -1, false, false) {
+		t.Fatal("empty stats printed")
+	}
+}
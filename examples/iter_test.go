@@ -0,0 +1,72 @@
+//go:build linux && go1.23
+// +build linux,go1.23
+
+// @@
+// @ Author       : Eacher
+// @ Date         : 2026-10-21 18:12:30
+// @ LastEditTime : 2026-10-21 18:45:54
+// @ LastEditors  : Eacher
+// @ --------------------------------------------------------------------------------<
+// @ Description  : All 迭代器测试
+// @ --------------------------------------------------------------------------------<
+// @ FilePath     : /inotify/examples/iter_test.go
+// @@
+package inotify_test
+
+import (
+	"os"
+	"time"
+	"context"
+	"testing"
+	"path/filepath"
+	"github.com/20yyq/inotify"
+)
+
+func TestAll(t *testing.T) {
+	w, err := inotify.NewWatcher()
+	if err != nil {
+		t.Fatal("NewWatcher", err)
+	}
+	dir := t.TempDir()
+	if err = w.AddWatch(dir, inotify.IN_CREATE); err != nil {
+		t.Fatal("AddWatch", err)
+	}
+	os.WriteFile(filepath.Join(dir, "a"), nil, 0644)
+	os.WriteFile(filepath.Join(dir, "b"), nil, 0644)
+	var names []string
+	for e, err := range w.All(context.Background()) {
+		if err != nil {
+			t.Fatal("All", err)
+		}
+		if names = append(names, filepath.Base(e.FileName)); len(names) == 2 {
+			break
+		}
+	}
+	if names[0] != "a" || names[1] != "b" {
+		t.Fatal("All events", names)
+	}
+	// ctx 结束时最后返回 ctx.Err()
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	var last error
+	for _, err := range w.All(ctx) {
+		last = err
+	}
+	if last != context.DeadlineExceeded {
+		t.Fatal("All after cancel", last)
+	}
+	// Close 后结束
+	done := make(chan struct{})
+	go func() {
+		for range w.All(context.Background()) {
+		}
+		close(done)
+	}()
+	time.Sleep(50*time.Millisecond)
+	w.Close()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("All not finished after Close")
+	}
+}
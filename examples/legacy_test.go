@@ -0,0 +1,85 @@
+//go:build linux
+// +build linux
+
+// @@
+// @ Author       : Eacher
+// @ Date         : 2026-10-15 16:55:12
+// @ LastEditTime : 2026-10-18 18:40:51
+// @ LastEditors  : Eacher
+// @ --------------------------------------------------------------------------------<
+// @ Description  : 旧版 WaitEvent 与新接口的事件一致
+// @ --------------------------------------------------------------------------------<
+// @ FilePath     : /inotify/examples/legacy_test.go
+// @@
+package inotify_test
+
+import (
+	"os"
+	"time"
+	"strings"
+	"testing"
+	"path/filepath"
+	"github.com/20yyq/inotify"
+)
+
+// touch 产生 CREATE、MODIFY、CLOSE_WRITE、MOVED_FROM、MOVED_TO、DELETE
+func touch(dir string) {
+	name := filepath.Join(dir, "a")
+	os.WriteFile(name, []byte("x"), 0644)
+	os.Rename(name, name+".bak")
+	os.Remove(name+".bak")
+}
+
+func TestLegacyWaitEvent(t *testing.T) {
+	const flags = inotify.IN_CREATE|inotify.IN_MODIFY|inotify.IN_CLOSE_WRITE|inotify.IN_MOVE|inotify.IN_DELETE
+	legacy, dir := newTestWatcher(t, flags)
+	touch(dir)
+	var want []inotify.Event
+	for len(want) < 6 {
+		ws, err := legacy.WaitEvent()
+		if err != nil {
+			t.Fatal("WaitEvent", err)
+		}
+		want = append(want, inotify.Event{FileName: strings.TrimRight(ws.FileName, "\x00"), Raw: ws.Mask, Cookie: ws.Cookie()})
+		if ws.GetEventName() != want[len(want)-1].GetEventName() || ws.IsDir() {
+			t.Fatal("GetEventName", ws.GetEventName(), want[len(want)-1].GetEventName(), ws.IsDir())
+		}
+	}
+	// 监听的是目录，只有子目录的事件 IsDir
+	os.Mkdir(filepath.Join(dir, "sub"), 0755)
+	if ws, err := legacy.WaitEvent(); err != nil || ws.Mask&inotify.IN_CREATE == 0 || !ws.IsDir() {
+		t.Fatal("IsDir", ws.Mask, err)
+	}
+	// 只有 rename 的两个事件有 cookie，且两者相同
+	if want[3].Cookie == 0 || want[3].Cookie != want[4].Cookie || want[0].Cookie != 0 || want[5].Cookie != 0 {
+		t.Fatal("Cookie", want)
+	}
+
+	compare := func(name string, next func(w *inotify.Watcher) (inotify.Event, bool)) {
+		w, dir2 := newTestWatcher(t, flags)
+		touch(dir2)
+		for i, e := range want {
+			got, ok := next(w)
+			if !ok {
+				t.Fatal(name, i)
+			}
+			rel := strings.TrimPrefix(got.FileName, dir2)
+			if rel != strings.TrimPrefix(e.FileName, dir) || got.Raw != e.Raw || got.GetEventName() != e.GetEventName() || (got.Cookie == 0) != (e.Cookie == 0) {
+				t.Fatal("WaitEvent and "+name+" differ", i, rel, got.Raw, e.FileName, e.Raw)
+			}
+		}
+		w.Close()
+	}
+	compare("WaitEventTimeout", func(w *inotify.Watcher) (inotify.Event, bool) {
+		e, ok, err := w.WaitEventTimeout(time.Second)
+		return e, ok && err == nil
+	})
+	compare("Events", func(w *inotify.Watcher) (inotify.Event, bool) {
+		select {
+		case e := <-w.Events():
+			return e, true
+		case <-time.After(time.Second):
+		}
+		return inotify.Event{}, false
+	})
+}
@@ -0,0 +1,132 @@
+//go:build linux
+// +build linux
+
+// @@
+// @ Author       : Eacher
+// @ Date         : 2026-10-17 17:53:55
+// @ LastEditTime : 2026-10-23 18:24:36
+// @ LastEditors  : Eacher
+// @ --------------------------------------------------------------------------------<
+// @ Description  : 目录同步测试
+// @ --------------------------------------------------------------------------------<
+// @ FilePath     : /inotify/examples/mirror_test.go
+// @@
+package inotify_test
+
+import (
+	"os"
+	"log"
+	"sync"
+	"time"
+	"strings"
+	"testing"
+	"path/filepath"
+	"github.com/20yyq/inotify/mirror"
+)
+
+// lockedLog 日志在同步的 goroutine 中写入
+type lockedLog struct {
+	mutex 	sync.Mutex
+	b 		strings.Builder
+}
+
+func (l *lockedLog) Write(p []byte) (int, error) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	return l.b.Write(p)
+}
+
+func (l *lockedLog) String() string {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	return l.b.String()
+}
+
+func TestMirror(t *testing.T) {
+	src, dst := t.TempDir(), t.TempDir()
+	os.MkdirAll(filepath.Join(src, "sub"), 0755)
+	os.WriteFile(filepath.Join(src, "sub", "a"), []byte("a"), 0644)
+	os.WriteFile(filepath.Join(dst, "extra"), []byte("x"), 0644)
+	logs := &lockedLog{}
+	m := mirror.New(src, dst)
+	m.Logger = log.New(logs, "", 0)
+	if err := m.Start(); err != nil {
+		t.Fatal("Start", err)
+	}
+	defer m.Close()
+	read := func(rel string) string {
+		b, err := os.ReadFile(filepath.Join(dst, rel))
+		if err != nil {
+			return "<none>"
+		}
+		return string(b)
+	}
+	// 等待同步完成
+	eventually := func(what string, ok func() bool) {
+		for i := 0; i < 100 && !ok(); i++ {
+			time.Sleep(time.Millisecond*20)
+		}
+		if !ok() {
+			t.Fatal(what, logs.String())
+		}
+	}
+	if read("sub/a") != "a" || read("extra") != "<none>" {
+		t.Fatal("initial sync", read("sub/a"), read("extra"))
+	}
+	os.WriteFile(filepath.Join(src, "b"), []byte("b"), 0644)
+	eventually("create", func() bool { return read("b") == "b" })
+	os.WriteFile(filepath.Join(src, "b"), []byte("bb"), 0644)
+	eventually("modify", func() bool { return read("b") == "bb" })
+	os.Rename(filepath.Join(src, "sub"), filepath.Join(src, "moved"))
+	eventually("rename", func() bool { return read("moved/a") == "a" && read("sub/a") == "<none>" })
+	os.WriteFile(filepath.Join(src, "moved", "c"), []byte("c"), 0644)
+	eventually("create in moved", func() bool { return read("moved/c") == "c" })
+	// 被移出 Src 视为删除
+	os.Rename(filepath.Join(src, "b"), filepath.Join(t.TempDir(), "b"))
+	eventually("moved out", func() bool { return read("b") == "<none>" })
+	// Dst 中被修改的文件以 Src 为准并记录冲突
+	os.WriteFile(filepath.Join(dst, "moved", "c"), []byte("local"), 0644)
+	os.Remove(filepath.Join(src, "moved", "c"))
+	eventually("delete", func() bool { return read("moved/c") == "<none>" })
+	if !strings.Contains(logs.String(), "conflict moved/c") {
+		t.Fatal("conflict log", logs.String())
+	}
+}
+
+func TestMirrorOverlap(t *testing.T) {
+	root := t.TempDir()
+	src := filepath.Join(root, "src")
+	os.Mkdir(src, 0755)
+	os.WriteFile(filepath.Join(src, "a.txt"), []byte("a"), 0644)
+	// Src 在 Dst 之中时 Src 会被当作 Dst 中多余的文件删除
+	for _, paths := range [][2]string{{src, root}, {root, filepath.Join(root, "dst")}, {src, src}} {
+		m := mirror.New(paths[0], paths[1])
+		if err := m.Start(); err == nil {
+			m.Close()
+			t.Fatal("Start overlapping", paths)
+		}
+	}
+	if b, err := os.ReadFile(filepath.Join(src, "a.txt")); err != nil || string(b) != "a" {
+		t.Fatal("Src removed", string(b), err)
+	}
+	// ..a 与 ..dst 不是上级目录
+	os.WriteFile(filepath.Join(src, "..a"), []byte("1"), 0644)
+	dst := src + "..dst"
+	m := mirror.New(src, dst)
+	if err := m.Start(); err != nil {
+		t.Fatal("Start", err)
+	}
+	defer m.Close()
+	os.WriteFile(filepath.Join(src, "..b"), []byte("2"), 0644)
+	for i := 0; i < 100; i++ {
+		if b, _ := os.ReadFile(filepath.Join(dst, "..b")); string(b) == "2" {
+			break
+		}
+		time.Sleep(time.Millisecond*20)
+	}
+	for name, want := range map[string]string{"..a": "1", "..b": "2"} {
+		if b, err := os.ReadFile(filepath.Join(dst, name)); err != nil || string(b) != want {
+			t.Fatal("mirror", name, string(b), err)
+		}
+	}
+}
@@ -0,0 +1,214 @@
+//go:build linux
+// +build linux
+
+// @@
+// @ Author       : Eacher
+// @ Date         : 2026-10-23 11:25:17
+// @ LastEditTime : 2026-10-24 09:08:31
+// @ LastEditors  : Eacher
+// @ --------------------------------------------------------------------------------<
+// @ Description  : 事件通知模板与 webhook、SMTP 发送测试
+// @ --------------------------------------------------------------------------------<
+// @ FilePath     : /inotify/examples/notify_test.go
+// @@
+package inotify_test
+
+import (
+	"os"
+	"net"
+	"mime"
+	"sync"
+	"time"
+	"bufio"
+	"strings"
+	"testing"
+	"net/http"
+	"encoding/json"
+	"net/http/httptest"
+	"github.com/20yyq/inotify"
+	"github.com/20yyq/inotify/notify"
+)
+
+// recordSender 记录每次发送的内容
+type recordSender struct {
+	mutex 	sync.Mutex
+	sent 	[][2]string
+}
+
+func (s *recordSender) Send(subject, body string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.sent = append(s.sent, [2]string{subject, body})
+	return nil
+}
+
+func (s *recordSender) list() [][2]string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return append([][2]string(nil), s.sent...)
+}
+
+func TestNotifyTemplate(t *testing.T) {
+	events := []inotify.Event{{FileName: "/data/a.txt", Raw: inotify.IN_CREATE}, {FileName: "/data/b.txt", Raw: inotify.IN_DELETE}}
+	host, _ := os.Hostname()
+	s := &recordSender{}
+	sink, err := notify.NewSink(s, 0, "", "")
+	if err != nil {
+		t.Fatal("NewSink", err)
+	}
+	if err = sink.Notify(events); err != nil {
+		t.Fatal("Notify", err)
+	}
+	if sent := s.list(); len(sent) != 1 || sent[0][0] != "["+host+"] 2 file events" || sent[0][1] != "CREATE /data/a.txt\nDELETE /data/b.txt\n" {
+		t.Fatal("default template", sent)
+	}
+	// 自定义模板与 base、dir 函数
+	s = &recordSender{}
+	if sink, err = notify.NewSink(s, 0, "{{len .Events}} in {{dir (index .Events 0).FileName}}", "{{range .Events}}{{base .FileName}};{{end}}"); err != nil {
+		t.Fatal("NewSink", err)
+	}
+	if err = sink.Notify(events); err != nil {
+		t.Fatal("Notify", err)
+	}
+	if sent := s.list(); len(sent) != 1 || sent[0][0] != "2 in /data" || sent[0][1] != "a.txt;b.txt;" {
+		t.Fatal("custom template", sent)
+	}
+	if _, err = notify.NewSink(s, 0, "{{", ""); err == nil {
+		t.Fatal("NewSink invalid template")
+	}
+	if sink, err = notify.NewSink(s, 0, "{{.Missing}}", ""); err != nil {
+		t.Fatal("NewSink", err)
+	}
+	if err = sink.Notify(events); err == nil {
+		t.Fatal("Notify with template error")
+	}
+}
+
+func TestNotifyInterval(t *testing.T) {
+	s := &recordSender{}
+	sink, err := notify.NewSink(s, time.Millisecond*100, "", "")
+	if err != nil {
+		t.Fatal("NewSink", err)
+	}
+	// Interval 内的事件合并为一次发送
+	sink.Add(inotify.Event{FileName: "/data/a", Raw: inotify.IN_CREATE})
+	sink.Add(inotify.Event{FileName: "/data/b", Raw: inotify.IN_CREATE})
+	time.Sleep(time.Millisecond*300)
+	if sent := s.list(); len(sent) != 1 || strings.Count(sent[0][1], "\n") != 2 {
+		t.Fatal("interval", sent)
+	}
+	// 没有事件时 Flush 不发送
+	if err = sink.Flush(); err != nil || len(s.list()) != 1 {
+		t.Fatal("Flush", err, s.list())
+	}
+}
+
+func TestWebhookSender(t *testing.T) {
+	var got map[string]string
+	var header http.Header
+	srv := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		header = r.Header
+		if r.Method != http.MethodPost || json.NewDecoder(r.Body).Decode(&got) != nil {
+			rw.WriteHeader(http.StatusBadRequest)
+		}
+	}))
+	defer srv.Close()
+	s := &notify.WebhookSender{URL: srv.URL, Header: http.Header{"Authorization": {"Bearer token"}}}
+	if err := s.Send("subject", "line 1\nline 2\n"); err != nil {
+		t.Fatal("Send", err)
+	}
+	if got["subject"] != "subject" || got["text"] != "line 1\nline 2\n" || header.Get("Authorization") != "Bearer token" || header.Get("Content-Type") != "application/json" {
+		t.Fatal("webhook", got, header)
+	}
+	// 非 2xx 为错误
+	fail := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		rw.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer fail.Close()
+	if err := (&notify.WebhookSender{URL: fail.URL}).Send("subject", "body"); err == nil || !strings.Contains(err.Error(), "500") {
+		t.Fatal("Send to failing webhook", err)
+	}
+}
+
+// smtpServer 只处理一封邮件的 SMTP 服务，返回收到的 MAIL、RCPT 与 DATA
+func smtpServer(t *testing.T) (string, <-chan []string) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal("Listen", err)
+	}
+	t.Cleanup(func() { l.Close() })
+	got := make(chan []string, 1)
+	go func() {
+		c, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer c.Close()
+		c.SetDeadline(time.Now().Add(time.Second*5))
+		r := bufio.NewReader(c)
+		var lines []string
+		reply := func(s string) { c.Write([]byte(s + "\r\n")) }
+		reply("220 localhost")
+		for data := false; ; {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				break
+			}
+			line = strings.TrimSuffix(line, "\r\n")
+			switch {
+			case data && line == ".":
+				data = false
+				reply("250 ok")
+			case data:
+				lines = append(lines, line)
+			case strings.HasPrefix(line, "EHLO"):
+				reply("250 localhost")
+			case strings.HasPrefix(line, "MAIL"), strings.HasPrefix(line, "RCPT"):
+				lines = append(lines, line)
+				reply("250 ok")
+			case line == "DATA":
+				data = true
+				reply("354 go ahead")
+			case line == "QUIT":
+				reply("221 bye")
+				got <- lines
+				return
+			default:
+				reply("502 unsupported")
+			}
+		}
+		got <- lines
+	}()
+	return l.Addr().String(), got
+}
+
+func TestSMTPSender(t *testing.T) {
+	addr, got := smtpServer(t)
+	s := &notify.SMTPSender{Addr: addr, From: "watch@example.com", To: []string{"a@example.com", "b@example.com"}}
+	if err := s.Send("2 file events", "CREATE /data/a\nDELETE /data/b\n"); err != nil {
+		t.Fatal("Send", err)
+	}
+	lines := <-got
+	msg := strings.Join(lines, "\n")
+	for _, want := range []string{"MAIL FROM:<watch@example.com>", "RCPT TO:<a@example.com>", "RCPT TO:<b@example.com>", "From: watch@example.com", "To: a@example.com, b@example.com", "Subject: 2 file events", "Content-Type: text/plain; charset=UTF-8", "CREATE /data/a\nDELETE /data/b"} {
+		if !strings.Contains(msg, want) {
+			t.Fatal("SMTP message without", want, lines)
+		}
+	}
+	// 文件名中的换行不能加入头部，非 ASCII 的主题按 RFC 2047 编码，正文已有的 CRLF 不变为 CR CR LF
+	addr, got = smtpServer(t)
+	s.Addr = addr
+	if err := s.Send("CREATE /data/x\r\nBcc: evil@example.com\n\n报告", "CREATE /data/x\r\nok\n"); err != nil {
+		t.Fatal("Send", err)
+	}
+	lines = <-got
+	for _, line := range lines {
+		if strings.HasPrefix(line, "Bcc:") || strings.Contains(line, "\r") {
+			t.Fatal("SMTP header injection", lines)
+		}
+	}
+	msg = strings.Join(lines, "\n")
+	if want := "Subject: " + mime.QEncoding.Encode("utf-8", "CREATE /data/x Bcc: evil@example.com  报告"); !strings.Contains(msg, want+"\n") || !strings.Contains(msg, "\n\nCREATE /data/x\nok") {
+		t.Fatal("SMTP message", want, lines)
+	}
+}
@@ -0,0 +1,189 @@
+//go:build linux
+// +build linux
+
+// @@
+// @ Author       : Eacher
+// @ Date         : 2026-10-17 15:55:26
+// @ LastEditTime : 2026-10-24 11:06:27
+// @ LastEditors  : Eacher
+// @ --------------------------------------------------------------------------------<
+// @ Description  : 消息队列转发测试
+// @ --------------------------------------------------------------------------------<
+// @ FilePath     : /inotify/examples/publish_test.go
+// @@
+package inotify_test
+
+import (
+	"io"
+	"net"
+	"sync"
+	"time"
+	"bufio"
+	"errors"
+	"context"
+	"strconv"
+	"strings"
+	"testing"
+	"github.com/20yyq/inotify"
+	"github.com/20yyq/inotify/publish"
+)
+
+// flakyPublisher 前 fails 次返回错误
+type flakyPublisher struct {
+	mutex 	sync.Mutex
+	fails 	int
+	calls 	int
+	got 	[][]byte
+}
+
+func (p *flakyPublisher) Publish(ctx context.Context, topic string, msgs [][]byte) error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if p.calls++; p.calls <= p.fails {
+		return errors.New("unavailable")
+	}
+	p.got = append(p.got, msgs...)
+	return nil
+}
+
+func (p *flakyPublisher) Close() error {
+	return nil
+}
+
+func TestForwarder(t *testing.T) {
+	p := &flakyPublisher{fails: 2}
+	f := publish.NewForwarder(p, "files")
+	f.BatchSize, f.Interval, f.Backoff = 2, time.Millisecond*20, time.Millisecond
+	f.Encode = func(e inotify.Event) ([]byte, error) { return []byte(e.FileName), nil }
+	var failed []inotify.Event
+	f.OnError = func(batch []inotify.Event, err error) { failed = append(failed, batch...) }
+	// 满 2 个立即发送，失败 2 次后成功
+	f.Add(inotify.Event{FileName: "a"})
+	f.Add(inotify.Event{FileName: "b"})
+	if p.calls != 3 || len(p.got) != 2 {
+		t.Fatal("batch", p.calls, len(p.got))
+	}
+	// 不满一批时 Interval 后发送
+	f.Add(inotify.Event{FileName: "c"})
+	time.Sleep(time.Millisecond*100)
+	p.mutex.Lock()
+	if len(p.got) != 3 || string(p.got[2]) != "c" {
+		t.Fatal("interval", len(p.got))
+	}
+	p.mutex.Unlock()
+	// 重试用完后交给 OnError
+	p.fails, f.Retries = 100, 1
+	f.Add(inotify.Event{FileName: "d"})
+	f.Close()
+	if len(failed) != 1 || failed[0].FileName != "d" {
+		t.Fatal("OnError", failed)
+	}
+}
+
+// gatePublisher 第一次 Publish 等待 gate 关闭，返回前关闭 left
+type gatePublisher struct {
+	flakyPublisher
+	gate 	chan struct{}
+	entered chan struct{}
+	left 	chan struct{}
+	once 	sync.Once
+}
+
+func (p *gatePublisher) Publish(ctx context.Context, topic string, msgs [][]byte) error {
+	first := false
+	p.once.Do(func() {
+		first = true
+		close(p.entered)
+		<-p.gate
+	})
+	err := p.flakyPublisher.Publish(ctx, topic, msgs)
+	if first {
+		close(p.left)
+	}
+	return err
+}
+
+func TestForwarderOrder(t *testing.T) {
+	p := &gatePublisher{gate: make(chan struct{}), entered: make(chan struct{}), left: make(chan struct{})}
+	f := publish.NewForwarder(p, "files")
+	f.BatchSize, f.Interval = 1, 0
+	f.Encode = func(e inotify.Event) ([]byte, error) { return []byte(e.FileName), nil }
+	var wg sync.WaitGroup
+	add := func(name string) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			f.Add(inotify.Event{FileName: name})
+		}()
+	}
+	add("0")
+	<-p.entered
+	// 第一批阻塞时后面的批次依次取出，发送顺序与取出顺序一致
+	for i := 1; i < 10; i++ {
+		add(strconv.Itoa(i))
+		time.Sleep(time.Millisecond*5)
+	}
+	// 第一批发送结束时新取出的批次不能插到等待的批次前面
+	close(p.gate)
+	<-p.left
+	for i := 10; i < 20; i++ {
+		f.Add(inotify.Event{FileName: strconv.Itoa(i)})
+	}
+	wg.Wait()
+	f.Close()
+	if len(p.got) != 20 {
+		t.Fatal("published", len(p.got))
+	}
+	for i, m := range p.got {
+		if string(m) != strconv.Itoa(i) {
+			t.Fatal("order", i, string(m))
+		}
+	}
+}
+
+func TestNATSPublisher(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal("Listen", err)
+	}
+	defer l.Close()
+	got := make(chan string, 10)
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		conn.Write([]byte("INFO {\"server_id\":\"test\"}\r\n"))
+		r := bufio.NewReader(conn)
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			fields := strings.Fields(line)
+			switch fields[0] {
+			case "PUB":
+				n, _ := strconv.Atoi(fields[2])
+				payload := make([]byte, n+2)
+				io.ReadFull(r, payload)
+				got <- fields[1] + " " + string(payload[:n])
+			case "PING":
+				conn.Write([]byte("PONG\r\n"))
+			}
+		}
+	}()
+	p := &publish.NATSPublisher{Addr: l.Addr().String()}
+	defer p.Close()
+	if err = p.Publish(context.Background(), "files.created", [][]byte{[]byte("a"), []byte("b c")}); err != nil {
+		t.Fatal("Publish", err)
+	}
+	for _, want := range []string{"files.created a", "files.created b c"} {
+		if v := <-got; v != want {
+			t.Fatal("message", v, want)
+		}
+	}
+	if err = p.Publish(context.Background(), "bad subject", nil); err == nil {
+		t.Fatal("invalid subject")
+	}
+}
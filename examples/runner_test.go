@@ -0,0 +1,243 @@
+//go:build linux
+// +build linux
+
+// @@
+// @ Author       : Eacher
+// @ Date         : 2026-10-14 14:14:16
+// @ LastEditTime : 2026-10-21 12:46:23
+// @ LastEditors  : Eacher
+// @ --------------------------------------------------------------------------------<
+// @ Description  : runner 规则引擎测试
+// @ --------------------------------------------------------------------------------<
+// @ FilePath     : /inotify/examples/runner_test.go
+// @@
+package inotify_test
+
+import (
+	"io"
+	"sync"
+	"time"
+	"strings"
+	"testing"
+	"net/http"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"sync/atomic"
+	"net/http/httptest"
+	"github.com/20yyq/inotify"
+	"github.com/20yyq/inotify/runner"
+)
+
+func TestRunnerPolicy(t *testing.T) {
+	tests := []struct {
+		policy 	runner.Policy
+		runs 	int
+		failed 	int
+	}{
+		{runner.Skip, 1, 0},
+		{runner.Queue, 2, 0},
+		{runner.Restart, 2, 1},
+	}
+	for _, tt := range tests {
+		var mutex sync.Mutex
+		runs, failed := 0, 0
+		eg := runner.NewEngine(runner.Rule{Command: []string{"sleep", "0.2"}, Policy: tt.policy})
+		eg.OnExit = func(r *runner.Rule, e inotify.Event, err error) {
+			mutex.Lock()
+			defer mutex.Unlock()
+			runs++
+			if err != nil {
+				failed++
+			}
+		}
+		for i := 0; i < 3; i++ {
+			eg.Dispatch(inotify.Event{FileName: "/tmp/a", Raw: inotify.IN_CLOSE_WRITE})
+		}
+		eg.Wait()
+		if runs != tt.runs || failed != tt.failed {
+			t.Fatal(tt.policy, "runs", runs, "failed", failed)
+		}
+	}
+}
+
+func TestRunnerMatch(t *testing.T) {
+	r := runner.Rule{Mask: inotify.IN_CLOSE_WRITE, Pattern: "*.go"}
+	if !r.Match(inotify.Event{FileName: "/src/main.go", Raw: inotify.IN_CLOSE_WRITE}) {
+		t.Fatal("Match main.go")
+	}
+	if r.Match(inotify.Event{FileName: "/src/main.go", Raw: inotify.IN_OPEN}) || r.Match(inotify.Event{FileName: "/src/a.txt", Raw: inotify.IN_CLOSE_WRITE}) {
+		t.Fatal("Match unexpected")
+	}
+}
+
+func TestRunnerMatchFold(t *testing.T) {
+	r := &runner.Rule{Pattern: "*.JPG", IgnoreCase: true}
+	if !r.Match(inotify.Event{FileName: "/smb/photo.jpg"}) || !r.Match(inotify.Event{FileName: "/smb/PHOTO.Jpg"}) {
+		t.Fatal("IgnoreCase")
+	}
+	// NFD 的文件名 (e + U+0301) 与 NFC 的 Pattern (U+00E9)
+	nfc := strings.NewReplacer("e\u0301", "\u00e9")
+	r = &runner.Rule{Pattern: "caf\u00e9.txt", Normalize: nfc.Replace}
+	if !r.Match(inotify.Event{FileName: "/nfs/cafe\u0301.txt"}) {
+		t.Fatal("Normalize")
+	}
+	if (&runner.Rule{Pattern: "caf\u00e9.txt"}).Match(inotify.Event{FileName: "/nfs/cafe\u0301.txt"}) {
+		t.Fatal("Match without Normalize")
+	}
+}
+
+func TestRunnerEnviron(t *testing.T) {
+	var out strings.Builder
+	eg := runner.NewEngine(runner.Rule{Command: []string{"sh", "-c", `sleep 0.1; echo "$INOTIFY_PATH|$INOTIFY_OP|$INOTIFY_COOKIE|$INOTIFY_BATCH"`}, Policy: runner.Queue})
+	eg.Stdout = &out
+	eg.Dispatch(inotify.Event{FileName: "/tmp/a", Raw: inotify.IN_CREATE})
+	eg.Dispatch(inotify.Event{FileName: "/tmp/b", Raw: inotify.IN_MOVED_FROM, Cookie: 7})
+	eg.Dispatch(inotify.Event{FileName: "/tmp/c", Raw: inotify.IN_MOVED_TO, Cookie: 7})
+	eg.Dispatch(inotify.Event{FileName: "/tmp/b", Raw: inotify.IN_CREATE})
+	eg.Wait()
+	want := "/tmp/a|CREATE|0|/tmp/a\n/tmp/b|CREATE|0|/tmp/b\n/tmp/c\n"
+	if out.String() != want {
+		t.Fatalf("environ %q", out.String())
+	}
+}
+
+func TestRunnerCooldown(t *testing.T) {
+	eg := runner.NewEngine(runner.Rule{Command: []string{"false"}})
+	eg.Cooldown = time.Millisecond*200
+	e := inotify.Event{FileName: "/tmp/poison", Raw: inotify.IN_CLOSE_WRITE}
+	eg.Dispatch(e)
+	eg.Wait()
+	fp := eg.FailingPaths()
+	if len(fp) != 1 || fp[0].Path != e.FileName || fp[0].Failures != 1 {
+		t.Fatal("FailingPaths", fp)
+	}
+	// 暂停期内不触发
+	eg.Dispatch(e)
+	eg.Wait()
+	if fp = eg.FailingPaths(); fp[0].Failures != 1 {
+		t.Fatal("Dispatch during cooldown", fp)
+	}
+	time.Sleep(time.Millisecond*250)
+	eg.Dispatch(e)
+	eg.Wait()
+	if fp = eg.FailingPaths(); fp[0].Failures != 2 || time.Until(fp[0].Until) < time.Millisecond*300 {
+		t.Fatal("backoff", fp)
+	}
+}
+
+func TestDebounceRunner(t *testing.T) {
+	out := &lockedLog{}
+	r := runner.NewRunner("sh", "-c", `echo "$INOTIFY_BATCH"; sleep 5`)
+	r.Stdout = out
+	r.Rule().Mask = inotify.IN_CLOSE_WRITE
+	var mutex sync.Mutex
+	var errs []error
+	r.OnExit = func(_ *runner.Rule, _ inotify.Event, err error) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		errs = append(errs, err)
+	}
+	// Debounce 内的事件合并为一次运行
+	r.Dispatch(inotify.Event{FileName: "/tmp/a", Raw: inotify.IN_CLOSE_WRITE})
+	r.Dispatch(inotify.Event{FileName: "/tmp/b", Raw: inotify.IN_CLOSE_WRITE})
+	r.Dispatch(inotify.Event{FileName: "/tmp/a", Raw: inotify.IN_OPEN})
+	time.Sleep(time.Millisecond*300)
+	if out.String() != "/tmp/a\n/tmp/b\n" {
+		t.Fatalf("debounce %q", out.String())
+	}
+	// 命令仍在运行，结束后重新运行
+	r.Dispatch(inotify.Event{FileName: "/tmp/c", Raw: inotify.IN_CLOSE_WRITE})
+	time.Sleep(time.Millisecond*300)
+	mutex.Lock()
+	n := len(errs)
+	mutex.Unlock()
+	if n != 1 || out.String() != "/tmp/a\n/tmp/b\n/tmp/c\n" {
+		t.Fatalf("restart %d %q", n, out.String())
+	}
+	r.Stop()
+	if len(errs) != 2 {
+		t.Fatal("Stop", errs)
+	}
+}
+
+func TestRunnerTemplate(t *testing.T) {
+	args, err := runner.SplitCommand(`convert {{.Path}} "out dir/{{ .Base }}.png" 'it''s' a\ b {{printf "%s x" .Ext}}`)
+	want := []string{"convert", "{{.Path}}", "out dir/{{ .Base }}.png", "its", "a b", `{{printf "%s x" .Ext}}`}
+	if err != nil || strings.Join(args, "|") != strings.Join(want, "|") {
+		t.Fatalf("SplitCommand %q %v", args, err)
+	}
+	if _, err = runner.SplitCommand(`echo "a`); err == nil {
+		t.Fatal("unterminated quote")
+	}
+	// 文件名中的空格与引号不会被 shell 解释
+	name := `/tmp/it's a "pic".jpg`
+	r := runner.Rule{Command: append(args, `sh -c "ls {{quote .Path}}"`)}
+	if args, err = r.Expand(inotify.Event{FileName: name}); err != nil {
+		t.Fatal("Expand", err)
+	}
+	if args[1] != name || args[2] != `out dir/it's a "pic".png` || args[5] != ".jpg x" || args[6] != `sh -c "ls '/tmp/it'\''s a "pic".jpg'"` {
+		t.Fatalf("Expand %q", args)
+	}
+	if _, err = (&runner.Rule{Command: []string{"{{.Missing}}"}}).Expand(inotify.Event{}); err == nil {
+		t.Fatal("Expand missing field")
+	}
+	var out strings.Builder
+	eg := runner.NewEngine(runner.Rule{Command: []string{"rm", "{{.Name}}"}})
+	eg.Stdout, eg.DryRun = &out, true
+	eg.Dispatch(inotify.Event{FileName: "/tmp/a b"})
+	eg.Wait()
+	if out.String() != "dry-run: 'rm' 'a b'\n" {
+		t.Fatalf("DryRun %q", out.String())
+	}
+}
+
+func TestWebhook(t *testing.T) {
+	var calls, inflight, peak int32
+	var mutex sync.Mutex
+	got := make(map[string]bool)
+	srv := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&inflight, 1)
+		defer atomic.AddInt32(&inflight, -1)
+		for p := atomic.LoadInt32(&peak); n > p && !atomic.CompareAndSwapInt32(&peak, p, n); p = atomic.LoadInt32(&peak) {
+		}
+		body, _ := io.ReadAll(r.Body)
+		mac := hmac.New(sha256.New, []byte("secret"))
+		mac.Write(body)
+		if r.Header.Get(runner.HeaderSignature) != "sha256="+hex.EncodeToString(mac.Sum(nil)) {
+			rw.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		// 第一个请求失败，之后重试成功
+		if atomic.AddInt32(&calls, 1) == 1 {
+			rw.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		time.Sleep(time.Millisecond*20)
+		var e inotify.Event
+		json.Unmarshal(body, &e)
+		mutex.Lock()
+		got[e.FileName] = true
+		mutex.Unlock()
+	}))
+	defer srv.Close()
+	h := runner.NewWebhook(srv.URL, "secret")
+	h.Pattern, h.Concurrency, h.Backoff = "*.csv", 2, time.Millisecond
+	var failed []error
+	h.OnError = func(e inotify.Event, err error) { failed = append(failed, err) }
+	for _, name := range []string{"/in/a.csv", "/in/b.csv", "/in/c.txt", "/in/d.csv", "/in/e.csv"} {
+		h.Dispatch(inotify.Event{FileName: name, Op: inotify.Create})
+	}
+	h.Close()
+	if len(got) != 4 || got["/in/c.txt"] || len(failed) != 0 {
+		t.Fatal("delivered", got, failed)
+	}
+	if peak > 2 {
+		t.Fatal("concurrency", peak)
+	}
+	h.Dispatch(inotify.Event{FileName: "/in/f.csv"})
+	if len(failed) != 1 || failed[0] != runner.ErrWebhookClosed {
+		t.Fatal("after Close", failed)
+	}
+}
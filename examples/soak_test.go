@@ -0,0 +1,113 @@
+//go:build soak && linux
+// +build soak,linux
+
+// @@
+// @ Author       : Eacher
+// @ Date         : 2026-10-14 09:12:33
+// @ LastEditTime : 2026-10-14 14:14:16
+// @ LastEditors  : Eacher
+// @ --------------------------------------------------------------------------------<
+// @ Description  : 长时间压测: go test -tags soak -timeout 0 ./examples -soak.cycles=1000000
+// @ --------------------------------------------------------------------------------<
+// @ FilePath     : /inotify/examples/soak_test.go
+// @@
+package inotify_test
+
+import (
+	"os"
+	"flag"
+	"testing"
+	"path/filepath"
+	"github.com/20yyq/inotify"
+	"github.com/20yyq/inotify/internal/soak"
+)
+
+var soakCycles = flag.Int("soak.cycles", 1000000, "soak test cycles")
+
+func soakCheck(t *testing.T, base soak.Sample, i int) {
+	cur, err := soak.Take()
+	if err != nil {
+		t.Fatal("soak.Take", err)
+	}
+	t.Log("cycle", i, cur)
+	if err = base.Check(cur, soak.DefaultLimit); err != nil {
+		t.Fatal("cycle", i, err)
+	}
+}
+
+// 反复 创建文件 -> 监听文件 -> 删除文件，监听者随 IN_IGNORED 被移除
+func TestSoakEvents(t *testing.T) {
+	dir := t.TempDir()
+	w, err := inotify.NewWatcher()
+	if err != nil {
+		t.Fatal("NewWatcher", err)
+	}
+	defer w.Close()
+	if err = w.AddWatch(dir, inotify.IN_CREATE|inotify.IN_DELETE); err != nil {
+		t.Fatal("AddWatch", err)
+	}
+	name := filepath.Join(dir, "soak")
+	base, err := soak.Take()
+	if err != nil {
+		t.Fatal("soak.Take", err)
+	}
+	step := *soakCycles/10 + 1
+	for i := 1; i <= *soakCycles; i++ {
+		f, err := os.Create(name)
+		if err != nil {
+			t.Fatal("Create", err)
+		}
+		f.Close()
+		if ws, err := w.WaitEvent(); err != nil || ws.GetEventName() != "CREATE" {
+			t.Fatal("WaitEvent CREATE", ws.GetEventName(), err)
+		}
+		if err = w.AddWatch(name, inotify.IN_DELETE_SELF); err != nil {
+			t.Fatal("AddWatch", err)
+		}
+		if err = os.Remove(name); err != nil {
+			t.Fatal("Remove", err)
+		}
+		for deleted, removed := false, false; !deleted || !removed; {
+			ws, err := w.WaitEvent()
+			if err != nil {
+				t.Fatal("WaitEvent", err)
+			}
+			switch ws.GetEventName() {
+			case "DELETE":
+				deleted = true
+			case "REMOVE":
+				removed = true
+			}
+		}
+		if i%step == 0 {
+			soakCheck(t, base, i)
+		}
+	}
+	soakCheck(t, base, *soakCycles)
+}
+
+// 反复 NewWatcher -> AddWatch -> Close
+func TestSoakWatcher(t *testing.T) {
+	dir := t.TempDir()
+	base, err := soak.Take()
+	if err != nil {
+		t.Fatal("soak.Take", err)
+	}
+	step := *soakCycles/10 + 1
+	for i := 1; i <= *soakCycles; i++ {
+		w, err := inotify.NewWatcher()
+		if err != nil {
+			t.Fatal("NewWatcher", err)
+		}
+		if err = w.AddWatch(dir, inotify.IN_CREATE); err != nil {
+			t.Fatal("AddWatch", err)
+		}
+		if err = w.Close(); err != nil {
+			t.Fatal("Close", err)
+		}
+		if i%step == 0 {
+			soakCheck(t, base, i)
+		}
+	}
+	soakCheck(t, base, *soakCycles)
+}
@@ -0,0 +1,197 @@
+//go:build linux
+// +build linux
+
+// @@
+// @ Author       : Eacher
+// @ Date         : 2026-10-24 13:35:24
+// @ LastEditTime : 2026-10-24 14:16:21
+// @ LastEditors  : Eacher
+// @ --------------------------------------------------------------------------------<
+// @ Description  : systemd 集成测试: sd_notify、watchdog、socket activation 与日志流
+// @ --------------------------------------------------------------------------------<
+// @ FilePath     : /inotify/examples/systemd_test.go
+// @@
+package inotify_test
+
+import (
+	"os"
+	"io"
+	"net"
+	"fmt"
+	"time"
+	"bufio"
+	"strings"
+	"strconv"
+	"testing"
+	"os/exec"
+	"path/filepath"
+	"github.com/20yyq/inotify/internal/systemd"
+)
+
+// notifySocket 在 NOTIFY_SOCKET 上监听 unixgram
+func notifySocket(t *testing.T, name string) *net.UnixConn {
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: name, Net: "unixgram"})
+	if err != nil {
+		t.Fatal("ListenUnixgram", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	t.Setenv("NOTIFY_SOCKET", name)
+	return conn
+}
+
+func readState(t *testing.T, conn *net.UnixConn) string {
+	buf := make([]byte, 256)
+	conn.SetReadDeadline(time.Now().Add(time.Second*2))
+	n, err := conn.Read(buf)
+	if err != nil {
+		t.Fatal("Read", err)
+	}
+	return string(buf[:n])
+}
+
+func TestSystemdNotify(t *testing.T) {
+	t.Setenv("NOTIFY_SOCKET", "")
+	if err := systemd.Notify("READY=1"); err != nil {
+		t.Fatal("Notify without NOTIFY_SOCKET", err)
+	}
+	for _, name := range []string{filepath.Join(t.TempDir(), "notify"), fmt.Sprintf("@inotify-test-%d", os.Getpid())} {
+		conn := notifySocket(t, name)
+		if err := systemd.Notify("READY=1"); err != nil {
+			t.Fatal("Notify", name, err)
+		}
+		if s := readState(t, conn); s != "READY=1" {
+			t.Fatal("state", name, s)
+		}
+	}
+}
+
+func TestSystemdWatchdog(t *testing.T) {
+	conn := notifySocket(t, filepath.Join(t.TempDir(), "notify"))
+	t.Setenv("WATCHDOG_USEC", "20000")
+	// 不是本进程的 watchdog 时直接返回
+	t.Setenv("WATCHDOG_PID", strconv.Itoa(os.Getpid()+1))
+	systemd.Watchdog(nil)
+	t.Setenv("WATCHDOG_PID", strconv.Itoa(os.Getpid()))
+	done := make(chan struct{})
+	go systemd.Watchdog(done)
+	defer close(done)
+	for i := 0; i < 2; i++ {
+		if s := readState(t, conn); s != "WATCHDOG=1" {
+			t.Fatal("state", s)
+		}
+	}
+}
+
+func TestSystemdListenFds(t *testing.T) {
+	pid := strconv.Itoa(os.Getpid())
+	tests := []struct {
+		pid 	string
+		fds 	string
+		n 		int
+	}{
+		{pid, "2", 2},
+		{"", "2", 0},
+		{strconv.Itoa(os.Getpid()+1), "2", 0},
+		{pid, "", 0},
+		{pid, "x", 0},
+		{pid, "-1", 0},
+	}
+	for _, tt := range tests {
+		t.Setenv("LISTEN_PID", tt.pid)
+		t.Setenv("LISTEN_FDS", tt.fds)
+		if n := systemd.ListenFds(); n != tt.n {
+			t.Fatal("ListenFds", tt.pid, tt.fds, n)
+		}
+	}
+}
+
+// TestSystemdListenersHelper 在子进程中输出传入的监听地址
+func TestSystemdListenersHelper(t *testing.T) {
+	if os.Getenv("SYSTEMD_TEST_HELPER") == "" {
+		t.Skip("helper process")
+	}
+	for _, l := range systemd.Listeners() {
+		fmt.Println("listener", l.Addr())
+	}
+	fmt.Println("LISTEN_FDS", os.Getenv("LISTEN_FDS"))
+}
+
+func TestSystemdListeners(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal("Listen", err)
+	}
+	defer l.Close()
+	f, err := l.(*net.TCPListener).File()
+	if err != nil {
+		t.Fatal("File", err)
+	}
+	defer f.Close()
+	// exec 后 pid 不变，LISTEN_PID 为子进程自己
+	cmd := exec.Command("sh", "-c", `LISTEN_PID=$$ LISTEN_FDS=1 exec "$0" -test.run=^TestSystemdListenersHelper$ -test.v`, os.Args[0])
+	cmd.Env, cmd.ExtraFiles = append(os.Environ(), "SYSTEMD_TEST_HELPER=1"), []*os.File{f}
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatal("helper process", err, string(out))
+	}
+	if !strings.Contains(string(out), "listener "+l.Addr().String()+"\n") || !strings.Contains(string(out), "LISTEN_FDS \n") {
+		t.Fatal("Listeners", string(out))
+	}
+}
+
+func TestSystemdStream(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal("Listen", err)
+	}
+	defer l.Close()
+	s := systemd.NewStream([]net.Listener{l})
+	fast, err := net.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatal("Dial", err)
+	}
+	defer fast.Close()
+	// slow 从不读取
+	slow, err := net.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatal("Dial", err)
+	}
+	defer slow.Close()
+	// 两个连接都收到 ping 后开始，之后 slow 不再读取
+	ready := func(c net.Conn, r *bufio.Reader) {
+		for i := 0; i < 100; i++ {
+			s.Write("ping")
+			c.SetReadDeadline(time.Now().Add(time.Millisecond*20))
+			if line, _ := r.ReadString('\n'); line == "ping\n" {
+				return
+			}
+		}
+		t.Fatal("connection not accepted")
+	}
+	r := bufio.NewReader(fast)
+	ready(slow, bufio.NewReader(slow))
+	ready(fast, r)
+	// 慢客户端不阻塞 Write，缓存满后被断开，快的客户端收到所有行
+	line := strings.Repeat("x", 64<<10)
+	for i := 0; i < 400; i++ {
+		start := time.Now()
+		s.Write(line)
+		if d := time.Since(start); d > time.Millisecond*100 {
+			t.Fatal("Write blocked", i, d)
+		}
+		fast.SetReadDeadline(time.Now().Add(time.Second*2))
+		got, err := r.ReadString('\n')
+		for err == nil && got == "ping\n" {
+			got, err = r.ReadString('\n')
+		}
+		if err != nil || len(got) != len(line)+1 {
+			t.Fatal("fast client", i, len(got), err)
+		}
+	}
+	slow.SetReadDeadline(time.Now().Add(time.Second*3))
+	if _, err = io.Copy(io.Discard, slow); err != nil {
+		if ne, ok := err.(net.Error); ok && ne.Timeout() {
+			t.Fatal("slow client was not dropped")
+		}
+	}
+}
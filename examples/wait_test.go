@@ -0,0 +1,2026 @@
+//go:build linux
+// +build linux
+
+// @@
+// @ Author       : Eacher
+// @ Date         : 2026-10-14 11:05:45
+// @ LastEditTime : 2026-10-24 18:26:56
+// @ LastEditors  : Eacher
+// @ --------------------------------------------------------------------------------<
+// @ Description  : 事件读取方式测试
+// @ --------------------------------------------------------------------------------<
+// @ FilePath     : /inotify/examples/wait_test.go
+// @@
+package inotify_test
+
+import (
+	"os"
+	"sync"
+	"time"
+	"errors"
+	"expvar"
+	"context"
+	"syscall"
+	"testing"
+	"strconv"
+	"encoding/json"
+	"path/filepath"
+	"github.com/20yyq/inotify"
+	"github.com/20yyq/inotify/eventpb"
+)
+
+func newTestWatcher(t *testing.T, flags uint32) (*inotify.Watcher, string) {
+	dir := t.TempDir()
+	w, err := inotify.NewWatcher()
+	if err != nil {
+		t.Fatal("NewWatcher", err)
+	}
+	t.Cleanup(func() { w.Close() })
+	if err = w.AddWatch(dir, flags); err != nil {
+		t.Fatal("AddWatch", err)
+	}
+	return w, dir
+}
+
+func TestWaitEventTimeout(t *testing.T) {
+	w, dir := newTestWatcher(t, inotify.IN_CREATE)
+	start := time.Now()
+	if _, ok, err := w.WaitEventTimeout(time.Millisecond*50); ok || err != nil {
+		t.Fatal("WaitEventTimeout without event", ok, err)
+	}
+	if time.Since(start) < time.Millisecond*50 {
+		t.Fatal("WaitEventTimeout returned early")
+	}
+	name := filepath.Join(dir, "a")
+	os.WriteFile(name, nil, 0644)
+	e, ok, err := w.WaitEventTimeout(time.Second)
+	if !ok || err != nil {
+		t.Fatal("WaitEventTimeout", ok, err)
+	}
+	if e.FileName != name || e.GetEventName() != "CREATE" {
+		t.Fatal("WaitEventTimeout event", e.FileName, e.GetEventName())
+	}
+	w.Close()
+	if _, _, err = w.WaitEventTimeout(time.Second); err != inotify.ErrClosed {
+		t.Fatal("WaitEventTimeout after Close", err)
+	}
+}
+
+func TestWaitEventInto(t *testing.T) {
+	w, dir := newTestWatcher(t, inotify.IN_MODIFY)
+	fa, _ := os.Create(filepath.Join(dir, "a"))
+	defer fa.Close()
+	fb, _ := os.Create(filepath.Join(dir, "b"))
+	defer fb.Close()
+	// 交替写入，避免内核合并相邻的相同事件
+	for i := 0; i < 5; i++ {
+		fa.Write([]byte("x"))
+		fb.Write([]byte("x"))
+	}
+	for deadline := time.Now().Add(time.Second); w.Stats().Read < 10 && time.Now().Before(deadline); {
+		time.Sleep(time.Millisecond*10)
+	}
+	var e inotify.Event
+	if ok, err := w.WaitEventInto(&e, time.Second); !ok || err != nil {
+		t.Fatal("WaitEventInto", ok, err)
+	}
+	if e.FileName != "" || e.Dir() != dir || e.Name() != "a" || e.Op != inotify.Write || e.Seq == 0 {
+		t.Fatal("event", e.FileName, e.Dir(), e.Name(), e.Op, e.Seq)
+	}
+	// 缓存中已有事件时不分配内存
+	if n := testing.AllocsPerRun(7, func() { w.WaitEventInto(&e, 0) }); n != 0 {
+		t.Fatal("allocs", n)
+	}
+	// 第 9 个事件，Name 随 e 的缓存改变
+	if e.Name() != "a" {
+		t.Fatal("Name", e.Name())
+	}
+}
+
+func TestWaitEventIntoEncode(t *testing.T) {
+	w, dir := newTestWatcher(t, inotify.IN_CREATE)
+	os.WriteFile(filepath.Join(dir, "a"), nil, 0644)
+	var e inotify.Event
+	if ok, err := w.WaitEventInto(&e, time.Second); !ok || err != nil {
+		t.Fatal("WaitEventInto", ok, err)
+	}
+	// FileName 为空时按 Path 编码
+	var v struct{ Path string }
+	if b, err := json.Marshal(e); err != nil || json.Unmarshal(b, &v) != nil || v.Path != filepath.Join(dir, "a") {
+		t.Fatal("MarshalJSON", v.Path, err)
+	}
+	if m := eventpb.FromEvent(e); m.Path != filepath.Join(dir, "a") {
+		t.Fatal("FromEvent", m.Path)
+	}
+}
+
+func TestPublish(t *testing.T) {
+	w, dir := newTestWatcher(t, inotify.IN_CREATE)
+	w.Publish("inotify_test_watcher")
+	os.WriteFile(filepath.Join(dir, "a"), nil, 0644)
+	w.WaitEventTimeout(time.Second)
+	var st inotify.Stats
+	if err := json.Unmarshal([]byte(expvar.Get("inotify_test_watcher").String()), &st); err != nil {
+		t.Fatal("Unmarshal", err)
+	}
+	if st.Read != 1 || st.Delivered != 1 || st.Watches != 1 {
+		t.Fatal("expvar", st)
+	}
+}
+
+func TestEventPath(t *testing.T) {
+	w, dir := newTestWatcher(t, inotify.IN_CREATE)
+	// 内核以 NUL 填充名字，FileName 与 Name 不包含填充
+	name := filepath.Join(dir, "a")
+	os.WriteFile(name, nil, 0644)
+	e, ok, err := w.WaitEventTimeout(time.Second)
+	if !ok || err != nil || e.FileName != name || e.Path() != name || e.Dir() != dir || e.Name() != "a" {
+		t.Fatalf("event %q %q %q %v %v", e.FileName, e.Dir(), e.Name(), ok, err)
+	}
+	os.WriteFile(filepath.Join(dir, "b"), nil, 0644)
+	if ws, err := w.WaitEvent(); err != nil || ws.FileName != filepath.Join(dir, "b") {
+		t.Fatalf("WaitEvent %q %v", ws.FileName, err)
+	}
+	os.WriteFile(filepath.Join(dir, "c"), nil, 0644)
+	var into inotify.Event
+	if ok, err = w.WaitEventInto(&into, time.Second); !ok || err != nil || into.FileName != "" || into.Path() != filepath.Join(dir, "c") {
+		t.Fatalf("WaitEventInto %q %v %v", into.Path(), ok, err)
+	}
+}
+
+func TestWaitStable(t *testing.T) {
+	const window = time.Millisecond*150
+	name := filepath.Join(t.TempDir(), "upload")
+	written := make(chan time.Time, 1)
+	go func() {
+		time.Sleep(time.Millisecond*50)
+		// 两次写入之间的 CLOSE_WRITE 不表示完成
+		for i := 0; i < 3; i++ {
+			if i > 0 {
+				time.Sleep(window/2)
+			}
+			f, _ := os.OpenFile(name, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+			f.Write([]byte("part"))
+			f.Close()
+		}
+		written <- time.Now()
+	}()
+	if err := inotify.WaitStable(name, window); err != nil {
+		t.Fatal("WaitStable", err)
+	}
+	// 最后一次写入之后至少等待了 window
+	if d := time.Since(<-written); d < window {
+		t.Fatal("WaitStable after the last write", d)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*100)
+	defer cancel()
+	if err := inotify.WaitStableContext(ctx, name, window); err != context.DeadlineExceeded {
+		t.Fatal("WaitStableContext without writes", err)
+	}
+}
+
+func TestWatchQuiet(t *testing.T) {
+	const quiet = time.Millisecond*100
+	dir := t.TempDir()
+	q, err := inotify.WatchQuiet(dir, quiet)
+	if err != nil {
+		t.Fatal("WatchQuiet", err)
+	}
+	for batch := 0; batch < 2; batch++ {
+		sub := filepath.Join(dir, strconv.Itoa(batch))
+		os.Mkdir(sub, 0755)
+		for i := 0; i < 5; i++ {
+			os.WriteFile(filepath.Join(sub, strconv.Itoa(i)), []byte("x"), 0644)
+			time.Sleep(quiet/5)
+		}
+		written := time.Now()
+		select {
+		case <-q.C:
+			if d := time.Since(written); d < quiet/2 {
+				t.Fatal("quiet too early", d)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("batch", batch, "no quiet")
+		}
+		// 一批只通知一次
+		select {
+		case at := <-q.C:
+			t.Fatal("quiet twice", at)
+		case <-time.After(quiet*2):
+		}
+	}
+	q.Close()
+	if _, ok := <-q.C; ok {
+		t.Fatal("C not closed")
+	}
+}
+
+func TestFileInfo(t *testing.T) {
+	dir := t.TempDir()
+	w := inotify.MustNewWatcher(inotify.WithFileInfo())
+	defer w.Close()
+	if err := w.AddWatch(dir, inotify.IN_CLOSE_WRITE|inotify.IN_DELETE); err != nil {
+		t.Fatal("AddWatch", err)
+	}
+	name := filepath.Join(dir, "a")
+	os.WriteFile(name, []byte("abc"), 0640)
+	e, ok, err := w.WaitEventTimeout(time.Second)
+	if !ok || err != nil || e.Info == nil || e.Info.Size() != 3 || e.Info.Mode().Perm() != 0640 || e.InfoRaced {
+		t.Fatal("CLOSE_WRITE", e.Info, e.InfoRaced, ok, err)
+	}
+	// 事件读到之后又写入，Info 为 lstat 时的状态
+	os.WriteFile(name, []byte("x"), 0640)
+	time.Sleep(time.Millisecond*50)
+	os.WriteFile(name, []byte("abcdef"), 0640)
+	if e, ok, err = w.WaitEventTimeout(time.Second); !ok || err != nil || e.Info == nil || e.Info.Size() != 6 || !e.InfoRaced {
+		t.Fatal("raced", e.Info, e.InfoRaced, ok, err)
+	}
+	w.WaitEventTimeout(time.Second)
+	os.Remove(name)
+	if e, ok, err = w.WaitEventTimeout(time.Second); !ok || err != nil || e.Raw != inotify.IN_DELETE || e.Info != nil {
+		t.Fatal("DELETE", e.GetEventName(), e.Info, ok, err)
+	}
+}
+
+func TestAttribDiff(t *testing.T) {
+	dir := t.TempDir()
+	name := filepath.Join(dir, "a")
+	os.WriteFile(name, nil, 0644)
+	w := inotify.MustNewWatcher(inotify.WithAttribDiff())
+	defer w.Close()
+	if err := w.AddWatch(dir, inotify.IN_ATTRIB|inotify.IN_CREATE); err != nil {
+		t.Fatal("AddWatch", err)
+	}
+	// 添加监听前已存在的文件
+	os.Chmod(name, 0600)
+	e, ok, err := w.WaitEventTimeout(time.Second)
+	if !ok || err != nil || e.Attrib == nil || e.Attrib.Before.Mode != 0644 || e.Attrib.After.Mode != 0600 {
+		t.Fatal("chmod", e.Attrib, ok, err)
+	}
+	if changed := e.Attrib.Changed(); len(changed) != 1 || changed[0] != "mode" {
+		t.Fatal("Changed", changed)
+	}
+	// 由 CREATE 缓存的文件
+	created := filepath.Join(dir, "b")
+	os.WriteFile(created, nil, 0644)
+	w.WaitEventTimeout(time.Second)
+	at := time.Now().Add(-time.Hour).Truncate(time.Second)
+	os.Chtimes(created, at, at)
+	if e, ok, err = w.WaitEventTimeout(time.Second); !ok || err != nil || e.Attrib == nil || !e.Attrib.After.Mtime.Equal(at) {
+		t.Fatal("chtimes", e.Attrib, ok, err)
+	}
+	if changed := e.Attrib.Changed(); len(changed) != 2 || changed[0] != "atime" || changed[1] != "mtime" {
+		t.Fatal("Changed", changed)
+	}
+}
+
+func TestSnapshot(t *testing.T) {
+	dir := t.TempDir()
+	old := filepath.Join(dir, "a")
+	os.WriteFile(old, []byte("a"), 0644)
+	w := inotify.MustNewWatcher(inotify.WithSnapshot())
+	defer w.Close()
+	// 只监听 CLOSE_WRITE，CREATE、DELETE 只更新快照
+	if err := w.AddWatch(dir, inotify.IN_CLOSE_WRITE); err != nil {
+		t.Fatal("AddWatch", err)
+	}
+	if a, ok := w.Snapshot()[old]; !ok || a.Size != 1 || a.Ino == 0 {
+		t.Fatal("Snapshot", w.Snapshot())
+	}
+	created := filepath.Join(dir, "b")
+	os.WriteFile(created, []byte("bb"), 0644)
+	os.Remove(old)
+	if e, ok, err := w.WaitEventTimeout(time.Second); !ok || err != nil || e.Raw != inotify.IN_CLOSE_WRITE || e.FileName != created {
+		t.Fatal("CLOSE_WRITE", e, ok, err)
+	}
+	// DELETE 与 CLOSE_WRITE 在同一次或下一次读取
+	var m map[string]inotify.FileAttr
+	for deadline := time.Now().Add(time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		if m = w.Snapshot(); len(m) == 2 {
+			break
+		}
+	}
+	if _, ok := m[old]; ok || m[created].Size != 2 || !m[dir].Mode.IsDir() {
+		t.Fatal("Snapshot", m)
+	}
+	if _, ok := w.TryEvent(); ok {
+		t.Fatal("unwanted event")
+	}
+}
+
+func TestSnapshotFile(t *testing.T) {
+	dir, file := t.TempDir(), filepath.Join(t.TempDir(), "snapshot.json")
+	for _, name := range []string{"a", "b"} {
+		os.WriteFile(filepath.Join(dir, name), []byte(name), 0644)
+	}
+	const flags = inotify.IN_CREATE|inotify.IN_DELETE|inotify.IN_MODIFY
+	w := inotify.MustNewWatcher(inotify.WithSnapshotFile(file))
+	if err := w.AddWatch(dir, flags); err != nil {
+		t.Fatal("AddWatch", err)
+	}
+	// 第一次启动不补发
+	if e, ok := w.TryEvent(); ok {
+		t.Fatal("first start", e)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal("Close", err)
+	}
+	// 进程不在时的变化
+	os.WriteFile(filepath.Join(dir, "a"), []byte("aa"), 0644)
+	os.Remove(filepath.Join(dir, "b"))
+	os.WriteFile(filepath.Join(dir, "c"), nil, 0644)
+	w = inotify.MustNewWatcher(inotify.WithSnapshotFile(file))
+	defer w.Close()
+	if err := w.AddWatch(dir, flags); err != nil {
+		t.Fatal("AddWatch", err)
+	}
+	want := []struct{ name string; raw uint32 }{{"a", inotify.IN_MODIFY}, {"b", inotify.IN_DELETE}, {"c", inotify.IN_CREATE}}
+	for _, v := range want {
+		if e, ok := w.TryEvent(); !ok || e.FileName != filepath.Join(dir, v.name) || e.Raw != v.raw {
+			t.Fatal("replay", v.name, e, ok)
+		}
+	}
+	// 每个监听只对比一次
+	if err := w.AddWatch(dir, flags); err != nil {
+		t.Fatal("AddWatch", err)
+	}
+	if e, ok := w.TryEvent(); ok {
+		t.Fatal("replayed twice", e)
+	}
+}
+
+func TestIdleUnwatch(t *testing.T) {
+	kept, idle, busy := t.TempDir(), t.TempDir(), t.TempDir()
+	var mutex sync.Mutex
+	asked := make(map[string]int)
+	w := inotify.MustNewWatcher(inotify.WithIdleUnwatch(200*time.Millisecond, func(path string) bool {
+		mutex.Lock()
+		defer mutex.Unlock()
+		asked[path]++
+		return path != kept
+	}))
+	defer w.Close()
+	for _, dir := range []string{kept, idle, busy} {
+		if err := w.AddWatch(dir, inotify.IN_CREATE); err != nil {
+			t.Fatal("AddWatch", err)
+		}
+	}
+	for i := 0; i < 10; i++ {
+		os.WriteFile(filepath.Join(busy, strconv.Itoa(i)), nil, 0644)
+		time.Sleep(50 * time.Millisecond)
+	}
+	mutex.Lock()
+	if asked[idle] != 1 || asked[kept] == 0 || asked[busy] != 0 {
+		t.Fatal("before", asked)
+	}
+	mutex.Unlock()
+	if err := w.RemoveWatch(idle); !errors.Is(err, inotify.ErrNotWatched) {
+		t.Fatal("idle watch not removed", err)
+	}
+	for _, dir := range []string{kept, busy} {
+		if err := w.RemoveWatch(dir); err != nil {
+			t.Fatal("RemoveWatch", dir, err)
+		}
+	}
+}
+
+func TestMaxWatches(t *testing.T) {
+	dirs := []string{t.TempDir(), t.TempDir(), t.TempDir()}
+	var evicted []string
+	w := inotify.MustNewWatcher(inotify.WithMaxWatches(2, func(path string) { evicted = append(evicted, path) }))
+	defer w.Close()
+	for _, dir := range dirs[:2] {
+		if err := w.AddWatch(dir, inotify.IN_CREATE); err != nil {
+			t.Fatal("AddWatch", err)
+		}
+	}
+	// dirs[0] 有事件，dirs[1] 最久没有活动
+	time.Sleep(10 * time.Millisecond)
+	os.WriteFile(filepath.Join(dirs[0], "a"), nil, 0644)
+	if _, ok, err := w.WaitEventTimeout(time.Second); !ok || err != nil {
+		t.Fatal("WaitEventTimeout", ok, err)
+	}
+	// 已监听的路径不算新的监听
+	if err := w.AddWatch(dirs[0], inotify.IN_DELETE); err != nil || len(evicted) != 0 {
+		t.Fatal("AddWatch", err, evicted)
+	}
+	if err := w.AddWatch(dirs[2], inotify.IN_CREATE); err != nil {
+		t.Fatal("AddWatch", err)
+	}
+	if len(evicted) != 1 || evicted[0] != dirs[1] {
+		t.Fatal("evicted", evicted)
+	}
+	if err := w.RemoveWatch(dirs[1]); !errors.Is(err, inotify.ErrNotWatched) {
+		t.Fatal("evicted watch not removed", err)
+	}
+}
+
+func TestReadRaw(t *testing.T) {
+	w, dir := newTestWatcher(t, inotify.IN_CREATE|inotify.IN_DELETE)
+	os.WriteFile(filepath.Join(dir, "a"), nil, 0644)
+	os.Remove(filepath.Join(dir, "a"))
+	var raws []inotify.RawEvent
+	for deadline := time.Now().Add(time.Second); len(raws) < 2 && time.Now().Before(deadline); {
+		r, err := w.ReadRaw()
+		if err != nil {
+			t.Fatal("ReadRaw", err)
+		}
+		raws = append(raws, r...)
+	}
+	if len(raws) != 2 || raws[0].Mask != inotify.IN_CREATE || raws[1].Mask != inotify.IN_DELETE || raws[0].Wd != raws[1].Wd {
+		t.Fatal("ReadRaw", raws)
+	}
+	// 名字保留内核填充的 NUL
+	if r := raws[0]; int(r.Len) != len(r.Name) || r.Len%16 != 0 || string(r.Name[:2]) != "a\x00" {
+		t.Fatal("Name", r.Len, r.Name)
+	}
+	if st := w.Stats(); st.Delivered != 2 {
+		t.Fatal("Delivered", st.Delivered)
+	}
+}
+
+func TestTryEvent(t *testing.T) {
+	w, dir := newTestWatcher(t, inotify.IN_CREATE)
+	if _, ok := w.TryEvent(); ok {
+		t.Fatal("TryEvent without event")
+	}
+	for _, name := range []string{"a", "b"} {
+		os.WriteFile(filepath.Join(dir, name), nil, 0644)
+	}
+	var names []string
+	for deadline := time.Now().Add(time.Second); len(names) < 2 && time.Now().Before(deadline); {
+		if e, ok := w.TryEvent(); ok {
+			names = append(names, filepath.Base(e.FileName))
+			continue
+		}
+		time.Sleep(time.Millisecond*10)
+	}
+	if len(names) != 2 || names[0] != "a" || names[1] != "b" {
+		t.Fatal("TryEvent events", names)
+	}
+	w.Close()
+	if _, ok := w.TryEvent(); ok {
+		t.Fatal("TryEvent after Close")
+	}
+}
+
+// 多个 goroutine 同时 WaitEvent，每个事件只交给其中一个
+func TestMultipleConsumers(t *testing.T) {
+	w, dir := newTestWatcher(t, inotify.IN_CREATE)
+	const consumers, batch, batches = 4, 4, 25
+	var mutex sync.Mutex
+	seen := make(map[string]int)
+	received := make(chan struct{}, batch*batches)
+	var wg sync.WaitGroup
+	for i := 0; i < consumers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				ws, err := w.WaitEvent()
+				if err == inotify.ErrClosed {
+					return
+				}
+				if err != nil {
+					t.Error("WaitEvent", err)
+					return
+				}
+				mutex.Lock()
+				seen[filepath.Base(ws.FileName)]++
+				mutex.Unlock()
+				received <- struct{}{}
+			}
+		}()
+	}
+	for b := 0; b < batches; b++ {
+		for i := 0; i < batch; i++ {
+			os.WriteFile(filepath.Join(dir, strconv.Itoa(b*batch+i)), nil, 0644)
+		}
+		for i := 0; i < batch; i++ {
+			select {
+			case <-received:
+			case <-time.After(time.Second*2):
+				t.Fatal("batch", b, "event lost")
+			}
+		}
+	}
+	w.Close()
+	wg.Wait()
+	if len(seen) != batch*batches {
+		t.Fatal("events", len(seen))
+	}
+	for name, n := range seen {
+		if n != 1 {
+			t.Fatal(name, "delivered", n)
+		}
+	}
+}
+
+func TestDispatch(t *testing.T) {
+	const dirs, files = 4, 20
+	// 不丢弃事件，检查每个目录的顺序
+	w := inotify.MustNewWatcher(inotify.WithBackpressure(inotify.Block))
+	root := t.TempDir()
+	for d := 0; d < dirs; d++ {
+		dir := filepath.Join(root, strconv.Itoa(d))
+		os.Mkdir(dir, 0755)
+		if err := w.AddWatch(dir, inotify.IN_CREATE); err != nil {
+			t.Fatal("AddWatch", err)
+		}
+	}
+	var mutex sync.Mutex
+	order := make(map[string][]int)
+	received := make(chan struct{}, dirs*files)
+	done := make(chan error)
+	go func() {
+		done <- w.Dispatch(3, func(e inotify.Event) {
+			n, _ := strconv.Atoi(e.Name())
+			mutex.Lock()
+			order[e.Dir()] = append(order[e.Dir()], n)
+			mutex.Unlock()
+			received <- struct{}{}
+		})
+	}()
+	for i := 0; i < files; i++ {
+		for d := 0; d < dirs; d++ {
+			os.WriteFile(filepath.Join(root, strconv.Itoa(d), strconv.Itoa(i)), nil, 0644)
+		}
+	}
+	for i := 0; i < dirs*files; i++ {
+		select {
+		case <-received:
+		case <-time.After(time.Second*2):
+			t.Fatal("event lost", i)
+		}
+	}
+	w.Close()
+	if err := <-done; err != inotify.ErrClosed {
+		t.Fatal("Dispatch", err)
+	}
+	// 同一目录的事件按顺序处理
+	for dir, names := range order {
+		for i, n := range names {
+			if n != i {
+				t.Fatal(dir, "order", names)
+			}
+		}
+	}
+}
+
+func TestBackpressure(t *testing.T) {
+	const files = 100
+	for _, b := range []inotify.Backpressure{inotify.DropOldest, inotify.DropNewest, inotify.Block} {
+		dir := t.TempDir()
+		w, err := inotify.NewWatcher(inotify.WithBackpressure(b))
+		if err != nil {
+			t.Fatal("NewWatcher", err)
+		}
+		if err = w.AddWatch(dir, inotify.IN_CREATE); err != nil {
+			t.Fatal("AddWatch", err)
+		}
+		for i := 0; i < files; i++ {
+			os.WriteFile(filepath.Join(dir, strconv.Itoa(i)), nil, 0644)
+		}
+		time.Sleep(time.Millisecond*100)
+		var got []string
+		var last uint64
+		dropped := false
+		for {
+			e, ok, err := w.WaitEventTimeout(time.Millisecond*100)
+			if err == inotify.ErrDropped {
+				dropped = true
+				continue
+			}
+			if err != nil {
+				t.Fatal(b, "WaitEventTimeout", err)
+			}
+			if !ok {
+				break
+			}
+			got = append(got, filepath.Base(e.FileName))
+			if e.Seq <= last {
+				t.Fatal(b, "Seq", e.Seq, last)
+			}
+			last = e.Seq
+		}
+		if st := w.Stats(); st.Read != files || st.Dropped != uint64(files-len(got)) || st.Delivered != uint64(len(got)) {
+			t.Fatalf("%v Stats %+v %d", b, st, len(got))
+		}
+		switch b {
+		case inotify.DropOldest:
+			// 丢弃的事件占用序号
+			if len(got) == 0 || len(got) == files || got[len(got)-1] != strconv.Itoa(files-1) || last != files {
+				t.Fatal("DropOldest", len(got), got, last)
+			}
+		case inotify.DropNewest:
+			if !dropped || len(got) == 0 || len(got) == files || got[0] != "0" || last != uint64(len(got)) {
+				t.Fatal("DropNewest", dropped, len(got), got, last)
+			}
+			// 丢弃的事件在已缓存的事件之后，计入下一个事件之前
+			os.WriteFile(filepath.Join(dir, "last"), nil, 0644)
+			if e, ok, _ := w.WaitEventTimeout(time.Second); !ok || e.Seq != files+1 {
+				t.Fatal("DropNewest Seq", ok, e.Seq)
+			}
+		case inotify.Block:
+			if len(got) != files || last != files {
+				t.Fatal("Block", len(got), last)
+			}
+		}
+		w.Close()
+	}
+}
+
+func TestEventFilter(t *testing.T) {
+	dir := t.TempDir()
+	w, err := inotify.NewWatcher(inotify.WithEventFilter(func(e inotify.Event) bool {
+		return filepath.Ext(e.FileName) == ".go"
+	}))
+	if err != nil {
+		t.Fatal("NewWatcher", err)
+	}
+	defer w.Close()
+	if err = w.AddWatch(dir, inotify.IN_CREATE); err != nil {
+		t.Fatal("AddWatch", err)
+	}
+	for _, name := range []string{"a.txt", "b.go", "c.txt", "d.go"} {
+		os.WriteFile(filepath.Join(dir, name), nil, 0644)
+	}
+	for _, want := range []string{"b.go", "d.go"} {
+		e, ok, err := w.WaitEventTimeout(time.Second)
+		if !ok || err != nil || filepath.Base(e.FileName) != want {
+			t.Fatal("WaitEventTimeout", want, e.FileName, ok, err)
+		}
+	}
+	if e, ok, _ := w.WaitEventTimeout(time.Millisecond*50); ok {
+		t.Fatal("unexpected event", e.FileName)
+	}
+}
+
+func TestModifyDedup(t *testing.T) {
+	dir := t.TempDir()
+	w, err := inotify.NewWatcher(inotify.WithModifyDedup(time.Second))
+	if err != nil {
+		t.Fatal("NewWatcher", err)
+	}
+	defer w.Close()
+	if err = w.AddWatch(dir, inotify.IN_MODIFY|inotify.IN_CLOSE_WRITE); err != nil {
+		t.Fatal("AddWatch", err)
+	}
+	name := filepath.Join(dir, "a")
+	for i := 0; i < 2; i++ {
+		f, err := os.Create(name)
+		if err != nil {
+			t.Fatal("Create", err)
+		}
+		for j := 0; j < 50; j++ {
+			f.Write([]byte("data"))
+		}
+		f.Close()
+	}
+	var got []string
+	for {
+		e, ok, err := w.WaitEventTimeout(time.Millisecond*100)
+		if err != nil {
+			t.Fatal("WaitEventTimeout", err)
+		}
+		if !ok {
+			break
+		}
+		got = append(got, e.GetEventName())
+	}
+	if len(got) != 4 || got[0] != "MODIFY" || got[1] != "CLOSE_WRITE" || got[2] != "MODIFY" || got[3] != "CLOSE_WRITE" {
+		t.Fatal("events", got)
+	}
+}
+
+func TestWatchGroup(t *testing.T) {
+	w, err := inotify.NewWatcher()
+	if err != nil {
+		t.Fatal("NewWatcher", err)
+	}
+	defer w.Close()
+	configs, logs := t.TempDir(), t.TempDir()
+	if err = w.AddWatchGroup("configs", configs, inotify.IN_CREATE); err != nil {
+		t.Fatal("AddWatchGroup", err)
+	}
+	if err = w.AddWatchGroup("logs", logs, inotify.IN_CREATE); err != nil {
+		t.Fatal("AddWatchGroup", err)
+	}
+	if err = w.PauseGroup("logs"); err != nil {
+		t.Fatal("PauseGroup", err)
+	}
+	os.WriteFile(filepath.Join(logs, "a"), nil, 0644)
+	os.WriteFile(filepath.Join(configs, "a"), nil, 0644)
+	e, ok, err := w.WaitEventTimeout(time.Second)
+	if !ok || err != nil || e.Group != "configs" || e.FileName != filepath.Join(configs, "a") {
+		t.Fatal("PauseGroup", e, ok, err)
+	}
+	if err = w.ResumeGroup("logs"); err != nil {
+		t.Fatal("ResumeGroup", err)
+	}
+	os.WriteFile(filepath.Join(logs, "b"), nil, 0644)
+	if e, ok, err = w.WaitEventTimeout(time.Second); !ok || err != nil || e.Group != "logs" {
+		t.Fatal("ResumeGroup", e, ok, err)
+	}
+	if err = w.RemoveGroup("configs"); err != nil {
+		t.Fatal("RemoveGroup", err)
+	}
+	// 移除后只剩 IGNORED
+	if e, ok, err = w.WaitEventTimeout(time.Second); !ok || err != nil || e.GetEventName() != "REMOVE" {
+		t.Fatal("RemoveGroup event", e, ok, err)
+	}
+	os.WriteFile(filepath.Join(configs, "b"), nil, 0644)
+	if e, ok, _ = w.WaitEventTimeout(time.Millisecond*100); ok {
+		t.Fatal("event after RemoveGroup", e)
+	}
+	if err = w.RemoveWatch(configs); err == nil {
+		t.Fatal("RemoveWatch removed path")
+	}
+	if err = w.RemoveWatch(logs); err != nil {
+		t.Fatal("RemoveWatch", err)
+	}
+	w.Close()
+	if err = w.PauseGroup("logs"); err != inotify.ErrClosed {
+		t.Fatal("PauseGroup after Close", err)
+	}
+	if err = w.ResumeGroup("logs"); err != inotify.ErrClosed {
+		t.Fatal("ResumeGroup after Close", err)
+	}
+}
+
+func TestRemoveAll(t *testing.T) {
+	w, dir := newTestWatcher(t, inotify.IN_CREATE)
+	sub := filepath.Join(dir, "sub")
+	os.Mkdir(sub, 0755)
+	if err := w.AddWatchGroup("sub", sub, inotify.IN_CREATE); err != nil {
+		t.Fatal("AddWatchGroup", err)
+	}
+	os.WriteFile(filepath.Join(sub, "a"), nil, 0644)
+	time.Sleep(time.Millisecond*50)
+	// 丢弃已读取的两个 CREATE(sub 与 sub/a)，只剩两个 IGNORED
+	if err := w.RemoveAll(true); err != nil {
+		t.Fatal("RemoveAll", err)
+	}
+	for i := 0; i < 2; i++ {
+		if e, ok, err := w.WaitEventTimeout(time.Second); !ok || err != nil || e.Raw != inotify.IN_IGNORED {
+			t.Fatal("IGNORED", e, ok, err)
+		}
+	}
+	os.WriteFile(filepath.Join(dir, "b"), nil, 0644)
+	if e, ok, _ := w.WaitEventTimeout(time.Millisecond*100); ok {
+		t.Fatal("event after RemoveAll", e)
+	}
+	if s := w.Stats(); s.Watches != 0 || s.Filtered != 2 {
+		t.Fatal("Stats", s)
+	}
+	// 仍可重新添加
+	if err := w.AddWatch(dir, inotify.IN_CREATE); err != nil {
+		t.Fatal("AddWatch", err)
+	}
+	os.WriteFile(filepath.Join(dir, "c"), nil, 0644)
+	if e, ok, err := w.WaitEventTimeout(time.Second); !ok || err != nil || e.FileName != filepath.Join(dir, "c") {
+		t.Fatal("event after AddWatch", e, ok, err)
+	}
+}
+
+func TestWatchData(t *testing.T) {
+	w, err := inotify.NewWatcher()
+	if err != nil {
+		t.Fatal("NewWatcher", err)
+	}
+	defer w.Close()
+	dir := t.TempDir()
+	type ctx struct{ name string }
+	if err = w.AddWatchData(dir, inotify.IN_CREATE, &ctx{"uploads"}); err != nil {
+		t.Fatal("AddWatchData", err)
+	}
+	os.WriteFile(filepath.Join(dir, "a"), nil, 0644)
+	e, ok, err := w.WaitEventTimeout(time.Second)
+	if c, _ := e.Data.(*ctx); !ok || err != nil || c == nil || c.name != "uploads" {
+		t.Fatal("Event.Data", e, ok, err)
+	}
+	// 不带 data 重复添加不会清除原来的 data
+	w.AddWatch(dir, inotify.IN_DELETE)
+	os.Remove(filepath.Join(dir, "a"))
+	if ws, err := w.WaitEvent(); err != nil || ws.Data() == nil {
+		t.Fatal("WatchSingle.Data", ws.Data(), err)
+	}
+}
+
+func TestEventSource(t *testing.T) {
+	w, dir := newTestWatcher(t, inotify.IN_CREATE)
+	var reg inotify.Registrar = w
+	var src inotify.EventSource = w
+	sub := filepath.Join(dir, "sub")
+	os.Mkdir(sub, 0755)
+	if err := reg.AddWatch(sub, inotify.IN_CREATE); err != nil {
+		t.Fatal("Registrar.AddWatch", err)
+	}
+	os.WriteFile(filepath.Join(sub, "a"), nil, 0644)
+	for _, name := range []string{sub, filepath.Join(sub, "a")} {
+		select {
+		case e := <-src.Events():
+			if e.FileName != name {
+				t.Fatal("Events", e.FileName, name)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("Events timeout", name)
+		}
+	}
+	if err := reg.RemoveWatch(sub); err != nil {
+		t.Fatal("Registrar.RemoveWatch", err)
+	}
+	src.Close()
+	for range src.Events() {
+	}
+	if _, ok := <-src.Errors(); ok {
+		t.Fatal("Errors not closed")
+	}
+}
+
+func TestRateLimit(t *testing.T) {
+	dir := t.TempDir()
+	w, err := inotify.NewWatcher(inotify.WithRateLimit(5, 2))
+	if err != nil {
+		t.Fatal("NewWatcher", err)
+	}
+	defer w.Close()
+	if err = w.AddWatch(dir, inotify.IN_MODIFY|inotify.IN_CREATE); err != nil {
+		t.Fatal("AddWatch", err)
+	}
+	a, b, quiet := filepath.Join(dir, "a"), filepath.Join(dir, "b"), filepath.Join(dir, "quiet")
+	fa, _ := os.Create(a)
+	defer fa.Close()
+	fb, _ := os.Create(b)
+	defer fb.Close()
+	// 交替写入，避免内核合并相邻的相同事件
+	for i := 0; i < 25; i++ {
+		fa.Write([]byte("x"))
+		fb.Write([]byte("x"))
+	}
+	os.WriteFile(quiet, nil, 0644)
+	counts, summary := map[string]int{}, map[string]inotify.Event{}
+	for {
+		e, ok, err := w.WaitEventTimeout(time.Millisecond*500)
+		if err != nil {
+			t.Fatal("WaitEventTimeout", err)
+		}
+		if !ok {
+			break
+		}
+		counts[e.FileName]++
+		if e.Count > 0 {
+			summary[e.FileName] = e
+		}
+	}
+	// CREATE 与第一个 MODIFY 用掉突发的令牌，其余合并为一个汇总事件
+	if counts[a] != 3 || counts[b] != 3 || counts[quiet] != 1 {
+		t.Fatal("WithRateLimit", counts)
+	}
+	if e := summary[a]; e.Count != 24 || e.Raw != inotify.IN_MODIFY {
+		t.Fatal("summary", e.FileName, e.Count, e.Raw)
+	}
+	// rate 不大于 0 时不限速
+	for _, rate := range []float64{0, -1} {
+		w, err := inotify.NewWatcher(inotify.WithRateLimit(rate, 1), inotify.WithBackpressure(inotify.Block))
+		if err != nil {
+			t.Fatal("NewWatcher", err)
+		}
+		defer w.Close()
+		if err = w.AddWatch(dir, inotify.IN_MODIFY); err != nil {
+			t.Fatal("AddWatch", err)
+		}
+		for i := 0; i < 5; i++ {
+			fa.Write([]byte("x"))
+			fb.Write([]byte("x"))
+		}
+		n := 0
+		for {
+			e, ok, err := w.WaitEventTimeout(time.Millisecond*200)
+			if err != nil || (ok && e.Count > 0) {
+				t.Fatal("WaitEventTimeout", rate, e.Count, err)
+			}
+			if !ok {
+				break
+			}
+			n++
+		}
+		if n != 10 {
+			t.Fatal("WithRateLimit", rate, n)
+		}
+	}
+}
+
+func TestCoalesceKey(t *testing.T) {
+	dir := t.TempDir()
+	w, err := inotify.NewWatcher(inotify.WithRateLimit(5, 1), inotify.WithCoalesceKey(inotify.ByPathOp))
+	if err != nil {
+		t.Fatal("NewWatcher", err)
+	}
+	defer w.Close()
+	name := filepath.Join(dir, "a")
+	f, _ := os.Create(name)
+	defer f.Close()
+	if err = w.AddWatch(dir, inotify.IN_MODIFY|inotify.IN_ATTRIB); err != nil {
+		t.Fatal("AddWatch", err)
+	}
+	for i := 0; i < 10; i++ {
+		f.Write([]byte("x"))
+		os.Chmod(name, 0600|os.FileMode(i%2)<<7)
+	}
+	counts := map[inotify.Op]int{}
+	for {
+		e, ok, err := w.WaitEventTimeout(time.Millisecond*500)
+		if err != nil {
+			t.Fatal("WaitEventTimeout", err)
+		}
+		if !ok {
+			break
+		}
+		counts[e.Op]++
+	}
+	// 同一文件的 Write 与 Chmod 分别限速，各有一个事件与一个汇总事件
+	if counts[inotify.Write] != 2 || counts[inotify.Chmod] != 2 {
+		t.Fatal("WithCoalesceKey", counts)
+	}
+}
+
+func TestWatchFlags(t *testing.T) {
+	w, dir := newTestWatcher(t, inotify.IN_CREATE)
+	// 已有监听时 IN_MASK_CREATE 返回 EEXIST
+	if err := w.AddWatch(dir, inotify.IN_DELETE|inotify.IN_MASK_CREATE); err == nil {
+		t.Fatal("IN_MASK_CREATE on watched path")
+	}
+	name := filepath.Join(dir, "a")
+	os.WriteFile(name, nil, 0644)
+	if err := w.AddWatch(name, inotify.IN_ALL_EVENTS|inotify.IN_ONESHOT|inotify.IN_ONLYDIR); err == nil {
+		t.Fatal("IN_ONLYDIR on file")
+	}
+	if err := w.AddWatch(name, inotify.IN_ATTRIB|inotify.IN_ONESHOT); err != nil {
+		t.Fatal("AddWatch", err)
+	}
+	if e, ok, err := w.WaitEventTimeout(time.Second); !ok || err != nil || e.Raw != inotify.IN_CREATE {
+		t.Fatal("CREATE", e, ok, err)
+	}
+	os.Chmod(name, 0600)
+	os.Chmod(name, 0644)
+	// IN_ONESHOT 只收到一个 ATTRIB，之后是 IGNORED
+	for _, mask := range []uint32{inotify.IN_ATTRIB, inotify.IN_IGNORED} {
+		if e, ok, err := w.WaitEventTimeout(time.Second); !ok || err != nil || e.Raw != mask {
+			t.Fatal("IN_ONESHOT", e.Raw, mask, ok, err)
+		}
+	}
+}
+
+func TestUpdateWatch(t *testing.T) {
+	w, dir := newTestWatcher(t, inotify.IN_CREATE|inotify.IN_DELETE)
+	if err := w.UpdateWatch(dir, inotify.IN_DELETE); err != nil {
+		t.Fatal("UpdateWatch", err)
+	}
+	name := filepath.Join(dir, "a")
+	os.WriteFile(name, nil, 0644)
+	os.Remove(name)
+	// CREATE 已不在监听中
+	if e, ok, err := w.WaitEventTimeout(time.Second); !ok || err != nil || e.Raw != inotify.IN_DELETE {
+		t.Fatal("UpdateWatch event", e, ok, err)
+	}
+	if err := w.UpdateWatch(filepath.Join(dir, "b"), inotify.IN_DELETE); err == nil {
+		t.Fatal("UpdateWatch path not watched")
+	}
+}
+
+func TestWatchError(t *testing.T) {
+	w, dir := newTestWatcher(t, inotify.IN_CREATE)
+	missing := filepath.Join(dir, "missing")
+	err := w.AddWatch(missing, inotify.IN_CREATE)
+	var we *inotify.WatchError
+	if !errors.Is(err, inotify.ErrNotExist) || !errors.Is(err, os.ErrNotExist) || !errors.As(err, &we) || we.Path != missing {
+		t.Fatal("AddWatch missing", err)
+	}
+	// 系统调用的错误保留 errno 与调用名
+	var se *os.SyscallError
+	if !errors.As(err, &se) || se.Syscall != "inotify_add_watch" || se.Err != syscall.ENOENT {
+		t.Fatal("AddWatch SyscallError", err)
+	}
+	if err = w.RemoveWatch(missing); !errors.Is(err, inotify.ErrNotWatched) {
+		t.Fatal("RemoveWatch not watched", err)
+	}
+	if err = w.UpdateWatch(missing, inotify.IN_CREATE); !errors.Is(err, inotify.ErrNotWatched) {
+		t.Fatal("UpdateWatch not watched", err)
+	}
+	if err = (&inotify.WatchError{Path: dir, Err: syscall.ENOSPC}); !errors.Is(err, inotify.ErrWatchLimit) || !errors.Is(err, syscall.ENOSPC) {
+		t.Fatal("WatchError ENOSPC", err)
+	}
+	w.Close()
+	if err = w.AddWatch(dir, inotify.IN_CREATE); !errors.Is(err, inotify.ErrClosed) {
+		t.Fatal("AddWatch after Close", err)
+	}
+
+	w, err = inotify.NewWatcher(inotify.WithInjection())
+	if err != nil {
+		t.Fatal("NewWatcher", err)
+	}
+	defer w.Close()
+	events, errs := w.Events(), w.Errors()
+	w.Inject(inotify.Event{Raw: inotify.IN_Q_OVERFLOW})
+	if e := <-events; e.Raw != inotify.IN_Q_OVERFLOW {
+		t.Fatal("overflow event", e)
+	}
+	select {
+	case err = <-errs:
+		if err != inotify.ErrOverflow {
+			t.Fatal("Errors", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("ErrOverflow not sent")
+	}
+}
+
+func TestAccessEvent(t *testing.T) {
+	w, dir := newTestWatcher(t, inotify.IN_ACCESS|inotify.IN_CREATE)
+	name := filepath.Join(dir, "a")
+	os.WriteFile(name, []byte("x"), 0644)
+	os.ReadFile(name)
+	os.Mkdir(filepath.Join(dir, "sub"), 0755)
+	for _, want := range []struct{ name string; dir bool }{{"CREATE", false}, {"ACCESS", false}, {"CREATE", true}} {
+		e, ok, err := w.WaitEventTimeout(time.Second)
+		if !ok || err != nil || e.GetEventName() != want.name || e.IsDir() != want.dir {
+			t.Fatal("event", e.GetEventName(), e.IsDir(), want, ok, err)
+		}
+	}
+}
+
+func TestDeleteSelf(t *testing.T) {
+	w, dir := newTestWatcher(t, inotify.IN_DELETE_SELF)
+	sub := filepath.Join(dir, "sub")
+	os.Mkdir(sub, 0755)
+	if err := w.AddWatch(sub, inotify.IN_DELETE_SELF); err != nil {
+		t.Fatal("AddWatch", err)
+	}
+	os.Remove(sub)
+	// 不调用 GetEventName，取出 IGNORED 后监听也已移除
+	for _, mask := range []uint32{inotify.IN_DELETE_SELF, inotify.IN_IGNORED} {
+		if e, ok, err := w.WaitEventTimeout(time.Second); !ok || err != nil || e.Raw != mask {
+			t.Fatal("event", e.Raw, mask, ok, err)
+		}
+	}
+	if err := w.RemoveWatch(sub); err == nil {
+		t.Fatal("watch not removed after IGNORED")
+	}
+	if name := (inotify.Event{Raw: inotify.IN_IGNORED}).GetEventName(); name != "REMOVE" {
+		t.Fatal("GetEventName", name)
+	}
+}
+
+func TestEventOp(t *testing.T) {
+	w, dir := newTestWatcher(t, inotify.IN_CREATE|inotify.IN_MODIFY|inotify.IN_ATTRIB|inotify.IN_MOVE|inotify.IN_DELETE)
+	name := filepath.Join(dir, "a")
+	os.WriteFile(name, []byte("x"), 0644)
+	os.Chmod(name, 0600)
+	os.Rename(name, name+".bak")
+	os.Remove(name+".bak")
+	for _, op := range []inotify.Op{inotify.Create, inotify.Write, inotify.Chmod, inotify.Rename, inotify.Create, inotify.Remove} {
+		e, ok, err := w.WaitEventTimeout(time.Second)
+		if !ok || err != nil || e.Op != op {
+			t.Fatal("Op", e.Op, op, e.GetEventName(), ok, err)
+		}
+	}
+	if s := (inotify.Create|inotify.Write).String(); s != "CREATE|WRITE" {
+		t.Fatal("Op.String", s)
+	}
+}
+
+func TestRenameWatchPath(t *testing.T) {
+	w, dir := newTestWatcher(t, inotify.IN_MOVE)
+	old := filepath.Join(dir, "a")
+	os.MkdirAll(filepath.Join(old, "b"), 0755)
+	if err := w.AddWatch(old, inotify.IN_CREATE|inotify.IN_MOVE_SELF); err != nil {
+		t.Fatal("AddWatch", err)
+	}
+	if err := w.AddWatch(filepath.Join(old, "b"), inotify.IN_CREATE); err != nil {
+		t.Fatal("AddWatch", err)
+	}
+	moved := filepath.Join(dir, "c")
+	os.Rename(old, moved)
+	for _, want := range []string{"MOVED_FROM", "MOVED_TO", "MOVE_SELF"} {
+		if e, ok, err := w.WaitEventTimeout(time.Second); !ok || err != nil || e.GetEventName() != want {
+			t.Fatal("rename", e.GetEventName(), want, ok, err)
+		}
+	}
+	// MOVE_SELF 之后仍在监听，a 与其下的监听都使用新路径
+	os.WriteFile(filepath.Join(moved, "f"), nil, 0644)
+	os.WriteFile(filepath.Join(moved, "b", "g"), nil, 0644)
+	for _, want := range []string{filepath.Join(moved, "f"), filepath.Join(moved, "b", "g")} {
+		if e, ok, err := w.WaitEventTimeout(time.Second); !ok || err != nil || e.FileName != want {
+			t.Fatal("event after rename", e.FileName, want, ok, err)
+		}
+	}
+	if err := w.RemoveWatch(filepath.Join(moved, "b")); err != nil {
+		t.Fatal("RemoveWatch new path", err)
+	}
+	// 移出监听范围后不知道新路径，MOVE_SELF 移除监听
+	os.Rename(moved, filepath.Join(t.TempDir(), "c"))
+	for {
+		e, ok, err := w.WaitEventTimeout(time.Second)
+		if !ok || err != nil {
+			t.Fatal("move out", ok, err)
+		}
+		if e.Raw&inotify.IN_MOVE_SELF != 0 {
+			break
+		}
+	}
+	if err := w.RemoveWatch(moved); err == nil {
+		t.Fatal("watch not removed after move out")
+	}
+}
+
+func TestPendingWatch(t *testing.T) {
+	w, err := inotify.NewWatcher(inotify.WithPendingWatches())
+	if err != nil {
+		t.Fatal("NewWatcher", err)
+	}
+	defer w.Close()
+	dir := t.TempDir()
+	if err = w.AddWatch(dir, inotify.IN_DELETE); err != nil {
+		t.Fatal("AddWatch", err)
+	}
+	name := filepath.Join(dir, "a", "b", "c")
+	if err = w.AddWatchData(name, inotify.IN_CLOSE_WRITE, "c"); err != nil {
+		t.Fatal("AddWatchData pending", err)
+	}
+	cancel := filepath.Join(dir, "a", "d")
+	if err = w.AddWatch(cancel, inotify.IN_CLOSE_WRITE); err != nil {
+		t.Fatal("AddWatch pending", err)
+	}
+	if err = w.RemoveWatch(cancel); err != nil {
+		t.Fatal("RemoveWatch pending", err)
+	}
+	os.MkdirAll(filepath.Dir(name), 0755)
+	os.WriteFile(cancel, nil, 0644)
+	os.WriteFile(name, nil, 0644)
+	// dir 只监听了 DELETE，等待时加入的 CREATE 不交给调用者
+	e, ok, err := w.WaitEventTimeout(time.Second)
+	if !ok || err != nil || e.Op != inotify.Create || e.FileName != name || e.Data != "c" || e.IsDir() {
+		t.Fatal("pending CREATE", e, ok, err)
+	}
+	f, _ := os.OpenFile(name, os.O_WRONLY, 0)
+	f.Close()
+	// WriteFile 的 CLOSE_WRITE 可能在加入监听之前或之后，最后一个是 OpenFile 的
+	var last inotify.Event
+	for {
+		if e, ok, err = w.WaitEventTimeout(time.Millisecond*100); !ok || err != nil {
+			break
+		}
+		if last = e; e.FileName != name || e.Raw != inotify.IN_CLOSE_WRITE || e.Data != "c" {
+			t.Fatal("event after CREATE", e)
+		}
+	}
+	if last.Raw != inotify.IN_CLOSE_WRITE {
+		t.Fatal("no CLOSE_WRITE", last, err)
+	}
+}
+
+func TestRecursiveWatch(t *testing.T) {
+	w, err := inotify.NewWatcher()
+	if err != nil {
+		t.Fatal("NewWatcher", err)
+	}
+	defer w.Close()
+	dir := t.TempDir()
+	os.MkdirAll(filepath.Join(dir, "a", "b"), 0755)
+	if err = w.AddRecursiveWatch(dir, inotify.IN_CLOSE_WRITE); err != nil {
+		t.Fatal("AddRecursiveWatch", err)
+	}
+	os.WriteFile(filepath.Join(dir, "a", "b", "f"), nil, 0644)
+	// 新目录中立即创建的文件可能在加入监听之前，由补发的 CREATE 覆盖
+	os.MkdirAll(filepath.Join(dir, "c", "d"), 0755)
+	os.WriteFile(filepath.Join(dir, "c", "d", "g"), nil, 0644)
+	seen := map[string]bool{}
+	for {
+		e, ok, err := w.WaitEventTimeout(time.Millisecond*300)
+		if err != nil {
+			t.Fatal("WaitEventTimeout", err)
+		}
+		if !ok {
+			break
+		}
+		rel, _ := filepath.Rel(dir, e.FileName)
+		seen[e.Op.String()+" "+filepath.ToSlash(rel)] = true
+	}
+	for _, want := range []string{"WRITE a/b/f", "CREATE c", "CREATE c/d", "CREATE c/d/g"} {
+		if !seen[want] {
+			t.Fatal("missing", want, seen)
+		}
+	}
+	// 新目录已加入监听
+	os.WriteFile(filepath.Join(dir, "c", "d", "h"), nil, 0644)
+	if e, ok, err := w.WaitEventTimeout(time.Second); !ok || err != nil || e.FileName != filepath.Join(dir, "c", "d", "h") {
+		t.Fatal("event in new dir", e, ok, err)
+	}
+}
+
+func TestRecursionLimit(t *testing.T) {
+	var limited []string
+	w, err := inotify.NewWatcher(inotify.WithRecursionLimit(1, 3, func(path string, err error) {
+		limited = append(limited, filepath.Base(path))
+	}))
+	if err != nil {
+		t.Fatal("NewWatcher", err)
+	}
+	defer w.Close()
+	dir := t.TempDir()
+	os.MkdirAll(filepath.Join(dir, "a", "deep"), 0755)
+	if err = w.AddRecursiveWatch(dir, inotify.IN_CREATE); err != nil {
+		t.Fatal("AddRecursiveWatch", err)
+	}
+	if len(limited) != 1 || limited[0] != "deep" {
+		t.Fatal("maxDepth", limited)
+	}
+	os.Mkdir(filepath.Join(dir, "b"), 0755)
+	os.Mkdir(filepath.Join(dir, "c"), 0755)
+	for i := 0; i < 2; i++ {
+		if _, ok, err := w.WaitEventTimeout(time.Second); !ok || err != nil {
+			t.Fatal("WaitEventTimeout", ok, err)
+		}
+	}
+	// b 为第 3 个目录，c 超过数量限制
+	os.WriteFile(filepath.Join(dir, "b", "f"), nil, 0644)
+	os.WriteFile(filepath.Join(dir, "c", "f"), nil, 0644)
+	if e, ok, err := w.WaitEventTimeout(time.Second); !ok || err != nil || e.FileName != filepath.Join(dir, "b", "f") {
+		t.Fatal("event in b", e, ok, err)
+	}
+	if e, ok, _ := w.WaitEventTimeout(time.Millisecond*100); ok {
+		t.Fatal("event in c", e.FileName)
+	}
+	if len(limited) != 2 || limited[1] != "c" {
+		t.Fatal("maxDirs", limited)
+	}
+	if err = w.AddRecursiveWatch(t.TempDir(), inotify.IN_CREATE); err != inotify.ErrLimit {
+		t.Fatal("AddRecursiveWatch over limit", err)
+	}
+}
+
+func TestRescan(t *testing.T) {
+	w, err := inotify.NewWatcher(inotify.WithRescan(time.Millisecond*50))
+	if err != nil {
+		t.Fatal("NewWatcher", err)
+	}
+	defer w.Close()
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "old"), nil, 0644)
+	// 只监听 IN_DELETE_SELF，其余变化只能由 rescan 发现
+	if err = w.AddWatch(dir, inotify.IN_DELETE_SELF); err != nil {
+		t.Fatal("AddWatch", err)
+	}
+	time.Sleep(time.Millisecond*120)
+	os.WriteFile(filepath.Join(dir, "new"), nil, 0644)
+	os.Remove(filepath.Join(dir, "old"))
+	seen := make(map[string]bool)
+	for len(seen) < 2 {
+		e, ok, err := w.WaitEventTimeout(time.Second)
+		if err != nil || !ok {
+			t.Fatal("WaitEventTimeout", ok, err, seen)
+		}
+		seen[e.Op.String()+" "+filepath.Base(e.FileName)] = true
+	}
+	if !seen["CREATE new"] || !seen["REMOVE old"] {
+		t.Fatal("rescan events", seen)
+	}
+	os.WriteFile(filepath.Join(dir, "new"), []byte("data"), 0644)
+	if e, ok, err := w.WaitEventTimeout(time.Second); !ok || err != nil || e.Op != inotify.Write || e.Raw != inotify.IN_MODIFY {
+		t.Fatal("rescan modify", e, ok, err)
+	}
+}
+
+func TestAuditor(t *testing.T) {
+	a, err := inotify.NewAuditor()
+	if err != nil {
+		t.Skip("fanotify needs CAP_SYS_ADMIN", err)
+	}
+	dir := t.TempDir()
+	if err = a.AddWatch(dir, inotify.IN_CLOSE_WRITE); err != nil {
+		t.Fatal("AddWatch", err)
+	}
+	name := filepath.Join(dir, "f")
+	os.WriteFile(name, nil, 0644)
+	e, err := a.WaitEvent()
+	if err != nil {
+		t.Fatal("WaitEvent", err)
+	}
+	exe, _ := os.Executable()
+	if e.FileName != name || e.Raw != inotify.IN_CLOSE_WRITE || e.Pid != os.Getpid() || e.Uid != os.Getuid() || e.Exe != exe {
+		t.Fatal("audit event", e)
+	}
+	wait := make(chan error)
+	go func() {
+		_, err := a.WaitEvent()
+		wait <- err
+	}()
+	a.Close()
+	if err = <-wait; err != inotify.ErrClosed {
+		t.Fatal("WaitEvent after Close", err)
+	}
+}
+
+func TestEventJSON(t *testing.T) {
+	w, err := inotify.NewWatcher()
+	if err != nil {
+		t.Fatal("NewWatcher", err)
+	}
+	defer w.Close()
+	dir := t.TempDir()
+	if err = w.AddWatch(dir, inotify.IN_CREATE); err != nil {
+		t.Fatal("AddWatch", err)
+	}
+	os.Mkdir(filepath.Join(dir, "a"), 0755)
+	os.WriteFile(filepath.Join(dir, "b"), nil, 0644)
+	var list []inotify.Event
+	for i := 0; i < 2; i++ {
+		e, ok, err := w.WaitEventTimeout(time.Second)
+		if !ok || err != nil {
+			t.Fatal("WaitEventTimeout", ok, err)
+		}
+		list = append(list, e)
+	}
+	if list[0].Seq != 1 || list[1].Seq != 2 || list[0].Time.IsZero() {
+		t.Fatal("Seq and Time", list)
+	}
+	data, err := json.Marshal(list[0])
+	if err != nil {
+		t.Fatal("Marshal", err)
+	}
+	var fields map[string]any
+	json.Unmarshal(data, &fields)
+	for _, k := range []string{"path", "op", "raw_mask", "cookie", "is_dir", "time", "seq"} {
+		if _, ok := fields[k]; !ok {
+			t.Fatal("missing field", k, string(data))
+		}
+	}
+	if fields["op"] != "CREATE" || fields["is_dir"] != true {
+		t.Fatal("fields", string(data))
+	}
+	var e inotify.Event
+	if err = json.Unmarshal(data, &e); err != nil {
+		t.Fatal("Unmarshal", err)
+	}
+	if e.FileName != list[0].FileName || e.Op != list[0].Op || e.Raw != list[0].Raw || e.Seq != 1 || !e.Time.Equal(list[0].Time) || !e.IsDir() {
+		t.Fatal("round trip", e, list[0])
+	}
+}
+
+func TestWatchFile(t *testing.T) {
+	w, err := inotify.NewWatcher()
+	if err != nil {
+		t.Fatal("NewWatcher", err)
+	}
+	defer w.Close()
+	dir := t.TempDir()
+	name := filepath.Join(dir, "config")
+	f, err := w.WatchFile(name, inotify.IN_CLOSE_WRITE)
+	if err != nil {
+		t.Fatal("WatchFile", err)
+	}
+	if again, _ := w.WatchFile(name, inotify.IN_CLOSE_WRITE); again != f || f.Path() != name {
+		t.Fatal("WatchFile handle", again, f)
+	}
+	next := func(mask uint32) {
+		e, ok, err := w.WaitEventTimeout(time.Second)
+		if !ok || err != nil || e.Raw != mask || e.FileName != name || e.Data != f {
+			t.Fatal("event", e, ok, err, mask)
+		}
+	}
+	// 同一目录中的其他文件与临时文件没有事件
+	os.WriteFile(filepath.Join(dir, "other"), nil, 0644)
+	for i := 0; i < 3; i++ {
+		tmp := filepath.Join(dir, ".config.swp")
+		os.WriteFile(tmp, []byte{byte(i)}, 0644)
+		os.Rename(tmp, name)
+		next(inotify.IN_MOVED_TO)
+	}
+	os.WriteFile(name, []byte("x"), 0644)
+	next(inotify.IN_CLOSE_WRITE)
+	os.Remove(name)
+	next(inotify.IN_DELETE)
+	if err = f.Close(); err != nil {
+		t.Fatal("Close", err)
+	}
+	os.WriteFile(name, nil, 0644)
+	if e, ok, _ := w.WaitEventTimeout(time.Millisecond*100); ok {
+		t.Fatal("event after Close", e)
+	}
+	if s := w.Stats(); s.Watches != 0 {
+		t.Fatal("directory still watched", s.Watches)
+	}
+}
+
+func TestAtomicSave(t *testing.T) {
+	w, err := inotify.NewWatcher(inotify.WithAtomicSave())
+	if err != nil {
+		t.Fatal("NewWatcher", err)
+	}
+	defer w.Close()
+	dir := t.TempDir()
+	name, tmp := filepath.Join(dir, "config"), filepath.Join(dir, "config.tmp")
+	os.WriteFile(name, nil, 0644)
+	f, err := w.WatchFile(name, inotify.IN_CLOSE_WRITE)
+	if err != nil {
+		t.Fatal("WatchFile", err)
+	}
+	next := func(mask uint32) inotify.Event {
+		e, ok, err := w.WaitEventTimeout(time.Second)
+		if !ok || err != nil || e.Raw != mask || e.FileName != name || e.Data != f {
+			t.Fatal("event", e, ok, err, mask)
+		}
+		return e
+	}
+	os.WriteFile(tmp, []byte("x"), 0644)
+	os.Rename(tmp, name)
+	next(inotify.IN_MOVED_TO)
+	if e := next(inotify.IN_CLOSE_WRITE); e.Op != inotify.Write {
+		t.Fatal("Op", e.Op)
+	}
+	// 不是替换已存在的文件时不补发
+	os.Remove(name)
+	next(inotify.IN_DELETE)
+	os.WriteFile(tmp, []byte("y"), 0644)
+	os.Rename(tmp, name)
+	next(inotify.IN_MOVED_TO)
+	if e, ok, _ := w.WaitEventTimeout(time.Millisecond*100); ok {
+		t.Fatal("unexpected event", e)
+	}
+}
+
+func TestWatchConfig(t *testing.T) {
+	dir := t.TempDir()
+	name := filepath.Join(dir, "app.json")
+	os.WriteFile(name, []byte("1"), 0644)
+	got := make(chan string, 10)
+	c, err := inotify.WatchConfig(name, func(b []byte) error {
+		got <- string(b)
+		return nil
+	})
+	if err != nil {
+		t.Fatal("WatchConfig", err)
+	}
+	defer c.Close()
+	expect := func(want string) {
+		select {
+		case s := <-got:
+			if s != want {
+				t.Fatal("decode", s, want)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("decode timeout", want)
+		}
+	}
+	expect("1")
+	// 原子保存: 写入临时文件后 rename
+	tmp := filepath.Join(dir, ".app.json.swp")
+	os.WriteFile(tmp, []byte("2"), 0644)
+	os.Rename(tmp, name)
+	expect("2")
+	// 内容没有变化
+	os.WriteFile(name, []byte("2"), 0644)
+	select {
+	case s := <-got:
+		t.Fatal("unchanged", s)
+	case <-time.After(time.Millisecond*300):
+	}
+	// 符号链接切换，与 Kubernetes ConfigMap 相同
+	for _, v := range []string{"v1", "v2"} {
+		os.Mkdir(filepath.Join(dir, v), 0755)
+		os.WriteFile(filepath.Join(dir, v, "app.json"), []byte(v), 0644)
+	}
+	os.Symlink("v1", filepath.Join(dir, "data"))
+	linked := filepath.Join(dir, "linked.json")
+	os.Symlink(filepath.Join("data", "app.json"), linked)
+	lc, err := inotify.WatchConfig(linked, func(b []byte) error {
+		got <- string(b)
+		return nil
+	})
+	if err != nil {
+		t.Fatal("WatchConfig symlink", err)
+	}
+	defer lc.Close()
+	expect("v1")
+	os.Symlink("v2", filepath.Join(dir, "data.tmp"))
+	os.Rename(filepath.Join(dir, "data.tmp"), filepath.Join(dir, "data"))
+	expect("v2")
+	if c.Err() != nil || lc.Err() != nil {
+		t.Fatal("Err", c.Err(), lc.Err())
+	}
+}
+
+func TestInject(t *testing.T) {
+	w := inotify.MustNewWatcher()
+	err := w.Inject(inotify.Event{Raw: inotify.IN_Q_OVERFLOW})
+	w.Close()
+	if err == nil {
+		t.Fatal("Inject without WithInjection")
+	}
+	w, err = inotify.NewWatcher(inotify.WithInjection())
+	if err != nil {
+		t.Fatal("NewWatcher", err)
+	}
+	defer w.Close()
+	dir := t.TempDir()
+	if err = w.AddWatch(dir, inotify.IN_MOVE); err != nil {
+		t.Fatal("AddWatch", err)
+	}
+	events := []inotify.Event{
+		{FileName: filepath.Join(dir, "a"), Raw: inotify.IN_MOVED_FROM, Cookie: 9},
+		{FileName: filepath.Join(dir, "b"), Raw: inotify.IN_MOVED_TO, Cookie: 9},
+		{Raw: inotify.IN_Q_OVERFLOW},
+		{FileName: dir, Raw: inotify.IN_IGNORED},
+	}
+	for _, e := range events {
+		if err = w.Inject(e); err != nil {
+			t.Fatal("Inject", e, err)
+		}
+	}
+	for _, want := range events {
+		e, ok, err := w.WaitEventTimeout(time.Second)
+		if !ok || err != nil || e.Raw != want.Raw || e.Cookie != want.Cookie || (want.FileName != "" && filepath.Clean(e.FileName) != want.FileName) {
+			t.Fatal("event", e, want, ok, err)
+		}
+	}
+	// IN_IGNORED 之后不再监听
+	if err = w.Inject(inotify.Event{FileName: filepath.Join(dir, "c"), Raw: inotify.IN_MOVED_TO}); err == nil {
+		t.Fatal("Inject after IN_IGNORED")
+	}
+}
+
+func TestStats(t *testing.T) {
+	w, err := inotify.NewWatcher(inotify.WithInjection(), inotify.WithEventFilter(func(e inotify.Event) bool { return filepath.Base(e.FileName) != "skip" }))
+	if err != nil {
+		t.Fatal("NewWatcher", err)
+	}
+	defer w.Close()
+	dir := t.TempDir()
+	if err = w.AddWatch(dir, inotify.IN_CREATE); err != nil {
+		t.Fatal("AddWatch", err)
+	}
+	for _, name := range []string{"a", "skip", "b"} {
+		os.WriteFile(filepath.Join(dir, name), nil, 0644)
+	}
+	w.Inject(inotify.Event{Raw: inotify.IN_Q_OVERFLOW})
+	for i := 0; i < 3; i++ {
+		if _, ok, err := w.WaitEventTimeout(time.Second); !ok || err != nil {
+			t.Fatal("WaitEventTimeout", ok, err)
+		}
+	}
+	st := w.Stats()
+	if st.Read != 4 || st.Delivered != 3 || st.Filtered != 1 || st.Overflows != 1 || st.Dropped != 0 || st.Watches != 1 || st.BufferHighWater == 0 || st.BufferSize < st.BufferHighWater {
+		t.Fatalf("Stats %+v", st)
+	}
+}
+
+func TestReadBuffer(t *testing.T) {
+	long := func(i int) string {
+		name := strconv.Itoa(i)
+		for len(name) < 255 {
+			name += "x"
+		}
+		return name
+	}
+	// 小于最小值时放得下一个文件名最长的事件
+	w, err := inotify.NewWatcher(inotify.WithReadBuffer(1))
+	if err != nil {
+		t.Fatal("NewWatcher", err)
+	}
+	defer w.Close()
+	dir := t.TempDir()
+	if err = w.AddWatch(dir, inotify.IN_CREATE); err != nil {
+		t.Fatal("AddWatch", err)
+	}
+	if st := w.Stats(); st.BufferSize != syscall.SizeofInotifyEvent+255+1 {
+		t.Fatal("BufferSize", st.BufferSize)
+	}
+	for i := 0; i < 5; i++ {
+		os.WriteFile(filepath.Join(dir, long(i)), nil, 0644)
+		if e, ok, err := w.WaitEventTimeout(time.Second); !ok || err != nil || filepath.Base(e.FileName) != long(i) {
+			t.Fatal("WaitEventTimeout", i, ok, err)
+		}
+	}
+	w2, err := inotify.NewWatcher(inotify.WithReadBuffer(4096+255+1))
+	if err != nil {
+		t.Fatal("NewWatcher", err)
+	}
+	defer w2.Close()
+	if err = w2.AddWatch(dir, inotify.IN_DELETE); err != nil {
+		t.Fatal("AddWatch", err)
+	}
+	// 默认 400 字节的缓存只放得下一个，不及时取出时会丢弃
+	for i := 0; i < 5; i++ {
+		os.Remove(filepath.Join(dir, long(i)))
+	}
+	time.Sleep(50*time.Millisecond)
+	for i := 0; i < 5; i++ {
+		if e, ok, err := w2.WaitEventTimeout(time.Second); !ok || err != nil || filepath.Base(e.FileName) != long(i) {
+			t.Fatal("WaitEventTimeout", i, ok, err)
+		}
+	}
+	if st := w2.Stats(); st.BufferSize != 4096+255+1 || st.Dropped != 0 {
+		t.Fatalf("Stats %+v", st)
+	}
+}
+
+func TestReadEventsBatch(t *testing.T) {
+	w, dir := newTestWatcher(t, inotify.IN_CREATE)
+	for i := 0; i < 5; i++ {
+		os.WriteFile(filepath.Join(dir, strconv.Itoa(i)), nil, 0644)
+	}
+	time.Sleep(50*time.Millisecond)
+	// 内部 epoll 循环时一次取出缓存中所有的事件
+	buf := make([]inotify.Event, 8)
+	var names []string
+	for len(names) < 5 {
+		n, err := w.ReadEvents(buf)
+		if n == 0 || err != nil {
+			t.Fatal("ReadEvents", n, err)
+		}
+		for _, e := range buf[:n] {
+			names = append(names, filepath.Base(e.FileName))
+		}
+	}
+	for i, name := range names {
+		if name != strconv.Itoa(i) {
+			t.Fatal("ReadEvents events", names)
+		}
+	}
+	// buf 已满时剩下的事件留给下一次
+	for i := 5; i < 8; i++ {
+		os.WriteFile(filepath.Join(dir, strconv.Itoa(i)), nil, 0644)
+	}
+	time.Sleep(50*time.Millisecond)
+	if n, err := w.ReadEvents(buf[:2]); n != 2 || err != nil || filepath.Base(buf[1].FileName) != "6" {
+		t.Fatal("ReadEvents full", n, err)
+	}
+	if e, ok, err := w.WaitEventTimeout(time.Second); !ok || err != nil || filepath.Base(e.FileName) != "7" {
+		t.Fatal("WaitEventTimeout", ok, err)
+	}
+	go func() {
+		time.Sleep(50*time.Millisecond)
+		w.Close()
+	}()
+	if n, err := w.ReadEvents(buf); n != 0 || err != inotify.ErrClosed {
+		t.Fatal("ReadEvents after Close", n, err)
+	}
+}
+
+func TestHealthy(t *testing.T) {
+	defer func(d time.Duration) { inotify.HealthStall = d }(inotify.HealthStall)
+	inotify.HealthStall = time.Millisecond*50
+	w, dir := newTestWatcher(t, inotify.IN_CREATE)
+	if err := w.Healthy(); err != nil {
+		t.Fatal("Healthy", err)
+	}
+	os.WriteFile(filepath.Join(dir, "a"), nil, 0644)
+	time.Sleep(time.Millisecond*100)
+	if err := w.Healthy(); err == nil {
+		t.Fatal("Healthy with events not consumed")
+	}
+	if _, ok, _ := w.WaitEventTimeout(time.Second); !ok {
+		t.Fatal("WaitEventTimeout")
+	}
+	if err := w.Healthy(); err != nil {
+		t.Fatal("Healthy after consumed", err)
+	}
+	w.Close()
+	if err := w.Healthy(); err != inotify.ErrClosed {
+		t.Fatal("Healthy after Close", err)
+	}
+}
+
+func TestEventTime(t *testing.T) {
+	w, err := inotify.NewWatcher(inotify.WithDeliveryTime())
+	if err != nil {
+		t.Fatal("NewWatcher", err)
+	}
+	defer w.Close()
+	dir := t.TempDir()
+	if err = w.AddWatch(dir, inotify.IN_CREATE); err != nil {
+		t.Fatal("AddWatch", err)
+	}
+	before := time.Now()
+	os.WriteFile(filepath.Join(dir, "a"), nil, 0644)
+	// 读取后在缓存中等待
+	time.Sleep(time.Millisecond*100)
+	e, ok, err := w.WaitEventTimeout(time.Second)
+	if !ok || err != nil {
+		t.Fatal("WaitEventTimeout", ok, err)
+	}
+	if e.Time.Before(before) || e.Delivered.Sub(e.Time) < time.Millisecond*90 {
+		t.Fatal("Time", before, e.Time, e.Delivered)
+	}
+	data, _ := json.Marshal(e)
+	var v inotify.Event
+	if err = json.Unmarshal(data, &v); err != nil || !v.Delivered.Equal(e.Delivered) {
+		t.Fatal("delivered", string(data), err)
+	}
+}
+
+func TestSubscribe(t *testing.T) {
+	w, dir := newTestWatcher(t, inotify.IN_CREATE|inotify.IN_DELETE)
+	if _, err := w.Subscribe(0, "[a-"); err == nil {
+		t.Fatal("Subscribe bad pattern")
+	}
+	logs, err := w.Subscribe(inotify.IN_CREATE, "*.log")
+	if err != nil {
+		t.Fatal("Subscribe", err)
+	}
+	deletes, _ := w.Subscribe(inotify.IN_DELETE)
+	full, _ := w.Subscribe(0, filepath.Join(dir, "b.*"))
+	os.WriteFile(filepath.Join(dir, "a.log"), nil, 0644)
+	os.WriteFile(filepath.Join(dir, "b.txt"), nil, 0644)
+	os.Remove(filepath.Join(dir, "a.log"))
+	recv := func(ch <-chan inotify.Event) inotify.Event {
+		select {
+		case e := <-ch:
+			return e
+		case <-time.After(time.Second):
+			t.Fatal("Subscribe timeout")
+		}
+		return inotify.Event{}
+	}
+	if e := recv(logs); filepath.Base(e.FileName) != "a.log" || e.Raw&inotify.IN_CREATE == 0 {
+		t.Fatal("logs", e)
+	}
+	if e := recv(deletes); filepath.Base(e.FileName) != "a.log" || e.Raw&inotify.IN_DELETE == 0 {
+		t.Fatal("deletes", e)
+	}
+	if e := recv(full); filepath.Base(e.FileName) != "b.txt" {
+		t.Fatal("full path pattern", e)
+	}
+	w.Unsubscribe(deletes)
+	if _, ok := <-deletes; ok {
+		t.Fatal("Unsubscribe not closed")
+	}
+	select {
+	case e := <-logs:
+		t.Fatal("logs extra event", e)
+	default:
+	}
+	w.Close()
+	if _, ok := <-logs; ok {
+		t.Fatal("Subscribe after Close")
+	}
+}
+
+func TestWatchChan(t *testing.T) {
+	w, err := inotify.NewWatcher()
+	if err != nil {
+		t.Fatal("NewWatcher", err)
+	}
+	defer w.Close()
+	dir := t.TempDir()
+	pid, conf := filepath.Join(dir, "app.pid"), filepath.Join(dir, "app.conf")
+	os.WriteFile(pid, nil, 0644)
+	os.WriteFile(conf, nil, 0644)
+	if _, err = w.WatchChan(filepath.Join(dir, "missing"), inotify.IN_MODIFY); err == nil {
+		t.Fatal("WatchChan missing path")
+	}
+	pidc, err := w.WatchChan(pid, inotify.IN_MODIFY|inotify.IN_DELETE_SELF)
+	if err != nil {
+		t.Fatal("WatchChan", err)
+	}
+	confc, _ := w.WatchChan(conf, inotify.IN_CLOSE_WRITE)
+	recv := func(ch <-chan inotify.Event) (inotify.Event, bool) {
+		select {
+		case e, ok := <-ch:
+			return e, ok
+		case <-time.After(time.Second):
+			t.Fatal("WatchChan timeout")
+		}
+		return inotify.Event{}, false
+	}
+	os.WriteFile(conf, []byte("a"), 0644)
+	if e, ok := recv(confc); !ok || e.Raw&inotify.IN_CLOSE_WRITE == 0 || e.FileName != conf {
+		t.Fatal("conf event", e, ok)
+	}
+	// 删除后收到 DELETE_SELF 与 IN_IGNORED，之后关闭
+	os.Remove(pid)
+	var raws []uint32
+	for {
+		e, ok := recv(pidc)
+		if !ok {
+			break
+		}
+		raws = append(raws, e.Raw)
+	}
+	if len(raws) != 2 || raws[0]&inotify.IN_DELETE_SELF == 0 || raws[1]&inotify.IN_IGNORED == 0 {
+		t.Fatal("pid events", raws)
+	}
+	// RemoveWatch 后关闭
+	if err = w.RemoveWatch(conf); err != nil {
+		t.Fatal("RemoveWatch", err)
+	}
+	for {
+		if _, ok := recv(confc); !ok {
+			break
+		}
+	}
+}
+
+func TestWatchChanGlob(t *testing.T) {
+	w, err := inotify.NewWatcher()
+	if err != nil {
+		t.Fatal("NewWatcher", err)
+	}
+	defer w.Close()
+	dir := t.TempDir()
+	a, b, c := filepath.Join(dir, "a.log"), filepath.Join(dir, "b.log"), filepath.Join(dir, "c.log")
+	os.WriteFile(a, nil, 0644)
+	os.WriteFile(b, nil, 0644)
+	pattern := filepath.Join(dir, "*.log")
+	ch, err := w.WatchChan(pattern, inotify.IN_MODIFY)
+	if err != nil {
+		t.Fatal("WatchChan", err)
+	}
+	recv := func() (inotify.Event, bool) {
+		select {
+		case e, ok := <-ch:
+			return e, ok
+		case <-time.After(time.Second):
+			t.Fatal("WatchChan timeout")
+		}
+		return inotify.Event{}, false
+	}
+	// 每个匹配的监听及之后出现的匹配都交给 chan，O_TRUNC 与 write 各有一个 MODIFY
+	os.WriteFile(c, nil, 0644)
+	time.Sleep(100*time.Millisecond)
+	for _, path := range []string{a, b, c} {
+		os.WriteFile(path, []byte("1"), 0644)
+	}
+	for seen := map[string]bool{}; len(seen) < 3; {
+		e, ok := recv()
+		if !ok || e.Raw&inotify.IN_MODIFY == 0 {
+			t.Fatal("glob event", e, ok)
+		}
+		seen[e.FileName] = true
+	}
+	// 删除一个匹配后仍然收到其他匹配的事件
+	os.Remove(a)
+	for {
+		e, ok := recv()
+		if !ok {
+			t.Fatal("closed after one match removed")
+		}
+		if e.Raw&inotify.IN_IGNORED != 0 {
+			break
+		}
+	}
+	os.WriteFile(b, []byte("2"), 0644)
+	if e, ok := recv(); !ok || e.Raw&inotify.IN_MODIFY == 0 || e.FileName != b {
+		t.Fatal("glob event after remove", e, ok)
+	}
+	// RemoveWatch 通配符后所有匹配的监听移除，最后一个 IN_IGNORED 之后关闭
+	if err = w.RemoveWatch(pattern); err != nil {
+		t.Fatal("RemoveWatch", err)
+	}
+	ignored := 0
+	for {
+		e, ok := recv()
+		if !ok {
+			break
+		}
+		if e.Raw&inotify.IN_IGNORED != 0 {
+			ignored++
+		}
+	}
+	if ignored != 2 || w.Stats().Watches != 0 {
+		t.Fatal("glob watches left", ignored, w.Stats().Watches)
+	}
+	// 没有匹配的通配符在 Close 后关闭
+	none, err := w.WatchChan(filepath.Join(dir, "*.none"), inotify.IN_MODIFY)
+	if err != nil {
+		t.Fatal("WatchChan", err)
+	}
+	w.Close()
+	select {
+	case _, ok := <-none:
+		if ok {
+			t.Fatal("event on unmatched glob")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("WatchChan not closed after Close")
+	}
+}
+
+func TestGlobWatch(t *testing.T) {
+	w, err := inotify.NewWatcher()
+	if err != nil {
+		t.Fatal("NewWatcher", err)
+	}
+	defer w.Close()
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "a.log"), nil, 0644)
+	os.WriteFile(filepath.Join(dir, "b.txt"), nil, 0644)
+	if err = w.AddWatch(filepath.Join(dir, "*", "x.log"), inotify.IN_MODIFY); err == nil {
+		t.Fatal("AddWatch glob in dir")
+	}
+	pattern := filepath.Join(dir, "*.log")
+	if err = w.AddWatch(pattern, inotify.IN_MODIFY|inotify.IN_CREATE); err != nil {
+		t.Fatal("AddWatch glob", err)
+	}
+	// 追加写入只有一个 MODIFY，截断时可能有两个
+	write := func(name string) {
+		f, _ := os.OpenFile(filepath.Join(dir, name), os.O_WRONLY|os.O_APPEND, 0644)
+		f.Write([]byte(name))
+		f.Close()
+	}
+	next := func() inotify.Event {
+		e, ok, err := w.WaitEventTimeout(time.Second)
+		if !ok || err != nil {
+			t.Fatal("WaitEventTimeout", ok, err)
+		}
+		return e
+	}
+	write("b.txt")
+	write("a.log")
+	if e := next(); e.FileName != filepath.Join(dir, "a.log") || e.Raw&inotify.IN_MODIFY == 0 {
+		t.Fatal("a.log", e.FileName, e.GetEventName())
+	}
+	// 新出现的匹配
+	os.WriteFile(filepath.Join(dir, "c.log"), nil, 0644)
+	if e := next(); e.FileName != filepath.Join(dir, "c.log") || e.Raw&inotify.IN_CREATE == 0 {
+		t.Fatal("c.log create", e.FileName, e.GetEventName())
+	}
+	write("c.log")
+	if e := next(); e.FileName != filepath.Join(dir, "c.log") || e.Raw&inotify.IN_MODIFY == 0 {
+		t.Fatal("c.log modify", e.FileName, e.GetEventName())
+	}
+	// 移走后不再监听
+	os.Rename(filepath.Join(dir, "c.log"), filepath.Join(dir, "c.old"))
+	for e := next(); e.Raw&inotify.IN_IGNORED == 0; e = next() {
+	}
+	write("c.old")
+	if err = w.RemoveWatch(pattern); err != nil {
+		t.Fatal("RemoveWatch glob", err)
+	}
+	if e := next(); e.FileName != filepath.Join(dir, "a.log") || e.Raw&inotify.IN_IGNORED == 0 {
+		t.Fatal("a.log removed", e.FileName, e.GetEventName())
+	}
+	os.WriteFile(filepath.Join(dir, "d.log"), nil, 0644)
+	if e, ok, _ := w.WaitEventTimeout(100*time.Millisecond); ok {
+		t.Fatal("event after RemoveWatch", e.FileName, e.GetEventName())
+	}
+}
+
+func TestUnmount(t *testing.T) {
+	dir := t.TempDir()
+	mnt := filepath.Join(dir, "mnt")
+	os.Mkdir(mnt, 0755)
+	if err := syscall.Mount("none", mnt, "tmpfs", 0, ""); err != nil {
+		t.Skip("mount needs CAP_SYS_ADMIN", err)
+	}
+	defer syscall.Unmount(mnt, syscall.MNT_DETACH)
+	os.Mkdir(filepath.Join(mnt, "sub"), 0755)
+	remounted := make(chan string, 4)
+	w, err := inotify.NewWatcher(inotify.WithRemount(20*time.Millisecond, func(path string) { remounted <- path }))
+	if err != nil {
+		t.Fatal("NewWatcher", err)
+	}
+	defer w.Close()
+	if err = w.AddRecursiveWatch(mnt, inotify.IN_CREATE); err != nil {
+		t.Fatal("AddRecursiveWatch", err)
+	}
+	if err = syscall.Unmount(mnt, 0); err != nil {
+		t.Fatal("Unmount", err)
+	}
+	unmounts := 0
+	for e, ok, _ := w.WaitEventTimeout(time.Second); ok; e, ok, _ = w.WaitEventTimeout(100*time.Millisecond) {
+		if e.Op.Has(inotify.Unmount) {
+			if e.GetEventName() != "UNMOUNT" || e.Op.String() != "UNMOUNT" {
+				t.Fatal("Unmount event", e.GetEventName(), e.Op)
+			}
+			unmounts++
+		}
+	}
+	if unmounts != 2 || w.Stats().Watches != 0 {
+		t.Fatal("Unmount events", unmounts, w.Stats().Watches)
+	}
+	// 重新挂载后恢复整个目录树
+	if err = syscall.Mount("none", mnt, "tmpfs", 0, ""); err != nil {
+		t.Fatal("Mount", err)
+	}
+	os.Mkdir(filepath.Join(mnt, "sub"), 0755)
+	select {
+	case path := <-remounted:
+		if path != mnt {
+			t.Fatal("remounted", path)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("remounted timeout")
+	}
+	os.WriteFile(filepath.Join(mnt, "sub", "a"), nil, 0644)
+	for {
+		e, ok, err := w.WaitEventTimeout(time.Second)
+		if !ok || err != nil {
+			t.Fatal("WaitEventTimeout after remount", ok, err)
+		}
+		if e.FileName == filepath.Join(mnt, "sub", "a") {
+			break
+		}
+	}
+}
@@ -0,0 +1,313 @@
+//go:build linux
+// +build linux
+
+// @@
+// @ Author       : Eacher
+// @ Date         : 2026-10-17 14:46:03
+// @ LastEditTime : 2026-10-25 09:24:55
+// @ LastEditors  : Eacher
+// @ --------------------------------------------------------------------------------<
+// @ Description  : http 推送事件测试
+// @ --------------------------------------------------------------------------------<
+// @ FilePath     : /inotify/examples/web_test.go
+// @@
+package inotify_test
+
+import (
+	"io"
+	"os"
+	"bytes"
+	"net"
+	"time"
+	"bufio"
+	"strings"
+	"strconv"
+	"testing"
+	"net/http"
+	"encoding/json"
+	"path/filepath"
+	"net/http/httptest"
+	"github.com/20yyq/inotify"
+	"github.com/20yyq/inotify/web"
+)
+
+// wsDial 最简单的 WebSocket 客户端，只用于测试
+func wsDial(t *testing.T, url, query string) (net.Conn, *bufio.Reader) {
+	c, err := net.Dial("tcp", url[len("http://"):])
+	if err != nil {
+		t.Fatal("Dial", err)
+	}
+	req, _ := http.NewRequest(http.MethodGet, url+"/?"+query, nil)
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Upgrade", "websocket")
+	req.Header.Set("Sec-WebSocket-Version", "13")
+	req.Header.Set("Sec-WebSocket-Key", "dGhlIHNhbXBsZSBub25jZQ==")
+	req.Write(c)
+	r := bufio.NewReader(c)
+	resp, err := http.ReadResponse(r, req)
+	if err != nil || resp.StatusCode != http.StatusSwitchingProtocols || resp.Header.Get("Sec-WebSocket-Accept") != "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=" {
+		t.Fatal("handshake", resp, err)
+	}
+	return c, r
+}
+
+func wsRead(t *testing.T, c net.Conn, r *bufio.Reader) (byte, []byte) {
+	c.SetReadDeadline(time.Now().Add(time.Second))
+	head := make([]byte, 2)
+	if _, err := io.ReadFull(r, head); err != nil {
+		t.Fatal("read frame", err)
+	}
+	n := int(head[1]&0x7f)
+	if n == 126 {
+		ext := make([]byte, 2)
+		io.ReadFull(r, ext)
+		n = int(ext[0])<<8 | int(ext[1])
+	}
+	data := make([]byte, n)
+	io.ReadFull(r, data)
+	return head[0]&0x0f, data
+}
+
+func wsWrite(c net.Conn, data []byte) {
+	mask := []byte{1, 2, 3, 4}
+	frame := append([]byte{0x81, 0x80 | byte(len(data))}, mask...)
+	for i, b := range data {
+		frame = append(frame, b^mask[i%4])
+	}
+	c.Write(frame)
+}
+
+func TestWebSocket(t *testing.T) {
+	w, err := inotify.NewWatcher()
+	if err != nil {
+		t.Fatal("NewWatcher", err)
+	}
+	dir := t.TempDir()
+	if err = w.AddWatch(dir, inotify.IN_CREATE|inotify.IN_DELETE); err != nil {
+		t.Fatal("AddWatch", err)
+	}
+	srv := httptest.NewServer(web.NewWebSocketHandler(w, dir))
+	defer srv.Close()
+	c, r := wsDial(t, srv.URL, "op=remove")
+	defer c.Close()
+	time.Sleep(time.Millisecond*50)
+	name := filepath.Join(dir, "f")
+	os.WriteFile(name, nil, 0644)
+	os.Remove(name)
+	op, data := wsRead(t, c, r)
+	var e inotify.Event
+	if err = json.Unmarshal(data, &e); op != 0x1 || err != nil || e.Op != inotify.Remove || e.FileName != name {
+		t.Fatal("event", op, string(data), err)
+	}
+	// 客户端修改过滤条件
+	wsWrite(c, []byte(`{"ops":["CREATE"]}`))
+	time.Sleep(time.Millisecond*50)
+	os.WriteFile(name, nil, 0644)
+	if _, data = wsRead(t, c, r); json.Unmarshal(data, &e) != nil || e.Op != inotify.Create {
+		t.Fatal("event after filter", string(data))
+	}
+	w.Close()
+	if op, _ = wsRead(t, c, r); op != 0x8 {
+		t.Fatal("close frame", op)
+	}
+}
+
+// sseRead 读取下一个消息的 event 与 data，跳过 retry 与注释
+func sseRead(t *testing.T, r *bufio.Reader) (string, string) {
+	event, data := "message", ""
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			t.Fatal("read", err)
+		}
+		line = strings.TrimSuffix(line, "\n")
+		switch {
+		case line == "" && data != "":
+			return event, data
+		case strings.HasPrefix(line, "event: "):
+			event = line[len("event: "):]
+		case strings.HasPrefix(line, "data: "):
+			data = line[len("data: "):]
+		}
+	}
+}
+
+func TestSSE(t *testing.T) {
+	w, err := inotify.NewWatcher()
+	if err != nil {
+		t.Fatal("NewWatcher", err)
+	}
+	defer w.Close()
+	dir := t.TempDir()
+	if err = w.AddWatch(dir, inotify.IN_CREATE); err != nil {
+		t.Fatal("AddWatch", err)
+	}
+	srv := httptest.NewServer(web.NewSSEHandler(w, 2, dir))
+	defer srv.Close()
+	for _, name := range []string{"a", "b", "c"} {
+		os.WriteFile(filepath.Join(dir, name), nil, 0644)
+	}
+	time.Sleep(time.Millisecond*100)
+	get := func(id string) *bufio.Reader {
+		req, _ := http.NewRequest(http.MethodGet, srv.URL, nil)
+		if id != "" {
+			req.Header.Set("Last-Event-ID", id)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil || resp.Header.Get("Content-Type") != "text/event-stream" {
+			t.Fatal("GET", resp, err)
+		}
+		t.Cleanup(func() { resp.Body.Close() })
+		return bufio.NewReader(resp.Body)
+	}
+	// 只保留了 b、c，从 a 之后补发
+	r := get("1")
+	for _, name := range []string{"b", "c"} {
+		var e inotify.Event
+		if _, data := sseRead(t, r); json.Unmarshal([]byte(data), &e) != nil || filepath.Base(e.FileName) != name {
+			t.Fatal("replay", name, data)
+		}
+	}
+	os.WriteFile(filepath.Join(dir, "d"), nil, 0644)
+	var e inotify.Event
+	if _, data := sseRead(t, r); json.Unmarshal([]byte(data), &e) != nil || filepath.Base(e.FileName) != "d" || e.Seq != 4 {
+		t.Fatal("live", data)
+	}
+	// 现在只保留 c、d，a 之后的 b 已不在保留范围内
+	if event, _ := sseRead(t, get("1")); event != "reset" {
+		t.Fatal("reset", event)
+	}
+	w.Close()
+}
+
+// slowWriter 在 gate 关闭之前阻塞所有写入，模拟读取很慢的客户端
+type slowWriter struct {
+	header 	http.Header
+	gate 	chan struct{}
+	buf 	bytes.Buffer
+}
+
+func (sw *slowWriter) Header() http.Header { return sw.header }
+func (sw *slowWriter) WriteHeader(int) {}
+func (sw *slowWriter) Flush() {}
+func (sw *slowWriter) Write(b []byte) (int, error) {
+	<-sw.gate
+	return sw.buf.Write(b)
+}
+
+func TestSSESlowClient(t *testing.T) {
+	// Watcher 自己不丢弃事件，只由 hub 处理跟不上的连接
+	w, err := inotify.NewWatcher(inotify.WithBackpressure(inotify.Block))
+	if err != nil {
+		t.Fatal("NewWatcher", err)
+	}
+	defer w.Close()
+	dir := t.TempDir()
+	if err = w.AddWatch(dir, inotify.IN_CREATE); err != nil {
+		t.Fatal("AddWatch", err)
+	}
+	h := web.NewSSEHandler(w, 0, dir)
+	sw := &slowWriter{header: make(http.Header), gate: make(chan struct{})}
+	done := make(chan struct{})
+	go func() {
+		h.ServeHTTP(sw, httptest.NewRequest(http.MethodGet, "/", nil))
+		close(done)
+	}()
+	time.Sleep(time.Millisecond*50)
+	for i := 0; i < 100; i++ {
+		os.WriteFile(filepath.Join(dir, strconv.Itoa(i)), nil, 0644)
+	}
+	time.Sleep(time.Millisecond*200)
+	close(sw.gate)
+	// 缓存满后连接被断开，已缓存的事件仍然发送
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("slow client not disconnected")
+	}
+	r := bufio.NewReader(&sw.buf)
+	var last uint64
+	for {
+		if _, err := r.Peek(1); err == io.EOF {
+			break
+		}
+		var e inotify.Event
+		if _, data := sseRead(t, r); json.Unmarshal([]byte(data), &e) != nil || e.Seq != last+1 {
+			t.Fatal("buffered", last, data)
+		}
+		last = e.Seq
+	}
+	if last == 0 || last >= 100 {
+		t.Fatal("buffered events", last)
+	}
+	// 以收到的最后一个 id 重连，补发之后所有的事件
+	srv := httptest.NewServer(h)
+	defer srv.Close()
+	req, _ := http.NewRequest(http.MethodGet, srv.URL, nil)
+	req.Header.Set("Last-Event-ID", strconv.FormatUint(last, 10))
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal("GET", err)
+	}
+	defer resp.Body.Close()
+	r = bufio.NewReader(resp.Body)
+	for last < 100 {
+		var e inotify.Event
+		event, data := sseRead(t, r)
+		if event != "message" || json.Unmarshal([]byte(data), &e) != nil || e.Seq != last+1 {
+			t.Fatal("replay", last, event, data)
+		}
+		last = e.Seq
+	}
+}
+
+func TestSSEHistoryRing(t *testing.T) {
+	w, err := inotify.NewWatcher()
+	if err != nil {
+		t.Fatal("NewWatcher", err)
+	}
+	defer w.Close()
+	dir := t.TempDir()
+	if err = w.AddWatch(dir, inotify.IN_CREATE); err != nil {
+		t.Fatal("AddWatch", err)
+	}
+	srv := httptest.NewServer(web.NewSSEHandler(w, 3, dir))
+	defer srv.Close()
+	// 第一个连接开始读取 Watcher
+	resp, err := http.Get(srv.URL)
+	if err != nil {
+		t.Fatal("GET", err)
+	}
+	resp.Body.Close()
+	// 环形缓存绕过多圈后只保留 8、9、10，按 Seq 顺序补发
+	for i := 1; i <= 10; i++ {
+		os.WriteFile(filepath.Join(dir, strconv.Itoa(i)), nil, 0644)
+	}
+	time.Sleep(time.Millisecond*100)
+	for _, after := range []uint64{7, 8, 9} {
+		req, _ := http.NewRequest(http.MethodGet, srv.URL, nil)
+		req.Header.Set("Last-Event-ID", strconv.FormatUint(after, 10))
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal("GET", err)
+		}
+		r := bufio.NewReader(resp.Body)
+		for seq := after+1; seq <= 10; seq++ {
+			var e inotify.Event
+			if event, data := sseRead(t, r); event != "message" || json.Unmarshal([]byte(data), &e) != nil || e.Seq != seq || e.FileName != filepath.Join(dir, strconv.FormatUint(seq, 10)) {
+				t.Fatal("replay", after, seq, event, data)
+			}
+		}
+		resp.Body.Close()
+	}
+	// 7 已移出缓存
+	req, _ := http.NewRequest(http.MethodGet, srv.URL, nil)
+	req.Header.Set("Last-Event-ID", "6")
+	if resp, err = http.DefaultClient.Do(req); err != nil {
+		t.Fatal("GET", err)
+	}
+	defer resp.Body.Close()
+	if event, _ := sseRead(t, bufio.NewReader(resp.Body)); event != "reset" {
+		t.Fatal("reset", event)
+	}
+}
@@ -0,0 +1,158 @@
+// @@
+// @ Author       : Eacher
+// @ Date         : 2026-10-15 17:59:19
+// @ LastEditTime : 2026-10-22 14:17:17
+// @ LastEditors  : Eacher
+// @ --------------------------------------------------------------------------------<
+// @ Description  : 由调用者自己的 epoll/netpoll 驱动读取 inotify fd
+// @ --------------------------------------------------------------------------------<
+// @ FilePath     : /inotify/external_linux.go
+// @@
+package inotify
+
+import (
+	"golang.org/x/sys/unix"
+)
+
+// WithExternalLoop 不创建内部的 epoll 与 goroutine，inotify fd 为非阻塞。
+// 调用者把 Fd 加入自己的事件循环，可读时调用 ReadEvents 取出事件
+func WithExternalLoop() Option {
+	return func(w *Watcher) {
+		w.external = true
+	}
+}
+
+// WithFdFlags inotify fd 的 inotify_init1 标志与内部 epoll fd 的 epoll_create1 标志，默认为 IN_CLOEXEC|IN_NONBLOCK 与 EPOLL_CLOEXEC，
+// 唤醒用的 eventfd 与 epoll fd 相同设置 close-on-exec。内部的 epoll 循环需要 IN_NONBLOCK，总是加上；
+// WithExternalLoop 时可以不含 IN_NONBLOCK，此时同 WithBlockingRead。
+// 不含 IN_CLOEXEC 时 syscall.ForkExec 等启动的子进程继承 inotify fd，os/exec 只传递 ExtraFiles 中的 fd(dup2 总是清除 close-on-exec)，
+// 子进程与 Watcher 共用同一个事件队列，子进程读取的事件 Watcher 不会收到。WithBackend、fanotify、轮询后端只使用 IN_CLOEXEC
+func WithFdFlags(inotifyFlags, epollFlags int) Option {
+	return func(w *Watcher) {
+		w.initFlags, w.epollFlags = inotifyFlags, epollFlags
+	}
+}
+
+// Fd inotify fd，Watcher 未初始化或已关闭时为 -1
+func (w *Watcher) Fd() int {
+	if !w.initialized() {
+		return -1
+	}
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	if w.closes {
+		return -1
+	}
+	return w.backend.Fd()
+}
+
+// ReadEvents 读取 inotify fd 直到 EAGAIN 或 buf 已满(WithBlockingRead 时只读取一次)，返回写入 buf 的事件数量。
+// buf 已满时 fd 中可能还有事件，需要再次调用。不是 WithExternalLoop 时见 readBuffered
+func (w *Watcher) ReadEvents(buf []Event) (int, error) {
+	if !w.initialized() {
+		return 0, ErrNotInitialized
+	}
+	if !w.external {
+		return w.readBuffered(buf)
+	}
+	if w.blocking {
+		w.reader.Lock()
+		defer w.reader.Unlock()
+	}
+	w.mutex.Lock()
+	if w.closes {
+		w.mutex.Unlock()
+		return 0, ErrClosed
+	}
+	var dirs []newDir
+	n, read := 0, false
+	for n < len(buf) {
+		if len(w.injected) > 0 {
+			ws := w.popInjected()
+			buf[n], n = w.stamp(ws.event()), n+1
+			continue
+		}
+		if uint32(unix.SizeofInotifyEvent) <= w.buffered() {
+			if ws := w.forwardBuffer(); ws != nil {
+				buf[n], n = w.stamp(ws.event()), n+1
+			}
+			continue
+		}
+		if len(w.readLeft) > 0 {
+			dirs = append(dirs, w.fill()...)
+			continue
+		}
+		if w.blocking {
+			// 阻塞的 fd 不会返回 EAGAIN，已有事件或已读取过时不再读取
+			if n > 0 || read {
+				break
+			}
+			m, err := w.readBlocking()
+			if w.closes {
+				w.mutex.Unlock()
+				return 0, ErrClosed
+			}
+			if err == unix.EINTR {
+				continue
+			}
+			if err == unix.EAGAIN {
+				break
+			}
+			if err != nil {
+				w.mutex.Unlock()
+				return n, err
+			}
+			w.readLeft, read = w.readBuf[:m], true
+			continue
+		}
+		// 缓存已取完，整个 eventBuffer 可用于读取
+		m, err := w.backend.ReadEvents(w.eventBuffer[w.bufferItem:])
+		if err == unix.EINTR {
+			continue
+		}
+		if err == unix.EAGAIN {
+			break
+		}
+		if err != nil {
+			w.mutex.Unlock()
+			return n, err
+		}
+		start := w.bufferItem
+		w.bufferItem += uint32(m)
+		dirs = append(dirs, w.received(start)...)
+	}
+	w.mutex.Unlock()
+	// 新目录中补发的事件在下一次 ReadEvents 时返回
+	w.watchDirs(dirs)
+	return n, nil
+}
+
+// readBuffered 内部 epoll 循环时的 ReadEvents: 一直等待到至少有一个事件，之后一次持有 mutex 取出缓存中所有的事件直到 buf 已满，
+// 与 WaitEvent 等共用同一个缓存，每个事件只会交给其中一个调用者。buf 为空时立即返回 0
+func (w *Watcher) readBuffered(buf []Event) (int, error) {
+	if len(buf) == 0 {
+		return 0, nil
+	}
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	ws, ok, err := w.wait(-1)
+	if !ok {
+		return 0, err
+	}
+	buf[0] = w.stamp(ws.event())
+	n := 1
+	for n < len(buf) {
+		if len(w.injected) > 0 {
+			ws := w.popInjected()
+			buf[n], n = w.stamp(ws.event()), n+1
+			continue
+		}
+		if uint32(unix.SizeofInotifyEvent) > w.buffered() {
+			break
+		}
+		if ws := w.forwardBuffer(); ws != nil {
+			buf[n], n = w.stamp(ws.event()), n+1
+		}
+	}
+	return n, nil
+}
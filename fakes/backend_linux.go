@@ -0,0 +1,260 @@
+// @@
+// @ Author       : Eacher
+// @ Date         : 2026-10-18 10:40:05
+// @ LastEditTime : 2026-10-19 10:08:47
+// @ LastEditors  : Eacher
+// @ --------------------------------------------------------------------------------<
+// @ Description  : 内存中的 inotify.WatchBackend，测试时不需要读写真实的文件系统
+// @ --------------------------------------------------------------------------------<
+// @ FilePath     : /inotify/fakes/backend_linux.go
+// @@
+package fakes
+
+import (
+	"sync"
+	"unsafe"
+	"path/filepath"
+	"golang.org/x/sys/unix"
+	"github.com/20yyq/inotify"
+)
+
+var _ inotify.WatchBackend = (*Backend)(nil)
+
+// Backend 内存中的监听，事件只由 Emit、Rename、Overflow 产生，按调用顺序读取。
+// 添加监听的路径默认为目录，文件需先调用 File。
+//
+//	b, _ := fakes.NewBackend()
+//	w, _ := inotify.NewWatcher(inotify.WithBackend(b))
+//	w.AddWatch("/data", inotify.IN_CREATE)
+//	b.Emit("/data/a", inotify.IN_CREATE)
+type Backend struct {
+	mutex 	sync.Mutex
+	// 有事件时可读，交给 Watcher 的 epoll
+	efd 	int
+	next 	int
+	// ReuseDescriptors 之后 Add 使用最小的空闲 wd
+	reuse 	bool
+	watches map[int]*watch
+	paths 	map[string]int
+	files 	map[string]bool
+	cookie 	uint32
+	queue 	[]byte
+	closed 	bool
+}
+
+type watch struct {
+	path 	string
+	mask 	uint32
+}
+
+func NewBackend() (*Backend, error) {
+	efd, err := unix.Eventfd(0, unix.EFD_CLOEXEC|unix.EFD_NONBLOCK)
+	if err != nil {
+		return nil, err
+	}
+	return &Backend{efd: efd, next: 1, watches: make(map[int]*watch), paths: make(map[string]int), files: make(map[string]bool)}, nil
+}
+
+// File 把 paths 记为文件，AddWatch 与 Rename 据此判断是否为目录
+func (b *Backend) File(paths ...string) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	for _, path := range paths {
+		b.files[filepath.Clean(path)] = true
+	}
+}
+
+// ReuseDescriptors 之后 Add 使用最小的空闲 wd，模拟内核在 IN_IGNORED 之后重用 wd
+func (b *Backend) ReuseDescriptors() {
+	b.mutex.Lock()
+	b.reuse = true
+	b.mutex.Unlock()
+}
+
+func (b *Backend) Add(path string, mask uint32) (int, bool, error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	if b.closed {
+		return -1, false, unix.EBADF
+	}
+	path = filepath.Clean(path)
+	isDir := !b.files[path]
+	if mask&unix.IN_ONLYDIR != 0 && !isDir {
+		return -1, false, unix.ENOTDIR
+	}
+	events := mask&(unix.IN_ALL_EVENTS|unix.IN_ONESHOT|unix.IN_EXCL_UNLINK)
+	if wd, ok := b.paths[path]; ok {
+		if mask&unix.IN_MASK_CREATE != 0 {
+			return -1, false, unix.EEXIST
+		}
+		if mask&unix.IN_MASK_ADD != 0 {
+			events |= b.watches[wd].mask
+		}
+		b.watches[wd].mask = events
+		return wd, isDir, nil
+	}
+	wd := b.next
+	if b.reuse {
+		for wd = 1; b.watches[wd] != nil; wd++ {
+		}
+	} else {
+		b.next++
+	}
+	b.watches[wd], b.paths[path] = &watch{path: path, mask: events}, wd
+	return wd, isDir, nil
+}
+
+func (b *Backend) Remove(wd int) error {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	if _, ok := b.watches[wd]; !ok {
+		return unix.EINVAL
+	}
+	b.drop(wd)
+	return nil
+}
+
+// drop 移除监听并产生 IN_IGNORED，调用者需持有 mutex
+func (b *Backend) drop(wd int) {
+	delete(b.paths, b.watches[wd].path)
+	delete(b.watches, wd)
+	b.push(wd, unix.IN_IGNORED, 0, "")
+}
+
+// push 以内核的格式加入一个事件，调用者需持有 mutex
+func (b *Backend) push(wd int, mask, cookie uint32, name string) {
+	size := 0
+	if name != "" {
+		// 与内核相同，名字以 NUL 填充对齐
+		size = (len(name)/unix.SizeofInotifyEvent + 1)*unix.SizeofInotifyEvent
+	}
+	record := make([]byte, unix.SizeofInotifyEvent+size)
+	*(*unix.InotifyEvent)(unsafe.Pointer(&record[0])) = unix.InotifyEvent{Wd: int32(wd), Mask: mask, Cookie: cookie, Len: uint32(size)}
+	copy(record[unix.SizeofInotifyEvent:], name)
+	b.queue = append(b.queue, record...)
+	var one = [8]byte{1}
+	unix.Write(b.efd, one[:])
+}
+
+// Emit 产生 path 的事件，交给监听 path 自身与监听其所在目录的监听，mask 不在监听中的不产生。
+// DELETE_SELF 与 IN_ONESHOT 的监听在事件之后移除并产生 IGNORED，返回产生的事件数量
+func (b *Backend) Emit(path string, mask uint32) int {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return b.emit(filepath.Clean(path), mask, 0)
+}
+
+// emit 调用者需持有 mutex
+func (b *Backend) emit(path string, mask, cookie uint32) int {
+	if b.closed {
+		return 0
+	}
+	n := 0
+	deliver := func(wd int, name string, mask, cookie uint32) {
+		ws, ok := b.watches[wd]
+		if !ok || ws.mask&mask&unix.IN_ALL_EVENTS == 0 {
+			return
+		}
+		b.push(wd, mask, cookie, name)
+		n++
+		if ws.mask&unix.IN_ONESHOT != 0 || mask&unix.IN_DELETE_SELF != 0 {
+			b.drop(wd)
+		}
+	}
+	// 所在目录收到除 DELETE_SELF、MOVE_SELF 以外的事件，自身收到除目录项变化以外的事件
+	if wd, ok := b.paths[filepath.Dir(path)]; ok && path != "/" {
+		deliver(wd, filepath.Base(path), mask&^(unix.IN_DELETE_SELF|unix.IN_MOVE_SELF), cookie)
+	}
+	if wd, ok := b.paths[path]; ok {
+		deliver(wd, "", mask&^(unix.IN_CREATE|unix.IN_DELETE|unix.IN_MOVED_FROM|unix.IN_MOVED_TO), 0)
+	}
+	return n
+}
+
+// Rename 产生 cookie 相同的 MOVED_FROM 与 MOVED_TO，以及 from 自身的 MOVE_SELF，返回产生的事件数量。
+// from 的监听之后以 to 为路径，与内核相同监听跟随 inode
+func (b *Backend) Rename(from, to string) int {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	from, to = filepath.Clean(from), filepath.Clean(to)
+	var isDir uint32
+	if !b.files[from] {
+		isDir = unix.IN_ISDIR
+	}
+	b.cookie++
+	n := b.emit(from, unix.IN_MOVED_FROM|isDir, b.cookie)
+	n += b.emit(to, unix.IN_MOVED_TO|isDir, b.cookie)
+	n += b.emit(from, unix.IN_MOVE_SELF, 0)
+	if b.files[from] {
+		delete(b.files, from)
+		b.files[to] = true
+	}
+	if wd, ok := b.paths[from]; ok {
+		delete(b.paths, from)
+		b.paths[to], b.watches[wd].path = wd, to
+	}
+	return n
+}
+
+// Overflow 产生 IN_Q_OVERFLOW
+func (b *Backend) Overflow() {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	if !b.closed {
+		b.push(-1, unix.IN_Q_OVERFLOW, 0, "")
+	}
+}
+
+// Watches 当前的监听，key 为路径，value 为 mask
+func (b *Backend) Watches() map[string]uint32 {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	m := make(map[string]uint32, len(b.paths))
+	for path, wd := range b.paths {
+		m[path] = b.watches[wd].mask
+	}
+	return m
+}
+
+func (b *Backend) ReadEvents(buf []byte) (int, error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	if b.closed {
+		return 0, unix.EBADF
+	}
+	if len(b.queue) == 0 {
+		// 清空计数，下一个事件再次通知
+		var counter [8]byte
+		unix.Read(b.efd, counter[:])
+		return 0, unix.EAGAIN
+	}
+	n := 0
+	for n < len(b.queue) {
+		event := (*unix.InotifyEvent)(unsafe.Pointer(&b.queue[n]))
+		size := unix.SizeofInotifyEvent + int(event.Len)
+		if n+size > len(buf) {
+			break
+		}
+		n += size
+	}
+	if n == 0 {
+		return 0, unix.EINVAL
+	}
+	copy(buf, b.queue[:n])
+	b.queue = b.queue[n:]
+	return n, nil
+}
+
+func (b *Backend) Fd() int {
+	return b.efd
+}
+
+func (b *Backend) Close() error {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	if b.closed {
+		return nil
+	}
+	b.closed = true
+	return unix.Close(b.efd)
+}
@@ -0,0 +1,337 @@
+//go:build linux
+// +build linux
+
+// @@
+// @ Author       : Eacher
+// @ Date         : 2026-10-16 16:24:05
+// @ LastEditTime : 2026-10-20 11:21:38
+// @ LastEditors  : Eacher
+// @ --------------------------------------------------------------------------------<
+// @ Description  : fanotify 审计监听，事件带有操作者的 PID、UID 与可执行文件
+// @ --------------------------------------------------------------------------------<
+// @ FilePath     : /inotify/fanotify_linux.go
+// @@
+package inotify
+
+import (
+	"os"
+	"sync"
+	"bufio"
+	"errors"
+	"strconv"
+	"strings"
+	"unsafe"
+	"path/filepath"
+
+	"golang.org/x/sys/unix"
+)
+
+// fanotify 不使用 FAN_REPORT_FID 时只支持这些事件，数值与 IN_* 相同
+const fanMask = unix.FAN_ACCESS|unix.FAN_MODIFY|unix.FAN_CLOSE_WRITE|unix.FAN_CLOSE_NOWRITE|unix.FAN_OPEN
+
+const sizeofMetadata = int(unsafe.Sizeof(unix.FanotifyEventMetadata{}))
+
+// AuditEvent fanotify 事件，Pid 为操作的进程，Uid 与 Exe 读取自 /proc，进程已退出时 Uid 为 -1、Exe 为空
+type AuditEvent struct {
+	Event
+	Pid 	int
+	Uid 	int
+	Exe 	string
+}
+
+// Auditor fanotify 监听者，需要 CAP_SYS_ADMIN
+type Auditor struct {
+	f 			*os.File
+	mutex 		sync.Mutex
+	buf 		[sizeofMetadata*64]byte
+	pending 	[]AuditEvent
+}
+
+func NewAuditor() (*Auditor, error) {
+	fd, err := unix.FanotifyInit(unix.FAN_CLASS_NOTIF|unix.FAN_CLOEXEC|unix.FAN_NONBLOCK, unix.O_RDONLY|unix.O_LARGEFILE|unix.O_CLOEXEC)
+	if err != nil {
+		return nil, os.NewSyscallError("fanotify_init", err)
+	}
+	return &Auditor{f: os.NewFile(uintptr(fd), "fanotify")}, nil
+}
+
+// AddWatch 监听文件或目录(只含直接子文件)，flags 只支持 IN_ACCESS、IN_MODIFY、IN_CLOSE、IN_OPEN
+func (a *Auditor) AddWatch(path string, flags uint32) error {
+	mask := uint64(flags) & fanMask
+	if mask == 0 {
+		return errors.New("The flags not supported by fanotify")
+	}
+	if info, _ := os.Stat(path); info == nil {
+		return &WatchError{Path: path, Err: ErrNotExist}
+	} else if info.IsDir() {
+		mask |= unix.FAN_EVENT_ON_CHILD
+	}
+	conn, err := a.f.SyscallConn()
+	if err != nil {
+		return err
+	}
+	if cerr := conn.Control(func(fd uintptr) {
+		err = unix.FanotifyMark(int(fd), unix.FAN_MARK_ADD, mask, unix.AT_FDCWD, path)
+	}); cerr != nil {
+		return ErrClosed
+	}
+	if err != nil {
+		return &WatchError{Path: path, Err: os.NewSyscallError("fanotify_mark", err)}
+	}
+	return nil
+}
+
+// WaitEvent 一直等待下一个事件
+func (a *Auditor) WaitEvent() (AuditEvent, error) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	for len(a.pending) == 0 {
+		n, err := a.f.Read(a.buf[:])
+		if err != nil {
+			if errors.Is(err, os.ErrClosed) {
+				return AuditEvent{}, ErrClosed
+			}
+			return AuditEvent{}, err
+		}
+		for start := 0; start+sizeofMetadata <= n; {
+			meta := (*unix.FanotifyEventMetadata)(unsafe.Pointer(&a.buf[start]))
+			if meta.Vers != unix.FANOTIFY_METADATA_VERSION || int(meta.Event_len) < sizeofMetadata {
+				return AuditEvent{}, ErrBufferCorrupt
+			}
+			start += int(meta.Event_len)
+			if meta.Mask&unix.FAN_Q_OVERFLOW != 0 {
+				return AuditEvent{}, ErrBufferCorrupt
+			}
+			a.pending = append(a.pending, audit(meta))
+		}
+	}
+	e := a.pending[0]
+	a.pending = a.pending[1:]
+	return e, nil
+}
+
+func (a *Auditor) Close() error {
+	if err := a.f.Close(); err != nil {
+		return ErrClosed
+	}
+	return nil
+}
+
+// audit 由内核返回的 fd 得到文件路径，之后关闭该 fd
+func audit(meta *unix.FanotifyEventMetadata) AuditEvent {
+	e := AuditEvent{Event: Event{Raw: uint32(meta.Mask&fanMask), Op: opOf(uint32(meta.Mask&fanMask))}, Pid: int(meta.Pid), Uid: -1}
+	if meta.Fd != unix.FAN_NOFD {
+		e.FileName, _ = os.Readlink("/proc/self/fd/" + strconv.Itoa(int(meta.Fd)))
+		unix.Close(int(meta.Fd))
+	}
+	proc := "/proc/" + strconv.Itoa(e.Pid)
+	e.Exe, _ = os.Readlink(proc + "/exe")
+	if f, err := os.Open(proc + "/status"); err == nil {
+		s := bufio.NewScanner(f)
+		for s.Scan() {
+			// Uid: 真实 有效 保存 文件系统
+			if fields := strings.Fields(s.Text()); len(fields) > 1 && fields[0] == "Uid:" {
+				if uid, err := strconv.Atoi(fields[1]); err == nil {
+					e.Uid = uid
+				}
+				break
+			}
+		}
+		f.Close()
+	}
+	return e
+}
+
+// fanBackend fanotify 实现的 WatchBackend，Fd 为包含 fanotify fd 与 recordQueue 的 eventfd 的 epoll，任一可读时可读。
+// 不使用 FAN_REPORT_FID 时没有 CREATE、DELETE、MOVE 等事件，这些 flags 被忽略
+type fanBackend struct {
+	*recordQueue
+	fan 		int
+	epfd 		int
+	next 		int
+	watches 	map[int]*fanWatch
+	paths 		map[string]int
+	buf 		[sizeofMetadata*64]byte
+}
+
+type fanWatch struct {
+	path 	string
+	mask 	uint32
+	isDir 	bool
+}
+
+func newFanBackend() (*fanBackend, error) {
+	fan, err := unix.FanotifyInit(unix.FAN_CLASS_NOTIF|unix.FAN_CLOEXEC|unix.FAN_NONBLOCK, unix.O_RDONLY|unix.O_LARGEFILE|unix.O_CLOEXEC)
+	if err != nil {
+		return nil, os.NewSyscallError("fanotify_init", err)
+	}
+	q, err := newRecordQueue()
+	if err != nil {
+		unix.Close(fan)
+		return nil, err
+	}
+	epfd, err := unix.EpollCreate1(unix.EPOLL_CLOEXEC)
+	if err != nil {
+		unix.Close(fan)
+		unix.Close(q.efd)
+		return nil, os.NewSyscallError("epoll_create1", err)
+	}
+	for _, fd := range []int{fan, q.efd} {
+		if err = unix.EpollCtl(epfd, unix.EPOLL_CTL_ADD, fd, &unix.EpollEvent{Fd: int32(fd), Events: unix.EPOLLIN}); err != nil {
+			unix.Close(fan)
+			unix.Close(q.efd)
+			unix.Close(epfd)
+			return nil, os.NewSyscallError("epoll_ctl", err)
+		}
+	}
+	return &fanBackend{recordQueue: q, fan: fan, epfd: epfd, next: 1, watches: make(map[int]*fanWatch), paths: make(map[string]int)}, nil
+}
+
+// markOf fanotify mark 的 mask，目录同时监听其直接子文件与自身
+func (fw *fanWatch) markOf() uint64 {
+	mask := uint64(fw.mask)&fanMask
+	if mask != 0 && fw.isDir {
+		mask |= unix.FAN_EVENT_ON_CHILD|unix.FAN_ONDIR
+	}
+	return mask
+}
+
+func (f *fanBackend) Add(path string, mask uint32) (int, bool, error) {
+	path = filepath.Clean(path)
+	st, err := statOf(path, mask&unix.IN_DONT_FOLLOW == 0)
+	if err != nil {
+		return -1, false, err
+	}
+	if mask&unix.IN_ONLYDIR != 0 && !st.isDir {
+		return -1, false, unix.ENOTDIR
+	}
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	if f.closed {
+		return -1, false, unix.EBADF
+	}
+	events := mask&(unix.IN_ALL_EVENTS|unix.IN_ONESHOT)
+	wd, ok := f.paths[path]
+	if ok {
+		if mask&unix.IN_MASK_CREATE != 0 {
+			return -1, false, unix.EEXIST
+		}
+		fw := f.watches[wd]
+		if mask&unix.IN_MASK_ADD != 0 {
+			events |= fw.mask
+		}
+		// 按新的 mask 重新设置
+		if m := fw.markOf(); m != 0 {
+			unix.FanotifyMark(f.fan, unix.FAN_MARK_REMOVE, m, unix.AT_FDCWD, path)
+		}
+	} else {
+		wd = f.next
+		f.next++
+	}
+	fw := &fanWatch{path: path, mask: events, isDir: st.isDir}
+	if m := fw.markOf(); m != 0 {
+		if err = unix.FanotifyMark(f.fan, unix.FAN_MARK_ADD, m, unix.AT_FDCWD, path); err != nil {
+			if ok {
+				f.drop(wd)
+			}
+			return -1, false, os.NewSyscallError("fanotify_mark", err)
+		}
+	}
+	f.watches[wd], f.paths[path] = fw, wd
+	return wd, st.isDir, nil
+}
+
+func (f *fanBackend) Remove(wd int) error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	fw, ok := f.watches[wd]
+	if !ok {
+		return unix.EINVAL
+	}
+	if m := fw.markOf(); m != 0 {
+		unix.FanotifyMark(f.fan, unix.FAN_MARK_REMOVE, m, unix.AT_FDCWD, fw.path)
+	}
+	f.drop(wd)
+	return nil
+}
+
+// drop 移除监听并产生 IN_IGNORED，调用者需持有 mutex
+func (f *fanBackend) drop(wd int) {
+	delete(f.paths, f.watches[wd].path)
+	delete(f.watches, wd)
+	f.push(wd, unix.IN_IGNORED, 0, "")
+}
+
+// ReadEvents 读取 fanotify 事件，转换为 inotify 的格式后与 IN_IGNORED 一起交给 Watcher
+func (f *fanBackend) ReadEvents(buf []byte) (int, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	if f.closed {
+		return 0, unix.EBADF
+	}
+	for len(f.queue) < len(buf) {
+		n, err := unix.Read(f.fan, f.buf[:])
+		if err != nil || n <= 0 {
+			break
+		}
+		for start := 0; start+sizeofMetadata <= n; {
+			meta := (*unix.FanotifyEventMetadata)(unsafe.Pointer(&f.buf[start]))
+			if meta.Vers != unix.FANOTIFY_METADATA_VERSION || int(meta.Event_len) < sizeofMetadata {
+				break
+			}
+			start += int(meta.Event_len)
+			f.translate(meta)
+		}
+	}
+	return f.read(buf)
+}
+
+// translate 由 fd 得到路径，交给监听该路径与其所在目录的监听，调用者需持有 mutex
+func (f *fanBackend) translate(meta *unix.FanotifyEventMetadata) {
+	if meta.Mask&unix.FAN_Q_OVERFLOW != 0 {
+		f.push(-1, unix.IN_Q_OVERFLOW, 0, "")
+	}
+	if meta.Fd < 0 {
+		return
+	}
+	path, _ := os.Readlink("/proc/self/fd/" + strconv.Itoa(int(meta.Fd)))
+	unix.Close(int(meta.Fd))
+	path = strings.TrimSuffix(path, " (deleted)")
+	mask := uint32(meta.Mask&fanMask)
+	if meta.Mask&unix.FAN_ONDIR != 0 {
+		mask |= unix.IN_ISDIR
+	}
+	deliver := func(wd int, name string) {
+		if fw, ok := f.watches[wd]; ok && fw.mask&mask&unix.IN_ALL_EVENTS != 0 {
+			f.push(wd, mask, 0, name)
+			if fw.mask&unix.IN_ONESHOT != 0 {
+				if m := fw.markOf(); m != 0 {
+					unix.FanotifyMark(f.fan, unix.FAN_MARK_REMOVE, m, unix.AT_FDCWD, fw.path)
+				}
+				f.drop(wd)
+			}
+		}
+	}
+	if wd, ok := f.paths[filepath.Dir(path)]; ok && path != "/" {
+		deliver(wd, filepath.Base(path))
+	}
+	if wd, ok := f.paths[path]; ok {
+		deliver(wd, "")
+	}
+}
+
+func (f *fanBackend) Fd() int {
+	return f.epfd
+}
+
+func (f *fanBackend) Close() error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	if f.closed {
+		return unix.EBADF
+	}
+	f.closed = true
+	unix.Close(f.fan)
+	unix.Close(f.efd)
+	return unix.Close(f.epfd)
+}
@@ -0,0 +1,80 @@
+// @@
+// @ Author       : Eacher
+// @ Date         : 2026-10-21 16:52:37
+// @ LastEditTime : 2026-10-21 18:11:50
+// @ LastEditors  : Eacher
+// @ --------------------------------------------------------------------------------<
+// @ Description  : 把调用者的 fd(signalfd、timerfd、pipe 等)加入内部的 epoll，与文件事件共用一个事件循环
+// @ --------------------------------------------------------------------------------<
+// @ FilePath     : /inotify/fds_linux.go
+// @@
+package inotify
+
+import (
+	"os"
+	"errors"
+	"golang.org/x/sys/unix"
+)
+
+// RegisterFd 把 fd 加入内部的 epoll，fd 就绪时在 epoll goroutine 中调用 handler，参数为 fd 与 EpollWait 返回的 events。
+// events 为 EPOLLIN、EPOLLOUT 等，需要边缘触发时加上 EPOLLET(此时 handler 需读到 EAGAIN)，EPOLLERR、EPOLLHUP 总是监听。
+// handler 返回前不读取 inotify fd，不能阻塞，可以调用 AddWatch、UnregisterFd 等方法，不能调用 Close。
+// fd 仍由调用者关闭，关闭前先 UnregisterFd，Close 之后不再调用 handler。WithExternalLoop 时没有内部的 epoll
+func (w *Watcher) RegisterFd(fd int, events uint32, handler func(fd int, events uint32)) error {
+	if !w.initialized() {
+		return ErrNotInitialized
+	}
+	if w.external {
+		return errors.New("The Watcher is in external loop mode")
+	}
+	if handler == nil {
+		return errors.New("The fd handler is nil")
+	}
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	if w.closes {
+		return ErrClosed
+	}
+	if fd == w.backend.Fd() || fd == w.wakeFD {
+		return errors.New("The fd is used by the Watcher")
+	}
+	if err := unix.EpollCtl(w.epollFD, unix.EPOLL_CTL_ADD, fd, &unix.EpollEvent{Fd: int32(fd), Events: events}); err != nil {
+		return os.NewSyscallError("epoll_ctl", err)
+	}
+	if w.fds == nil {
+		w.fds = make(map[int]func(int, uint32))
+	}
+	w.fds[fd] = handler
+	return nil
+}
+
+// UnregisterFd 从内部的 epoll 移除 RegisterFd 的 fd，之后由 EpollWait 返回的就绪不再调用 handler，
+// 在其他 goroutine 调用时正在进行的那一次 handler 可能在返回后才结束
+func (w *Watcher) UnregisterFd(fd int) error {
+	if !w.initialized() {
+		return ErrNotInitialized
+	}
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	if w.closes {
+		return ErrClosed
+	}
+	if _, ok := w.fds[fd]; !ok {
+		return errors.New("The fd is not registered")
+	}
+	delete(w.fds, fd)
+	if err := unix.EpollCtl(w.epollFD, unix.EPOLL_CTL_DEL, fd, nil); err != nil {
+		return os.NewSyscallError("epoll_ctl", err)
+	}
+	return nil
+}
+
+// fdHandler RegisterFd 的 fd 的 handler，已移除或已关闭时为 nil
+func (w *Watcher) fdHandler(fd int32) func(int, uint32) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	if w.closes {
+		return nil
+	}
+	return w.fds[int(fd)]
+}
@@ -0,0 +1,148 @@
+// @@
+// @ Author       : Eacher
+// @ Date         : 2026-10-19 12:38:40
+// @ LastEditTime : 2026-10-20 14:55:27
+// @ LastEditors  : Eacher
+// @ --------------------------------------------------------------------------------<
+// @ Description  : 通过所在目录监听单个文件，rename 替换保存后仍然有效
+// @ --------------------------------------------------------------------------------<
+// @ FilePath     : /inotify/file_linux.go
+// @@
+package inotify
+
+import (
+	"os"
+	"path/filepath"
+	"golang.org/x/sys/unix"
+)
+
+// 文件的新建、删除与 rename 总是交给调用者，原子保存时旧文件被 rename 替换
+const fileMask = unix.IN_CREATE|unix.IN_DELETE|unix.IN_MOVE
+
+// WatchedFile WatchFile 监听的文件，该文件的事件的 Data 为它
+type WatchedFile struct {
+	w 		*Watcher
+	path 	string
+	name 	string
+	flags 	uint32
+	// 所在目录的监听，Close 之后为 nil
+	dir 	*WatchSingle
+	// 当前是否存在，由取出的事件更新，用于 WithAtomicSave 区分替换与新建
+	exists 	bool
+}
+
+// WithAtomicSave WatchFile 的文件被 rename 替换时(编辑器的原子保存)，在 MOVED_TO 之后补发一个 Op 为 Write 的事件，
+// Raw 为 IN_CLOSE_WRITE，WatchFile 没有监听 IN_CLOSE_WRITE 时为 IN_MODIFY。rename 到还不存在的路径时不补发
+func WithAtomicSave() Option {
+	return func(w *Watcher) {
+		w.atomicSave = true
+	}
+}
+
+// WatchFile 通过监听 path 所在的目录监听文件 path，编辑器的原子保存(写入临时文件后 rename 为 path)之后仍能收到事件，
+// 直接监听文件时 rename 替换后原来的监听就失效了。flags 总是包含 IN_CREATE、IN_DELETE 与 IN_MOVE，path 可以还不存在。
+// 该文件的事件 Data 为返回的 *WatchedFile，重复调用返回同一个；所在目录同时由 AddWatch 监听时同一事件只交给一次
+func (w *Watcher) WatchFile(path string, flags uint32) (*WatchedFile, error) {
+	if !w.initialized() {
+		return nil, ErrNotInitialized
+	}
+	var err error
+	if path, err = filepath.Abs(path); err != nil {
+		return nil, err
+	}
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	if w.closes {
+		return nil, ErrClosed
+	}
+	dir, name := filepath.Split(path)
+	f := &WatchedFile{w: w, path: path, name: name, flags: flags&unix.IN_ALL_EVENTS}
+	ws := w.fileDir(dir)
+	if ws != nil && ws.files[name] != nil {
+		f = ws.files[name]
+		f.flags |= flags&unix.IN_ALL_EVENTS
+	} else if _, err = os.Lstat(path); err == nil {
+		f.exists = true
+	}
+	wd, _, err := w.backend.Add(dir, f.dirMask()|unix.IN_ONLYDIR|unix.IN_MASK_ADD|unix.IN_DONT_FOLLOW)
+	if err != nil {
+		return nil, &WatchError{Path: dir, Err: err}
+	}
+	if ws = w.watchOf(uint32(wd), dir, true); ws.files == nil {
+		ws.files = make(map[string]*WatchedFile)
+	}
+	ws.extra |= f.dirMask()
+	ws.files[name], f.dir = f, ws
+	return f, nil
+}
+
+// fileDir 已监听的目录 dir，调用者需持有 mutex
+func (w *Watcher) fileDir(dir string) *WatchSingle {
+	for _, ws := range w.watchMap {
+		if !ws.remove && ws.path == dir {
+			return ws
+		}
+	}
+	return nil
+}
+
+// Path WatchFile 的路径
+func (f *WatchedFile) Path() string {
+	return f.path
+}
+
+// Close 停止监听该文件，所在目录只为它监听时一起移除
+func (f *WatchedFile) Close() error {
+	w := f.w
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	if f.dir == nil || w.closes {
+		return nil
+	}
+	if f.dir.files[f.name] == f {
+		delete(f.dir.files, f.name)
+	}
+	w.restore(f.dir)
+	f.dir = nil
+	return nil
+}
+
+// mask 交给调用者的事件
+func (f *WatchedFile) mask() uint32 {
+	return f.flags|fileMask
+}
+
+// dirMask 所在目录需要的事件，DELETE_SELF、MOVE_SELF 由目录中的 DELETE、MOVED_FROM 代替
+func (f *WatchedFile) dirMask() uint32 {
+	return f.mask()&^(unix.IN_DELETE_SELF|unix.IN_MOVE_SELF)
+}
+
+// fileOf 名字为 name 的文件由 WatchFile 监听且监听了 mask 中的事件时返回该文件
+func (ws *WatchSingle) fileOf(name []byte, mask uint32) *WatchedFile {
+	if len(ws.files) == 0 {
+		return nil
+	}
+	if f := ws.files[string(nameOf(name))]; f != nil && mask&f.mask() != 0 {
+		return f
+	}
+	return nil
+}
+
+// saved 由取出的 f 的事件更新 f 是否存在，WithAtomicSave 时 rename 替换已存在的文件后补发 Write 事件，调用者需持有 mutex
+func (w *Watcher) saved(f *WatchedFile, ws *WatchSingle) {
+	switch {
+	case ws.Mask&unix.IN_MOVED_TO != 0:
+		if f.exists && w.atomicSave {
+			mask := uint32(unix.IN_MODIFY)
+			if f.flags&unix.IN_CLOSE_WRITE != 0 {
+				mask = unix.IN_CLOSE_WRITE
+			}
+			w.pushInjected(Event{wd: ws.watchId, FileName: f.path, Raw: mask, Op: Write, Data: f, Time: ws.time})
+		}
+		f.exists = true
+	case ws.Mask&unix.IN_CREATE != 0:
+		f.exists = true
+	case ws.Mask&(unix.IN_DELETE|unix.IN_MOVED_FROM) != 0:
+		f.exists = false
+	}
+}
@@ -0,0 +1,198 @@
+//go:build linux
+// +build linux
+
+// @@
+// @ Author       : Eacher
+// @ Date         : 2026-10-14 09:41:39
+// @ LastEditTime : 2026-10-24 17:33:19
+// @ LastEditors  : Eacher
+// @ --------------------------------------------------------------------------------<
+// @ Description  : io/fs 适配，fs.FS 与监听事件互通
+// @ --------------------------------------------------------------------------------<
+// @ FilePath     : /inotify/fs_linux.go
+// @@
+package inotify
+
+import (
+	"os"
+	"sync"
+	"errors"
+	"io/fs"
+	"path/filepath"
+	"golang.org/x/sys/unix"
+)
+
+// WatchFS、FS 默认监听的事件
+const fsMask = IN_CREATE|IN_DELETE|IN_MODIFY|IN_ATTRIB|IN_CLOSE_WRITE|IN_MOVED_FROM|IN_MOVED_TO|IN_DELETE_SELF|IN_MOVE_SELF
+
+// OSDirFS 可以映射回操作系统目录的 fs.FS
+type OSDirFS interface {
+	fs.FS
+	OSDir() string
+}
+
+// dirFS os.DirFS 加上其目录
+type dirFS struct {
+	fs.FS
+	dir 	string
+}
+
+func (d dirFS) OSDir() string {
+	return d.dir
+}
+
+// DirFS 与 os.DirFS(dir) 相同，同时实现 OSDirFS，可以交给 WatchFS。
+// os.DirFS 返回的值取不到目录(不依赖其未导出的类型)，需要 WatchFS 时用 DirFS 代替 os.DirFS
+func DirFS(dir string) OSDirFS {
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		abs = dir
+	}
+	return dirFS{FS: os.DirFS(dir), dir: abs}
+}
+
+// osDir 找出 fsys 对应的操作系统目录，只支持 OSDirFS
+func osDir(fsys fs.FS) (string, error) {
+	if d, ok := fsys.(OSDirFS); ok {
+		return d.OSDir(), nil
+	}
+	return "", errors.New("The fs.FS cannot map to OS path")
+}
+
+// WatchFS 监听 fsys 中的 root 目录或文件，root 需为 fs.ValidPath。fsys 需实现 OSDirFS，如 DirFS 与 NewFS 的返回值；
+// os.DirFS 与其他没有对应目录的 fs.FS(embed.FS、fstest.MapFS 等)返回错误，os.DirFS 由 DirFS 代替
+func (w *Watcher) WatchFS(fsys fs.FS, root string) error {
+	if !w.initialized() {
+		return ErrNotInitialized
+	}
+	if !fs.ValidPath(root) {
+		return &fs.PathError{Op: "watch", Path: root, Err: fs.ErrInvalid}
+	}
+	dir, err := osDir(fsys)
+	if err != nil {
+		return err
+	}
+	return w.AddWatch(filepath.Join(dir, filepath.FromSlash(root)), fsMask)
+}
+
+// FSEvent FS 中发生变化的文件，Name 为相对 FS 根目录的路径
+type FSEvent struct {
+	Name 	string
+	Mask 	uint32
+}
+
+// FS 目录缓存随监听事件失效的 fs.FS，ReadDir 始终与最近的事件保持一致
+type FS struct {
+	dir 	string
+	fsys 	fs.FS
+	watch 	*Watcher
+
+	mutex 	sync.Mutex
+	cache 	map[string][]fs.DirEntry
+	e 		chan FSEvent
+}
+
+func NewFS(dir string) (*FS, error) {
+	var err error
+	if dir, err = filepath.Abs(dir); err != nil {
+		return nil, err
+	}
+	if info, _ := os.Stat(dir); info == nil {
+		return nil, &WatchError{Path: dir, Err: ErrNotExist}
+	} else if !info.IsDir() {
+		return nil, &WatchError{Path: dir, Err: unix.ENOTDIR}
+	}
+	f := &FS{dir: dir, fsys: os.DirFS(dir), cache: make(map[string][]fs.DirEntry), e: make(chan FSEvent, 10)}
+	if f.watch, err = NewWatcher(); err != nil {
+		return nil, err
+	}
+	go f.loop()
+	return f, nil
+}
+
+func (f *FS) OSDir() string {
+	return f.dir
+}
+
+func (f *FS) Open(name string) (fs.File, error) {
+	return f.fsys.Open(name)
+}
+
+func (f *FS) Stat(name string) (fs.FileInfo, error) {
+	return fs.Stat(f.fsys, name)
+}
+
+func (f *FS) ReadFile(name string) ([]byte, error) {
+	return fs.ReadFile(f.fsys, name)
+}
+
+// ReadDir 首次读取目录时开始监听该目录，之后命中缓存直到目录发生变化
+func (f *FS) ReadDir(name string) ([]fs.DirEntry, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	if list, ok := f.cache[name]; ok {
+		return append([]fs.DirEntry(nil), list...), nil
+	}
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrInvalid}
+	}
+	if err := f.watch.AddWatch(filepath.Join(f.dir, filepath.FromSlash(name)), fsMask); err != nil {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: err}
+	}
+	list, err := fs.ReadDir(f.fsys, name)
+	if err != nil {
+		return nil, err
+	}
+	f.cache[name] = list
+	return append([]fs.DirEntry(nil), list...), nil
+}
+
+// WaitEvent 等待 FS 中的变化，只保留最近 10 个未读取的事件
+func (f *FS) WaitEvent() (FSEvent, error) {
+	e, ok := <-f.e
+	if !ok {
+		return FSEvent{}, ErrClosed
+	}
+	return e, nil
+}
+
+func (f *FS) Close() error {
+	return f.watch.Close()
+}
+
+func (f *FS) loop() {
+	defer close(f.e)
+	for {
+		ws, err := f.watch.WaitEvent()
+		if err != nil {
+			if err == ErrClosed {
+				return
+			}
+			continue
+		}
+		name := ws.FileName
+		if rel, err := filepath.Rel(f.dir, name); err == nil {
+			name = filepath.ToSlash(rel)
+		}
+		f.mutex.Lock()
+		delete(f.cache, name)
+		if dir, err := filepath.Rel(f.dir, ws.path); err == nil {
+			delete(f.cache, filepath.ToSlash(dir))
+		}
+		f.mutex.Unlock()
+		e := FSEvent{Name: name, Mask: ws.Mask}
+		select {
+		case f.e <- e:
+		default:
+			// 已满时丢弃最旧的事件，读取者同时取走时不阻塞
+			select {
+			case <-f.e:
+			default:
+			}
+			select {
+			case f.e <- e:
+			default:
+			}
+		}
+	}
+}
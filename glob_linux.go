@@ -0,0 +1,141 @@
+// @@
+// @ Author       : Eacher
+// @ Date         : 2026-10-22 10:34:18
+// @ LastEditTime : 2026-10-22 11:29:46
+// @ LastEditors  : Eacher
+// @ --------------------------------------------------------------------------------<
+// @ Description  : AddWatch 的路径为通配符时监听所有匹配的文件，由上级目录的事件加入新出现的匹配
+// @ --------------------------------------------------------------------------------<
+// @ FilePath     : /inotify/glob_linux.go
+// @@
+package inotify
+
+import (
+	"os"
+	"errors"
+	"unsafe"
+	"strings"
+	"path/filepath"
+	"golang.org/x/sys/unix"
+)
+
+// 通配符所在目录中匹配的文件出现或消失时的事件
+const globMask = unix.IN_CREATE|unix.IN_MOVED_TO|unix.IN_DELETE|unix.IN_MOVED_FROM
+
+// globWatch AddWatch 的通配符与添加监听时的参数
+type globWatch struct {
+	pattern 	string
+	flags 		uint32
+	set 		func(*WatchSingle)
+	// 通配符所在的目录
+	parent 		*WatchSingle
+}
+
+// isGlob path 不存在且最后一个元素含有 filepath.Match 的通配符，存在的路径即使含有 *?[ 也按原样监听
+func isGlob(path string) bool {
+	if !strings.ContainsAny(filepath.Base(path), `*?[`) {
+		return false
+	}
+	_, err := os.Lstat(path)
+	return err != nil
+}
+
+// glob 监听 g 所在的目录与当前所有匹配的路径，同一个通配符再次添加时替换原来的参数，调用者需持有 mutex。
+// 之后目录中出现匹配的文件(CREATE、MOVED_TO)时添加监听，flags 包含该事件时补发一个；匹配的文件被删除或移走时移除其监听。
+// 只有最后一个元素可以是通配符，不递归，RemoveWatch 同一个通配符时移除它与所有匹配的监听
+func (w *Watcher) glob(g *globWatch) error {
+	dir := filepath.Dir(g.pattern)
+	if strings.ContainsAny(dir, `*?[`) {
+		return &WatchError{Path: g.pattern, Err: errors.New("The glob pattern is only supported in the last element")}
+	}
+	if _, err := filepath.Match(g.pattern, ""); err != nil {
+		return &WatchError{Path: g.pattern, Err: err}
+	}
+	wd, _, err := w.backend.Add(dir, globMask|unix.IN_ONLYDIR|unix.IN_MASK_ADD|unix.IN_DONT_FOLLOW)
+	if err != nil {
+		return &WatchError{Path: g.pattern, Err: err}
+	}
+	name := dir
+	if !strings.HasSuffix(name, string(os.PathSeparator)) {
+		name += string(os.PathSeparator)
+	}
+	g.parent = w.watchOf(uint32(wd), name, true)
+	g.parent.extra |= globMask
+	if w.globs == nil {
+		w.globs = make(map[string]*globWatch)
+	}
+	w.globs[g.pattern] = g
+	// 添加目录的监听之后再展开，之间出现的文件由目录的事件加入
+	matches, _ := filepath.Glob(g.pattern)
+	for _, path := range matches {
+		// 展开后被删除或没有权限的跳过
+		w.add(path, g.flags, g.set)
+	}
+	return nil
+}
+
+// unglob 取消通配符 pattern 并移除所有匹配的监听，调用者需持有 mutex
+func (w *Watcher) unglob(pattern string) bool {
+	g, ok := w.globs[pattern]
+	if !ok {
+		return false
+	}
+	delete(w.globs, pattern)
+	w.restore(g.parent)
+	for _, ws := range w.watchMap {
+		if ok, _ := filepath.Match(pattern, filepath.Clean(ws.path)); ok && !ws.remove && ws.flags != 0 {
+			w.unwatch(ws)
+		}
+	}
+	return true
+}
+
+// globbed 从 start 开始新读到的事件中找出通配符所在目录中匹配的文件的出现与消失，需在 unwanted 之前，调用者需持有 mutex
+func (w *Watcher) globbed(start uint32) {
+	type change struct {
+		g 		*globWatch
+		path 	string
+		mask 	uint32
+	}
+	var changes []change
+	var lost []*WatchSingle
+	for offset := start; offset+unix.SizeofInotifyEvent <= w.bufferItem; {
+		event := (*unix.InotifyEvent)(unsafe.Pointer(&w.eventBuffer[offset]))
+		size := uint32(unix.SizeofInotifyEvent) + event.Len
+		if ws, ok := w.owner(event.Wd, offset); ok && ws.extra&globMask != 0 {
+			if event.Mask&globMask != 0 && 0 < event.Len {
+				path := ws.path+string(nameOf(w.eventBuffer[offset+unix.SizeofInotifyEvent:offset+size]))
+				for _, g := range w.globs {
+					if ok, _ := filepath.Match(g.pattern, path); ok && g.parent == ws {
+						changes = append(changes, change{g, path, event.Mask})
+					}
+				}
+			}
+			if event.Mask&unix.IN_IGNORED != 0 {
+				lost = append(lost, ws)
+			}
+		}
+		offset += size
+	}
+	for _, c := range changes {
+		if c.mask&(unix.IN_CREATE|unix.IN_MOVED_TO) == 0 {
+			if ws := w.lookup(c.path); ws != nil && !ws.remove && ws.flags != 0 {
+				w.unwatch(ws)
+			}
+			continue
+		}
+		ws, err := w.add(c.path, c.g.flags, c.g.set)
+		if err != nil || c.g.flags&c.mask&unix.IN_ALL_EVENTS == 0 {
+			continue
+		}
+		w.pushInjected(Event{wd: ws.watchId, FileName: c.path, Raw: c.mask, Op: opOf(c.mask), Group: ws.group, Data: ws.data})
+	}
+	// 目录被删除或移除后通配符不再有效
+	for _, ws := range lost {
+		for pattern, g := range w.globs {
+			if g.parent == ws {
+				delete(w.globs, pattern)
+			}
+		}
+	}
+}
@@ -2,3 +2,4 @@ module github.com/20yyq/inotify
 
 go 1.19
 
+require golang.org/x/sys v0.15.0
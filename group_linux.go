@@ -0,0 +1,168 @@
+// @@
+// @ Author       : Eacher
+// @ Date         : 2026-10-15 13:37:07
+// @ LastEditTime : 2026-10-24 18:26:56
+// @ LastEditors  : Eacher
+// @ --------------------------------------------------------------------------------<
+// @ Description  : 监听分组，按分组移除或暂停监听
+// @ --------------------------------------------------------------------------------<
+// @ FilePath     : /inotify/group_linux.go
+// @@
+package inotify
+
+import (
+	"os"
+	"errors"
+	"path/filepath"
+	"golang.org/x/sys/unix"
+)
+
+// AddWatchGroup 同 AddWatch，监听属于 group，事件的 Group 为 group。
+// 重复添加同一路径时分组改为最后一次的 group
+func (w *Watcher) AddWatchGroup(group, path string, flags uint32) error {
+	return w.addWatch(path, flags, func(ws *WatchSingle) { ws.group = group })
+}
+
+// RemoveWatch 移除 path 的监听，之后还会收到一个该路径的 IGNORED 事件
+func (w *Watcher) RemoveWatch(path string) error {
+	if !w.initialized() {
+		return ErrNotInitialized
+	}
+	var err error
+	if path, err = filepath.Abs(path); err != nil {
+		return err
+	}
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	if w.closes {
+		return ErrClosed
+	}
+	if w.unpend(path) {
+		return nil
+	}
+	if w.unglob(path) {
+		return nil
+	}
+	if w.remount != nil && w.unlose(path) {
+		return nil
+	}
+	for _, ws := range w.watchMap {
+		// flags 为 0 的只是 WithPendingWatches 等待的上级目录
+		if !ws.remove && ws.flags != 0 && (ws.path == path || ws.path == path+string(os.PathSeparator)) {
+			return w.unwatch(ws)
+		}
+	}
+	return &WatchError{Path: path, Err: ErrNotWatched}
+}
+
+// RemoveGroup 移除 group 中所有的监听
+func (w *Watcher) RemoveGroup(group string) error {
+	if !w.initialized() {
+		return ErrNotInitialized
+	}
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	if w.closes {
+		return ErrClosed
+	}
+	var err error
+	for _, ws := range w.watchMap {
+		if !ws.remove && ws.flags != 0 && ws.group == group {
+			if e := w.unwatch(ws); e != nil {
+				err = e
+			}
+		}
+	}
+	delete(w.paused, group)
+	return err
+}
+
+// RemoveAll 移除所有监听，Watcher 仍可继续添加监听，之后还会收到每个监听的 IGNORED 事件。
+// drain 为 true 时先丢弃已读取还未取走的事件
+func (w *Watcher) RemoveAll(drain bool) error {
+	if !w.initialized() {
+		return ErrNotInitialized
+	}
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	if w.closes {
+		return ErrClosed
+	}
+	if drain {
+		w.discard()
+	}
+	for path := range w.pending {
+		w.unpend(path)
+	}
+	var err error
+	for _, ws := range w.watchMap {
+		for _, f := range ws.files {
+			f.dir = nil
+		}
+		ws.files = nil
+		if !ws.remove {
+			if e := w.rmWatch(ws); e != nil {
+				err = e
+			}
+		}
+	}
+	w.paused = make(map[string]bool)
+	return err
+}
+
+// discard 丢弃缓存中的所有事件，其中的 IGNORED 同样释放其监听，调用者需持有 mutex
+func (w *Watcher) discard() {
+	buf := append([]byte(nil), w.eventBuffer[w.bufferHead:w.bufferItem]...)
+	n, _ := records(buf)
+	w.stats.Filtered += n + uint64(len(w.injected))
+	w.bufferHead, w.bufferItem, w.arrivals, w.arrivalHead, w.injected = 0, 0, nil, 0, nil
+	w.discarded(buf)
+	w.space.Broadcast()
+}
+
+// PauseGroup 暂停 group，暂停期间该分组的事件直接丢弃，监听仍然保留
+func (w *Watcher) PauseGroup(group string) error {
+	if !w.initialized() {
+		return ErrNotInitialized
+	}
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	if w.closes {
+		return ErrClosed
+	}
+	w.paused[group] = true
+	return nil
+}
+
+// ResumeGroup 恢复 PauseGroup 暂停的分组
+func (w *Watcher) ResumeGroup(group string) error {
+	if !w.initialized() {
+		return ErrNotInitialized
+	}
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	if w.closes {
+		return ErrClosed
+	}
+	delete(w.paused, group)
+	return nil
+}
+
+// unwatch 移除调用者添加的 ws，仍被 WithPendingWatches、WatchFile 使用时只移除调用者的事件，不会收到 IGNORED，调用者需持有 mutex
+func (w *Watcher) unwatch(ws *WatchSingle) error {
+	if w.extraMask(ws) != 0 {
+		ws.flags = 0
+		w.restore(ws)
+		return nil
+	}
+	return w.rmWatch(ws)
+}
+
+// rmWatch 调用者需持有 mutex，watchMap 中的记录在读到 IGNORED 后删除
+func (w *Watcher) rmWatch(ws *WatchSingle) error {
+	ws.remove = true
+	if err := w.backend.Remove(int(ws.watchId)); err != nil && !errors.Is(err, unix.EINVAL) {
+		return &WatchError{Path: ws.path, Err: err}
+	}
+	return nil
+}
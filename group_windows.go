@@ -0,0 +1,114 @@
+// @@
+// @ Author       : Eacher
+// @ Date         : 2026-10-15 13:37:07
+// @ LastEditTime : 2026-10-24 18:26:56
+// @ LastEditors  : Eacher
+// @ --------------------------------------------------------------------------------<
+// @ Description  : 监听分组，按分组移除或暂停监听
+// @ --------------------------------------------------------------------------------<
+// @ FilePath     : /inotify/group_windows.go
+// @@
+package inotify
+
+import (
+	"os"
+	"syscall"
+	"path/filepath"
+)
+
+// AddWatchGroup 同 AddWatch，监听属于 group，事件的 Group 为 group
+func (w *Watcher) AddWatchGroup(group, path string, flags uint32) error {
+	return w.addWatch(path, flags, func(ws *WatchSingle) { ws.group = group })
+}
+
+// RemoveWatch 移除 path 的监听
+func (w *Watcher) RemoveWatch(path string) error {
+	if !w.initialized() {
+		return ErrNotInitialized
+	}
+	var err error
+	if path, err = filepath.Abs(path); err != nil {
+		return err
+	}
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	for key, ws := range w.watchMap {
+		if ws.path == path || ws.path == path+string(os.PathSeparator) {
+			w.rmWatch(key, ws)
+			return nil
+		}
+	}
+	return &WatchError{Path: path, Err: ErrNotWatched}
+}
+
+// RemoveGroup 移除 group 中所有的监听
+func (w *Watcher) RemoveGroup(group string) error {
+	if !w.initialized() {
+		return ErrNotInitialized
+	}
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	for key, ws := range w.watchMap {
+		if ws.group == group {
+			w.rmWatch(key, ws)
+		}
+	}
+	delete(w.paused, group)
+	return nil
+}
+
+// RemoveAll 移除所有监听，Watcher 仍可继续添加监听。drain 为 true 时先丢弃还未取走的事件
+func (w *Watcher) RemoveAll(drain bool) error {
+	if !w.initialized() {
+		return ErrNotInitialized
+	}
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	if w.closes {
+		return ErrClosed
+	}
+	for drain {
+		select {
+		case e := <-w.e:
+			if e != nil {
+				freeEvent(e)
+			}
+		default:
+			drain = false
+		}
+	}
+	for key, ws := range w.watchMap {
+		w.rmWatch(key, ws)
+	}
+	w.paused = make(map[string]bool)
+	return nil
+}
+
+// PauseGroup 暂停 group，暂停期间该分组的事件直接丢弃，监听仍然保留
+func (w *Watcher) PauseGroup(group string) error {
+	if !w.initialized() {
+		return ErrNotInitialized
+	}
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	w.paused[group] = true
+	return nil
+}
+
+// ResumeGroup 恢复 PauseGroup 暂停的分组
+func (w *Watcher) ResumeGroup(group string) error {
+	if !w.initialized() {
+		return ErrNotInitialized
+	}
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	delete(w.paused, group)
+	return nil
+}
+
+// rmWatch 调用者需持有 mutex
+func (w *Watcher) rmWatch(key uint32, ws *WatchSingle) {
+	delete(w.watchMap, key)
+	syscall.CancelIo(ws.h)
+	syscall.CloseHandle(ws.h)
+}
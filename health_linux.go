@@ -0,0 +1,58 @@
+// @@
+// @ Author       : Eacher
+// @ Date         : 2026-10-18 14:01:28
+// @ LastEditTime : 2026-10-19 18:40:06
+// @ LastEditors  : Eacher
+// @ --------------------------------------------------------------------------------<
+// @ Description  : 供服务的就绪检查使用的健康检查
+// @ --------------------------------------------------------------------------------<
+// @ FilePath     : /inotify/health_linux.go
+// @@
+package inotify
+
+import (
+	"fmt"
+	"errors"
+	"time"
+	"golang.org/x/sys/unix"
+)
+
+// HealthStall 缓存中有事件且超过该时间没有交给调用者时 Healthy 返回错误
+var HealthStall = time.Minute
+
+// Healthy 检查 inotify 与 epoll 的 fd 仍然有效、读取的 goroutine 仍在运行、事件没有长时间积压在缓存中(没有消费者或消费者卡住)。
+// 已关闭时返回 ErrClosed
+func (w *Watcher) Healthy() error {
+	if !w.initialized() {
+		return ErrNotInitialized
+	}
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	if w.closes {
+		return ErrClosed
+	}
+	fds := map[string]int{"inotify": w.backend.Fd()}
+	if !w.external {
+		fds["epoll"], fds["wake"] = w.epollFD, w.wakeFD
+		select {
+		case <-w.done:
+			return errors.New("The epoll goroutine is not running")
+		default:
+		}
+	}
+	for name, fd := range fds {
+		if _, err := unix.FcntlInt(uintptr(fd), unix.F_GETFD, 0); err != nil {
+			return fmt.Errorf("The %s fd %d is invalid: %w", name, fd, err)
+		}
+	}
+	if w.bufferItem > 0 || len(w.injected) > 0 {
+		since := w.pendingAt
+		if w.deliveredAt.After(since) {
+			since = w.deliveredAt
+		}
+		if d := time.Since(since); d > HealthStall {
+			return fmt.Errorf("The events are not consumed for %v", d.Round(time.Second))
+		}
+	}
+	return nil
+}
@@ -0,0 +1,31 @@
+// @@
+// @ Author       : Eacher
+// @ Date         : 2026-10-18 14:01:28
+// @ LastEditTime : 2026-10-18 14:39:05
+// @ LastEditors  : Eacher
+// @ --------------------------------------------------------------------------------<
+// @ Description  : windows 只检查是否已关闭
+// @ --------------------------------------------------------------------------------<
+// @ FilePath     : /inotify/health_windows.go
+// @@
+package inotify
+
+import (
+	"time"
+)
+
+// HealthStall windows 不检查事件积压
+var HealthStall = time.Minute
+
+// Healthy 已关闭时返回 ErrClosed
+func (w *Watcher) Healthy() error {
+	if !w.initialized() {
+		return ErrNotInitialized
+	}
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	if w.closes {
+		return ErrClosed
+	}
+	return nil
+}
@@ -0,0 +1,88 @@
+// @@
+// @ Author       : Eacher
+// @ Date         : 2026-10-20 20:14:51
+// @ LastEditTime : 2026-10-21 11:29:53
+// @ LastEditors  : Eacher
+// @ --------------------------------------------------------------------------------<
+// @ Description  : 自动移除一段时间没有事件的监听，长期运行时监听数量不会只增不减
+// @ --------------------------------------------------------------------------------<
+// @ FilePath     : /inotify/idle_linux.go
+// @@
+package inotify
+
+import (
+	"time"
+	"unsafe"
+	"path/filepath"
+	"golang.org/x/sys/unix"
+)
+
+type idler struct {
+	timeout 	time.Duration
+	before 		func(path string) bool
+}
+
+// WithIdleUnwatch 自动移除 timeout 内没有读到事件的监听，添加监听(包括再次 AddWatch 同一路径)也算一次活动。
+// 移除前调用 before(不持有锁，可以调用 Watcher 的方法)，返回 false 时保留该监听并重新计时，before 为 nil 时直接移除。
+// 每 timeout/2 检查一次，监听最晚在 1.5 倍 timeout 后移除，递归监听的每个目录分别计时
+func WithIdleUnwatch(timeout time.Duration, before func(path string) bool) Option {
+	return func(w *Watcher) {
+		if timeout > 0 {
+			w.idle = &idler{timeout: timeout, before: before}
+		}
+	}
+}
+
+// active 读到从 start 开始新的事件的监听重新计时，调用者需持有 mutex
+func (w *Watcher) active(start uint32) {
+	now := time.Now()
+	for offset := start; offset+unix.SizeofInotifyEvent <= w.bufferItem; {
+		event := (*unix.InotifyEvent)(unsafe.Pointer(&w.eventBuffer[offset]))
+		if ws, ok := w.owner(event.Wd, offset); ok {
+			ws.active = now
+		}
+		offset += uint32(unix.SizeofInotifyEvent) + event.Len
+	}
+}
+
+func (w *Watcher) idleLoop() {
+	ticker := time.NewTicker(w.idle.timeout/2)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			w.unwatchIdle()
+		case <-w.closed():
+			return
+		}
+	}
+}
+
+// unwatchIdle 移除一次空闲的监听，before 调用期间有新事件的监听不移除
+func (w *Watcher) unwatchIdle() {
+	w.mutex.Lock()
+	if w.closes {
+		w.mutex.Unlock()
+		return
+	}
+	var idle []*WatchSingle
+	active := make(map[*WatchSingle]time.Time)
+	for _, ws := range w.watchMap {
+		if !ws.remove && ws.flags != 0 && time.Since(ws.active) >= w.idle.timeout {
+			idle, active[ws] = append(idle, ws), ws.active
+		}
+	}
+	w.mutex.Unlock()
+	for _, ws := range idle {
+		keep := w.idle.before != nil && !w.idle.before(filepath.Clean(ws.path))
+		w.mutex.Lock()
+		switch {
+		case w.closes || ws.remove || ws.flags == 0 || !ws.active.Equal(active[ws]):
+		case keep:
+			ws.active = time.Now()
+		default:
+			w.unwatch(ws)
+		}
+		w.mutex.Unlock()
+	}
+}
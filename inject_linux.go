@@ -0,0 +1,93 @@
+// @@
+// @ Author       : Eacher
+// @ Date         : 2026-10-18 11:36:05
+// @ LastEditTime : 2026-10-20 10:45:20
+// @ LastEditors  : Eacher
+// @ --------------------------------------------------------------------------------<
+// @ Description  : 测试时模拟难以用真实文件操作产生的事件，如溢出、IN_IGNORED、rename
+// @ --------------------------------------------------------------------------------<
+// @ FilePath     : /inotify/inject_linux.go
+// @@
+package inotify
+
+import (
+	"os"
+	"errors"
+	"unsafe"
+	"path/filepath"
+	"golang.org/x/sys/unix"
+)
+
+// WithInjection 允许调用 Inject，只用于测试
+func WithInjection() Option {
+	return func(w *Watcher) {
+		w.injection = true
+	}
+}
+
+// Inject 把 e 当作从 inotify fd 读到的事件处理，与真实的事件相同地经过 WithEventFilter、暂停的分组、递归监听，
+// 并更新监听的状态(如 IN_IGNORED 之后移除该监听)。只使用 e.FileName、e.Raw、e.Cookie:
+// FileName 为监听的路径且 Raw 包含 IN_IGNORED、IN_DELETE_SELF、IN_MOVE_SELF、IN_UNMOUNT 时是监听自身的事件，否则是所在目录的监听的事件，
+// IN_Q_OVERFLOW 不需要 FileName。模拟的 IN_IGNORED 不会移除内核中的监听。需要 WithInjection
+func (w *Watcher) Inject(e Event) error {
+	if !w.initialized() {
+		return ErrNotInitialized
+	}
+	if !w.injection {
+		return errors.New("The Watcher is not created with WithInjection")
+	}
+	w.mutex.Lock()
+	if w.closes {
+		w.mutex.Unlock()
+		return ErrClosed
+	}
+	wd, name := int32(-1), ""
+	if e.Raw&unix.IN_Q_OVERFLOW == 0 {
+		path := filepath.Clean(e.FileName)
+		self, parent := w.lookup(path), w.lookup(filepath.Dir(path))
+		switch {
+		case self != nil && (e.Raw&(unix.IN_IGNORED|unix.IN_DELETE_SELF|unix.IN_MOVE_SELF|unix.IN_UNMOUNT) != 0 || parent == nil):
+			wd = int32(self.watchId)
+		case parent != nil:
+			wd, name = int32(parent.watchId), filepath.Base(path)
+		default:
+			w.mutex.Unlock()
+			return &WatchError{Path: path, Err: ErrNotWatched}
+		}
+	}
+	size := 0
+	if name != "" {
+		// 与内核相同，名字以 NUL 填充对齐
+		size = (len(name)/unix.SizeofInotifyEvent + 1)*unix.SizeofInotifyEvent
+	}
+	if int(w.bufferItem)+unix.SizeofInotifyEvent+size > len(w.eventBuffer) {
+		w.compact()
+	}
+	if int(w.bufferItem)+unix.SizeofInotifyEvent+size > len(w.eventBuffer) {
+		w.mutex.Unlock()
+		return errors.New("The events buffer is full")
+	}
+	start := w.bufferItem
+	*(*unix.InotifyEvent)(unsafe.Pointer(&w.eventBuffer[start])) = unix.InotifyEvent{Wd: wd, Mask: e.Raw, Cookie: e.Cookie, Len: uint32(size)}
+	record := w.eventBuffer[start+unix.SizeofInotifyEvent:start+unix.SizeofInotifyEvent+uint32(size)]
+	copy(record, name)
+	for i := len(name); i < len(record); i++ {
+		record[i] = 0
+	}
+	w.bufferItem += uint32(unix.SizeofInotifyEvent+size)
+	dirs := w.received(start)
+	w.signal(w.eventBuffer[start:w.bufferItem])
+	w.mutex.Unlock()
+	w.watchDirs(dirs)
+	return nil
+}
+
+// lookup 路径为 path 的监听，调用者需持有 mutex
+func (w *Watcher) lookup(path string) *WatchSingle {
+	for _, ws := range w.watchMap {
+		if ws.path == path || ws.path == path+string(os.PathSeparator) {
+			return ws
+		}
+	}
+	return nil
+}
@@ -1,7 +1,7 @@
 // @@
 // @ Author       : Eacher
 // @ Date         : 2023-02-25 11:49:47
-// @ LastEditTime : 2023-02-28 10:05:26
+// @ LastEditTime : 2026-10-21 14:41:14
 // @ LastEditors  : Eacher
 // @ --------------------------------------------------------------------------------<
 // @ Description  : 
@@ -11,21 +11,280 @@
 package inotify
 
 import (
+	"os"
+	"time"
+	"errors"
+	"expvar"
+	"context"
+	"io/fs"
+	"path/filepath"
+)
 
+var (
+	// ErrClosed 监听者已关闭
+	ErrClosed 			= errors.New("The Watcher is closed")
+	// ErrNotInitialized 监听者为 nil 或未通过 NewWatcher 创建
+	ErrNotInitialized 	= errors.New("The Watcher is not initialized")
+	// ErrDropped 缓存已满丢弃了新事件(DropNewest)，每次丢弃后返回一次
+	ErrDropped 			= errors.New("The events dropped")
+	// ErrLimit 递归监听达到 WithRecursionLimit 的限制
+	ErrLimit 			= errors.New("The recursive watch limit reached")
+	// ErrNotExist 监听的文件或目录不存在，与 fs.ErrNotExist 相同，内核返回的 ENOENT 同样满足 errors.Is
+	ErrNotExist 		= fs.ErrNotExist
+	// ErrNotWatched 移除或修改的路径没有被监听
+	ErrNotWatched 		= errors.New("The path is not watched")
+	// ErrWatchLimit 达到系统的监听数量限制(linux 的 fs.inotify.max_user_watches)，用 errors.Is 判断 AddWatch 等返回的 *WatchError
+	ErrWatchLimit 		= errors.New("The watch limit reached")
+	// ErrOverflow 内核队列已满丢弃了事件，Errors 在收到 IN_Q_OVERFLOW 事件时发送
+	ErrOverflow 		= errors.New("The events queue overflowed")
+	// ErrBufferCorrupt 缓存中的事件不完整，缓存已被清空
+	ErrBufferCorrupt 	= errors.New("The event buffer is corrupt")
+	// ErrWatchGone 事件的监听已被删除或移动，缓存已被清空
+	ErrWatchGone 		= errors.New("The monitored directory or file has been deleted or renamed")
 )
 
+// WatchError 添加、修改或移除 Path 的监听失败，Err 为原因(ErrNotExist、ErrNotWatched 或系统调用的错误)
+type WatchError struct {
+	Path 	string
+	Err 	error
+}
+
+func (e *WatchError) Error() string {
+	return "The watch " + e.Path + ": " + e.Err.Error()
+}
+
+func (e *WatchError) Unwrap() error {
+	return e.Err
+}
+
+// Is 系统调用返回的监听数量限制(linux 的 ENOSPC)满足 errors.Is(err, ErrWatchLimit)
+func (e *WatchError) Is(target error) bool {
+	return target == ErrWatchLimit && watchLimited(e.Err)
+}
+
+// Backpressure 消费者跟不上时缓存已满的处理方式
+type Backpressure int
+
+const (
+	// DropOldest 丢弃最早的事件，默认
+	DropOldest Backpressure = iota
+	// DropNewest 丢弃新读到的事件，之后的 WaitEvent 返回一次 ErrDropped
+	DropNewest
+	// Block 暂停读取直到缓存有空间，事件积压在内核队列中，内核队列满后产生 IN_Q_OVERFLOW
+	Block
+)
+
+// Option NewWatcher 的可选配置
+type Option func(*Watcher)
+
+func WithBackpressure(b Backpressure) Option {
+	return func(w *Watcher) {
+		w.backpressure = b
+	}
+}
+
+// WithEventFilter 在读取事件时调用 f，返回 false 的事件不会进入缓存，多次设置时需全部通过。
+// f 在读取者中持有锁调用，不能再调用该 Watcher 的方法
+func WithEventFilter(f func(Event) bool) Option {
+	return func(w *Watcher) {
+		if prev := w.filter; prev != nil {
+			w.filter = func(e Event) bool { return prev(e) && f(e) }
+			return
+		}
+		w.filter = f
+	}
+}
+
+// WithDeliveryTime 设置 Event.Delivered，Delivered 与 Time 之差为事件在缓存中等待的时间
+func WithDeliveryTime() Option {
+	return func(w *Watcher) {
+		w.deliveryTime = true
+	}
+}
+
+// WithFileInfo 交给调用者之前 lstat 事件的对象，结果为 Event.Info，调用者不需要再次 stat，对象随后被删除时仍有数据。
+// 每个事件多一次系统调用，WaitEventInto 也会因此分配内存
+func WithFileInfo() Option {
+	return func(w *Watcher) {
+		w.fileInfo = true
+	}
+}
+
+// describe 设置 e.Info 与 e.InfoRaced，对象已不存在时 Info 为 nil
+func describe(e *Event) {
+	path := e.Path()
+	if path == "" || e.Raw&IN_Q_OVERFLOW != 0 {
+		return
+	}
+	info, err := os.Lstat(path)
+	if err != nil {
+		return
+	}
+	// 读到事件之后对象又发生了变化，或删除、移走的路径上已经是另一个对象
+	e.Info, e.InfoRaced = info, changedAt(info).After(e.Time) || e.Raw&(IN_DELETE|IN_DELETE_SELF|IN_MOVED_FROM) != 0
+}
+
+// Event 监听到的事件，FileName 为绝对路径，Op 为由 Raw 得到的简化操作，Raw 为内核返回的 mask，
+// Cookie 为内核的 cookie，关联同一次 rename 的 MOVED_FROM 与 MOVED_TO，其他事件与 windows 上为 0，
+// Group 为 AddWatchGroup 添加时的分组，Data 为 AddWatchData 添加时的数据，
+// Count 为 WithRateLimit 合并的事件数量，普通事件为 0，
+// Time 为从内核读到事件的时间(不来自内核的事件为产生或交给调用者的时间)，
+// Delivered 为交给调用者的时间，需要 WithDeliveryTime，
+// Seq 为同一 Watcher 中从 1 开始递增的序号，按 Backpressure 丢弃的事件也占用序号，Seq 不连续时中间的事件已被丢弃，
+// Info 为 WithFileInfo 时交给调用者前 lstat 的结果，InfoRaced 表示 lstat 时对象已在事件之后再次改变，Info 不是事件时的状态，
+// Attrib 为 WithAttribDiff 时 IN_ATTRIB 前后的属性
+type Event struct {
+	wd 			uint32
+	FileName 	string
+	Op 			Op
+	Raw 		uint32
+	Cookie 		uint32
+	Group 		string
+	Data 		any
+	Count 		int
+	Time 		time.Time
+	Delivered 	time.Time
+	Seq 		uint64
+	Info 		fs.FileInfo
+	InfoRaced 	bool
+	Attrib 		*AttribChange
+	// WaitEventInto 设置，FileName 为空时 Dir、Name 的结果，name 指向 scratch
+	dir 		string
+	name 		string
+	scratch 	[]byte
+}
+
+// Dir 事件对象所在的目录，Name 为对象的名字，由 FileName 得到。WaitEventInto 的事件 FileName 为空，只能使用它们或 Path
+func (e Event) Dir() string {
+	if e.dir != "" || e.FileName == "" {
+		return e.dir
+	}
+	return filepath.Dir(e.FileName)
+}
+
+// Name 见 Dir，来自 WaitEventInto 时在下一次 WaitEventInto 之前有效
+func (e Event) Name() string {
+	if e.dir != "" || e.FileName == "" {
+		return e.name
+	}
+	return filepath.Base(e.FileName)
+}
+
+// 事件与监听标志，类型与 AddWatch 的 flags、Event.Raw 相同。windows 不支持的为 0
 const (
-	IN_ATTRIB                        = in_ATTRIB
-	IN_CLOSE                         = in_CLOSE
-	IN_CLOSE_NOWRITE                 = in_CLOSE_NOWRITE
-	IN_CLOSE_WRITE                   = in_CLOSE_WRITE
-	IN_CREATE                        = in_CREATE
-	IN_DELETE                        = in_DELETE
-	IN_DELETE_SELF                   = in_DELETE_SELF
-	IN_MODIFY                        = in_MODIFY
-	IN_MOVE                          = in_MOVE
-	IN_MOVED_FROM                    = in_MOVED_FROM
-	IN_MOVED_TO                      = in_MOVED_TO
-	IN_MOVE_SELF                     = in_MOVE_SELF
-	IN_OPEN                          = in_OPEN
+	IN_ACCESS                 uint32 = in_ACCESS
+	IN_ATTRIB                 uint32 = in_ATTRIB
+	IN_CLOSE                  uint32 = in_CLOSE
+	IN_CLOSE_NOWRITE          uint32 = in_CLOSE_NOWRITE
+	IN_CLOSE_WRITE            uint32 = in_CLOSE_WRITE
+	IN_CREATE                 uint32 = in_CREATE
+	IN_DELETE                 uint32 = in_DELETE
+	IN_DELETE_SELF            uint32 = in_DELETE_SELF
+	IN_MODIFY                 uint32 = in_MODIFY
+	IN_MOVE                   uint32 = in_MOVE
+	IN_MOVED_FROM             uint32 = in_MOVED_FROM
+	IN_MOVED_TO               uint32 = in_MOVED_TO
+	IN_MOVE_SELF              uint32 = in_MOVE_SELF
+	IN_OPEN                   uint32 = in_OPEN
+	IN_UNMOUNT                uint32 = in_UNMOUNT
+	IN_ALL_EVENTS             uint32 = in_ALL_EVENTS
+
+	// 只在 AddWatch 的 flags 中使用
+	IN_DONT_FOLLOW            uint32 = in_DONT_FOLLOW
+	IN_EXCL_UNLINK            uint32 = in_EXCL_UNLINK
+	IN_MASK_ADD               uint32 = in_MASK_ADD
+	IN_MASK_CREATE            uint32 = in_MASK_CREATE
+	IN_ONESHOT                uint32 = in_ONESHOT
+	IN_ONLYDIR                uint32 = in_ONLYDIR
+
+	// 只出现在 Event.Raw 中
+	IN_IGNORED                uint32 = in_IGNORED
+	IN_Q_OVERFLOW             uint32 = in_Q_OVERFLOW
+	IN_ISDIR                  uint32 = in_ISDIR
 )
+
+// Path 事件对象的路径，即 FileName；WaitEventInto 的事件由 Dir 与 Name 拼接，会分配内存
+func (e Event) Path() string {
+	if e.FileName != "" || e.dir == "" {
+		return e.FileName
+	}
+	return filepath.Join(e.dir, e.name)
+}
+
+// IsDir 事件的对象是否为目录，由每个事件的 IN_ISDIR 得到，windows 上总是 false
+func (e Event) IsDir() bool {
+	return e.Raw&IN_ISDIR != 0
+}
+
+// Stats Watcher 创建以来的计数，用于排查事件丢失
+type Stats struct {
+	// 从内核读到的事件，windows 为 0
+	Read 			uint64
+	// 交给调用者的事件
+	Delivered 		uint64
+	// 缓存已满时按 Backpressure 丢弃的事件
+	Dropped 		uint64
+	// 被 WithEventFilter、WithModifyDedup、WithRateLimit 过滤或合并的事件，以及暂停的分组与 RemoveAll 丢弃的事件
+	Filtered 		uint64
+	// IN_Q_OVERFLOW 的次数，每次都表示内核丢弃了事件
+	Overflows 		uint64
+	// 当前的监听数量
+	Watches 		int
+	// 当前缓存中还未交给调用者的事件
+	Queued 			int
+	// 缓存使用的最大字节数与缓存的容量，windows 为 0
+	BufferHighWater int
+	BufferSize 		int
+}
+
+// Publish 以 name 发布到 expvar，内容为 Stats()，已有服务的 /debug/vars 中即可看到事件、丢弃、溢出与监听的数量。
+// 与 expvar.Publish 相同，name 已发布时 panic
+func (w *Watcher) Publish(name string) {
+	expvar.Publish(name, expvar.Func(func() interface{} { return w.Stats() }))
+}
+
+// Registrar 只能添加和移除监听，交给只负责配置监听路径的组件
+type Registrar interface {
+	AddWatch(path string, flags uint32) error
+	RemoveWatch(path string) error
+}
+
+// EventSource 只能读取事件，交给只负责处理事件的组件
+type EventSource interface {
+	Events() <-chan Event
+	Errors() <-chan error
+	Close() error
+}
+
+var (
+	_ Registrar 	= (*Watcher)(nil)
+	_ EventSource 	= (*Watcher)(nil)
+)
+
+// MustNewWatcher 同 NewWatcher，创建失败时 panic
+func MustNewWatcher(opts ...Option) *Watcher {
+	w, err := NewWatcher(opts...)
+	if err != nil {
+		panic(err)
+	}
+	return w
+}
+
+// NewWatcherContext 同 NewWatcher，ctx 结束时自动 Close: 读取的 goroutine 退出，WaitEvent 等返回 ErrClosed，Events、Errors 被关闭
+func NewWatcherContext(ctx context.Context, opts ...Option) (*Watcher, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	w, err := NewWatcher(opts...)
+	if err != nil {
+		return nil, err
+	}
+	go func() {
+		select {
+		case <-ctx.Done():
+			w.Close()
+		case <-w.closed():
+		}
+	}()
+	return w, nil
+}
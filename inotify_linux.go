@@ -1,9 +1,10 @@
 //go:build linux
 // +build linux
+
 // @@
 // @ Author       : Eacher
 // @ Date         : 2023-02-20 08:45:05
-// @ LastEditTime : 2023-02-28 10:06:20
+// @ LastEditTime : 2026-10-23 11:25:17
 // @ LastEditors  : Eacher
 // @ --------------------------------------------------------------------------------<
 // @ Description  : Linux inotify 文件监听功能
@@ -14,45 +15,164 @@ package inotify
 
 import (
 	"os"
+	"bytes"
 	"unsafe"
 	"sync"
-	"syscall"
-	"fmt"
+	"time"
+	"context"
 	"errors"
+	"strings"
+	"io/fs"
+	"syscall"
 	"path/filepath"
+	"golang.org/x/sys/unix"
 )
 
 const (
-	in_OPEN 				= syscall.IN_OPEN
-	in_ATTRIB 				= syscall.IN_ATTRIB
-	in_CLOSE 				= syscall.IN_CLOSE
-	in_CLOSE_NOWRITE		= syscall.IN_CLOSE_NOWRITE
-	in_CLOSE_WRITE 			= syscall.IN_CLOSE_WRITE
-	in_CREATE 				= syscall.IN_CREATE
-	in_DELETE 				= syscall.IN_DELETE
-	in_DELETE_SELF 			= syscall.IN_DELETE_SELF
-	in_MODIFY 				= syscall.IN_MODIFY
-	in_MOVE 				= syscall.IN_MOVE
-	in_MOVED_FROM 			= syscall.IN_MOVED_FROM
-	in_MOVED_TO 			= syscall.IN_MOVED_TO
-	in_MOVE_SELF 			= syscall.IN_MOVE_SELF
+	in_ACCESS 				= unix.IN_ACCESS
+	in_OPEN 				= unix.IN_OPEN
+	in_ATTRIB 				= unix.IN_ATTRIB
+	in_CLOSE 				= unix.IN_CLOSE
+	in_CLOSE_NOWRITE		= unix.IN_CLOSE_NOWRITE
+	in_CLOSE_WRITE 			= unix.IN_CLOSE_WRITE
+	in_CREATE 				= unix.IN_CREATE
+	in_DELETE 				= unix.IN_DELETE
+	in_DELETE_SELF 			= unix.IN_DELETE_SELF
+	in_MODIFY 				= unix.IN_MODIFY
+	in_MOVE 				= unix.IN_MOVE
+	in_MOVED_FROM 			= unix.IN_MOVED_FROM
+	in_MOVED_TO 			= unix.IN_MOVED_TO
+	in_MOVE_SELF 			= unix.IN_MOVE_SELF
+	in_UNMOUNT 				= unix.IN_UNMOUNT
+
+	in_ALL_EVENTS 			= unix.IN_ALL_EVENTS
+	in_DONT_FOLLOW 			= unix.IN_DONT_FOLLOW
+	in_EXCL_UNLINK 			= unix.IN_EXCL_UNLINK
+	in_MASK_ADD 			= unix.IN_MASK_ADD
+	in_MASK_CREATE 			= unix.IN_MASK_CREATE
+	in_ONESHOT 				= unix.IN_ONESHOT
+	in_ONLYDIR 				= unix.IN_ONLYDIR
+	in_IGNORED 				= unix.IN_IGNORED
+	in_ISDIR 				= unix.IN_ISDIR
+	in_Q_OVERFLOW 			= unix.IN_Q_OVERFLOW
 )
 
 
-// 防止数组溢出
-const MAX_ITEM = syscall.SizeofInotifyEvent*20
+// 防止数组溢出，默认缓存大小时的高水位，WithReadBuffer 时为 highWater
+const MAX_ITEM = unix.SizeofInotifyEvent*20
 
 type Watcher struct {
-	inotifyFD 	int
+	// 默认为内核的 inotify fd，WithBackend 替换
+	backend 	WatchBackend
+	// WithBackendType、WithPollInterval 选择的内置后端
+	backendType BackendType
+	pollInterval time.Duration
+	// WithInstancePool 每个 inotify 实例的监听数量
+	poolWatches int
 	epollFD 	int
+	// Close 由 wake 写入该 eventfd，唤醒阻塞在 EpollWait 的 goroutine，不需要关闭 inotify fd
+	wakeFD 		int
+	done 		chan struct{}
+	// WithExternalLoop 时不创建 epoll 与 goroutine，由调用者调用 ReadEvents
+	external 	bool
+	// WithFdFlags 设置的 inotify_init1 与 epoll_create1 的标志
+	initFlags 	int
+	epollFlags 	int
+	// WithBlockingRead 时 ReadEvents 不持有 mutex 阻塞读取到 readBuf，reader 使同一时刻只有一个 ReadEvents，
+	// readLeft 为读到后还未移入缓存的数据
+	blocking 	bool
+	reading 	bool
+	reader 		sync.Mutex
+	readBuf 	[]byte
+	readLeft 	[]byte
+	// WithInjection 时可以调用 Inject
+	injection 	bool
+	// WithDeliveryTime 时设置 Event.Delivered
+	deliveryTime bool
+	// WithFileInfo 时设置 Event.Info
+	fileInfo 	bool
+	// WithAttribDiff 时缓存的属性，key 为不以分隔符结尾的路径
+	attrs 		map[string]FileAttr
+	// WithSnapshot 时由读到的事件更新的快照，key 同 attrs
+	tree 		map[string]FileAttr
+	// WithSnapshotFile 的文件与 NewWatcher 时读到的快照中还未对比的路径、保存时的监听
+	snapshotFile string
+	previous 	map[string]FileAttr
+	replayed 	map[string]bool
+	// WithAtomicSave 时 WatchFile 的文件被 rename 替换后补发 Write 事件
+	atomicSave 	bool
+	// WithCoalesceKey 设置的分组，nil 时为 ByPath
+	coalesceKey CoalesceKey
 
 	watchMap 	map[uint32]*WatchSingle
-	eventBuffer [syscall.SizeofInotifyEvent*25]byte
+	// 已读到或即将读到 IN_IGNORED 的监听，在 IN_IGNORED 取出之前缓存中该 wd 的事件仍属于它们，内核重用的 wd 不会继承它们的数据
+	retired 	map[uint32][]*WatchSingle
+	// WithReadBuffer 设置的缓存大小
+	bufferSize 	int
+	// 缓存中的事件为 eventBuffer[bufferHead:bufferItem]，取出事件只移动 bufferHead，取完时两者归零，
+	// 尾部放不下新读到的事件时由 compact 移到开头
+	eventBuffer []byte
+	bufferHead 	uint32
 	bufferItem 	uint32
+	// eventBuffer 中每个事件从内核读到的时间，arrivals[arrivalHead:] 为还未取出的事件
+	arrivals 	[]arrival
+	arrivalHead int
+	// 按 DropNewest 丢弃、还未计入序号的事件，计入下一个读到的事件之前
+	gap 		uint64
+	// 等待 MOVED_TO 的 MOVED_FROM，用于更新被移动的监听的路径
+	moveFrom 	moveFrom
+	// WithPendingWatches 时还不存在的路径
+	pending 	map[string]*pendingWatch
+	// AddWatch 的通配符
+	globs 		map[string]*globWatch
 
 	mutex   	sync.Mutex
 	cond   		*sync.Cond
-	wait   		bool
+	// Block 时读取者等待缓存空间
+	space 		*sync.Cond
+	backpressure Backpressure
+	dropped 	bool
+	// epoll goroutine 中读取 fd、移除监听的错误，由下一个读取者返回一次(Events 时交给 Errors)
+	failed 		error
+	filter 		func(Event) bool
+	observers 	[]func(Event)
+	// 暂停的分组，读取者直接丢弃这些分组的事件
+	paused 		map[string]bool
+	// RegisterFd 加入 epoll 的 fd 与 handler
+	fds 		map[int]func(fd int, events uint32)
+	// 不来自 inotify fd 的事件(WithRateLimit 的汇总事件、新目录中补发的 CREATE)，先于缓存中的事件读取
+	injected 	[]Event
+	// WithRecursionLimit 的限制与当前递归监听的目录数量
+	limit 		recursionLimit
+	recursiveDirs int
+	// WithRescan 定期对比监听目录的快照
+	rescan 		*rescanner
+	// WithIdleUnwatch 的设置
+	idle 		*idler
+	// WithRemount 的设置
+	remount 	*remounter
+	// WithMaxWatches 的设置
+	maxWatches 	int
+	evicted 	func(path string)
+	// 阻塞在 cond 上的 WaitEvent、WaitEventTimeout 数量
+	waiters 	int
+	// 等待超时使用的 timer，Stop 后重用
+	timers 		sync.Pool
+	// IN_Q_OVERFLOW 不属于任何监听，take 重用它返回
+	overflow 	WatchSingle
+	// 最后使用的序号，交给调用者与按 Backpressure 丢弃的事件都占用一个
+	seq 		uint64
+	// Stats 的计数，Watches 在 Stats 中计算
+	stats 		Stats
+	// Healthy 判断事件是否长时间没有被取走: 缓存由空变为非空的时间与最后一次交给调用者的时间
+	pendingAt 	time.Time
+	deliveredAt time.Time
+	// Events、Errors 第一次调用时启动 pump
+	pumpOnce 	sync.Once
+	events 		chan Event
+	errs 		chan error
+	// Subscribe 的订阅者
+	subs 		subscribers
 	closes 		bool
 }
 
@@ -63,199 +183,1133 @@ type WatchSingle struct {
 	flags 		uint32
 	watch 		*Watcher
 	remove 		bool
+	cookie 		uint32
+	group 		string
+	data 		any
+	count 		int
+	// 从 inotify fd 读到的时间，或 inject 加入的事件的 Time
+	time 		time.Time
+	// AddRecursiveWatch 添加，新建或移入的子目录自动监听
+	recursive 	bool
+	// 相对递归监听根目录的深度
+	depth 		int
+	// 在监听的目录之间 rename，已更新路径，等待自身的 MOVE_SELF
+	moved 		bool
+	// 为 WithPendingWatches、WatchFile 加入过内核 mask 的事件，调用者没有监听的事件不交给调用者
+	extra 		uint32
+	// WatchFile 监听的该目录下的文件
+	files 		map[string]*WatchedFile
+	// 添加监听或最近一次读到它的事件的时间，WithIdleUnwatch、WithMaxWatches 时使用
+	active 		time.Time
 
 	FileName 	string
 	Mask 		uint32
 }
 
-func (ws WatchSingle) GetEventName() string {
+// GetEventName 事件名称，不会修改监听的状态。目录的事件名称与文件相同，使用 IsDir 区分
+func (e Event) GetEventName() string {
 	switch {
-	case ws.Mask&IN_DELETE_SELF == IN_DELETE_SELF:
-		if ws.watch != nil {
-			ws.watch.watchMap[ws.watchId].remove = true
-		}
+	case e.Raw&IN_Q_OVERFLOW == IN_Q_OVERFLOW:
+		return "OVERFLOW"
+	case e.Raw&IN_UNMOUNT == IN_UNMOUNT:
+		return "UNMOUNT"
+	case e.Raw&IN_DELETE_SELF == IN_DELETE_SELF:
 		return "DELETE_SELF"
-	case ws.Mask&IN_MOVE_SELF == IN_MOVE_SELF:
-		if ws.watch != nil {
-			ws.watch.watchMap[ws.watchId].remove = true
-			if _, err := syscall.InotifyRmWatch(ws.watch.inotifyFD, ws.watchId); err != nil {
-				fmt.Println("Undeserved errors occur", err)
-			}
-		}
+	case e.Raw&IN_MOVE_SELF == IN_MOVE_SELF:
 		return "MOVE_SELF"
-	case ws.Mask&IN_CREATE == IN_CREATE:
+	case e.Raw&IN_CREATE == IN_CREATE:
 		return "CREATE"
-	case ws.Mask&IN_DELETE == IN_DELETE:
+	case e.Raw&IN_DELETE == IN_DELETE:
 		return "DELETE"
-	case ws.Mask&IN_OPEN == IN_OPEN:
+	case e.Raw&IN_OPEN == IN_OPEN:
 		return "OPEN"
-	case ws.Mask&IN_CLOSE == IN_CLOSE:
+	case e.Raw&IN_CLOSE == IN_CLOSE:
 		return "CLOSE"
-	case ws.Mask&IN_CLOSE_WRITE == IN_CLOSE_WRITE:
+	case e.Raw&IN_CLOSE_WRITE == IN_CLOSE_WRITE:
 		return "CLOSE_WRITE"
-	case ws.Mask&IN_CLOSE_NOWRITE == IN_CLOSE_NOWRITE:
+	case e.Raw&IN_CLOSE_NOWRITE == IN_CLOSE_NOWRITE:
 		return "CLOSE_NOWRITE"
-	case ws.Mask&IN_MOVE == IN_MOVE:
+	case e.Raw&IN_MOVE == IN_MOVE:
 		return "MOVE"
-	case ws.Mask&IN_MOVED_FROM == IN_MOVED_FROM:
+	case e.Raw&IN_MOVED_FROM == IN_MOVED_FROM:
 		return "MOVED_FROM"
-	case ws.Mask&IN_MOVED_TO == IN_MOVED_TO:
+	case e.Raw&IN_MOVED_TO == IN_MOVED_TO:
 		return "MOVED_TO"
-	case ws.Mask&IN_MODIFY == IN_MODIFY:
+	case e.Raw&IN_MODIFY == IN_MODIFY:
 		return "MODIFY"
-	case ws.Mask&IN_ATTRIB == IN_ATTRIB:
+	case e.Raw&IN_ATTRIB == IN_ATTRIB:
 		return "ATTRIB"
-	case ws.Mask&syscall.IN_IGNORED == syscall.IN_IGNORED:
-		if ws.watch != nil && ws.watch.watchMap[ws.watchId].remove {
-			delete(ws.watch.watchMap, ws.watchId)
-		}
+	case e.Raw&IN_ACCESS == IN_ACCESS:
+		return "ACCESS"
+	case e.Raw&IN_IGNORED == IN_IGNORED:
 		return "REMOVE"
 	}
 	return "ERROR"
 }
 
+// opOf 内核 mask 对应的 Op
+func opOf(mask uint32) Op {
+	var op Op
+	if mask&(unix.IN_CREATE|unix.IN_MOVED_TO) != 0 {
+		op |= Create
+	}
+	if mask&(unix.IN_MODIFY|unix.IN_CLOSE_WRITE) != 0 {
+		op |= Write
+	}
+	if mask&(unix.IN_DELETE|unix.IN_DELETE_SELF) != 0 {
+		op |= Remove
+	}
+	if mask&(unix.IN_MOVED_FROM|unix.IN_MOVE_SELF) != 0 {
+		op |= Rename
+	}
+	if mask&unix.IN_ATTRIB != 0 {
+		op |= Chmod
+	}
+	if mask&unix.IN_UNMOUNT != 0 {
+		op |= Unmount
+	}
+	return op
+}
+
+func (ws *WatchSingle) event() Event {
+	return Event{wd: ws.watchId, FileName: ws.FileName, Raw: ws.Mask, Op: opOf(ws.Mask), Cookie: ws.cookie, Group: ws.group, Data: ws.data, Count: ws.count, Time: ws.time}
+}
+
+// stamp 设置交给调用者的事件的 Seq 与 Time，inject 时已有 Time 的保留，调用者需持有 mutex
+func (w *Watcher) stamp(e Event) Event {
+	w.seq++
+	e.Seq = w.seq
+	w.stats.Delivered++
+	w.deliveredAt = time.Now()
+	if e.Time.IsZero() {
+		e.Time = w.deliveredAt
+	}
+	if w.deliveryTime {
+		e.Delivered = w.deliveredAt
+	}
+	if w.fileInfo {
+		describe(&e)
+	}
+	if w.attrs != nil {
+		w.attrib(&e)
+	}
+	return e
+}
+
+// changedAt 对象最后一次改变(内容、属性、rename)的时间，即 ctime
+func changedAt(info fs.FileInfo) time.Time {
+	if st, ok := info.Sys().(*syscall.Stat_t); ok {
+		return time.Unix(st.Ctim.Unix())
+	}
+	return info.ModTime()
+}
+
+// initialized 零值或 nil 的 Watcher 没有可用的 fd 与 cond
+func (w *Watcher) initialized() bool {
+	return w != nil && w.cond != nil
+}
+
+// AddWatch 添加 path 的监听。path 不存在且最后一个元素含有 *?[ 时为 filepath.Match 的通配符，如 /var/log/*.log:
+// 监听所有匹配的文件，之后所在目录中出现匹配的文件时自动添加(flags 包含 IN_CREATE 或 IN_MOVED_TO 时补发该事件)，
+// 匹配的文件被删除或移走时移除，RemoveWatch 同一个通配符时全部移除
 func (w *Watcher) AddWatch(path string, flags uint32) error {
+	return w.addWatch(path, flags, nil)
+}
+
+// AddWatchData 同 AddWatch，该监听的每个事件都带有 data，重复添加同一路径时替换原来的 data
+func (w *Watcher) AddWatchData(path string, flags uint32, data any) error {
+	return w.addWatch(path, flags, func(ws *WatchSingle) { ws.data = data })
+}
+
+// addWatch set 不为 nil 时在持有 mutex 的情况下修改新建或已有的监听，WithPendingWatches 时等待不存在的路径出现
+func (w *Watcher) addWatch(path string, flags uint32, set func(*WatchSingle)) error {
+	return w.watchPath(path, flags, set, true)
+}
+
+// watchPath await 为 true 且 WithPendingWatches 时路径不存在不返回错误，改为等待其出现
+func (w *Watcher) watchPath(path string, flags uint32, set func(*WatchSingle), await bool) error {
+	if !w.initialized() {
+		return ErrNotInitialized
+	}
 	var err error
     if path, err = filepath.Abs(path); err != nil {
     	return err
     }
-    info, _ := os.Stat(path)
-    if info == nil {
-    	return errors.New("File or Dir not")
-    }
-	wd, err := syscall.InotifyAddWatch(w.inotifyFD, path, flags|syscall.IN_DONT_FOLLOW|syscall.IN_MASK_ADD)
-	if err == nil {
-		ws, ok := w.watchMap[uint32(wd)]
-		if !ok {
-			ws = &WatchSingle{watch: w, path: path, isDir: info.IsDir(), watchId: uint32(wd), flags: flags}
-			if ws.isDir {
-				ws.path += string(os.PathSeparator)
-			}
-			w.watchMap[uint32(wd)] = ws
-		}
-		ws.flags |= flags
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	if w.closes {
+		return ErrClosed
 	}
-	return err
+	if isGlob(path) {
+		return w.glob(&globWatch{pattern: path, flags: flags, set: set})
+	}
+	if _, err = w.add(path, flags, set); err == nil {
+		return nil
+	}
+	if errors.Is(err, unix.ENOENT) && await && w.pending != nil {
+		return w.resolve(&pendingWatch{path: path, flags: flags, set: set})
+	}
+	return &WatchError{Path: path, Err: err}
+}
+
+// add 添加 path 的监听，调用者需持有 mutex
+func (w *Watcher) add(path string, flags uint32, set func(*WatchSingle)) (*WatchSingle, error) {
+	mask := flags|unix.IN_DONT_FOLLOW
+	if w.tree != nil && flags != 0 {
+		mask |= snapshotMask
+	}
+	// IN_MASK_CREATE 与 IN_MASK_ADD 不能同时使用
+	if flags&unix.IN_MASK_CREATE == 0 {
+		mask |= unix.IN_MASK_ADD
+	}
+	if w.maxWatches > 0 && flags != 0 {
+		w.evict(path)
+	}
+	wd, isDir, err := w.backend.Add(path, mask)
+	if err != nil {
+		return nil, err
+	}
+	if w.attrs != nil {
+		seedAttrs(w.attrs, path, isDir)
+	}
+	if w.tree != nil {
+		seedAttrs(w.tree, path, isDir)
+	}
+	if isDir {
+		path += string(os.PathSeparator)
+	}
+	ws := w.watchOf(uint32(wd), path, isDir)
+	// 同一个 inode 返回相同的 wd，目录被移动(如递归监听下的 rename)后以新路径重新添加时更新路径
+	ws.path = path
+	ws.flags, ws.remove = ws.flags|flags, false
+	if w.timed() {
+		ws.active = time.Now()
+	}
+	if w.tree != nil && flags != 0 {
+		ws.extra |= snapshotMask
+	}
+	if set != nil {
+		set(ws)
+	}
+	if w.previous != nil && flags != 0 {
+		w.replay(ws)
+	}
+	return ws, nil
 }
 
-func (w *Watcher) WaitEvent() (WatchSingle, error) {
+// watchOf 内核返回的 wd 的监听，没有时新建，调用者需持有 mutex
+func (w *Watcher) watchOf(wd uint32, path string, isDir bool) *WatchSingle {
+	ws, ok := w.watchMap[wd]
+	if ok && ws.remove {
+		// 旧的监听已移除，内核重用了它的 wd，IN_IGNORED 还未读到
+		w.retire(ws)
+		ok = false
+	}
+	if !ok {
+		ws = &WatchSingle{watch: w, path: path, isDir: isDir, watchId: wd}
+		w.watchMap[wd] = ws
+	}
+	return ws
+}
+
+// UpdateWatch 将已监听的 path 的 flags 替换为 flags，而不是像 AddWatch 一样与原来的合并，用于减少监听的事件。
+// 递归监听的目录仍包含 IN_CREATE 与 IN_MOVED_TO
+func (w *Watcher) UpdateWatch(path string, flags uint32) error {
+	if !w.initialized() {
+		return ErrNotInitialized
+	}
+	var err error
+	if path, err = filepath.Abs(path); err != nil {
+		return err
+	}
 	w.mutex.Lock()
 	defer w.mutex.Unlock()
-	if w.bufferItem == 0 {
+	if w.closes {
+		return ErrClosed
+	}
+	flags &^= unix.IN_MASK_ADD|unix.IN_MASK_CREATE
+	for _, ws := range w.watchMap {
+		if ws.remove || (ws.path != path && ws.path != path+string(os.PathSeparator)) {
+			continue
+		}
+		if ws.recursive {
+			flags |= unix.IN_CREATE|unix.IN_MOVED_TO
+		}
+		// 保留 WithPendingWatches、WatchFile、WithSnapshot 还需要的事件
+		wd, _, err := w.backend.Add(path, flags|w.extraMask(ws)|unix.IN_DONT_FOLLOW)
+		if err != nil {
+			return &WatchError{Path: path, Err: err}
+		}
+		if uint32(wd) != ws.watchId {
+			// path 已经是另一个文件，不替换它的监听
+			w.backend.Remove(wd)
+			return &WatchError{Path: path, Err: ErrNotWatched}
+		}
+		ws.flags = flags
+		return nil
+	}
+	return &WatchError{Path: path, Err: ErrNotWatched}
+}
+
+// wait 等待一个事件，d 小于 0 时一直等待，超时没有事件时 ok 为 false，调用者需持有 mutex
+func (w *Watcher) wait(d time.Duration) (WatchSingle, bool, error) {
+	if ok, err := w.await(d); !ok {
+		return WatchSingle{}, false, err
+	}
+	if len(w.injected) > 0 {
+		return w.popInjected(), true, nil
+	}
+
+	if uint32(unix.SizeofInotifyEvent) > w.buffered() {
+		return WatchSingle{}, false, ErrBufferCorrupt
+	}
+
+	if ws := w.forwardBuffer(); ws != nil {
+		return *ws, true, nil
+	}
+	return WatchSingle{}, false, ErrWatchGone
+}
+
+// await 等待缓存中有事件，d 小于 0 时一直等待，超时没有事件时 ok 为 false，fail 记录的错误返回一次，调用者需持有 mutex
+func (w *Watcher) await(d time.Duration) (bool, error) {
+	if w.dropped {
+		w.dropped = false
+		return false, ErrDropped
+	}
+	deadline := time.Now().Add(d)
+	var timer *time.Timer
+	for w.bufferItem == 0 && len(w.injected) == 0 && w.failed == nil {
 		if w.closes {
-			return WatchSingle{}, errors.New("The Watcher is closes")
+			return false, ErrClosed
 		}
-		w.wait = true
+		if d >= 0 {
+			wait := time.Until(deadline)
+			if wait <= 0 {
+				return false, nil
+			}
+			if timer == nil {
+				timer = w.timer(wait)
+				defer w.putTimer(timer)
+			}
+		}
+		w.waiters++
 		w.cond.Wait()
-		w.wait = false
+		w.waiters--
+	}
+	if err := w.failed; err != nil {
+		w.failed = nil
+		return false, err
+	}
+	return true, nil
+}
+
+// timer 取出一个 d 之后唤醒所有等待者的 timer，来自 timers 时不分配内存，用完后由 putTimer 放回
+func (w *Watcher) timer(d time.Duration) *time.Timer {
+	if t, ok := w.timers.Get().(*time.Timer); ok {
+		t.Reset(d)
+		return t
+	}
+	return time.AfterFunc(d, func() {
+		w.mutex.Lock()
+		w.cond.Broadcast()
+		w.mutex.Unlock()
+	})
+}
+
+// putTimer 停止 t 并放回 timers，Stop 之前已触发时等待者只会多醒一次
+func (w *Watcher) putTimer(t *time.Timer) {
+	t.Stop()
+	w.timers.Put(t)
+}
+
+// WaitEventTimeout 最多等待 d，超时没有事件时 ok 为 false。可在多个 goroutine 中同时调用，每个事件只会交给其中一个调用者
+func (w *Watcher) WaitEventTimeout(d time.Duration) (Event, bool, error) {
+	if !w.initialized() {
+		return Event{}, false, ErrNotInitialized
+	}
+	if d < 0 {
+		d = 0
+	}
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	ws, ok, err := w.wait(d)
+	if !ok {
+		return Event{}, false, err
+	}
+	return w.stamp(ws.event()), true, nil
+}
+
+// WaitEventInto 同 WaitEventTimeout，事件写入 e，d 小于 0 时一直等待。缓存中已有事件时不分配内存:
+// e.FileName 为空，路径由 e.Dir()、e.Name() 得到，Name 使用 e 自身的缓存，再次传入同一个 e 后原来的 Name 会改变，需要保留时先复制。
+// 每个消费者使用自己的 e，不能在多个 goroutine 中共用
+func (w *Watcher) WaitEventInto(e *Event, d time.Duration) (bool, error) {
+	if !w.initialized() {
+		return false, ErrNotInitialized
+	}
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	if ok, err := w.await(d); !ok {
+		return false, err
+	}
+	if len(w.injected) > 0 {
+		ws := w.popInjected()
+		*e = w.stamp(ws.event())
+		return true, nil
+	}
+	if uint32(unix.SizeofInotifyEvent) > w.buffered() {
+		return false, ErrBufferCorrupt
+	}
+	ws, name, f := w.take()
+	if ws == nil {
+		return false, ErrWatchGone
+	}
+	v := Event{wd: ws.watchId, Raw: ws.Mask, Op: opOf(ws.Mask), Cookie: ws.cookie, Group: ws.group, Data: ws.data, Count: ws.count, Time: ws.time}
+	if f != nil {
+		v.Group, v.Data = "", f
+	}
+	v.scratch = append(e.scratch[:0], name...)
+	if path := strings.TrimSuffix(ws.path, string(os.PathSeparator)); len(name) > 0 {
+		if v.dir = path; v.dir == "" {
+			v.dir = string(os.PathSeparator)
+		}
+		// 与 scratch 共用内存，不复制
+		v.name = *(*string)(unsafe.Pointer(&v.scratch))
+	} else if path != "" {
+		v.dir, v.name = filepath.Dir(path), filepath.Base(path)
+	}
+	*e = w.stamp(v)
+	return true, nil
+}
+
+// next 一直等待下一个事件
+func (w *Watcher) next() (Event, error) {
+	if !w.initialized() {
+		return Event{}, ErrNotInitialized
+	}
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	ws, ok, err := w.wait(-1)
+	if !ok {
+		return Event{}, err
 	}
+	return w.stamp(ws.event()), nil
+}
 
-	if uint32(syscall.SizeofInotifyEvent) > w.bufferItem {
-		return WatchSingle{}, errors.New("The event bufferItem Cross Lines")
+// nextContext 同 next，ctx 结束时返回 ctx.Err()，需由 wakeWaiters 唤醒
+func (w *Watcher) nextContext(ctx context.Context) (Event, error) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	for {
+		if err := ctx.Err(); err != nil {
+			return Event{}, err
+		}
+		ws, ok, err := w.wait(0)
+		if ok {
+			return w.stamp(ws.event()), nil
+		}
+		if err != nil {
+			return Event{}, err
+		}
+		w.waiters++
+		w.cond.Wait()
+		w.waiters--
 	}
+}
 
+// wakeWaiters 唤醒所有等待者重新检查条件
+func (w *Watcher) wakeWaiters() {
+	w.mutex.Lock()
+	w.cond.Broadcast()
+	w.mutex.Unlock()
+}
+
+// closed Close 之后所有 fd 关闭时关闭
+func (w *Watcher) closed() <-chan struct{} {
+	return w.done
+}
+
+// TryEvent 不阻塞，没有已缓存的事件时 ok 为 false
+func (w *Watcher) TryEvent() (Event, bool) {
+	if !w.initialized() {
+		return Event{}, false
+	}
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	if len(w.injected) > 0 {
+		ws := w.popInjected()
+		return w.stamp(ws.event()), true
+	}
+	if uint32(unix.SizeofInotifyEvent) > w.buffered() {
+		return Event{}, false
+	}
 	if ws := w.forwardBuffer(); ws != nil {
-		return *ws, nil
+		return w.stamp(ws.event()), true
 	}
-	return WatchSingle{}, errors.New("The monitored directory or file has been deleted or renamed") 
+	return Event{}, false
 }
 
 func (w *Watcher) epollWait() {
-	eventSlice := make([]syscall.EpollEvent, 5)
-	n, err := syscall.EpollWait(w.epollFD, eventSlice, -1)
-	// 不排除系统返回大于10的长度
-	if n == -1 || n > 5 {
-		w.mutex.Lock()
-		if err != syscall.EINTR {
+	eventSlice := make([]unix.EpollEvent, 5)
+	for {
+		n, err := unix.EpollWait(w.epollFD, eventSlice, -1)
+		// 不排除系统返回大于10的长度
+		if n == -1 || n > 5 {
+			if err == unix.EINTR {
+				continue
+			}
+			w.mutex.Lock()
 			w.closes = true
-			syscall.Close(w.inotifyFD)
+			w.cond.Broadcast()
+			w.mutex.Unlock()
+			w.release()
+			return
 		}
-		if w.wait {
-			w.cond.Signal()
+
+		for _, e := range eventSlice[:n] {
+			switch {
+			case e.Fd == int32(w.wakeFD):
+				// Close 已设置 closes，由本 goroutine 负责关闭 fd 并退出
+				w.release()
+				return
+			case e.Fd != int32(w.backend.Fd()):
+				// RegisterFd 的 fd，已移除的忽略
+				if h := w.fdHandler(e.Fd); h != nil {
+					h(int(e.Fd), e.Events)
+				}
+			case e.Events&unix.EPOLLHUP != 0:
+				fallthrough
+			case e.Events&unix.EPOLLERR != 0:
+				fallthrough
+			case e.Events&unix.EPOLLIN != 0:
+				if !w.drain() {
+					w.release()
+					return
+				}
+			default:
+				// 没有需要处理的事件
+			}
 		}
-		if !w.closes {
-			go w.epollWait()
+	}
+}
+
+// drain 边缘触发，一直读取到 EAGAIN，否则之后不会再收到通知。Watcher 已关闭时返回 false
+func (w *Watcher) drain() bool {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	// 剩余空间放不下下一个事件时 Read 返回 EINVAL
+	full := false
+	for !w.closes {
+		if (full || w.bufferItem > w.highWater()) && w.bufferHead > 0 {
+			full = false
+			w.compact()
+			continue
+		}
+		if full || w.bufferItem > w.highWater() {
+			full = false
+			switch w.backpressure {
+			case Block:
+				for used := w.buffered(); w.buffered() >= used && !w.closes; {
+					w.space.Wait()
+				}
+				continue
+			case DropNewest:
+				discard := make([]byte, len(w.eventBuffer))
+				n, err := w.backend.ReadEvents(discard)
+				if err == unix.EAGAIN {
+					return true
+				}
+				if err == nil {
+					w.dropped = true
+					w.discarded(discard[:n])
+					read, overflows := records(discard[:n])
+					w.stats.Read, w.stats.Dropped, w.stats.Overflows = w.stats.Read+read, w.stats.Dropped+read, w.stats.Overflows+overflows
+					// 丢弃的事件占用序号，调用者由 Seq 不连续发现丢弃
+					w.gap += read
+				}
+				continue
+			default:
+				w.forwardBuffer()
+				w.stats.Dropped++
+				w.seq++
+			}
 		}
+		n, err := w.backend.ReadEvents(w.eventBuffer[w.bufferItem:])
+		switch err {
+		case nil:
+		case unix.EAGAIN:
+			return true
+		case unix.EINTR:
+			continue
+		case unix.EINVAL:
+			full = true
+			continue
+		default:
+			w.fail(os.NewSyscallError("read", err))
+			return true
+		}
+		start := w.bufferItem
+		w.bufferItem += uint32(n)
+		dirs := w.received(start)
+		w.signal(w.eventBuffer[start:w.bufferItem])
+		// 让等待的读取者有机会取走事件
 		w.mutex.Unlock()
-		return
+		w.watchDirs(dirs)
+		w.mutex.Lock()
 	}
+	return false
+}
+
+// fail 记录 err 并唤醒一个读取者，还未返回的错误只保留最新的，调用者需持有 mutex
+func (w *Watcher) fail(err error) {
+	w.failed = err
+	w.cond.Signal()
+}
+
+// buffered 缓存中的字节数，调用者需持有 mutex
+func (w *Watcher) buffered() uint32 {
+	return w.bufferItem - w.bufferHead
+}
+
+// compact 把缓存中的事件移到 eventBuffer 的开头，只在尾部放不下新事件时调用，调用者需持有 mutex
+func (w *Watcher) compact() {
+	if w.bufferHead > 0 {
+		copy(w.eventBuffer[0:], w.eventBuffer[w.bufferHead:w.bufferItem])
+		w.bufferItem, w.bufferHead = w.bufferItem-w.bufferHead, 0
+	}
+}
+
+// inject 加入一个不来自 inotify fd 的事件
+func (w *Watcher) inject(e Event) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	w.pushInjected(e)
+}
+
+// pushInjected 同 inject，调用者需持有 mutex
+func (w *Watcher) pushInjected(e Event) {
+	if !w.closes {
+		if len(w.injected) == 0 && w.bufferItem == 0 {
+			w.pendingAt = time.Now()
+		}
+		w.injected = append(w.injected, e)
+		w.cond.Signal()
+	}
+}
+
+// popInjected 取出第一个 inject 加入的事件，调用者需持有 mutex
+func (w *Watcher) popInjected() WatchSingle {
+	e := w.injected[0]
+	w.injected = w.injected[1:]
+	ws := WatchSingle{watchId: e.wd, FileName: e.FileName, Mask: e.Raw, cookie: e.Cookie, group: e.Group, data: e.Data, count: e.Count, time: e.Time}
+	if v, ok := w.watchMap[e.wd]; ok {
+		ws.path, ws.isDir, ws.flags = v.path, v.isDir, v.flags
+	}
+	return ws
+}
+
+// signal 每读到一个新事件唤醒一个等待者，调用者需持有 mutex
+func (w *Watcher) signal(buf []byte) {
+	for offset, n := 0, 0; n < w.waiters && offset+unix.SizeofInotifyEvent <= len(buf); n++ {
+		event := (*unix.InotifyEvent)(unsafe.Pointer(&buf[offset]))
+		offset += unix.SizeofInotifyEvent + int(event.Len)
+		w.cond.Signal()
+	}
+}
+
+// observe 读取者每保留一个事件调用一次 f
+func (w *Watcher) observe(f func(Event)) {
+	w.mutex.Lock()
+	w.observers = append(w.observers, f)
+	w.mutex.Unlock()
+}
+
+// received 处理从 start 开始新读到的事件，返回需要自动监听的新目录，调用者需持有 mutex
+func (w *Watcher) received(start uint32) []newDir {
+	// 内核总是返回完整的事件，WithBackend 的实现返回了不完整的事件时丢弃不完整的部分
+	if n := uint32(whole(w.eventBuffer[start:w.bufferItem])); start+n < w.bufferItem {
+		w.bufferItem = start+n
+		w.stats.Dropped++
+	}
+	if start == 0 && len(w.injected) == 0 {
+		w.pendingAt = time.Now()
+	}
+	read, overflows := records(w.eventBuffer[start:w.bufferItem])
+	w.stats.Read, w.stats.Overflows = w.stats.Read+read, w.stats.Overflows+overflows
+	if int(w.buffered()) > w.stats.BufferHighWater {
+		w.stats.BufferHighWater = int(w.buffered())
+	}
+	w.ignored(start)
+	if w.remount != nil {
+		w.unmounts(start)
+	}
+	if w.pending != nil {
+		w.arrived(start)
+	}
+	if w.tree != nil {
+		w.track(start)
+	}
+	if w.timed() {
+		w.active(start)
+	}
+	if w.globs != nil {
+		w.globbed(start)
+	}
+	w.unwanted(start)
+	w.renames(start)
+	dirs := w.newDirs(start)
+	if w.filter != nil || len(w.observers) > 0 || len(w.paused) > 0 {
+		w.filterBuffer(start)
+	}
+	// 同一次读取的事件使用相同的时间
+	n, _ := records(w.eventBuffer[start:w.bufferItem])
+	for now := time.Now(); n > 0; n-- {
+		if w.arrivalHead > 0 && len(w.arrivals) == cap(w.arrivals) {
+			m := copy(w.arrivals, w.arrivals[w.arrivalHead:])
+			w.arrivals, w.arrivalHead = w.arrivals[:m], 0
+		}
+		w.arrivals = append(w.arrivals, arrival{at: now, gap: w.gap})
+		w.gap = 0
+	}
+	return dirs
+}
+
+// arrival 缓存中一个事件读到的时间，gap 为在它之前丢弃的事件数量
+type arrival struct {
+	at 		time.Time
+	gap 	uint64
+}
 
-	for _, e := range eventSlice[:n] {
-		switch {
-		case e.Events&syscall.EPOLLHUP != 0:
-			fallthrough
-		case e.Events&syscall.EPOLLERR != 0:
-			fallthrough
-		case e.Events&syscall.EPOLLIN != 0:
-			if e.Fd != int32(w.inotifyFD) {
-				fmt.Println("The inotify fd not event fd")
-				break
+// popArrival 取出缓存中第一个事件读到的时间，并跳过之前丢弃的事件占用的序号，调用者需持有 mutex
+func (w *Watcher) popArrival() time.Time {
+	if w.arrivalHead >= len(w.arrivals) {
+		return time.Time{}
+	}
+	a := w.arrivals[w.arrivalHead]
+	// 取完时从头重用，不重新分配
+	if w.arrivalHead++; w.arrivalHead == len(w.arrivals) {
+		w.arrivals, w.arrivalHead = w.arrivals[:0], 0
+	}
+	w.seq += a.gap
+	return a.at
+}
+
+// filterBuffer 过滤从 start 开始新读到的事件，丢弃的事件直接移出缓存，保留的事件交给 observers，调用者需持有 mutex
+func (w *Watcher) filterBuffer(start uint32) {
+	for offset := start; offset+unix.SizeofInotifyEvent <= w.bufferItem; {
+		event := (*unix.InotifyEvent)(unsafe.Pointer(&w.eventBuffer[offset]))
+		size := uint32(unix.SizeofInotifyEvent) + event.Len
+		if ws, ok := w.owner(event.Wd, offset); ok {
+			if w.paused[ws.group] && event.Mask&unix.IN_IGNORED == 0 {
+				copy(w.eventBuffer[offset:], w.eventBuffer[offset+size:w.bufferItem])
+				w.bufferItem -= size
+				w.stats.Filtered++
+				continue
 			}
-			w.mutex.Lock()
-			if w.wait {
-				w.cond.Signal()
+			name := ws.path
+			if 0 < event.Len {
+				name += string(nameOf(w.eventBuffer[offset+unix.SizeofInotifyEvent:offset+size]))
 			}
-			if w.bufferItem > uint32(MAX_ITEM) {
-				w.forwardBuffer()
+			e := Event{wd: ws.watchId, FileName: name, Raw: event.Mask, Op: opOf(event.Mask), Cookie: event.Cookie, Group: ws.group, Data: ws.data}
+			if f := ws.fileOf(w.eventBuffer[offset+unix.SizeofInotifyEvent:offset+size], event.Mask); f != nil {
+				e.Group, e.Data = "", f
 			}
-			if n, err := syscall.Read(w.inotifyFD, w.eventBuffer[w.bufferItem:]); err == nil {
-				w.bufferItem += uint32(n)
+			if w.filter != nil && !w.filter(e) {
+				copy(w.eventBuffer[offset:], w.eventBuffer[offset+size:w.bufferItem])
+				w.bufferItem -= size
+				w.stats.Filtered++
+				if event.Mask&unix.IN_IGNORED != 0 {
+					w.forget(ws)
+				}
+				continue
+			}
+			for _, f := range w.observers {
+				f(e)
 			}
-			w.mutex.Unlock()
-		default:
-			fmt.Println("Events Unknown")
 		}
+		offset += size
 	}
-	go w.epollWait()
 }
 
+// forwardBuffer 取出缓存中的第一个事件，调用者需持有 mutex
 func (w *Watcher) forwardBuffer() *WatchSingle {
-	offset, event := uint32(syscall.SizeofInotifyEvent), (*syscall.InotifyEvent)(unsafe.Pointer(&w.eventBuffer[0]))
+	ws, name, f := w.take()
+	if ws == nil {
+		return nil
+	}
+	if 0 < len(name) {
+		// 一次拼接，+= 会多分配一次 string(name)
+		ws.FileName = ws.path + string(name)
+	}
+	// WatchFile 的文件的事件，Data 为该文件
+	if f != nil {
+		c := *ws
+		c.group, c.data = "", f
+		return &c
+	}
+	return ws
+}
+
+// nameOf 内核返回的名字在第一个 NUL 之前，之后为对齐填充
+func nameOf(b []byte) []byte {
+	if i := bytes.IndexByte(b, 0); i >= 0 {
+		return b[:i]
+	}
+	return b
+}
+
+// take 同 forwardBuffer，不拼接 FileName: name 为内核返回的名字(已去掉填充的 NUL)，指向 eventBuffer，在释放 mutex 之前有效，
+// f 为 WatchFile 的文件，调用者需持有 mutex
+func (w *Watcher) take() (*WatchSingle, []byte, *WatchedFile) {
+	head := w.bufferHead
+	offset, event := head+unix.SizeofInotifyEvent, (*unix.InotifyEvent)(unsafe.Pointer(&w.eventBuffer[head]))
 	
-	if ws, ok := w.watchMap[uint32(event.Wd)]; ok {
-		ws.Mask = event.Mask
+	if ws, ok := w.owner(event.Wd, head); ok {
+		ws.Mask, ws.cookie, ws.count, ws.time = event.Mask, event.Cookie, 0, w.popArrival()
 		ws.FileName = ws.path
-		if 0 < event.Len {
-			ws.FileName += string(w.eventBuffer[offset:offset+event.Len])
-			offset += event.Len
+		name := nameOf(w.eventBuffer[offset:offset+event.Len])
+		f := ws.fileOf(name, event.Mask)
+		w.advance(offset+event.Len)
+		w.lifecycle(ws)
+		if f != nil {
+			w.saved(f, ws)
 		}
-		copy(w.eventBuffer[0:], w.eventBuffer[offset:])
-		w.bufferItem -= offset
-		return ws
+		return ws, name, f
+	}
+	if event.Mask&unix.IN_Q_OVERFLOW != 0 {
+		// 内核队列溢出，wd 为 -1，不属于任何监听
+		ws := &w.overflow
+		*ws = WatchSingle{watch: w, watchId: uint32(event.Wd), Mask: event.Mask, time: w.popArrival()}
+		w.advance(offset+event.Len)
+		return ws, nil, nil
 	}
 	// TODO 如果监视者已经移除仍有事件产生，这是不应该出现的情况，暂时清空事件BUFFER
-	copy(w.eventBuffer[0:], w.eventBuffer[w.bufferItem:])
-	w.bufferItem = 0
-	fmt.Println("Error Watcher EventBuffer")
-	return nil
+	for _, r := range w.retired {
+		for _, ws := range r {
+			w.forget(ws)
+		}
+	}
+	w.bufferHead, w.bufferItem, w.arrivals, w.arrivalHead = 0, 0, w.arrivals[:0], 0
+	w.space.Signal()
+	return nil, nil, nil
 }
 
-func (w *Watcher) Close() {
-	if w.inotifyFD != -1 {
-		syscall.Close(w.inotifyFD)
+// advance 取出 offset 之前的事件，缓存取完时归零，调用者需持有 mutex
+func (w *Watcher) advance(offset uint32) {
+	if w.bufferHead = offset; w.bufferHead >= w.bufferItem {
+		w.bufferHead, w.bufferItem = 0, 0
 	}
-	if w.epollFD != -1 {
-		syscall.Close(w.epollFD)
+	w.space.Signal()
+}
+
+// lifecycle 根据取出的事件更新监听的状态，调用者需持有 mutex
+func (w *Watcher) lifecycle(ws *WatchSingle) {
+	switch {
+	case ws.Mask&(unix.IN_DELETE_SELF|unix.IN_UNMOUNT) != 0:
+		// 之后总有 IN_IGNORED
+		ws.remove = true
+	case ws.Mask&unix.IN_MOVE_SELF != 0 && ws.moved:
+		// 已由 MOVED_FROM 与 MOVED_TO 得到新的路径，继续监听
+		ws.moved = false
+	case ws.Mask&unix.IN_MOVE_SELF != 0:
+		// 移动后原路径已失效，不再继续监听
+		ws.remove = true
+		if !w.closes {
+			if err := w.backend.Remove(int(ws.watchId)); err != nil && !errors.Is(err, unix.EINVAL) {
+				w.fail(&WatchError{Path: ws.path, Err: err})
+			}
+		}
+	case ws.Mask&unix.IN_IGNORED != 0:
+		w.forget(ws)
 	}
 }
 
-func NewWatcher() (*Watcher, error) {
-	w := &Watcher{inotifyFD: -1, epollFD: -1, watchMap: make(map[uint32]*WatchSingle)}
-	w.inotifyFD, _ = syscall.InotifyInit1(syscall.IN_CLOEXEC)
-	if w.inotifyFD == -1 {
-		return nil, errors.New("The inotify cannot create")
+// extraMask WithPendingWatches、通配符、WatchFile、WithSnapshot 当前需要 ws 的内核 mask 额外包含的事件，调用者需持有 mutex
+func (w *Watcher) extraMask(ws *WatchSingle) uint32 {
+	var mask uint32
+	for _, p := range w.pending {
+		if p.parent == ws {
+			mask |= pendingMask
+			break
+		}
 	}
-	w.epollFD, _ = syscall.EpollCreate1(syscall.EPOLL_CLOEXEC)
-	if w.epollFD == -1 {
-		syscall.Close(w.inotifyFD)
-		return nil, errors.New("The epoll cannot create")
+	for _, g := range w.globs {
+		if g.parent == ws {
+			mask |= globMask
+			break
+		}
 	}
-	if err := syscall.EpollCtl(w.epollFD, syscall.EPOLL_CTL_ADD, w.inotifyFD, &syscall.EpollEvent{Fd: int32(w.inotifyFD), Events: syscall.EPOLLIN}); err != nil {
-		syscall.Close(w.inotifyFD)
-		syscall.Close(w.epollFD)
-		return nil, err
+	for _, f := range ws.files {
+		mask |= f.dirMask()
+	}
+	if w.tree != nil && ws.flags != 0 {
+		mask |= snapshotMask
+	}
+	return mask
+}
+
+// restore 按 flags 与 extraMask 重新设置 ws 的内核 mask，都不需要时移除只为它们添加的监听，调用者需持有 mutex
+func (w *Watcher) restore(ws *WatchSingle) {
+	if ws.remove || w.watchMap[ws.watchId] != ws {
+		return
+	}
+	extra := w.extraMask(ws)
+	if ws.flags == 0 && extra == 0 {
+		w.rmWatch(ws)
+		return
+	}
+	w.backend.Add(ws.path, ws.flags|extra|unix.IN_DONT_FOLLOW)
+}
+
+// unwanted 移出只为 WithPendingWatches、WatchFile、WithSnapshot 而读到的事件，调用者需持有 mutex
+func (w *Watcher) unwanted(start uint32) {
+	for offset := start; offset+unix.SizeofInotifyEvent <= w.bufferItem; {
+		event := (*unix.InotifyEvent)(unsafe.Pointer(&w.eventBuffer[offset]))
+		size := uint32(unix.SizeofInotifyEvent) + event.Len
+		ws, ok := w.owner(event.Wd, offset)
+		if !ok || ws.extra == 0 || event.Mask&ws.flags&unix.IN_ALL_EVENTS != 0 || (ws.flags != 0 && event.Mask&unix.IN_ALL_EVENTS == 0) {
+			offset += size
+			continue
+		}
+		if ws.fileOf(w.eventBuffer[offset+unix.SizeofInotifyEvent:offset+size], event.Mask) == nil {
+			copy(w.eventBuffer[offset:], w.eventBuffer[offset+size:w.bufferItem])
+			w.bufferItem -= size
+			w.stats.Filtered++
+			if event.Mask&unix.IN_IGNORED != 0 {
+				w.forget(ws)
+			}
+			continue
+		}
+		offset += size
+	}
+}
+
+// owner 缓存中 offset 处 wd 的事件所属的监听，之前每有一个该 wd 的 IN_IGNORED 跳过一个旧监听，调用者需持有 mutex
+func (w *Watcher) owner(wd int32, offset uint32) (*WatchSingle, bool) {
+	return w.ownerAfter(wd, ignoredIn(w.eventBuffer[w.bufferHead:offset], wd))
+}
+
+// ownerAfter 跳过 k 个旧监听后 wd 的监听，调用者需持有 mutex
+func (w *Watcher) ownerAfter(wd int32, k int) (*WatchSingle, bool) {
+	if r := w.retired[uint32(wd)]; k < len(r) {
+		return r[k], true
+	}
+	ws, ok := w.watchMap[uint32(wd)]
+	return ws, ok
+}
+
+// ignoredIn buf 中 wd 的 IN_IGNORED 数量
+func ignoredIn(buf []byte, wd int32) int {
+	n := 0
+	for offset := 0; offset+unix.SizeofInotifyEvent <= len(buf); {
+		event := (*unix.InotifyEvent)(unsafe.Pointer(&buf[offset]))
+		if event.Wd == wd && event.Mask&unix.IN_IGNORED != 0 {
+			n++
+		}
+		offset += unix.SizeofInotifyEvent + int(event.Len)
+	}
+	return n
+}
+
+// retire 将 ws 移出 watchMap，之后添加的监听即使得到相同的 wd 也是新的记录，调用者需持有 mutex
+func (w *Watcher) retire(ws *WatchSingle) {
+	delete(w.watchMap, ws.watchId)
+	w.retired[ws.watchId] = append(w.retired[ws.watchId], ws)
+}
+
+// ignored 读到 IN_IGNORED 时立即移出其监听，不等到事件被取出，调用者需持有 mutex
+func (w *Watcher) ignored(start uint32) {
+	for offset := start; offset+unix.SizeofInotifyEvent <= w.bufferItem; {
+		event := (*unix.InotifyEvent)(unsafe.Pointer(&w.eventBuffer[offset]))
+		if event.Mask&unix.IN_IGNORED != 0 {
+			if ws, ok := w.owner(event.Wd, offset); ok && w.watchMap[ws.watchId] == ws {
+				w.retire(ws)
+			}
+		}
+		offset += uint32(unix.SizeofInotifyEvent) + event.Len
+	}
+}
+
+// discarded 按 DropNewest 丢弃的 IN_IGNORED 同样释放其监听，调用者需持有 mutex
+func (w *Watcher) discarded(buf []byte) {
+	for offset := 0; offset+unix.SizeofInotifyEvent <= len(buf); {
+		event := (*unix.InotifyEvent)(unsafe.Pointer(&buf[offset]))
+		if event.Mask&unix.IN_IGNORED != 0 {
+			// 缓存中的 IN_IGNORED 都在它之前
+			k := ignoredIn(w.eventBuffer[w.bufferHead:w.bufferItem], event.Wd) + ignoredIn(buf[:offset], event.Wd)
+			if ws, ok := w.ownerAfter(event.Wd, k); ok {
+				w.forget(ws)
+			}
+		}
+		offset += unix.SizeofInotifyEvent + int(event.Len)
+	}
+}
+
+// forget 取出或丢弃 IN_IGNORED 时释放 ws，内核已移除该监听(RemoveWatch、IN_ONESHOT、文件删除或卸载)，之后不会再有事件，调用者需持有 mutex
+func (w *Watcher) forget(ws *WatchSingle) {
+	r := w.retired[ws.watchId]
+	for i := range r {
+		if r[i] == ws {
+			r = append(r[:i:i], r[i+1:]...)
+			break
+		}
+	}
+	if len(r) == 0 {
+		delete(w.retired, ws.watchId)
+	} else {
+		w.retired[ws.watchId] = r
+	}
+	if w.watchMap[ws.watchId] == ws {
+		delete(w.watchMap, ws.watchId)
+	}
+	if !ws.remove && ws.flags&unix.IN_ONESHOT == 0 && w.rescan != nil {
+		// 不是调用者要求移除的(如卸载)，之后由 rescan 继续检查
+		w.rescan.lose(ws)
+	}
+	ws.remove = true
+	if ws.recursive {
+		w.recursiveDirs--
+	}
+}
+
+// Close 可重复调用，唤醒所有阻塞的 WaitEvent 并等待 epoll goroutine 退出，之后的操作返回 ErrClosed。WithSnapshotFile 时第一次调用返回保存快照的错误
+func (w *Watcher) Close() error {
+	if !w.initialized() {
+		return ErrNotInitialized
+	}
+	var err error
+	w.mutex.Lock()
+	if !w.closes {
+		// 先唤醒 epoll goroutine，失败时 Watcher 保持打开，可以再次 Close。
+		// 成功后 goroutine 需要 mutex 才能释放 fd，此时 closes 已设置
+		if !w.external {
+			if err := w.wake(); err != nil {
+				w.mutex.Unlock()
+				return err
+			}
+		}
+		w.closes = true
+		if w.snapshotFile != "" {
+			// 之后读到的事件不再更新快照
+			err = w.saveSnapshot()
+		}
+		w.cond.Broadcast()
+		w.space.Broadcast()
+		if w.external {
+			if w.reading {
+				w.interrupt()
+			}
+			w.mutex.Unlock()
+			// 等待阻塞的 ReadEvents 返回后再关闭 fd
+			w.reader.Lock()
+			w.release()
+			w.reader.Unlock()
+			return err
+		}
+	}
+	w.mutex.Unlock()
+	<-w.done
+	return err
+}
+
+// wake 使阻塞在 EpollWait 的 goroutine 立即返回，eventfd 的计数不清零，之后的 EpollWait 同样立即返回
+func (w *Watcher) wake() error {
+	var one [8]byte
+	*(*uint64)(unsafe.Pointer(&one[0])) = 1
+	if _, err := unix.Write(w.wakeFD, one[:]); err != nil && err != unix.EAGAIN {
+		return os.NewSyscallError("write", err)
+	}
+	return nil
+}
+
+// release epoll goroutine 退出时关闭所有 fd，其他地方在 closes 之后不再使用这些 fd
+func (w *Watcher) release() {
+	w.mutex.Lock()
+	w.backend.Close()
+	unix.Close(w.epollFD)
+	unix.Close(w.wakeFD)
+	w.mutex.Unlock()
+	close(w.done)
+}
+
+func NewWatcher(opts ...Option) (*Watcher, error) {
+	w := &Watcher{epollFD: -1, wakeFD: -1, initFlags: unix.IN_CLOEXEC|unix.IN_NONBLOCK, epollFlags: unix.EPOLL_CLOEXEC, bufferSize: defaultBufferSize, watchMap: make(map[uint32]*WatchSingle), retired: make(map[uint32][]*WatchSingle), paused: make(map[string]bool), done: make(chan struct{})}
+	for _, opt := range opts {
+		opt(w)
+	}
+	if w.snapshotFile != "" {
+		v, err := loadSnapshot(w.snapshotFile)
+		if err != nil {
+			return nil, err
+		}
+		if v != nil {
+			w.restored(v)
+		}
+	}
+	if w.blocking {
+		w.initFlags &^= unix.IN_NONBLOCK
+	}
+	if !w.external {
+		// 边缘触发
+		w.initFlags |= unix.IN_NONBLOCK
+	}
+	w.blocking = w.external && w.initFlags&unix.IN_NONBLOCK == 0
+	w.eventBuffer = make([]byte, w.bufferSize)
+	if w.blocking {
+		w.readBuf = make([]byte, w.bufferSize)
+	}
+	if w.backend == nil {
+		b, err := newBackend(w.backendType, w.pollInterval, w.poolWatches, w.initFlags)
+		if err != nil {
+			return nil, err
+		}
+		w.backend = b
 	}
 	w.cond = sync.NewCond(&w.mutex)
+	w.space = sync.NewCond(&w.mutex)
+	if w.external {
+		if w.rescan != nil {
+			go w.rescanLoop()
+		}
+		if w.idle != nil {
+			go w.idleLoop()
+		}
+		if w.remount != nil {
+			go w.remountLoop()
+		}
+		return w, nil
+	}
+	var err error
+	if w.epollFD, err = unix.EpollCreate1(w.epollFlags); err != nil {
+		w.backend.Close()
+		return nil, os.NewSyscallError("epoll_create1", err)
+	}
+	wakeFlags := unix.EFD_NONBLOCK
+	if w.epollFlags&unix.EPOLL_CLOEXEC != 0 {
+		wakeFlags |= unix.EFD_CLOEXEC
+	}
+	if w.wakeFD, err = unix.Eventfd(0, wakeFlags); err != nil {
+		w.backend.Close()
+		unix.Close(w.epollFD)
+		return nil, os.NewSyscallError("eventfd", err)
+	}
+	// inotify fd 边缘触发
+	for fd, events := range map[int]uint32{w.backend.Fd(): unix.EPOLLIN|unix.EPOLLET, w.wakeFD: unix.EPOLLIN} {
+		if err := unix.EpollCtl(w.epollFD, unix.EPOLL_CTL_ADD, fd, &unix.EpollEvent{Fd: int32(fd), Events: events}); err != nil {
+			w.backend.Close()
+			unix.Close(w.epollFD)
+			unix.Close(w.wakeFD)
+			return nil, os.NewSyscallError("epoll_ctl", err)
+		}
+	}
 	go w.epollWait()
+	if w.rescan != nil {
+		go w.rescanLoop()
+	}
+	if w.idle != nil {
+		go w.idleLoop()
+	}
+	if w.remount != nil {
+		go w.remountLoop()
+	}
 	return w, nil
 }
@@ -1,9 +1,10 @@
 //go:build windows
 // +build windows
+
 // @@
 // @ Author       : Eacher
 // @ Date         : 2023-02-21 09:46:27
-// @ LastEditTime : 2023-02-28 10:16:06
+// @ LastEditTime : 2026-10-22 14:17:17
 // @ LastEditors  : Eacher
 // @ --------------------------------------------------------------------------------<
 // @ Description  : windows 文件通知项目
@@ -14,14 +15,35 @@ package inotify
 
 import (
 	"os"
+	"io/fs"
 	"unsafe"
+	"sync"
+	"time"
+	"context"
 	"syscall"
+	"sync/atomic"
 	"fmt"
 	"path/filepath"
 )
 
 const bufferSize = 100
 
+// eventPool 通过 w.e 传递的 *Event，stamp 复制后放回
+var eventPool = sync.Pool{New: func() any { return new(Event) }}
+
+// newEvent 从 eventPool 取出一个值为 e 的 *Event
+func newEvent(e Event) *Event {
+	v := eventPool.Get().(*Event)
+	*v = e
+	return v
+}
+
+// freeEvent 清空 e 后放回 eventPool，不再持有 Data
+func freeEvent(e *Event) {
+	*e = Event{}
+	eventPool.Put(e)
+}
+
 const (
 	in_CLOSE 				= 0x00000000
 	in_CLOSE_NOWRITE		= 0x00000000
@@ -32,6 +54,8 @@ const (
 	in_MOVE_SELF 			= 0x00000000
 	in_OPEN					= 0x00000000
 	in_CREATE				= 0x00000000
+	in_ACCESS 				= 0x00000000
+	in_UNMOUNT 				= 0x00000000
 	// in_OPEN					= syscall.FILE_NOTIFY_CHANGE_LAST_ACCESS
 	// in_CREATE				= syscall.FILE_NOTIFY_CHANGE_CREATION
 	// in_MOVE					= syscall.FILE_NOTIFY_CHANGE_SECURITY
@@ -40,6 +64,17 @@ const (
 	in_ATTRIB				= syscall.FILE_NOTIFY_CHANGE_ATTRIBUTES
 	in_MODIFY				= syscall.FILE_NOTIFY_CHANGE_SIZE
 	in_CLOSE_WRITE			= syscall.FILE_NOTIFY_CHANGE_LAST_WRITE
+
+	in_ALL_EVENTS 			= in_DELETE|in_ATTRIB|in_MODIFY|in_CLOSE_WRITE
+	in_DONT_FOLLOW 			= 0x00000000
+	in_EXCL_UNLINK 			= 0x00000000
+	in_MASK_ADD 			= 0x00000000
+	in_MASK_CREATE 			= 0x00000000
+	in_ONESHOT 				= 0x00000000
+	in_ONLYDIR 				= 0x00000000
+	in_IGNORED 				= 0x00000000
+	in_ISDIR 				= 0x00000000
+	in_Q_OVERFLOW 			= 0x00000000
 )
 
 type WatchSingle struct {
@@ -50,25 +85,22 @@ type WatchSingle struct {
 	watch 		*Watcher
 	// remove 		bool
 	buf 		[]byte
+	group 		string
+	data 		any
 }
 
-type EventBody struct {
-	wd 			uint32
-	FileName 	string
-	Mask 		uint32
-}
-
-func (eb EventBody) GetEventName() string {
+// GetEventName 事件名称
+func (e Event) GetEventName() string {
 	switch {
-	case eb.Mask == syscall.FILE_ACTION_MODIFIED:
+	case e.Raw == syscall.FILE_ACTION_MODIFIED:
 		return "MODIFIED"
-	case eb.Mask == syscall.FILE_ACTION_ADDED:
+	case e.Raw == syscall.FILE_ACTION_ADDED:
 		return "ADDED"
-	case eb.Mask == syscall.FILE_ACTION_REMOVED:
+	case e.Raw == syscall.FILE_ACTION_REMOVED:
 		return "REMOVED"
-	case eb.Mask == syscall.FILE_ACTION_RENAMED_NEW_NAME:
+	case e.Raw == syscall.FILE_ACTION_RENAMED_NEW_NAME:
 		return "RENAMED_NEW"
-	case eb.Mask == syscall.FILE_ACTION_RENAMED_OLD_NAME:
+	case e.Raw == syscall.FILE_ACTION_RENAMED_OLD_NAME:
 		return "RENAMED_OLD"
 	}
 	return "ERROR"
@@ -77,72 +109,315 @@ func (eb EventBody) GetEventName() string {
 type Watcher struct {
 	cphandle 	syscall.Handle
 	watchMap 	map[uint32]*WatchSingle
-	e 			chan *EventBody
+	e 			chan *Event
+	backpressure Backpressure
+	dropped 	int32
+	filter 		func(Event) bool
+	mutex 		sync.Mutex
+	observers 	[]func(Event)
+	paused 		map[string]bool
+	done 		chan struct{}
+	pumpOnce 	sync.Once
+	events 		chan Event
+	errs 		chan error
+	// Subscribe 的订阅者
+	subs 		subscribers
+	// 最后使用的序号，交给调用者与按 Backpressure 丢弃的事件都占用一个
+	seq 		uint64
+	// 交给调用者的事件数量
+	delivered 	uint64
+	// WithDeliveryTime 时设置 Event.Delivered
+	deliveryTime bool
+	// WithFileInfo 时设置 Event.Info
+	fileInfo 	bool
+	// WithCoalesceKey 设置的分组，nil 时为 ByPath
+	coalesceKey CoalesceKey
 
 	closes 		bool
 }
 
-func NewWatcher() (*Watcher, error) {
+func NewWatcher(opts ...Option) (*Watcher, error) {
 	var err error
-	w := &Watcher{watchMap: make(map[uint32]*WatchSingle), e: make(chan *EventBody, 10)}
+	w := &Watcher{watchMap: make(map[uint32]*WatchSingle), paused: make(map[string]bool), done: make(chan struct{}), e: make(chan *Event, 10)}
+	for _, opt := range opts {
+		opt(w)
+	}
 	w.cphandle, err = syscall.CreateIoCompletionPort(syscall.InvalidHandle, 0, 0, 1)
 	if err != nil {
-		return nil, fmt.Errorf("Watcher new Error: %s", err.Error())
+		return nil, os.NewSyscallError("CreateIoCompletionPort", err)
 	}
 	go w.epollWait()
 	return w, nil
 }
 
+// opOf FILE_ACTION 对应的 Op
+func opOf(action uint32) Op {
+	switch action {
+	case syscall.FILE_ACTION_ADDED, syscall.FILE_ACTION_RENAMED_NEW_NAME:
+		return Create
+	case syscall.FILE_ACTION_MODIFIED:
+		return Write
+	case syscall.FILE_ACTION_REMOVED:
+		return Remove
+	case syscall.FILE_ACTION_RENAMED_OLD_NAME:
+		return Rename
+	}
+	return 0
+}
+
+// initialized 零值或 nil 的 Watcher 没有可用的完成端口
+func (w *Watcher) initialized() bool {
+	return w != nil && w.e != nil
+}
+
 // 单个文件监听暂时不支持
 func (w *Watcher) AddWatch(path string, flags uint32) error {
+	return w.addWatch(path, flags, nil)
+}
+
+// AddWatchData 同 AddWatch，该监听的每个事件都带有 data
+func (w *Watcher) AddWatchData(path string, flags uint32, data any) error {
+	return w.addWatch(path, flags, func(ws *WatchSingle) { ws.data = data })
+}
+
+func (w *Watcher) addWatch(path string, flags uint32, set func(*WatchSingle)) error {
+	if !w.initialized() {
+		return ErrNotInitialized
+	}
 	var err error
     if path, err = filepath.Abs(path); err != nil {
     	return err
     }
     if info, _ := os.Stat(path); info != nil {
     	if !info.IsDir() {
-    		return fmt.Errorf("Watcher Dir Only")
+    		return &WatchError{Path: path, Err: syscall.ENOTDIR}
     	}
 		var h syscall.Handle
 		if h, err = syscall.CreateFile(syscall.StringToUTF16Ptr(path), syscall.FILE_LIST_DIRECTORY,
 			syscall.FILE_SHARE_READ|syscall.FILE_SHARE_WRITE|syscall.FILE_SHARE_DELETE, nil,
 			syscall.OPEN_EXISTING, syscall.FILE_FLAG_BACKUP_SEMANTICS|syscall.FILE_FLAG_OVERLAPPED, 0); err != nil {
-			return err
+			return &WatchError{Path: path, Err: os.NewSyscallError("CreateFile", err)}
 		}
 		
+		w.mutex.Lock()
+		defer w.mutex.Unlock()
 		ws, ok := w.watchMap[uint32(h)]
 		// TODO 暂未能实现更新事件监听mask
 		if ok {
 			ws.flags |= flags
+			if set != nil {
+				set(ws)
+			}
 			return nil
 		}
 		ws = &WatchSingle{watch: w, path: path, isDir: info.IsDir(), h: h, flags: flags, buf: make([]byte, bufferSize)}
+		if set != nil {
+			set(ws)
+		}
 		if ws.isDir {
 			ws.path += string(os.PathSeparator)
 		}
 		if _, err = syscall.CreateIoCompletionPort(ws.h, w.cphandle, uint32(ws.h), 0); err != nil {
 			syscall.CloseHandle(ws.h)
-			return err
+			return &WatchError{Path: path, Err: os.NewSyscallError("CreateIoCompletionPort", err)}
 		}
 		if err = syscall.ReadDirectoryChanges(ws.h, &ws.buf[0], bufferSize, true, ws.flags, nil, &syscall.Overlapped{}, 0); err != nil {
 			syscall.CloseHandle(ws.h)
-			return err
+			return &WatchError{Path: path, Err: os.NewSyscallError("ReadDirectoryChanges", err)}
 		}
 		w.watchMap[uint32(ws.h)] = ws
 		return nil
     }
-    return fmt.Errorf("File or Dir not")
+    return &WatchError{Path: path, Err: ErrNotExist}
+}
+
+// watchLimited windows 没有监听数量限制
+func watchLimited(err error) bool {
+	return false
+}
+
+// UpdateWatch 将已监听的 path 的 flags 替换为 flags，下一次 ReadDirectoryChanges 开始使用
+func (w *Watcher) UpdateWatch(path string, flags uint32) error {
+	if !w.initialized() {
+		return ErrNotInitialized
+	}
+	var err error
+	if path, err = filepath.Abs(path); err != nil {
+		return err
+	}
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	for _, ws := range w.watchMap {
+		if ws.path == path || ws.path == path+string(os.PathSeparator) {
+			ws.flags = flags
+			return nil
+		}
+	}
+	return &WatchError{Path: path, Err: ErrNotWatched}
+}
+
+// WaitEventTimeout 最多等待 d，超时没有事件时 ok 为 false
+func (w *Watcher) WaitEventTimeout(d time.Duration) (Event, bool, error) {
+	if !w.initialized() {
+		return Event{}, false, ErrNotInitialized
+	}
+	if w.closes {
+		return Event{}, false, ErrClosed
+	}
+	if atomic.CompareAndSwapInt32(&w.dropped, 1, 0) {
+		return Event{}, false, ErrDropped
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case e, ok := <-w.e:
+		if e == nil && !ok {
+			return Event{}, false, ErrClosed
+		}
+		return w.stamp(e), true, nil
+	case <-timer.C:
+	}
+	return Event{}, false, nil
+}
+
+// WaitEventInto 同 WaitEventTimeout，事件写入 e，d 小于 0 时一直等待。windows 的事件仍会分配内存
+func (w *Watcher) WaitEventInto(e *Event, d time.Duration) (bool, error) {
+	if d < 0 {
+		v, err := w.next()
+		if err != nil {
+			return false, err
+		}
+		*e = v
+		return true, nil
+	}
+	v, ok, err := w.WaitEventTimeout(d)
+	if ok {
+		*e = v
+	}
+	return ok, err
+}
+
+// inject 加入一个不来自完成端口的事件，缓存已满时丢弃
+func (w *Watcher) inject(e Event) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	if !w.closes {
+		select {
+		case w.e <- newEvent(e):
+		default:
+		}
+	}
 }
 
-func (w *Watcher) WaitEvent() (EventBody, error) {
+// next 一直等待下一个事件
+func (w *Watcher) next() (Event, error) {
+	if !w.initialized() {
+		return Event{}, ErrNotInitialized
+	}
 	if w.closes {
-		return EventBody{}, fmt.Errorf("The Watcher is closes")
+		return Event{}, ErrClosed
+	}
+	if atomic.CompareAndSwapInt32(&w.dropped, 1, 0) {
+		return Event{}, ErrDropped
 	}
 	e, ok := <-w.e
 	if e == nil && !ok{
-		return EventBody{}, fmt.Errorf("The Watcher is closes")
+		return Event{}, ErrClosed
 	}
-	return *e, nil
+	return w.stamp(e), nil
+}
+
+// ReadEvents 一直等待到至少有一个事件，之后取出已排队的事件直到 buf 已满，返回写入 buf 的事件数量。buf 为空时立即返回 0
+func (w *Watcher) ReadEvents(buf []Event) (int, error) {
+	if !w.initialized() {
+		return 0, ErrNotInitialized
+	}
+	if len(buf) == 0 {
+		return 0, nil
+	}
+	e, err := w.next()
+	if err != nil {
+		return 0, err
+	}
+	buf[0] = e
+	n := 1
+	for n < len(buf) {
+		select {
+		case e, ok := <-w.e:
+			if e == nil && !ok {
+				return n, nil
+			}
+			buf[n], n = w.stamp(e), n+1
+		default:
+			return n, nil
+		}
+	}
+	return n, nil
+}
+
+// nextContext 同 next，ctx 结束时返回 ctx.Err()
+func (w *Watcher) nextContext(ctx context.Context) (Event, error) {
+	if w.closes {
+		return Event{}, ErrClosed
+	}
+	if atomic.CompareAndSwapInt32(&w.dropped, 1, 0) {
+		return Event{}, ErrDropped
+	}
+	select {
+	case e, ok := <-w.e:
+		if e == nil && !ok {
+			return Event{}, ErrClosed
+		}
+		return w.stamp(e), nil
+	case <-ctx.Done():
+		return Event{}, ctx.Err()
+	}
+}
+
+// wakeWaiters nextContext 已在 select 中等待 ctx，不需要唤醒
+func (w *Watcher) wakeWaiters() {}
+
+// stamp 设置交给调用者的事件的 Seq 与 Time，inject 时已有 Time 的保留
+func (w *Watcher) stamp(e *Event) Event {
+	v := *e
+	freeEvent(e)
+	v.Seq = atomic.AddUint64(&w.seq, 1)
+	atomic.AddUint64(&w.delivered, 1)
+	now := time.Now()
+	if v.Time.IsZero() {
+		v.Time = now
+	}
+	if w.deliveryTime {
+		v.Delivered = now
+	}
+	if w.fileInfo {
+		describe(&v)
+	}
+	return v
+}
+
+// changedAt windows 没有 ctime，使用修改时间
+func changedAt(info fs.FileInfo) time.Time {
+	return info.ModTime()
+}
+
+func (w *Watcher) closed() <-chan struct{} {
+	return w.done
+}
+
+// TryEvent 不阻塞，没有已缓存的事件时 ok 为 false
+func (w *Watcher) TryEvent() (Event, bool) {
+	if !w.initialized() {
+		return Event{}, false
+	}
+	select {
+	case e, ok := <-w.e:
+		if e != nil && ok {
+			return w.stamp(e), true
+		}
+	default:
+	}
+	return Event{}, false
 }
 
 func (w *Watcher) epollWait() {
@@ -155,6 +430,7 @@ func (w *Watcher) epollWait() {
 			continue
 		}
 		if key == 0 {
+			w.mutex.Lock()
 			w.closes = true
 			syscall.Close(w.cphandle)
 			syscall.CancelIo(w.cphandle)
@@ -163,33 +439,70 @@ func (w *Watcher) epollWait() {
 				syscall.Close(v.h)
 			}
 			close(w.e)
+			w.mutex.Unlock()
+			close(w.done)
 			return
 		}
+		w.mutex.Lock()
 		ws, ok := w.watchMap[key]
+		paused := ok && w.paused[ws.group]
+		w.mutex.Unlock()
 		if !ok {
 			fmt.Println("The watchMap error ", key)
 			continue
 		}
 		event := (*syscall.FileNotifyInformation)(unsafe.Pointer(&ws.buf[0]))
-		body := &EventBody{wd: key, Mask: event.Action, FileName: ws.path}
+		body := newEvent(Event{wd: key, Raw: event.Action, Op: opOf(event.Action), FileName: ws.path, Group: ws.group, Data: ws.data, Time: time.Now()})
 		if ws.isDir {
 			body.FileName += syscall.UTF16ToString(((*[syscall.MAX_PATH]uint16)(unsafe.Pointer(&event.FileName)))[:event.FileNameLength/2])
 		}
 
+		keep := !paused && (w.filter == nil || w.filter(*body))
+		if keep {
+			w.mutex.Lock()
+			for _, f := range w.observers {
+				f(*body)
+			}
+			w.mutex.Unlock()
+		}
 		// 留存不超过10个缓存事件
-		if len(w.e) == cap(w.e) {
-			<-w.e
+		switch {
+		case !keep:
+			freeEvent(body)
+		case len(w.e) < cap(w.e) || w.backpressure == Block:
+			w.e <- body
+		case w.backpressure == DropNewest:
+			freeEvent(body)
+			atomic.StoreInt32(&w.dropped, 1)
+			atomic.AddUint64(&w.seq, 1)
+		default:
+			if old, ok := <-w.e; ok && old != nil {
+				freeEvent(old)
+			}
+			w.e <- body
+			atomic.AddUint64(&w.seq, 1)
 		}
-		w.e <- body
 
 		if err = syscall.ReadDirectoryChanges(ws.h, &ws.buf[0], bufferSize, true, ws.flags, nil, &syscall.Overlapped{}, 0); err != nil {
 			fmt.Println("The ReadDirectoryChanges error ", err)
+			w.mutex.Lock()
 			delete(w.watchMap, key)
+			w.mutex.Unlock()
 		}
 	}
 }
 
+// observe 每个通过过滤的事件调用一次 f
+func (w *Watcher) observe(f func(Event)) {
+	w.mutex.Lock()
+	w.observers = append(w.observers, f)
+	w.mutex.Unlock()
+}
+
 func (w *Watcher) Close() error {
+	if !w.initialized() {
+		return ErrNotInitialized
+	}
 	if !w.closes {
 		return syscall.PostQueuedCompletionStatus(w.cphandle, 0, 0, nil)
 	}
@@ -0,0 +1,116 @@
+//go:build linux
+// +build linux
+
+// @@
+// @ Author       : Eacher
+// @ Date         : 2026-10-14 13:10:12
+// @ LastEditTime : 2026-10-24 15:35:56
+// @ LastEditors  : Eacher
+// @ --------------------------------------------------------------------------------<
+// @ Description  : 文件写入完成(CLOSE_WRITE)后复制到按时间分目录的归档中
+// @ --------------------------------------------------------------------------------<
+// @ FilePath     : /inotify/internal/archive/archive.go
+// @@
+package archive
+
+import (
+	"io"
+	"os"
+	"time"
+	"errors"
+	"strings"
+	"path/filepath"
+)
+
+// 归档目录名，精确到毫秒避免同一秒内多次写入互相覆盖
+const Layout = "20060102-150405.000"
+
+// Archiver 把文件复制到 dest/<时间>/<监听根的名字>/<相对监听根的路径>
+type Archiver struct {
+	dest 	string
+	// 监听的文件或目录 -> 归档中的相对根目录
+	roots 	map[string]string
+}
+
+func New(dest string) (*Archiver, error) {
+	dest, err := filepath.Abs(dest)
+	if err != nil {
+		return nil, err
+	}
+	return &Archiver{dest: dest, roots: make(map[string]string)}, nil
+}
+
+// AddRoot 添加监听的文件或目录，返回绝对路径
+func (a *Archiver) AddRoot(path string) (string, error) {
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+	a.roots[path] = filepath.Base(path)
+	return path, nil
+}
+
+// Target 文件在 t 时刻的归档路径，dest 中的文件(dest 在监听的目录中时)返回 false
+func (a *Archiver) Target(name string, t time.Time) (string, bool) {
+	if within(a.dest, name) {
+		return "", false
+	}
+	return filepath.Join(a.dest, t.Format(Layout), a.name(name)), true
+}
+
+// Archive 把 name 复制到 t 时刻的归档路径并返回该路径，dest 中的文件返回空路径与 nil
+func (a *Archiver) Archive(name string, t time.Time) (string, error) {
+	to, ok := a.Target(name, t)
+	if !ok {
+		return "", nil
+	}
+	return to, CopyFile(name, to)
+}
+
+// name 归档中的相对路径: 监听根的名字 + 相对监听根的路径
+func (a *Archiver) name(name string) string {
+	for root, base := range a.roots {
+		if name == root {
+			return base
+		}
+		if within(root, name) {
+			rel, _ := filepath.Rel(root, name)
+			return filepath.Join(base, rel)
+		}
+	}
+	return filepath.Base(name)
+}
+
+// within path 是否是 dir 或在 dir 之中
+func within(dir, path string) bool {
+	rel, err := filepath.Rel(dir, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// CopyFile 复制普通文件，to 已存在时失败
+func CopyFile(from, to string) error {
+	src, err := os.Open(from)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	info, err := src.Stat()
+	if err != nil {
+		return err
+	}
+	if !info.Mode().IsRegular() {
+		return errors.New("not a regular file")
+	}
+	if err = os.MkdirAll(filepath.Dir(to), 0755); err != nil {
+		return err
+	}
+	dst, err := os.OpenFile(to, os.O_WRONLY|os.O_CREATE|os.O_EXCL, info.Mode().Perm())
+	if err != nil {
+		return err
+	}
+	if _, err = io.Copy(dst, src); err != nil {
+		dst.Close()
+		return err
+	}
+	return dst.Close()
+}
@@ -0,0 +1,333 @@
+//go:build linux
+// +build linux
+
+// @@
+// @ Author       : Eacher
+// @ Date         : 2026-10-17 10:56:38
+// @ LastEditTime : 2026-10-24 13:35:24
+// @ LastEditors  : Eacher
+// @ --------------------------------------------------------------------------------<
+// @ Description  : 按配置文件运行监听与动作(命令、webhook、日志)，SIGHUP 重新加载
+// @ --------------------------------------------------------------------------------<
+// @ FilePath     : /inotify/internal/daemon/daemon.go
+// @@
+package daemon
+
+import (
+	"os"
+	"fmt"
+	"time"
+	"errors"
+	"strings"
+	"syscall"
+	"encoding/json"
+	"path/filepath"
+	"github.com/20yyq/inotify"
+	"github.com/20yyq/inotify/notify"
+	"github.com/20yyq/inotify/runner"
+	"github.com/20yyq/inotify/internal/eventname"
+)
+
+// config daemon 的配置文件，只支持 JSON，不支持 YAML(根模块除 x/sys 外不引入依赖):
+//
+//	{"watches": [{"path": "/etc/nginx", "recursive": true, "events": ["close_write", "moved_to"], "pattern": "*.conf",
+//		"actions": [{"exec": ["nginx", "-s", "reload"], "policy": "queue"}, {"webhook": "https://example.com/hook", "interval": "10s"}, {"log": true}]}]}
+//
+// exec 也可以是一个字符串，按 sh 的规则拆分，参数中可以使用 runner.Vars 的模板，如 "convert {{.Path}} out/{{.Base}}.png"
+type config struct {
+	Watches 	[]watchConfig 	`json:"watches"`
+}
+
+type watchConfig struct {
+	Path 		string 			`json:"path"`
+	Recursive 	bool 			`json:"recursive"`
+	// eventname 中的事件名，空为所有事件
+	Events 		[]string 		`json:"events"`
+	// filepath.Match 匹配文件名，空匹配所有文件
+	Pattern 	string 			`json:"pattern"`
+	// pattern 不区分大小写
+	IgnoreCase 	bool 			`json:"ignore_case"`
+	Actions 	[]actionConfig 	`json:"actions"`
+}
+
+// actionConfig Exec、Webhook、Log 只能设置一个
+type actionConfig struct {
+	Exec 		argv 		`json:"exec"`
+	// skip、queue、restart，默认 skip
+	Policy 		string 		`json:"policy"`
+	Webhook 	string 		`json:"webhook"`
+	// webhook 合并发送的间隔，time.ParseDuration 格式，默认 5s
+	Interval 	string 		`json:"interval"`
+	Log 		bool 		`json:"log"`
+}
+
+// argv 字符串或字符串数组
+type argv []string
+
+func (c *argv) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return json.Unmarshal(data, (*[]string)(c))
+	}
+	args, err := runner.SplitCommand(s)
+	*c = args
+	return err
+}
+
+// watch 一个 watchConfig 加载后的结果
+type watch struct {
+	path 		string
+	mask 		uint32
+	pattern 	string
+	ignoreCase 	bool
+	engine 		*runner.Engine
+	sinks 		[]*notify.Sink
+	log 		bool
+}
+
+// match 事件是否来自该监听并通过 events 与 pattern
+func (wt *watch) match(e inotify.Event) bool {
+	if e.Raw&wt.mask == 0 {
+		return false
+	}
+	name := filepath.Clean(e.FileName)
+	if name != wt.path && !strings.HasPrefix(name, wt.path+string(os.PathSeparator)) {
+		return false
+	}
+	return (&runner.Rule{Pattern: wt.pattern, IgnoreCase: wt.ignoreCase}).Match(e)
+}
+
+func (wt *watch) dispatch(e inotify.Event) {
+	if wt.engine != nil {
+		wt.engine.Dispatch(e)
+	}
+	for _, s := range wt.sinks {
+		s.Add(e)
+	}
+	if wt.log {
+		fmt.Println(logLine(e))
+	}
+}
+
+func logLine(e inotify.Event) string {
+	return time.Now().Format(time.RFC3339) + " " + eventname.Join(e.Raw, ",") + " " + e.FileName
+}
+
+// Daemon 运行中的一份配置
+type Daemon struct {
+	w 		*inotify.Watcher
+	watches []*watch
+}
+
+// 收到退出信号后处理已读取的事件，最多等待 drainTimeout，drainIdle 内没有新事件即结束
+const (
+	drainTimeout 	= time.Second
+	drainIdle 		= time.Millisecond*100
+)
+
+// Load 读取配置并添加所有监听，dryRun 时 exec 只输出展开后的命令
+func Load(file string, dryRun bool) (*Daemon, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	var c config
+	if err = json.Unmarshal(data, &c); err != nil {
+		return nil, fmt.Errorf("%s: %w", file, err)
+	}
+	if len(c.Watches) == 0 {
+		return nil, fmt.Errorf("%s: no watches", file)
+	}
+	d := &Daemon{}
+	for i, wc := range c.Watches {
+		wt, err := newWatch(wc, dryRun)
+		if err != nil {
+			return nil, fmt.Errorf("%s: watches[%d]: %w", file, i, err)
+		}
+		d.watches = append(d.watches, wt)
+	}
+	if d.w, err = inotify.NewWatcher(); err != nil {
+		return nil, err
+	}
+	for i, wt := range d.watches {
+		if c.Watches[i].Recursive {
+			err = d.w.AddRecursiveWatch(wt.path, wt.mask)
+		} else {
+			err = d.w.AddWatch(wt.path, wt.mask)
+		}
+		if err != nil {
+			d.w.Close()
+			return nil, fmt.Errorf("%s: %w", wt.path, err)
+		}
+	}
+	return d, nil
+}
+
+func newWatch(wc watchConfig, dryRun bool) (*watch, error) {
+	var err error
+	wt := &watch{pattern: wc.Pattern, ignoreCase: wc.IgnoreCase}
+	if wt.path, err = filepath.Abs(wc.Path); err != nil || wc.Path == "" {
+		return nil, errors.New("path is required")
+	}
+	var mask eventname.Flag
+	for _, name := range wc.Events {
+		if err = mask.Set(name); err != nil {
+			return nil, err
+		}
+	}
+	wt.mask = mask.Mask()
+	if _, err = filepath.Match(wc.Pattern, ""); err != nil {
+		return nil, err
+	}
+	var rules []runner.Rule
+	for i, ac := range wc.Actions {
+		switch {
+		case len(ac.Exec) > 0:
+			r := runner.Rule{Name: fmt.Sprintf("%s#%d", wt.path, i), Command: ac.Exec}
+			// 加载时检查模板
+			if _, err = r.Expand(inotify.Event{}); err != nil {
+				return nil, fmt.Errorf("actions[%d]: %w", i, err)
+			}
+			switch ac.Policy {
+			case "", "skip":
+			case "queue":
+				r.Policy = runner.Queue
+			case "restart":
+				r.Policy = runner.Restart
+			default:
+				return nil, fmt.Errorf("actions[%d]: unknown policy %q", i, ac.Policy)
+			}
+			rules = append(rules, r)
+		case ac.Webhook != "":
+			interval := time.Second*5
+			if ac.Interval != "" {
+				if interval, err = time.ParseDuration(ac.Interval); err != nil {
+					return nil, fmt.Errorf("actions[%d]: %w", i, err)
+				}
+			}
+			s, err := notify.NewSink(&notify.WebhookSender{URL: ac.Webhook}, interval, "", "")
+			if err != nil {
+				return nil, err
+			}
+			s.OnError = func(err error) { fmt.Fprintln(os.Stderr, "inotify daemon:", err) }
+			wt.sinks = append(wt.sinks, s)
+		case ac.Log:
+			wt.log = true
+		default:
+			return nil, fmt.Errorf("actions[%d]: one of exec, webhook or log is required", i)
+		}
+	}
+	if len(rules) > 0 {
+		wt.engine = runner.NewEngine(rules...)
+		wt.engine.DryRun = dryRun
+		wt.engine.OnExit = func(r *runner.Rule, e inotify.Event, err error) {
+			if err != nil {
+				fmt.Fprintln(os.Stderr, "inotify daemon:", r.Name, e.FileName, err)
+			}
+		}
+	}
+	return wt, nil
+}
+
+// Handle 把事件交给匹配的监听，返回是否有监听匹配
+func (d *Daemon) Handle(e inotify.Event) bool {
+	matched := false
+	for _, wt := range d.watches {
+		if wt.match(e) {
+			matched = true
+			wt.dispatch(e)
+		}
+	}
+	return matched
+}
+
+// handle matched 不为 nil 时为匹配的事件调用
+func (d *Daemon) handle(e inotify.Event, matched func(line string)) {
+	if d.Handle(e) && matched != nil {
+		matched(logLine(e))
+	}
+}
+
+// drain 处理已经读取但还未分发的事件
+func (d *Daemon) drain(matched func(line string)) {
+	deadline := time.NewTimer(drainTimeout)
+	defer deadline.Stop()
+	for {
+		idle := time.NewTimer(drainIdle)
+		select {
+		case e, ok := <-d.w.Events():
+			idle.Stop()
+			if !ok {
+				return
+			}
+			d.handle(e, matched)
+			continue
+		case <-idle.C:
+		case <-deadline.C:
+			idle.Stop()
+		}
+		return
+	}
+}
+
+// Stop 关闭监听，等待运行中的命令结束并发送剩余的通知
+func (d *Daemon) Stop() {
+	d.w.Close()
+	for _, wt := range d.watches {
+		if wt.engine != nil {
+			wt.engine.Wait()
+		}
+		for _, s := range wt.sinks {
+			if err := s.Flush(); err != nil {
+				fmt.Fprintln(os.Stderr, "inotify daemon:", err)
+			}
+		}
+	}
+}
+
+// Run 按 file 运行直到 sig 收到 SIGHUP 以外的信号，处理完已读取的事件后返回 nil。
+// SIGHUP 重新加载 file，新配置有误时输出错误并继续使用旧配置。
+// matched 为匹配的事件调用，参数为日志行；notify 接收 systemd 状态(READY=1 等)，都可以为 nil
+func Run(file string, dryRun bool, sig <-chan os.Signal, matched func(line string), notify func(state string)) error {
+	if notify == nil {
+		notify = func(string) {}
+	}
+	d, err := Load(file, dryRun)
+	if err != nil {
+		return err
+	}
+	notify("READY=1")
+	for {
+		select {
+		case s := <-sig:
+			if s != syscall.SIGHUP {
+				notify("STOPPING=1")
+				d.drain(matched)
+				d.Stop()
+				return nil
+			}
+			notify("RELOADING=1")
+			// 新配置有误时继续使用旧配置
+			next, err := Load(file, dryRun)
+			if err != nil {
+				fmt.Fprintln(os.Stderr, "inotify daemon: reload:", err)
+				notify("READY=1")
+				continue
+			}
+			d.drain(matched)
+			d.Stop()
+			d = next
+			notify("READY=1")
+			fmt.Fprintln(os.Stderr, "inotify daemon: reloaded", file)
+		case e, ok := <-d.w.Events():
+			if !ok {
+				return inotify.ErrClosed
+			}
+			d.handle(e, matched)
+		case err := <-d.w.Errors():
+			if err != nil {
+				fmt.Fprintln(os.Stderr, "inotify daemon:", err)
+			}
+		}
+	}
+}
@@ -0,0 +1,99 @@
+//go:build linux
+// +build linux
+
+// @@
+// @ Author       : Eacher
+// @ Date         : 2026-10-17 09:57:30
+// @ LastEditTime : 2026-10-17 10:56:38
+// @ LastEditors  : Eacher
+// @ --------------------------------------------------------------------------------<
+// @ Description  : 命令行工具共用的事件名与 --event 参数解析
+// @ --------------------------------------------------------------------------------<
+// @ FilePath     : /inotify/internal/eventname/eventname.go
+// @@
+package eventname
+
+import (
+	"fmt"
+	"strings"
+	"github.com/20yyq/inotify"
+)
+
+// Names 事件名，顺序即输出的顺序
+var Names = []struct {
+	Name 	string
+	Mask 	uint32
+}{
+	{"access", inotify.IN_ACCESS},
+	{"modify", inotify.IN_MODIFY},
+	{"attrib", inotify.IN_ATTRIB},
+	{"close_write", inotify.IN_CLOSE_WRITE},
+	{"close_nowrite", inotify.IN_CLOSE_NOWRITE},
+	{"open", inotify.IN_OPEN},
+	{"moved_from", inotify.IN_MOVED_FROM},
+	{"moved_to", inotify.IN_MOVED_TO},
+	{"create", inotify.IN_CREATE},
+	{"delete", inotify.IN_DELETE},
+	{"delete_self", inotify.IN_DELETE_SELF},
+	{"move_self", inotify.IN_MOVE_SELF},
+	{"unmount", inotify.IN_UNMOUNT},
+	{"isdir", inotify.IN_ISDIR},
+}
+
+// Flag 可重复的 --event，每个值可用逗号分隔多个事件
+type Flag uint32
+
+func (f *Flag) String() string {
+	return fmt.Sprintf("%#x", uint32(*f))
+}
+
+func (f *Flag) Set(s string) error {
+	for _, name := range strings.Split(s, ",") {
+		switch name = strings.ToLower(strings.TrimSpace(name)); name {
+		case "close":
+			*f |= Flag(inotify.IN_CLOSE)
+		case "move":
+			*f |= Flag(inotify.IN_MOVE)
+		case "all":
+			*f |= Flag(inotify.IN_ALL_EVENTS)
+		default:
+			mask := maskOf(name)
+			if mask == 0 || mask == inotify.IN_ISDIR {
+				return fmt.Errorf("unknown event %q", name)
+			}
+			*f |= Flag(mask)
+		}
+	}
+	return nil
+}
+
+// Mask 没有设置时为 IN_ALL_EVENTS
+func (f Flag) Mask() uint32 {
+	if f == 0 {
+		return inotify.IN_ALL_EVENTS
+	}
+	return uint32(f)
+}
+
+func maskOf(name string) uint32 {
+	for _, n := range Names {
+		if n.Name == name {
+			return n.Mask
+		}
+	}
+	return 0
+}
+
+// Join mask 中所有事件的大写名称，如 CLOSE_WRITE,CLOSE
+func Join(mask uint32, sep string) string {
+	var list []string
+	for _, n := range Names {
+		if mask&n.Mask != 0 {
+			list = append(list, strings.ToUpper(n.Name))
+		}
+	}
+	if mask&inotify.IN_CLOSE != 0 {
+		list = append(list, "CLOSE")
+	}
+	return strings.Join(list, sep)
+}
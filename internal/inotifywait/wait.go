@@ -0,0 +1,117 @@
+//go:build linux
+// +build linux
+
+// @@
+// @ Author       : Eacher
+// @ Date         : 2026-10-24 15:35:56
+// @ LastEditTime : 2026-10-24 16:04:04
+// @ LastEditors  : Eacher
+// @ --------------------------------------------------------------------------------<
+// @ Description  : goinotifywait 的事件循环、退出码与 --format 输出
+// @ --------------------------------------------------------------------------------<
+// @ FilePath     : /inotify/internal/inotifywait/wait.go
+// @@
+package inotifywait
+
+import (
+	"os"
+	"fmt"
+	"time"
+	"errors"
+	"strings"
+	"path/filepath"
+	"github.com/20yyq/inotify"
+	"github.com/20yyq/inotify/internal/eventname"
+)
+
+// 退出码与 inotifywait 相同: 0 收到事件，1 出错，2 超时
+const (
+	ExitEvent 	= 0
+	ExitError 	= 1
+	ExitTimeout = 2
+)
+
+// Waiter 等待 Mask 中的事件并交给 Print
+type Waiter struct {
+	Mask 	uint32
+	// Monitor 收到事件后继续等待，而不是返回 ExitEvent
+	Monitor bool
+	// Timeout 大于 0 时超过 Timeout 没有事件返回 ExitTimeout，Monitor 时每个事件后重新计时
+	Timeout time.Duration
+	Print 	func(inotify.Event)
+}
+
+// Wait 返回退出码，stop 收到信号时返回 ExitEvent，w 关闭时返回 ExitError
+func (wt *Waiter) Wait(w *inotify.Watcher, stop <-chan os.Signal) int {
+	var deadline time.Time
+	for {
+		if wt.Timeout > 0 {
+			deadline = time.Now().Add(wt.Timeout)
+		}
+		for {
+			select {
+			case <-stop:
+				return ExitEvent
+			default:
+			}
+			d := time.Millisecond*500
+			if !deadline.IsZero() {
+				if left := time.Until(deadline); left <= 0 {
+					return ExitTimeout
+				} else if left < d {
+					d = left
+				}
+			}
+			e, ok, err := w.WaitEventTimeout(d)
+			if err != nil {
+				if errors.Is(err, inotify.ErrClosed) {
+					return ExitError
+				}
+				fmt.Fprintln(os.Stderr, "goinotifywait:", err)
+				continue
+			}
+			if !ok || e.Raw&wt.Mask == 0 {
+				continue
+			}
+			wt.Print(e)
+			break
+		}
+		if !wt.Monitor {
+			return ExitEvent
+		}
+	}
+}
+
+// Render 按 --format 输出，被监听的文件本身的事件 %f 为空，%T 为事件的时间
+func Render(format, timefmt string, watched map[string]bool, e inotify.Event) string {
+	dir, file := e.FileName, ""
+	if !watched[e.FileName] {
+		dir, file = filepath.Dir(e.FileName)+string(os.PathSeparator), filepath.Base(e.FileName)
+	} else if info, err := os.Stat(dir); err == nil && info.IsDir() {
+		dir += string(os.PathSeparator)
+	}
+	var b strings.Builder
+	for i := 0; i < len(format); i++ {
+		if format[i] != '%' || i+1 == len(format) {
+			b.WriteByte(format[i])
+			continue
+		}
+		i++
+		switch format[i] {
+		case 'w':
+			b.WriteString(dir)
+		case 'f':
+			b.WriteString(file)
+		case 'e':
+			b.WriteString(eventname.Join(e.Raw, ","))
+		case 'T':
+			b.WriteString(e.Time.Format(timefmt))
+		case '%':
+			b.WriteByte('%')
+		default:
+			b.WriteByte('%')
+			b.WriteByte(format[i])
+		}
+	}
+	return b.String()
+}
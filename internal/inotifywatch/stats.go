@@ -0,0 +1,123 @@
+//go:build linux
+// +build linux
+
+// @@
+// @ Author       : Eacher
+// @ Date         : 2026-10-24 16:04:04
+// @ LastEditTime : 2026-10-24 16:54:07
+// @ LastEditors  : Eacher
+// @ --------------------------------------------------------------------------------<
+// @ Description  : goinotifywatch 按路径与事件的计数和统计表输出
+// @ --------------------------------------------------------------------------------<
+// @ FilePath     : /inotify/internal/inotifywatch/stats.go
+// @@
+package inotifywatch
+
+import (
+	"io"
+	"os"
+	"fmt"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"path/filepath"
+	"github.com/20yyq/inotify"
+	"github.com/20yyq/inotify/internal/eventname"
+)
+
+// stat 一个路径的统计，counts 与 eventname.Names 一一对应
+type stat struct {
+	path 	string
+	total 	int
+	counts 	[]int
+}
+
+// Stats 按目录(Files 时按文件)累计事件数量
+type Stats struct {
+	watched map[string]bool
+	files 	bool
+	stats 	map[string]*stat
+}
+
+// New watched 为监听的路径，监听的文件本身的事件计入该文件
+func New(watched map[string]bool, files bool) *Stats {
+	return &Stats{watched: watched, files: files, stats: make(map[string]*stat)}
+}
+
+// Column --sort 对应的事件列，total 为 -1
+func Column(by string) (int, error) {
+	if by == "total" {
+		return -1, nil
+	}
+	for i, n := range eventname.Names {
+		if n.Name == by {
+			return i, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown --sort %q", by)
+}
+
+func (st *Stats) Add(e inotify.Event) {
+	// 监听的目录本身的事件以路径分隔符结尾，监听的文件本身的事件即为该文件
+	path := filepath.Clean(e.FileName)
+	if !st.files && !strings.HasSuffix(e.FileName, string(os.PathSeparator)) && !st.watched[path] {
+		path = filepath.Dir(path)
+	}
+	s := st.stats[path]
+	if s == nil {
+		s = &stat{path: path, counts: make([]int, len(eventname.Names))}
+		st.stats[path] = s
+	}
+	s.total++
+	for i, n := range eventname.Names {
+		if e.Raw&n.Mask != 0 && n.Mask != inotify.IN_ISDIR {
+			s.counts[i]++
+		}
+	}
+}
+
+// Print 输出统计表，column 为排序的事件列，-1 为按 total 排序；zero 时输出没有发生的事件列。没有事件时返回 false
+func (st *Stats) Print(out io.Writer, column int, ascending, zero bool) bool {
+	if len(st.stats) == 0 {
+		return false
+	}
+	rows := make([]*stat, 0, len(st.stats))
+	sums := make([]int, len(eventname.Names))
+	for _, s := range st.stats {
+		rows = append(rows, s)
+		for i, c := range s.counts {
+			sums[i] += c
+		}
+	}
+	key := func(s *stat) int {
+		if column < 0 {
+			return s.total
+		}
+		return s.counts[column]
+	}
+	sort.Slice(rows, func(i, j int) bool {
+		if a, b := key(rows[i]), key(rows[j]); a != b {
+			return (a < b) == ascending
+		}
+		return rows[i].path < rows[j].path
+	})
+	tw := tabwriter.NewWriter(out, 0, 8, 2, ' ', 0)
+	header := []string{"total"}
+	for i, n := range eventname.Names {
+		if zero && n.Mask != inotify.IN_ISDIR || sums[i] > 0 {
+			header = append(header, n.Name)
+		}
+	}
+	fmt.Fprintln(tw, strings.Join(append(header, "filename"), "\t"))
+	for _, s := range rows {
+		line := []string{fmt.Sprint(s.total)}
+		for i, c := range s.counts {
+			if zero && eventname.Names[i].Mask != inotify.IN_ISDIR || sums[i] > 0 {
+				line = append(line, fmt.Sprint(c))
+			}
+		}
+		fmt.Fprintln(tw, strings.Join(append(line, s.path), "\t"))
+	}
+	tw.Flush()
+	return true
+}
@@ -0,0 +1,73 @@
+//go:build linux
+// +build linux
+
+// @@
+// @ Author       : Eacher
+// @ Date         : 2026-10-14 09:12:33
+// @ LastEditTime : 2026-10-14 09:41:39
+// @ LastEditors  : Eacher
+// @ --------------------------------------------------------------------------------<
+// @ Description  : 长时间压测用的资源采样，检测 fd、goroutine、堆内存泄漏
+// @ --------------------------------------------------------------------------------<
+// @ FilePath     : /inotify/internal/soak/soak.go
+// @@
+package soak
+
+import (
+	"os"
+	"fmt"
+	"time"
+	"runtime"
+)
+
+// Sample 某一时刻的进程资源占用
+type Sample struct {
+	FDs 		int
+	Goroutines 	int
+	HeapAlloc 	uint64
+}
+
+// Limit 允许相对基线的增长量
+type Limit struct {
+	FDs 		int
+	Goroutines 	int
+	HeapAlloc 	uint64
+}
+
+// DefaultLimit 留出 runtime 自身抖动的余量
+var DefaultLimit = Limit{FDs: 4, Goroutines: 4, HeapAlloc: 4 << 20}
+
+// Take 采样前先 GC 并等待已退出的 goroutine 被回收
+func Take() (Sample, error) {
+	var s Sample
+	for i := 0; i < 3; i++ {
+		runtime.GC()
+		time.Sleep(time.Millisecond*10)
+	}
+	entries, err := os.ReadDir("/proc/self/fd")
+	if err != nil {
+		return s, err
+	}
+	var ms runtime.MemStats
+	runtime.ReadMemStats(&ms)
+	s.FDs, s.Goroutines, s.HeapAlloc = len(entries), runtime.NumGoroutine(), ms.HeapAlloc
+	return s, nil
+}
+
+// Check 判断 cur 相对 base 的增长是否超出 l
+func (base Sample) Check(cur Sample, l Limit) error {
+	if cur.FDs > base.FDs + l.FDs {
+		return fmt.Errorf("fd leak: %d -> %d", base.FDs, cur.FDs)
+	}
+	if cur.Goroutines > base.Goroutines + l.Goroutines {
+		return fmt.Errorf("goroutine leak: %d -> %d", base.Goroutines, cur.Goroutines)
+	}
+	if cur.HeapAlloc > base.HeapAlloc + l.HeapAlloc {
+		return fmt.Errorf("heap growth: %d -> %d", base.HeapAlloc, cur.HeapAlloc)
+	}
+	return nil
+}
+
+func (s Sample) String() string {
+	return fmt.Sprintf("fds=%d goroutines=%d heap=%d", s.FDs, s.Goroutines, s.HeapAlloc)
+}
@@ -0,0 +1,161 @@
+//go:build linux
+// +build linux
+
+// @@
+// @ Author       : Eacher
+// @ Date         : 2026-10-17 11:50:42
+// @ LastEditTime : 2026-10-24 14:16:21
+// @ LastEditors  : Eacher
+// @ --------------------------------------------------------------------------------<
+// @ Description  : systemd 集成: sd_notify、watchdog 与 socket activation
+// @ --------------------------------------------------------------------------------<
+// @ FilePath     : /inotify/internal/systemd/systemd.go
+// @@
+package systemd
+
+import (
+	"os"
+	"net"
+	"sync"
+	"time"
+	"strconv"
+)
+
+// Notify 向 NOTIFY_SOCKET 发送状态，不是由 systemd 启动(Type=notify)时不做任何处理
+func Notify(state string) error {
+	addr := os.Getenv("NOTIFY_SOCKET")
+	if addr == "" {
+		return nil
+	}
+	// @ 开头为抽象命名空间
+	if addr[0] == '@' {
+		addr = "\x00" + addr[1:]
+	}
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: addr, Net: "unixgram"})
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	_, err = conn.Write([]byte(state))
+	return err
+}
+
+// Watchdog 设置了 WatchdogSec 时每半个周期发送一次 WATCHDOG=1，直到 done 关闭
+func Watchdog(done <-chan struct{}) {
+	usec, err := strconv.ParseInt(os.Getenv("WATCHDOG_USEC"), 10, 64)
+	if err != nil || usec <= 0 {
+		return
+	}
+	if pid := os.Getenv("WATCHDOG_PID"); pid != "" && pid != strconv.Itoa(os.Getpid()) {
+		return
+	}
+	ticker := time.NewTicker(time.Duration(usec)*time.Microsecond/2)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			Notify("WATCHDOG=1")
+		case <-done:
+			return
+		}
+	}
+}
+
+// 传入的第一个 fd，见 sd_listen_fds(3)
+const listenFdsStart = 3
+
+// ListenFds socket activation 传入的 fd 数量，LISTEN_PID 不是当前进程或 LISTEN_FDS 无效时为 0
+func ListenFds() int {
+	if os.Getenv("LISTEN_PID") != strconv.Itoa(os.Getpid()) {
+		return 0
+	}
+	n, err := strconv.Atoi(os.Getenv("LISTEN_FDS"))
+	if err != nil || n <= 0 {
+		return 0
+	}
+	return n
+}
+
+// Listeners socket activation 传入的监听 socket，没有时为空。取出后清除 LISTEN_* 环境变量，子进程不会再次使用
+func Listeners() []net.Listener {
+	n := ListenFds()
+	if n == 0 {
+		return nil
+	}
+	os.Unsetenv("LISTEN_PID")
+	os.Unsetenv("LISTEN_FDS")
+	os.Unsetenv("LISTEN_FDNAMES")
+	var list []net.Listener
+	for fd := listenFdsStart; fd < listenFdsStart+n; fd++ {
+		f := os.NewFile(uintptr(fd), "LISTEN_FD_"+strconv.Itoa(fd))
+		if l, err := net.FileListener(f); err == nil {
+			list = append(list, l)
+		}
+		f.Close()
+	}
+	return list
+}
+
+// 每个客户端缓存的日志行数，写满的客户端被断开
+const streamQueue = 64
+
+// Stream 把每个事件的日志行发送给连接到监听的客户端。每个客户端由自己的 goroutine 写入，
+// Write 不会阻塞，缓存满或写入失败的客户端被断开
+type Stream struct {
+	mutex 	sync.Mutex
+	conns 	map[net.Conn]chan string
+}
+
+func NewStream(list []net.Listener) *Stream {
+	s := &Stream{conns: make(map[net.Conn]chan string)}
+	for _, l := range list {
+		go s.accept(l)
+	}
+	return s
+}
+
+func (s *Stream) accept(l net.Listener) {
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		queue := make(chan string, streamQueue)
+		s.mutex.Lock()
+		s.conns[conn] = queue
+		s.mutex.Unlock()
+		go s.send(conn, queue)
+	}
+}
+
+func (s *Stream) send(conn net.Conn, queue chan string) {
+	for line := range queue {
+		conn.SetWriteDeadline(time.Now().Add(time.Second))
+		if _, err := conn.Write([]byte(line + "\n")); err != nil {
+			s.mutex.Lock()
+			s.drop(conn)
+			s.mutex.Unlock()
+		}
+	}
+}
+
+// drop 调用者需持有 mutex
+func (s *Stream) drop(conn net.Conn) {
+	if queue, ok := s.conns[conn]; ok {
+		close(queue)
+		delete(s.conns, conn)
+		conn.Close()
+	}
+}
+
+func (s *Stream) Write(line string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for conn, queue := range s.conns {
+		select {
+		case queue <- line:
+		default:
+			s.drop(conn)
+		}
+	}
+}
@@ -0,0 +1,41 @@
+//go:build go1.23
+
+// @@
+// @ Author       : Eacher
+// @ Date         : 2026-10-21 18:12:30
+// @ LastEditTime : 2026-10-21 18:45:54
+// @ LastEditors  : Eacher
+// @ --------------------------------------------------------------------------------<
+// @ Description  : range-over-func 方式读取事件，只在 go1.23 及以上编译，go.mod 仍为 go 1.19
+// @ --------------------------------------------------------------------------------<
+// @ FilePath     : /inotify/iter.go
+// @@
+package inotify
+
+import (
+	"iter"
+	"context"
+)
+
+// All 依次返回事件，for e, err := range w.All(ctx)。Close 后结束；ctx 结束时最后返回一次 ctx.Err() 后结束；
+// 其他错误(如 ErrDropped)返回后继续，此时 Event 为零值。与 Events 同名的方法已返回 channel，故命名为 All。
+// 可在多个 goroutine 中同时迭代，每个事件只会交给其中一个，与 WaitEvent 等共用同一个缓存
+func (w *Watcher) All(ctx context.Context) iter.Seq2[Event, error] {
+	return func(yield func(Event, error) bool) {
+		if !w.initialized() {
+			yield(Event{}, ErrNotInitialized)
+			return
+		}
+		stop := context.AfterFunc(ctx, w.wakeWaiters)
+		defer stop()
+		for {
+			e, err := w.nextContext(ctx)
+			if err == ErrClosed {
+				return
+			}
+			if !yield(e, err) || (err != nil && ctx.Err() != nil) {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,58 @@
+// @@
+// @ Author       : Eacher
+// @ Date         : 2026-10-17 12:53:19
+// @ LastEditTime : 2026-10-24 10:04:25
+// @ LastEditors  : Eacher
+// @ --------------------------------------------------------------------------------<
+// @ Description  : Event 的 JSON 编码
+// @ --------------------------------------------------------------------------------<
+// @ FilePath     : /inotify/json.go
+// @@
+package inotify
+
+import (
+	"time"
+	"encoding/json"
+)
+
+// eventJSON Event 的 JSON 字段，字段名保持不变。Group、Data、Count 不编码
+type eventJSON struct {
+	Path 	string 		`json:"path"`
+	Op 		string 		`json:"op"`
+	RawMask uint32 		`json:"raw_mask"`
+	Cookie 	uint32 		`json:"cookie"`
+	IsDir 	bool 		`json:"is_dir"`
+	Time 	time.Time 	`json:"time"`
+	// WithDeliveryTime 时才有
+	Delivered *time.Time `json:"delivered,omitempty"`
+	Seq 	uint64 		`json:"seq"`
+}
+
+// MarshalJSON 编码为 {"path", "op", "raw_mask", "cookie", "is_dir", "time", "seq"}，op 同 Op.String，Delivered 不为零时还有 "delivered"
+func (e Event) MarshalJSON() ([]byte, error) {
+	v := eventJSON{Path: e.Path(), Op: e.Op.String(), RawMask: e.Raw, Cookie: e.Cookie, IsDir: e.IsDir(), Time: e.Time, Seq: e.Seq}
+	if !e.Delivered.IsZero() {
+		v.Delivered = &e.Delivered
+	}
+	return json.Marshal(v)
+}
+
+// UnmarshalJSON 解码 MarshalJSON 的结果，is_dir 为 true 时 Raw 包含 IN_ISDIR
+func (e *Event) UnmarshalJSON(data []byte) error {
+	var v eventJSON
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	op, err := ParseOp(v.Op)
+	if err != nil {
+		return err
+	}
+	*e = Event{FileName: v.Path, Op: op, Raw: v.RawMask, Cookie: v.Cookie, Time: v.Time, Seq: v.Seq}
+	if v.IsDir {
+		e.Raw |= IN_ISDIR
+	}
+	if v.Delivered != nil {
+		e.Delivered = *v.Delivered
+	}
+	return nil
+}
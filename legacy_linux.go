@@ -0,0 +1,53 @@
+// @@
+// @ Author       : Eacher
+// @ Date         : 2026-10-15 16:55:12
+// @ LastEditTime : 2026-10-23 15:07:11
+// @ LastEditors  : Eacher
+// @ --------------------------------------------------------------------------------<
+// @ Description  : 旧版 WaitEvent、WatchSingle 兼容层，语义保持不变，新功能只加在 Event 上
+// @ --------------------------------------------------------------------------------<
+// @ FilePath     : /inotify/legacy_linux.go
+// @@
+package inotify
+
+// WaitEvent 兼容旧版接口，与 WaitEventTimeout 共用同一个缓存，FileName 与 Event 相同在第一个 NUL 处截断。
+// 新代码使用 WaitEventTimeout 或 Events
+func (w *Watcher) WaitEvent() (WatchSingle, error) {
+	if !w.initialized() {
+		return WatchSingle{}, ErrNotInitialized
+	}
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	ws, _, err := w.wait(-1)
+	return ws, err
+}
+
+// GetEventName 同 Event.GetEventName
+func (ws WatchSingle) GetEventName() string {
+	return Event{wd: ws.watchId, Raw: ws.Mask}.GetEventName()
+}
+
+// Group 同 Event.Group
+func (ws WatchSingle) Group() string {
+	return ws.group
+}
+
+// Data 同 Event.Data
+func (ws WatchSingle) Data() any {
+	return ws.data
+}
+
+// Count 同 Event.Count
+func (ws WatchSingle) Count() int {
+	return ws.count
+}
+
+// IsDir 同 Event.IsDir，由每个事件的 IN_ISDIR 得到，而不是添加监听时的路径类型
+func (ws WatchSingle) IsDir() bool {
+	return ws.Mask&IN_ISDIR != 0
+}
+
+// Cookie 同 Event.Cookie
+func (ws WatchSingle) Cookie() uint32 {
+	return ws.cookie
+}
@@ -0,0 +1,29 @@
+// @@
+// @ Author       : Eacher
+// @ Date         : 2026-10-15 16:55:12
+// @ LastEditTime : 2026-10-16 13:51:45
+// @ LastEditors  : Eacher
+// @ --------------------------------------------------------------------------------<
+// @ Description  : 旧版 WaitEvent、EventBody 兼容层，语义保持不变，新功能只加在 Event 上
+// @ --------------------------------------------------------------------------------<
+// @ FilePath     : /inotify/legacy_windows.go
+// @@
+package inotify
+
+// EventBody 旧版 WaitEvent 返回的事件，Mask 为 FILE_ACTION
+type EventBody struct {
+	wd 			uint32
+	FileName 	string
+	Mask 		uint32
+}
+
+// GetEventName 同 Event.GetEventName
+func (eb EventBody) GetEventName() string {
+	return Event{Raw: eb.Mask}.GetEventName()
+}
+
+// WaitEvent 兼容旧版接口，新代码使用 WaitEventTimeout 或 Events
+func (w *Watcher) WaitEvent() (EventBody, error) {
+	e, err := w.next()
+	return EventBody{wd: e.wd, FileName: e.FileName, Mask: e.Raw}, err
+}
@@ -0,0 +1,54 @@
+// @@
+// @ Author       : Eacher
+// @ Date         : 2026-10-20 20:52:16
+// @ LastEditTime : 2026-10-21 12:17:43
+// @ LastEditors  : Eacher
+// @ --------------------------------------------------------------------------------<
+// @ Description  : 限制监听数量，超过时移除最久没有事件的监听
+// @ --------------------------------------------------------------------------------<
+// @ FilePath     : /inotify/limit_linux.go
+// @@
+package inotify
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// WithMaxWatches 最多 n 个调用者添加的监听(递归监听的每个目录各算一个)，添加新的监听时已有 n 个则先移除
+// 最久没有读到事件的监听，再调用 evicted(可以为 nil)。evicted 在持有锁时调用，不能再调用该 Watcher 的方法。
+// 用于避免一个调用者用完 max_user_watches，n 小于等于 0 时不限制
+func WithMaxWatches(n int, evicted func(path string)) Option {
+	return func(w *Watcher) {
+		w.maxWatches, w.evicted = n, evicted
+	}
+}
+
+// timed 是否需要记录每个监听最近的活动时间
+func (w *Watcher) timed() bool {
+	return w.idle != nil || w.maxWatches > 0
+}
+
+// evict 添加 path 之前已有 maxWatches 个监听时移除最久没有事件的一个，path 已监听时不移除，调用者需持有 mutex
+func (w *Watcher) evict(path string) {
+	var n int
+	var oldest *WatchSingle
+	for _, ws := range w.watchMap {
+		if ws.remove || ws.flags == 0 {
+			continue
+		}
+		if ws.path == path || ws.path == path+string(os.PathSeparator) {
+			return
+		}
+		if n++; oldest == nil || ws.active.Before(oldest.active) {
+			oldest = ws
+		}
+	}
+	if n < w.maxWatches {
+		return
+	}
+	w.unwatch(oldest)
+	if w.evicted != nil {
+		w.evicted(filepath.Clean(oldest.path))
+	}
+}
@@ -0,0 +1,370 @@
+// @@
+// @ Author       : Eacher
+// @ Date         : 2026-10-17 17:53:55
+// @ LastEditTime : 2026-10-23 18:24:36
+// @ LastEditors  : Eacher
+// @ --------------------------------------------------------------------------------<
+// @ Description  : 根据监听事件把目录同步到另一个目录
+// @ --------------------------------------------------------------------------------<
+// @ FilePath     : /inotify/mirror/mirror_linux.go
+// @@
+package mirror
+
+import (
+	"io"
+	"os"
+	"log"
+	"sync"
+	"time"
+	"errors"
+	"strings"
+	"path/filepath"
+	"github.com/20yyq/inotify"
+)
+
+const mask = inotify.IN_CREATE|inotify.IN_CLOSE_WRITE|inotify.IN_ATTRIB|inotify.IN_DELETE|inotify.IN_MOVED_FROM|inotify.IN_MOVED_TO
+
+// 等待与 MOVED_FROM 相同 cookie 的 MOVED_TO 的时间，超时视为移出了 Src
+const moveTimeout = time.Millisecond*100
+
+// fileState 写入 Dst 时文件的大小与修改时间，用于发现 Dst 中被其他程序修改的文件
+type fileState struct {
+	size 	int64
+	mtime 	time.Time
+}
+
+// Mirror 保持 Dst 与 Src 一致: 新建、写入完成后复制，删除时删除，Src 内的 rename 在 Dst 中同样 rename。
+// Dst 中被其他程序修改过的文件仍以 Src 为准覆盖或删除，并记录冲突日志。字段需在 Start 之前设置
+type Mirror struct {
+	Src 	string
+	Dst 	string
+	// 冲突与错误的日志，nil 时为 log.Default()
+	Logger 	*log.Logger
+
+	w 		*inotify.Watcher
+	// 已写入 Dst 的文件，key 为相对路径
+	synced 	map[string]fileState
+	// 等待 MOVED_TO 的 MOVED_FROM，key 为 cookie
+	moves 	map[uint32]string
+	done 	chan struct{}
+	once 	sync.Once
+}
+
+// New 创建 Mirror，Start 之后开始同步
+func New(src, dst string) *Mirror {
+	return &Mirror{Src: src, Dst: dst}
+}
+
+// Start 监听 Src，全部同步一次后在后台根据事件同步，直到 Close
+func (m *Mirror) Start() (err error) {
+	if m.Src, err = filepath.Abs(m.Src); err != nil {
+		return err
+	}
+	if m.Dst, err = filepath.Abs(m.Dst); err != nil {
+		return err
+	}
+	// 一个在另一个之中时，同步会复制 Dst 自身或把 Src 当作 Dst 中多余的文件删除
+	if within(m.Src, m.Dst) || within(m.Dst, m.Src) {
+		return errors.New("The mirror Src and Dst overlap")
+	}
+	if m.Logger == nil {
+		m.Logger = log.Default()
+	}
+	m.synced, m.moves, m.done = make(map[string]fileState), make(map[uint32]string), make(chan struct{})
+	if m.w, err = inotify.NewWatcher(); err != nil {
+		return err
+	}
+	// 先监听再同步，同步期间的变化由之后的事件处理
+	if err = m.w.AddRecursiveWatch(m.Src, mask); err == nil {
+		err = m.sync()
+	}
+	if err != nil {
+		m.w.Close()
+		return err
+	}
+	go m.loop()
+	return nil
+}
+
+// sync 全部同步: 复制 Src 中新的或不同的文件，删除 Dst 中 Src 没有的文件
+func (m *Mirror) sync() error {
+	if err := os.MkdirAll(m.Dst, 0755); err != nil {
+		return err
+	}
+	seen := map[string]bool{}
+	err := filepath.Walk(m.Src, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			// 遍历期间被删除的文件忽略
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		rel, _ := filepath.Rel(m.Src, path)
+		seen[rel] = true
+		if rel != "." {
+			m.copy(rel, true)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	var extra []string
+	filepath.Walk(m.Dst, func(path string, info os.FileInfo, err error) error {
+		if err == nil && info.IsDir() && path == m.Src {
+			return filepath.SkipDir
+		}
+		if rel, _ := filepath.Rel(m.Dst, path); err == nil && !seen[rel] {
+			extra = append(extra, rel)
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+		}
+		return nil
+	})
+	for _, rel := range extra {
+		m.remove(rel)
+	}
+	return nil
+}
+
+func (m *Mirror) loop() {
+	defer close(m.done)
+	var timer <-chan time.Time
+	for {
+		select {
+		case e, ok := <-m.w.Events():
+			if !ok {
+				return
+			}
+			m.handle(e)
+			if len(m.moves) > 0 && timer == nil {
+				timer = time.After(moveTimeout)
+			}
+		case <-timer:
+			timer = nil
+			for cookie, rel := range m.moves {
+				delete(m.moves, cookie)
+				m.remove(rel)
+			}
+		}
+	}
+}
+
+func (m *Mirror) handle(e inotify.Event) {
+	if e.Raw&inotify.IN_Q_OVERFLOW != 0 {
+		m.logf("events overflow, full sync")
+		if err := m.sync(); err != nil {
+			m.logf("sync: %v", err)
+		}
+		return
+	}
+	rel, err := filepath.Rel(m.Src, e.FileName)
+	if err != nil || rel == "." || !within(m.Src, e.FileName) {
+		return
+	}
+	if e.Raw&inotify.IN_MOVED_TO != 0 {
+		if from, ok := m.moves[e.Cookie]; ok {
+			delete(m.moves, e.Cookie)
+			m.rename(from, rel)
+			return
+		}
+	}
+	// 同一路径之前移出的文件先删除，以免覆盖之后新建的文件
+	for cookie, from := range m.moves {
+		if from == rel || strings.HasPrefix(from, rel+string(filepath.Separator)) {
+			delete(m.moves, cookie)
+			m.remove(from)
+		}
+	}
+	switch {
+	case e.Raw&inotify.IN_MOVED_FROM != 0:
+		m.moves[e.Cookie] = rel
+	case e.Raw&inotify.IN_DELETE != 0:
+		m.remove(rel)
+	case e.Raw&(inotify.IN_CREATE|inotify.IN_MOVED_TO) != 0:
+		// 移入的目录中已有的文件不会产生事件
+		if e.IsDir() {
+			m.copyTree(rel)
+			return
+		}
+		m.copy(rel, false)
+	case e.Raw&(inotify.IN_CLOSE_WRITE|inotify.IN_ATTRIB) != 0:
+		m.copy(rel, false)
+	}
+}
+
+// within path 是否为 dir 或在 dir 之中，..a 这样的文件名不是上级目录
+func within(dir, path string) bool {
+	rel, err := filepath.Rel(dir, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+func (m *Mirror) logf(format string, args ...any) {
+	m.Logger.Printf("mirror: "+format, args...)
+}
+
+// conflict Dst 中的 rel 是否被其他程序修改过
+func (m *Mirror) conflict(rel string, info os.FileInfo) bool {
+	st, ok := m.synced[rel]
+	if !ok || info.IsDir() {
+		return false
+	}
+	return st.size != info.Size() || !st.mtime.Equal(info.ModTime())
+}
+
+// copy 复制 Src 中的 rel 到 Dst，目录只创建不复制内容。initial 为全部同步时，Dst 中已有的相同文件跳过
+func (m *Mirror) copy(rel string, initial bool) {
+	src, dst := filepath.Join(m.Src, rel), filepath.Join(m.Dst, rel)
+	info, err := os.Lstat(src)
+	if err != nil {
+		// 已被删除或移走，之后的事件会处理
+		return
+	}
+	if old, err := os.Lstat(dst); err == nil {
+		if !info.IsDir() && old.Mode().IsRegular() && info.Mode().IsRegular() && old.Size() == info.Size() && old.ModTime().Equal(info.ModTime()) {
+			m.synced[rel] = fileState{old.Size(), old.ModTime()}
+			return
+		}
+		if initial && old.ModTime().After(info.ModTime()) {
+			m.logf("conflict %s: newer in %s, overwritten", rel, m.Dst)
+		} else if m.conflict(rel, old) {
+			m.logf("conflict %s: modified in %s, overwritten", rel, m.Dst)
+		}
+		if old.IsDir() != info.IsDir() {
+			m.logf("conflict %s: type differs in %s, replaced", rel, m.Dst)
+			os.RemoveAll(dst)
+		} else if old.IsDir() {
+			os.Chmod(dst, info.Mode().Perm())
+			return
+		}
+	}
+	if err = m.write(src, dst, info); err != nil {
+		m.logf("copy %s: %v", rel, err)
+		return
+	}
+	if info, err = os.Lstat(dst); err == nil && info.Mode().IsRegular() {
+		m.synced[rel] = fileState{info.Size(), info.ModTime()}
+	}
+}
+
+// write 先写入临时文件再 rename，Dst 中不会出现写了一半的文件
+func (m *Mirror) write(src, dst string, info os.FileInfo) error {
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+	switch {
+	case info.IsDir():
+		return os.Mkdir(dst, info.Mode().Perm())
+	case info.Mode()&os.ModeSymlink != 0:
+		link, err := os.Readlink(src)
+		if err != nil {
+			return err
+		}
+		os.Remove(dst)
+		return os.Symlink(link, dst)
+	case !info.Mode().IsRegular():
+		// 设备、管道等不复制
+		return nil
+	}
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	tmp, err := os.CreateTemp(filepath.Dir(dst), "."+filepath.Base(dst)+".mirror")
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(tmp, in)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Chmod(tmp.Name(), info.Mode().Perm())
+	}
+	if err == nil {
+		// 保留修改时间，全部同步时以大小与修改时间判断是否相同
+		err = os.Chtimes(tmp.Name(), info.ModTime(), info.ModTime())
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), dst)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+	}
+	return err
+}
+
+// copyTree 复制 Src 中的目录 rel 及其下所有文件
+func (m *Mirror) copyTree(rel string) {
+	filepath.Walk(filepath.Join(m.Src, rel), func(path string, info os.FileInfo, err error) error {
+		if err == nil {
+			r, _ := filepath.Rel(m.Src, path)
+			m.copy(r, false)
+		}
+		return nil
+	})
+}
+
+// remove 删除 Dst 中的 rel
+func (m *Mirror) remove(rel string) {
+	dst := filepath.Join(m.Dst, rel)
+	info, err := os.Lstat(dst)
+	if err != nil {
+		return
+	}
+	if m.conflict(rel, info) {
+		m.logf("conflict %s: modified in %s, removed", rel, m.Dst)
+	}
+	if err = os.RemoveAll(dst); err != nil {
+		m.logf("remove %s: %v", rel, err)
+	}
+	m.forget(rel)
+}
+
+// rename 在 Dst 中 rename，Dst 中没有 from 时复制 to
+func (m *Mirror) rename(from, to string) {
+	src, dst := filepath.Join(m.Dst, from), filepath.Join(m.Dst, to)
+	if _, err := os.Lstat(src); err != nil {
+		m.copyTree(to)
+		return
+	}
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err == nil {
+		if err = os.Rename(src, dst); err == nil {
+			prefix := from + string(filepath.Separator)
+			for rel, st := range m.synced {
+				if rel == from || strings.HasPrefix(rel, prefix) {
+					delete(m.synced, rel)
+					m.synced[to+rel[len(from):]] = st
+				}
+			}
+			return
+		}
+	}
+	// 例如 to 是已有的非空目录，重新复制
+	m.remove(from)
+	m.copyTree(to)
+}
+
+// forget 清除 rel 及其下文件的记录
+func (m *Mirror) forget(rel string) {
+	prefix := rel + string(filepath.Separator)
+	for r := range m.synced {
+		if r == rel || strings.HasPrefix(r, prefix) {
+			delete(m.synced, r)
+		}
+	}
+}
+
+// Close 停止同步
+func (m *Mirror) Close() (err error) {
+	m.once.Do(func() {
+		if m.w != nil {
+			err = m.w.Close()
+			<-m.done
+		}
+	})
+	return err
+}
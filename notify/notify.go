@@ -0,0 +1,124 @@
+// @@
+// @ Author       : Eacher
+// @ Date         : 2026-10-14 18:26:41
+// @ LastEditTime : 2026-10-14 18:55:51
+// @ LastEditors  : Eacher
+// @ --------------------------------------------------------------------------------<
+// @ Description  : 事件批量通知，模板渲染后交给 Sender 发送
+// @ --------------------------------------------------------------------------------<
+// @ FilePath     : /inotify/notify/notify.go
+// @@
+package notify
+
+import (
+	"os"
+	"sync"
+	"time"
+	"bytes"
+	"strings"
+	"text/template"
+	"path/filepath"
+	"github.com/20yyq/inotify"
+)
+
+// Sender 发送渲染好的通知
+type Sender interface {
+	Send(subject, body string) error
+}
+
+// Batch 模板的数据
+type Batch struct {
+	Host 	string
+	Time 	time.Time
+	Events 	[]inotify.Event
+}
+
+const (
+	DefaultSubject 	= `[{{.Host}}] {{len .Events}} file events`
+	DefaultBody 	= `{{range .Events}}{{.GetEventName}} {{.FileName}}
+{{end}}`
+)
+
+var funcs = template.FuncMap{
+	"base": filepath.Base,
+	"dir": filepath.Dir,
+	"join": strings.Join,
+}
+
+// Sink 收集事件，每 Interval 渲染一次并发送，Interval 为 0 时需手动 Flush
+type Sink struct {
+	subject 	*template.Template
+	body 		*template.Template
+	sender 		Sender
+	interval 	time.Duration
+
+	mutex 		sync.Mutex
+	events 		[]inotify.Event
+	timer 		*time.Timer
+
+	// OnError 定时发送失败时调用，该批事件不再重发
+	OnError 	func(err error)
+}
+
+// NewSink subject、body 为 text/template 模板，空字符串使用默认模板
+func NewSink(sender Sender, interval time.Duration, subject, body string) (*Sink, error) {
+	if subject == "" {
+		subject = DefaultSubject
+	}
+	if body == "" {
+		body = DefaultBody
+	}
+	s := &Sink{sender: sender, interval: interval}
+	var err error
+	if s.subject, err = template.New("subject").Funcs(funcs).Parse(subject); err != nil {
+		return nil, err
+	}
+	if s.body, err = template.New("body").Funcs(funcs).Parse(body); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Add 加入一个事件，距离上次发送 Interval 后发送本批事件
+func (s *Sink) Add(e inotify.Event) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.events = append(s.events, e)
+	if s.interval > 0 && s.timer == nil {
+		s.timer = time.AfterFunc(s.interval, func() {
+			if err := s.Flush(); err != nil && s.OnError != nil {
+				s.OnError(err)
+			}
+		})
+	}
+}
+
+// Flush 立即发送已收集的事件，没有事件时不发送
+func (s *Sink) Flush() error {
+	s.mutex.Lock()
+	events := s.events
+	s.events = nil
+	if s.timer != nil {
+		s.timer.Stop()
+		s.timer = nil
+	}
+	s.mutex.Unlock()
+	if len(events) == 0 {
+		return nil
+	}
+	return s.Notify(events)
+}
+
+// Notify 渲染并发送一批事件
+func (s *Sink) Notify(events []inotify.Event) error {
+	host, _ := os.Hostname()
+	b := Batch{Host: host, Time: time.Now(), Events: events}
+	var subject, body bytes.Buffer
+	if err := s.subject.Execute(&subject, b); err != nil {
+		return err
+	}
+	if err := s.body.Execute(&body, b); err != nil {
+		return err
+	}
+	return s.sender.Send(strings.TrimSpace(subject.String()), body.String())
+}
@@ -0,0 +1,76 @@
+// @@
+// @ Author       : Eacher
+// @ Date         : 2026-10-14 18:26:41
+// @ LastEditTime : 2026-10-24 09:08:31
+// @ LastEditors  : Eacher
+// @ --------------------------------------------------------------------------------<
+// @ Description  : SMTP 与 webhook 通知发送
+// @ --------------------------------------------------------------------------------<
+// @ FilePath     : /inotify/notify/sender.go
+// @@
+package notify
+
+import (
+	"fmt"
+	"mime"
+	"bytes"
+	"strings"
+	"net/smtp"
+	"net/http"
+	"encoding/json"
+)
+
+// SMTPSender 通过 SMTP 发送纯文本邮件
+type SMTPSender struct {
+	// host:port
+	Addr 	string
+	Auth 	smtp.Auth
+	From 	string
+	To 		[]string
+}
+
+// Send subject 来自模板，可能含有文件名，CR、LF 替换为空格后按 RFC 2047 编码，不能加入其他头部
+func (s *SMTPSender) Send(subject, body string) error {
+	subject = strings.NewReplacer("\r\n", " ", "\r", " ", "\n", " ").Replace(subject)
+	var msg bytes.Buffer
+	fmt.Fprintf(&msg, "From: %s\r\nTo: %s\r\nSubject: %s\r\n", s.From, strings.Join(s.To, ", "), mime.QEncoding.Encode("utf-8", subject))
+	msg.WriteString("MIME-Version: 1.0\r\nContent-Type: text/plain; charset=UTF-8\r\n\r\n")
+	msg.WriteString(strings.ReplaceAll(strings.ReplaceAll(body, "\r\n", "\n"), "\n", "\r\n"))
+	return smtp.SendMail(s.Addr, s.Auth, s.From, s.To, msg.Bytes())
+}
+
+// WebhookSender 以 JSON {"subject": ..., "text": ...} POST 到 URL
+type WebhookSender struct {
+	URL 	string
+	Header 	http.Header
+	// nil 使用 http.DefaultClient
+	Client 	*http.Client
+}
+
+func (s *WebhookSender) Send(subject, body string) error {
+	data, err := json.Marshal(map[string]string{"subject": subject, "text": body})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, s.URL, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	for k, v := range s.Header {
+		req.Header[k] = v
+	}
+	req.Header.Set("Content-Type", "application/json")
+	client := s.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("webhook %s: %s", s.URL, resp.Status)
+	}
+	return nil
+}
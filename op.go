@@ -0,0 +1,71 @@
+// @@
+// @ Author       : Eacher
+// @ Date         : 2026-10-16 12:42:16
+// @ LastEditTime : 2026-10-22 12:34:32
+// @ LastEditors  : Eacher
+// @ --------------------------------------------------------------------------------<
+// @ Description  : 与平台无关的简化操作类型
+// @ --------------------------------------------------------------------------------<
+// @ FilePath     : /inotify/op.go
+// @@
+package inotify
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Op 由 Event.Raw 得到的操作，可能同时包含多个，OPEN、ACCESS、CLOSE_NOWRITE 等没有对应的 Op
+type Op uint32
+
+const (
+	// Create 新建或移入
+	Create Op = 1 << iota
+	// Write 修改内容或写入后关闭
+	Write
+	// Remove 删除
+	Remove
+	// Rename 移出或自身被移动
+	Rename
+	// Chmod 属性修改
+	Chmod
+	// Unmount 监听所在的文件系统被卸载，之后该监听被移除
+	Unmount
+)
+
+// Has 是否包含 o
+func (op Op) Has(o Op) bool {
+	return op&o != 0
+}
+
+var opNames = []struct{ op Op; name string }{{Create, "CREATE"}, {Write, "WRITE"}, {Remove, "REMOVE"}, {Rename, "RENAME"}, {Chmod, "CHMOD"}, {Unmount, "UNMOUNT"}}
+
+func (op Op) String() string {
+	var list []string
+	for _, v := range opNames {
+		if op.Has(v.op) {
+			list = append(list, v.name)
+		}
+	}
+	return strings.Join(list, "|")
+}
+
+// ParseOp 解析 String 的结果，空字符串为 0
+func ParseOp(s string) (Op, error) {
+	var op Op
+	for _, name := range strings.Split(s, "|") {
+		if name == "" {
+			continue
+		}
+		found := false
+		for _, v := range opNames {
+			if v.name == name {
+				op, found = op|v.op, true
+			}
+		}
+		if !found {
+			return 0, fmt.Errorf("The Op %q unknown", name)
+		}
+	}
+	return op, nil
+}
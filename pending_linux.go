@@ -0,0 +1,142 @@
+// @@
+// @ Author       : Eacher
+// @ Date         : 2026-10-19 12:11:18
+// @ LastEditTime : 2026-10-20 14:55:27
+// @ LastEditors  : Eacher
+// @ --------------------------------------------------------------------------------<
+// @ Description  : 监听还不存在的路径，由最近的已存在的上级目录等待其出现
+// @ --------------------------------------------------------------------------------<
+// @ FilePath     : /inotify/pending_linux.go
+// @@
+package inotify
+
+import (
+	"os"
+	"errors"
+	"unsafe"
+	"strings"
+	"path/filepath"
+	"golang.org/x/sys/unix"
+)
+
+// 上级目录中等待的路径出现时的事件
+const pendingMask = unix.IN_CREATE|unix.IN_MOVED_TO
+
+// WithPendingWatches AddWatch、AddWatchData、AddWatchGroup 的路径不存在时不返回错误，改为监听最近的已存在的上级目录，
+// 路径出现后自动添加监听并补发一个该路径的 CREATE 事件。上级目录中调用者没有监听的事件不会交给调用者，
+// 还未出现的路径可以由 RemoveWatch 取消
+func WithPendingWatches() Option {
+	return func(w *Watcher) {
+		w.pending = make(map[string]*pendingWatch)
+	}
+}
+
+// pendingWatch 等待出现的路径与添加监听时的参数
+type pendingWatch struct {
+	path 	string
+	flags 	uint32
+	set 	func(*WatchSingle)
+	// 正在等待的最近的已存在的上级目录
+	parent 	*WatchSingle
+}
+
+// resolve 添加 p 的监听，路径还不存在时改为监听最近的已存在的上级目录，调用者需持有 mutex
+func (w *Watcher) resolve(p *pendingWatch) error {
+	for {
+		ws, err := w.add(p.path, p.flags, p.set)
+		if err == nil {
+			delete(w.pending, p.path)
+			w.setParent(p, nil)
+			mask := IN_CREATE
+			if ws.isDir {
+				mask |= IN_ISDIR
+			}
+			w.pushInjected(Event{wd: ws.watchId, FileName: p.path, Raw: mask, Op: Create, Group: ws.group, Data: ws.data})
+			return nil
+		}
+		if !errors.Is(err, unix.ENOENT) {
+			delete(w.pending, p.path)
+			w.setParent(p, nil)
+			return err
+		}
+		child, dir := p.path, filepath.Dir(p.path)
+		for {
+			wd, _, err := w.backend.Add(dir, pendingMask|unix.IN_ONLYDIR|unix.IN_MASK_ADD|unix.IN_DONT_FOLLOW)
+			if err == nil {
+				name := dir
+				if !strings.HasSuffix(name, string(os.PathSeparator)) {
+					name += string(os.PathSeparator)
+				}
+				parent := w.watchOf(uint32(wd), name, true)
+				parent.extra |= pendingMask
+				w.pending[p.path] = p
+				w.setParent(p, parent)
+				break
+			}
+			if (!errors.Is(err, unix.ENOENT) && !errors.Is(err, unix.ENOTDIR)) || dir == filepath.Dir(dir) {
+				delete(w.pending, p.path)
+				w.setParent(p, nil)
+				return err
+			}
+			child, dir = dir, filepath.Dir(dir)
+		}
+		// 添加上级目录的监听之前 child 可能已经出现
+		if info, err := os.Lstat(child); err != nil || (child != p.path && !info.IsDir()) {
+			return nil
+		}
+	}
+}
+
+// setParent p 改为等待 ws，原来的上级目录按还需要的事件重新设置，调用者需持有 mutex
+func (w *Watcher) setParent(p *pendingWatch, ws *WatchSingle) {
+	old := p.parent
+	p.parent = ws
+	if old != nil && old != ws {
+		w.restore(old)
+	}
+}
+
+// unpend 取消等待 path，调用者需持有 mutex
+func (w *Watcher) unpend(path string) bool {
+	p, ok := w.pending[path]
+	if ok {
+		delete(w.pending, path)
+		w.setParent(p, nil)
+	}
+	return ok
+}
+
+// arrived 从 start 开始新读到的事件中找出等待的路径或其上级目录的出现，调用者需持有 mutex
+func (w *Watcher) arrived(start uint32) {
+	var appeared []string
+	var lost []*WatchSingle
+	for offset := start; offset+unix.SizeofInotifyEvent <= w.bufferItem; {
+		event := (*unix.InotifyEvent)(unsafe.Pointer(&w.eventBuffer[offset]))
+		size := uint32(unix.SizeofInotifyEvent) + event.Len
+		if ws, ok := w.owner(event.Wd, offset); ok && ws.extra&pendingMask != 0 {
+			if event.Mask&pendingMask != 0 && 0 < event.Len {
+				appeared = append(appeared, ws.path+string(nameOf(w.eventBuffer[offset+unix.SizeofInotifyEvent:offset+size])))
+			}
+			if event.Mask&unix.IN_IGNORED != 0 {
+				lost = append(lost, ws)
+			}
+		}
+		offset += size
+	}
+	// 上级目录被删除或移除，改为等待更上一级
+	for _, ws := range lost {
+		for _, p := range w.pending {
+			if p.parent == ws {
+				p.parent = nil
+				w.resolve(p)
+			}
+		}
+	}
+	for _, path := range appeared {
+		for _, p := range w.pending {
+			if p.path == path || strings.HasPrefix(p.path, path+string(os.PathSeparator)) {
+				w.resolve(p)
+			}
+		}
+	}
+}
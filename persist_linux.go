@@ -0,0 +1,155 @@
+// @@
+// @ Author       : Eacher
+// @ Date         : 2026-10-20 19:32:08
+// @ LastEditTime : 2026-10-21 10:46:20
+// @ LastEditors  : Eacher
+// @ --------------------------------------------------------------------------------<
+// @ Description  : Close 时保存快照，下次启动时对比，补发进程不在时的变化
+// @ --------------------------------------------------------------------------------<
+// @ FilePath     : /inotify/persist_linux.go
+// @@
+package inotify
+
+import (
+	"os"
+	"sort"
+	"errors"
+	"io/fs"
+	"encoding/json"
+	"path/filepath"
+)
+
+// WithSnapshotFile 同 WithSnapshot，并在 Close 时把快照保存到 file。NewWatcher 时读取 file，之后每添加一个监听，
+// 对比该路径与目录中的文件与保存时的区别，按监听的事件补发 CREATE、DELETE、MODIFY: inode 变化时为 DELETE 与 CREATE，
+// 大小或修改时间变化时为 MODIFY。补发的事件在添加监听的调用返回前加入，不经过 WithEventFilter。
+// 递归监听时删除的目录只有目录自身的 DELETE，新建的目录中的文件各有一个 CREATE。file 不存在时(第一次启动)不补发
+func WithSnapshotFile(file string) Option {
+	return func(w *Watcher) {
+		w.snapshotFile = file
+		if w.tree == nil {
+			w.tree = make(map[string]FileAttr)
+		}
+	}
+}
+
+// savedSnapshot WithSnapshotFile 保存的内容，Watches 为保存时的监听
+type savedSnapshot struct {
+	Watches []string 				`json:"watches"`
+	Entries map[string]FileAttr 	`json:"entries"`
+}
+
+// loadSnapshot 读取 saveSnapshot 保存的快照，file 不存在时为 nil
+func loadSnapshot(file string) (*savedSnapshot, error) {
+	b, err := os.ReadFile(file)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var v savedSnapshot
+	if err = json.Unmarshal(b, &v); err != nil {
+		return nil, errors.New("The snapshot file is invalid: " + err.Error())
+	}
+	return &v, nil
+}
+
+// saveSnapshot 先写入同一目录的临时文件再 rename，中途退出不会留下不完整的快照，调用者需持有 mutex
+func (w *Watcher) saveSnapshot() error {
+	v := savedSnapshot{Entries: w.tree}
+	for _, ws := range w.watchMap {
+		if !ws.remove && ws.flags != 0 {
+			v.Watches = append(v.Watches, filepath.Clean(ws.path))
+		}
+	}
+	sort.Strings(v.Watches)
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	f, err := os.CreateTemp(filepath.Dir(w.snapshotFile), filepath.Base(w.snapshotFile)+".*")
+	if err != nil {
+		return err
+	}
+	if _, err = f.Write(b); err == nil {
+		err = f.Sync()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), w.snapshotFile)
+	}
+	if err != nil {
+		os.Remove(f.Name())
+	}
+	return err
+}
+
+// restored 设置 NewWatcher 时读到的快照，replayed 中的监听为 true 时还未对比
+func (w *Watcher) restored(v *savedSnapshot) {
+	w.previous, w.replayed = v.Entries, make(map[string]bool, len(v.Watches))
+	if w.previous == nil {
+		w.previous = make(map[string]FileAttr)
+	}
+	for _, path := range v.Watches {
+		w.replayed[path] = true
+	}
+}
+
+// replay 对比新添加的 ws 的快照与保存的快照并补发事件，对比过的路径从 previous 中移除，调用者需持有 mutex。
+// 只对比保存时已有的监听，以及这些监听的递归监听中进程不在时新建的目录，每个监听只对比一次
+func (w *Watcher) replay(ws *WatchSingle) {
+	path := filepath.Clean(ws.path)
+	if waiting, ok := w.replayed[path]; ok && !waiting {
+		return
+	} else if _, parent := w.replayed[filepath.Dir(path)]; !ok && !(parent && ws.recursive) {
+		return
+	}
+	w.replayed[path] = false
+	// 目录只对比其中的文件，目录自身的变化由它的上级目录的监听补发
+	in := func(p string) bool {
+		if ws.isDir {
+			return p != path && filepath.Dir(p) == path
+		}
+		return p == path
+	}
+	var events []Event
+	emit := func(p string, mask uint32, isDir bool) {
+		if ws.flags&mask == 0 {
+			return
+		}
+		if isDir {
+			mask |= IN_ISDIR
+		}
+		events = append(events, Event{wd: ws.watchId, FileName: p, Raw: mask, Op: opOf(mask), Group: ws.group, Data: ws.data})
+	}
+	for p, cur := range w.tree {
+		if !in(p) {
+			continue
+		}
+		old, ok := w.previous[p]
+		switch {
+		case !ok:
+			emit(p, IN_CREATE, cur.Mode.IsDir())
+		case cur.Ino != old.Ino || cur.Mode.IsDir() != old.Mode.IsDir():
+			emit(p, IN_DELETE, old.Mode.IsDir())
+			emit(p, IN_CREATE, cur.Mode.IsDir())
+		case !cur.Mode.IsDir() && (cur.Size != old.Size || !cur.Mtime.Equal(old.Mtime)):
+			emit(p, IN_MODIFY, false)
+		}
+	}
+	for p, old := range w.previous {
+		if !in(p) {
+			continue
+		}
+		if _, ok := w.tree[p]; !ok {
+			emit(p, IN_DELETE, old.Mode.IsDir())
+		}
+		delete(w.previous, p)
+	}
+	sort.SliceStable(events, func(i, j int) bool { return events[i].FileName < events[j].FileName })
+	for _, e := range events {
+		w.pushInjected(e)
+	}
+}
@@ -0,0 +1,290 @@
+// @@
+// @ Author       : Eacher
+// @ Date         : 2026-10-20 09:04:54
+// @ LastEditTime : 2026-10-20 11:21:38
+// @ LastEditors  : Eacher
+// @ --------------------------------------------------------------------------------<
+// @ Description  : 定期对比 stat 的 WatchBackend，用于没有 inotify 的环境
+// @ --------------------------------------------------------------------------------<
+// @ FilePath     : /inotify/poll_linux.go
+// @@
+package inotify
+
+import (
+	"os"
+	"sort"
+	"time"
+	"path/filepath"
+	"golang.org/x/sys/unix"
+)
+
+// BackendPoll 默认的对比间隔
+const pollInterval = time.Second
+
+// WithPollInterval BackendPoll 对比监听路径的间隔，默认 1 秒，间隔内的多次修改只产生一个事件
+func WithPollInterval(d time.Duration) Option {
+	return func(w *Watcher) {
+		w.pollInterval = d
+	}
+}
+
+// poller 按路径轮询的后端，同一路径返回相同的 wd，路径被删除或替换为其他 inode 时产生 DELETE_SELF 并移除监听
+type poller struct {
+	*recordQueue
+	interval 	time.Duration
+	next 		int
+	watches 	map[int]*polled
+	paths 		map[string]int
+	stop 		chan struct{}
+}
+
+// polled 轮询的路径与上一次的 stat，entries 为目录上一次的内容
+type polled struct {
+	path 	string
+	mask 	uint32
+	follow 	bool
+	stat 	pollStat
+	entries map[string]pollStat
+}
+
+type pollStat struct {
+	ino 	uint64
+	size 	int64
+	mtime 	int64
+	ctime 	int64
+	mode 	uint32
+	isDir 	bool
+}
+
+func newPoller(interval time.Duration) (*poller, error) {
+	if interval <= 0 {
+		interval = pollInterval
+	}
+	q, err := newRecordQueue()
+	if err != nil {
+		return nil, err
+	}
+	p := &poller{recordQueue: q, interval: interval, next: 1, watches: make(map[int]*polled), paths: make(map[string]int), stop: make(chan struct{})}
+	go p.loop()
+	return p, nil
+}
+
+// statOf 与 inotify_add_watch 相同返回包含 ENOENT、ENOTDIR 等的错误
+func statOf(path string, follow bool) (pollStat, error) {
+	var st unix.Stat_t
+	if follow {
+		if err := unix.Stat(path, &st); err != nil {
+			return pollStat{}, os.NewSyscallError("stat", err)
+		}
+	} else if err := unix.Lstat(path, &st); err != nil {
+		return pollStat{}, os.NewSyscallError("lstat", err)
+	}
+	return pollStat{ino: st.Ino, size: st.Size, mtime: st.Mtim.Nano(), ctime: st.Ctim.Nano(), mode: st.Mode, isDir: st.Mode&unix.S_IFMT == unix.S_IFDIR}, nil
+}
+
+// entriesOf 目录 dir 的内容，读取失败时为 nil
+func entriesOf(dir string) map[string]pollStat {
+	f, err := os.Open(dir)
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+	names, _ := f.Readdirnames(-1)
+	m := make(map[string]pollStat, len(names))
+	for _, name := range names {
+		if st, err := statOf(filepath.Join(dir, name), false); err == nil {
+			m[name] = st
+		}
+	}
+	return m
+}
+
+func (p *poller) Add(path string, mask uint32) (int, bool, error) {
+	path = filepath.Clean(path)
+	follow := mask&unix.IN_DONT_FOLLOW == 0
+	st, err := statOf(path, follow)
+	if err != nil {
+		return -1, false, err
+	}
+	if mask&unix.IN_ONLYDIR != 0 && !st.isDir {
+		return -1, false, unix.ENOTDIR
+	}
+	var entries map[string]pollStat
+	if st.isDir {
+		entries = entriesOf(path)
+	}
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if p.closed {
+		return -1, false, unix.EBADF
+	}
+	events := mask&(unix.IN_ALL_EVENTS|unix.IN_ONESHOT|unix.IN_EXCL_UNLINK)
+	if wd, ok := p.paths[path]; ok {
+		if mask&unix.IN_MASK_CREATE != 0 {
+			return -1, false, unix.EEXIST
+		}
+		if mask&unix.IN_MASK_ADD != 0 {
+			events |= p.watches[wd].mask
+		}
+		p.watches[wd].mask = events
+		return wd, st.isDir, nil
+	}
+	wd := p.next
+	p.next++
+	p.watches[wd], p.paths[path] = &polled{path: path, mask: events, follow: follow, stat: st, entries: entries}, wd
+	return wd, st.isDir, nil
+}
+
+func (p *poller) Remove(wd int) error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if _, ok := p.watches[wd]; !ok {
+		return unix.EINVAL
+	}
+	p.drop(wd)
+	return nil
+}
+
+// drop 移除监听并产生 IN_IGNORED，调用者需持有 mutex
+func (p *poller) drop(wd int) {
+	delete(p.paths, p.watches[wd].path)
+	delete(p.watches, wd)
+	p.push(wd, unix.IN_IGNORED, 0, "")
+}
+
+func (p *poller) ReadEvents(buf []byte) (int, error) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	return p.read(buf)
+}
+
+func (p *poller) Fd() int {
+	return p.efd
+}
+
+func (p *poller) Close() error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if p.closed {
+		return unix.EBADF
+	}
+	p.closed = true
+	close(p.stop)
+	return unix.Close(p.efd)
+}
+
+func (p *poller) loop() {
+	t := time.NewTicker(p.interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-p.stop:
+			return
+		case <-t.C:
+			p.poll()
+		}
+	}
+}
+
+// poll 对比所有监听，stat 与读取目录时不持有 mutex
+func (p *poller) poll() {
+	p.mutex.Lock()
+	watches := make(map[int]*polled, len(p.watches))
+	for wd, pw := range p.watches {
+		watches[wd] = pw
+	}
+	p.mutex.Unlock()
+	for wd, pw := range watches {
+		st, err := statOf(pw.path, pw.follow)
+		var entries map[string]pollStat
+		if err == nil && st.isDir {
+			entries = entriesOf(pw.path)
+		}
+		p.mutex.Lock()
+		if p.watches[wd] == pw && !p.closed {
+			p.diff(wd, pw, st, err, entries)
+		}
+		p.mutex.Unlock()
+	}
+}
+
+// diff 由两次 stat 的区别产生事件，调用者需持有 mutex
+func (p *poller) diff(wd int, pw *polled, st pollStat, err error, entries map[string]pollStat) {
+	// emit 产生监听了的事件，IN_ONESHOT 的监听之后移除，返回监听是否仍然存在
+	emit := func(name string, mask uint32) bool {
+		if pw.mask&mask&unix.IN_ALL_EVENTS == 0 {
+			return true
+		}
+		p.push(wd, mask, 0, name)
+		if pw.mask&unix.IN_ONESHOT != 0 {
+			p.drop(wd)
+			return false
+		}
+		return true
+	}
+	if err != nil || st.ino != pw.stat.ino {
+		// 轮询无法区分删除与移走
+		if pw.mask&unix.IN_DELETE_SELF != 0 {
+			p.push(wd, unix.IN_DELETE_SELF, 0, "")
+		}
+		p.drop(wd)
+		return
+	}
+	if pw.stat.isDir {
+		names := make([]string, 0, len(entries)+len(pw.entries))
+		for name := range entries {
+			names = append(names, name)
+		}
+		for name := range pw.entries {
+			if _, ok := entries[name]; !ok {
+				names = append(names, name)
+			}
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			old, had := pw.entries[name]
+			cur, has := entries[name]
+			var isDir, isOldDir uint32
+			if cur.isDir {
+				isDir = unix.IN_ISDIR
+			}
+			if old.isDir {
+				isOldDir = unix.IN_ISDIR
+			}
+			ok := true
+			switch {
+			case !had:
+				ok = emit(name, unix.IN_CREATE|isDir)
+			case !has:
+				ok = emit(name, unix.IN_DELETE|isOldDir)
+			case cur.ino != old.ino:
+				if ok = emit(name, unix.IN_DELETE|isOldDir); ok {
+					ok = emit(name, unix.IN_CREATE|isDir)
+				}
+			default:
+				ok = p.changed(old, cur, func(mask uint32) bool { return emit(name, mask|isDir) })
+			}
+			if !ok {
+				return
+			}
+		}
+		pw.entries = entries
+	}
+	if !p.changed(pw.stat, st, func(mask uint32) bool { return emit("", mask) }) {
+		return
+	}
+	pw.stat = st
+}
+
+// changed 同一 inode 的内容或属性变化，目录只比较属性(内核不为子目录中的变化产生 MODIFY)
+func (p *poller) changed(old, cur pollStat, emit func(uint32) bool) bool {
+	modified := !cur.isDir && (cur.mtime != old.mtime || cur.size != old.size)
+	if modified && !emit(unix.IN_MODIFY) {
+		return false
+	}
+	// 写入同样会改变 ctime
+	if cur.mode != old.mode || (!modified && !cur.isDir && cur.ctime != old.ctime) {
+		return emit(unix.IN_ATTRIB)
+	}
+	return true
+}
@@ -0,0 +1,232 @@
+// @@
+// @ Author       : Eacher
+// @ Date         : 2026-10-20 11:21:38
+// @ LastEditTime : 2026-10-21 16:28:48
+// @ LastEditors  : Eacher
+// @ --------------------------------------------------------------------------------<
+// @ Description  : 多个 inotify fd 组成的后端，监听分散到各个实例，合并为一个事件流
+// @ --------------------------------------------------------------------------------<
+// @ FilePath     : /inotify/pool_linux.go
+// @@
+package inotify
+
+import (
+	"os"
+	"sync"
+	"unsafe"
+	"golang.org/x/sys/unix"
+)
+
+// 返回给 Watcher 的 wd 的低 poolBits 位为实例的序号
+const (
+	poolBits 	= 8
+	maxPool 	= 1<<poolBits
+)
+
+// WithInstancePool 每个 inotify 实例最多 n 个监听，更多的监听时新建实例，最多 256 个。
+// 每个实例有自己的事件队列(max_queued_events)，大的递归监听不容易 IN_Q_OVERFLOW，
+// 监听数量仍受所有实例共同的 max_user_watches 限制。不同实例的事件之间不保证顺序，
+// 在两个实例的目录之间 rename 时 MOVED_FROM 与 MOVED_TO 可能不相邻。n 小于等于 0 时只使用一个实例
+func WithInstancePool(n int) Option {
+	return func(w *Watcher) {
+		w.poolWatches = n
+	}
+}
+
+// kernelPool 同一 inode 总是在同一个实例中监听，使同一路径返回相同的 wd
+type kernelPool struct {
+	mutex 		sync.Mutex
+	// 所有实例的 fd 都在 epfd 中，epfd 由 Watcher 的 epoll 边缘触发
+	epfd 		int
+	per 		int
+	// 每个实例的 inotify_init1 标志
+	flags 		int
+	shards 		[]*poolShard
+	inodes 		map[inodeKey]int
+	watches 	map[int]inodeKey
+	// 下一次 ReadEvents 先读取的实例，轮流先读避免繁忙的实例使其他实例的队列溢出
+	next 		int
+	closed 		bool
+}
+
+type poolShard struct {
+	k 		kernel
+	// 该实例的监听数量，读到 IN_IGNORED 后减少
+	count 	int
+}
+
+type inodeKey struct {
+	dev 	uint64
+	ino 	uint64
+}
+
+// newKernelPool flags 中只使用 IN_CLOEXEC，ReadEvents 依次读取每个实例，实例总是非阻塞
+func newKernelPool(per, flags int) (*kernelPool, error) {
+	// epfd 为 Watcher 的 Fd，与 inotify fd 相同设置 close-on-exec
+	epollFlags := 0
+	if flags&unix.IN_CLOEXEC != 0 {
+		epollFlags = unix.EPOLL_CLOEXEC
+	}
+	epfd, err := unix.EpollCreate1(epollFlags)
+	if err != nil {
+		return nil, os.NewSyscallError("epoll_create1", err)
+	}
+	p := &kernelPool{epfd: epfd, per: per, flags: flags&unix.IN_CLOEXEC|unix.IN_NONBLOCK, inodes: make(map[inodeKey]int), watches: make(map[int]inodeKey)}
+	if _, err = p.grow(); err != nil {
+		unix.Close(epfd)
+		return nil, err
+	}
+	return p, nil
+}
+
+// grow 新建一个实例，调用者需持有 mutex
+func (p *kernelPool) grow() (int, error) {
+	k, err := newKernel(p.flags)
+	if err != nil {
+		return -1, err
+	}
+	if err = unix.EpollCtl(p.epfd, unix.EPOLL_CTL_ADD, int(k), &unix.EpollEvent{Fd: int32(k), Events: unix.EPOLLIN}); err != nil {
+		k.Close()
+		return -1, os.NewSyscallError("epoll_ctl", err)
+	}
+	p.shards = append(p.shards, &poolShard{k: k})
+	return len(p.shards)-1, nil
+}
+
+// shardOf 添加 key 的实例，已在某个实例中监听时为该实例，调用者需持有 mutex
+func (p *kernelPool) shardOf(key inodeKey) (int, error) {
+	if wd, ok := p.inodes[key]; ok {
+		return wd&(maxPool-1), nil
+	}
+	for i, s := range p.shards {
+		if s.count < p.per {
+			return i, nil
+		}
+	}
+	if len(p.shards) == maxPool {
+		// 实例已用完，放入监听最少的实例
+		least := 0
+		for i, s := range p.shards {
+			if s.count < p.shards[least].count {
+				least = i
+			}
+		}
+		return least, nil
+	}
+	return p.grow()
+}
+
+func (p *kernelPool) Add(path string, mask uint32) (int, bool, error) {
+	var st unix.Stat_t
+	var err error
+	if mask&unix.IN_DONT_FOLLOW != 0 {
+		err = unix.Lstat(path, &st)
+	} else {
+		err = unix.Stat(path, &st)
+	}
+	if err != nil {
+		// 与 kernel 相同
+		return -1, false, os.NewSyscallError("inotify_add_watch", err)
+	}
+	key := inodeKey{dev: st.Dev, ino: st.Ino}
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if p.closed {
+		return -1, false, os.NewSyscallError("inotify_add_watch", unix.EBADF)
+	}
+	i, err := p.shardOf(key)
+	if err != nil {
+		return -1, false, err
+	}
+	local, isDir, err := p.shards[i].k.Add(path, mask)
+	if err != nil {
+		return -1, false, err
+	}
+	wd := local<<poolBits|i
+	if _, ok := p.watches[wd]; !ok {
+		p.shards[i].count++
+	}
+	p.watches[wd], p.inodes[key] = key, wd
+	return wd, isDir, nil
+}
+
+func (p *kernelPool) Remove(wd int) error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if i := wd&(maxPool-1); i < len(p.shards) {
+		return p.shards[i].k.Remove(wd>>poolBits)
+	}
+	return os.NewSyscallError("inotify_rm_watch", unix.EINVAL)
+}
+
+// ReadEvents 依次读取每个实例，把事件的 wd 改为 Watcher 的 wd，所有实例都没有事件时返回 EAGAIN
+func (p *kernelPool) ReadEvents(buf []byte) (int, error) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if p.closed {
+		return 0, unix.EBADF
+	}
+	n, start := 0, p.next
+	p.next = (p.next+1)%len(p.shards)
+	for j := range p.shards {
+		i := (start+j)%len(p.shards)
+		m, err := p.shards[i].k.ReadEvents(buf[n:])
+		if err == unix.EAGAIN {
+			continue
+		}
+		if err != nil {
+			if n > 0 {
+				// buf 已放不下，剩下的由下一次读取
+				break
+			}
+			return 0, err
+		}
+		p.translate(i, buf[n:n+m])
+		n += m
+	}
+	if n == 0 {
+		return 0, unix.EAGAIN
+	}
+	return n, nil
+}
+
+// translate 改写实例 i 读到的事件的 wd，IN_IGNORED 之后该监听不再属于实例，调用者需持有 mutex
+func (p *kernelPool) translate(i int, buf []byte) {
+	for offset := 0; offset+unix.SizeofInotifyEvent <= len(buf); {
+		event := (*unix.InotifyEvent)(unsafe.Pointer(&buf[offset]))
+		offset += unix.SizeofInotifyEvent+int(event.Len)
+		if event.Wd < 0 {
+			// IN_Q_OVERFLOW
+			continue
+		}
+		event.Wd = event.Wd<<poolBits|int32(i)
+		if event.Mask&unix.IN_IGNORED == 0 {
+			continue
+		}
+		wd := int(event.Wd)
+		if key, ok := p.watches[wd]; ok {
+			delete(p.watches, wd)
+			if p.inodes[key] == wd {
+				delete(p.inodes, key)
+			}
+			p.shards[i].count--
+		}
+	}
+}
+
+func (p *kernelPool) Fd() int {
+	return p.epfd
+}
+
+func (p *kernelPool) Close() error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if p.closed {
+		return os.NewSyscallError("close", unix.EBADF)
+	}
+	p.closed = true
+	for _, s := range p.shards {
+		s.k.Close()
+	}
+	return os.NewSyscallError("close", unix.Close(p.epfd))
+}
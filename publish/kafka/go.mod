@@ -0,0 +1,16 @@
+module github.com/20yyq/inotify/publish/kafka
+
+go 1.23
+
+require (
+	github.com/20yyq/inotify v0.0.0
+	github.com/segmentio/kafka-go v0.4.51
+)
+
+require (
+	github.com/klauspost/compress v1.15.9 // indirect
+	github.com/pierrec/lz4/v4 v4.1.15 // indirect
+	golang.org/x/sys v0.15.0 // indirect
+)
+
+replace github.com/20yyq/inotify => ../../
@@ -0,0 +1,55 @@
+// @@
+// @ Author       : Eacher
+// @ Date         : 2026-10-17 15:55:26
+// @ LastEditTime : 2026-10-24 11:06:27
+// @ LastEditors  : Eacher
+// @ --------------------------------------------------------------------------------<
+// @ Description  : Kafka 发布，单独的模块，只有使用时才需要 kafka-go 依赖
+// @ --------------------------------------------------------------------------------<
+// @ FilePath     : /inotify/publish/kafka/kafka.go
+// @@
+package kafka
+
+import (
+	"context"
+	"github.com/segmentio/kafka-go"
+	"github.com/20yyq/inotify/publish"
+)
+
+var _ publish.Publisher = (*Publisher)(nil)
+
+// Writer *kafka.Writer 实现了该接口
+type Writer interface {
+	WriteMessages(ctx context.Context, msgs ...kafka.Message) error
+	Close() error
+}
+
+// Publisher 每批消息一次写入，重试由 publish.Forwarder 负责
+type Publisher struct {
+	w 	Writer
+}
+
+// NewPublisher w 为 nil 时按 brokers 创建，w 的 Topic 需为空，Publish 时为每条消息设置 topic
+func NewPublisher(w *kafka.Writer, brokers ...string) *Publisher {
+	if w == nil {
+		w = &kafka.Writer{Addr: kafka.TCP(brokers...), Balancer: &kafka.Hash{}, RequiredAcks: kafka.RequireAll, MaxAttempts: 1}
+	}
+	return &Publisher{w: w}
+}
+
+// NewWriterPublisher 使用任意的 Writer
+func NewWriterPublisher(w Writer) *Publisher {
+	return &Publisher{w: w}
+}
+
+func (p *Publisher) Publish(ctx context.Context, topic string, msgs [][]byte) error {
+	list := make([]kafka.Message, len(msgs))
+	for i, m := range msgs {
+		list[i] = kafka.Message{Topic: topic, Value: m}
+	}
+	return p.w.WriteMessages(ctx, list...)
+}
+
+func (p *Publisher) Close() error {
+	return p.w.Close()
+}
@@ -0,0 +1,61 @@
+//go:build linux
+// +build linux
+
+// @@
+// @ Author       : Eacher
+// @ Date         : 2026-10-24 10:04:25
+// @ LastEditTime : 2026-10-24 11:06:27
+// @ LastEditors  : Eacher
+// @ --------------------------------------------------------------------------------<
+// @ Description  : Kafka 发布测试，用假的 Writer 代替 broker
+// @ --------------------------------------------------------------------------------<
+// @ FilePath     : /inotify/publish/kafka/kafka_test.go
+// @@
+package kafka_test
+
+import (
+	"errors"
+	"context"
+	"testing"
+	kafkago "github.com/segmentio/kafka-go"
+	"github.com/20yyq/inotify/publish/kafka"
+)
+
+type fakeWriter struct {
+	err 	error
+	got 	[]kafkago.Message
+	closed 	bool
+}
+
+func (w *fakeWriter) WriteMessages(ctx context.Context, msgs ...kafkago.Message) error {
+	w.got = append(w.got, msgs...)
+	return w.err
+}
+
+func (w *fakeWriter) Close() error {
+	w.closed = true
+	return nil
+}
+
+func TestPublisher(t *testing.T) {
+	w := &fakeWriter{}
+	p := kafka.NewWriterPublisher(w)
+	if err := p.Publish(context.Background(), "files", [][]byte{[]byte("a"), []byte("b")}); err != nil {
+		t.Fatal("Publish", err)
+	}
+	if len(w.got) != 2 {
+		t.Fatal("messages", len(w.got))
+	}
+	for i, want := range []string{"a", "b"} {
+		if m := w.got[i]; m.Topic != "files" || string(m.Value) != want || m.Key != nil {
+			t.Fatal("message", i, m.Topic, string(m.Value), m.Key)
+		}
+	}
+	w.err = errors.New("unavailable")
+	if err := p.Publish(context.Background(), "files", [][]byte{[]byte("c")}); err != w.err {
+		t.Fatal("Publish error", err)
+	}
+	if p.Close(); !w.closed {
+		t.Fatal("Close")
+	}
+}
@@ -0,0 +1,135 @@
+// @@
+// @ Author       : Eacher
+// @ Date         : 2026-10-17 15:55:26
+// @ LastEditTime : 2026-10-17 16:51:49
+// @ LastEditors  : Eacher
+// @ --------------------------------------------------------------------------------<
+// @ Description  : NATS 发布，只实现发布需要的 core 协议(CONNECT、PUB、PING)
+// @ --------------------------------------------------------------------------------<
+// @ FilePath     : /inotify/publish/nats.go
+// @@
+package publish
+
+import (
+	"fmt"
+	"net"
+	"sync"
+	"time"
+	"bufio"
+	"errors"
+	"context"
+	"strings"
+	"encoding/json"
+)
+
+// NATSPublisher 每批消息之后发送 PING，收到 PONG 时服务端已处理这批消息。连接断开后下一次 Publish 重新连接
+type NATSPublisher struct {
+	// host:port
+	Addr 		string
+	User 		string
+	Pass 		string
+	Token 		string
+	// Timeout 连接与等待 PONG 的超时，默认 5s
+	Timeout 	time.Duration
+
+	mutex 		sync.Mutex
+	conn 		net.Conn
+	r 			*bufio.Reader
+}
+
+func (p *NATSPublisher) timeout() time.Duration {
+	if p.Timeout > 0 {
+		return p.Timeout
+	}
+	return time.Second*5
+}
+
+// connect 调用者需持有 mutex
+func (p *NATSPublisher) connect(ctx context.Context) error {
+	d := net.Dialer{Timeout: p.timeout()}
+	conn, err := d.DialContext(ctx, "tcp", p.Addr)
+	if err != nil {
+		return err
+	}
+	conn.SetDeadline(time.Now().Add(p.timeout()))
+	r := bufio.NewReader(conn)
+	// 服务端先发送 INFO
+	line, err := r.ReadString('\n')
+	if err != nil || !strings.HasPrefix(line, "INFO ") {
+		conn.Close()
+		return fmt.Errorf("nats %s: unexpected greeting %q %v", p.Addr, line, err)
+	}
+	opts := map[string]any{"verbose": false, "pedantic": false, "name": "inotify"}
+	for k, v := range map[string]string{"user": p.User, "pass": p.Pass, "auth_token": p.Token} {
+		if v != "" {
+			opts[k] = v
+		}
+	}
+	data, _ := json.Marshal(opts)
+	if _, err = fmt.Fprintf(conn, "CONNECT %s\r\n", data); err != nil {
+		conn.Close()
+		return err
+	}
+	p.conn, p.r = conn, r
+	return nil
+}
+
+func (p *NATSPublisher) Publish(ctx context.Context, topic string, msgs [][]byte) error {
+	if topic == "" || strings.ContainsAny(topic, " \t\r\n") {
+		return fmt.Errorf("nats: invalid subject %q", topic)
+	}
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if p.conn == nil {
+		if err := p.connect(ctx); err != nil {
+			return err
+		}
+	}
+	err := p.publish(topic, msgs)
+	if err != nil {
+		p.conn.Close()
+		p.conn, p.r = nil, nil
+	}
+	return err
+}
+
+func (p *NATSPublisher) publish(topic string, msgs [][]byte) error {
+	p.conn.SetDeadline(time.Now().Add(p.timeout()))
+	w := bufio.NewWriter(p.conn)
+	for _, m := range msgs {
+		fmt.Fprintf(w, "PUB %s %d\r\n", topic, len(m))
+		w.Write(m)
+		w.WriteString("\r\n")
+	}
+	w.WriteString("PING\r\n")
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	for {
+		line, err := p.r.ReadString('\n')
+		if err != nil {
+			return err
+		}
+		switch line = strings.TrimSpace(line); {
+		case line == "PONG":
+			return nil
+		case line == "PING":
+			if _, err = p.conn.Write([]byte("PONG\r\n")); err != nil {
+				return err
+			}
+		case strings.HasPrefix(line, "-ERR"):
+			return errors.New("nats: " + strings.TrimSpace(strings.TrimPrefix(line, "-ERR")))
+		}
+	}
+}
+
+func (p *NATSPublisher) Close() error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if p.conn == nil {
+		return nil
+	}
+	err := p.conn.Close()
+	p.conn, p.r = nil, nil
+	return err
+}
@@ -0,0 +1,185 @@
+// @@
+// @ Author       : Eacher
+// @ Date         : 2026-10-17 15:55:26
+// @ LastEditTime : 2026-10-24 11:06:27
+// @ LastEditors  : Eacher
+// @ --------------------------------------------------------------------------------<
+// @ Description  : 批量转发事件到消息队列，失败时退避重试
+// @ --------------------------------------------------------------------------------<
+// @ FilePath     : /inotify/publish/publish.go
+// @@
+package publish
+
+import (
+	"sync"
+	"time"
+	"context"
+	"encoding/json"
+	"github.com/20yyq/inotify"
+)
+
+// Publisher 把一批消息发送到 topic，返回 nil 时全部发送成功
+type Publisher interface {
+	Publish(ctx context.Context, topic string, msgs [][]byte) error
+	Close() error
+}
+
+// Forwarder 默认值
+const (
+	DefaultBatchSize 	= 100
+	DefaultInterval 	= time.Second
+	DefaultRetries 		= 5
+	DefaultBackoff 		= time.Millisecond*200
+	DefaultMaxBackoff 	= time.Second*10
+)
+
+// Forwarder 收集事件，满 BatchSize 个或距第一个事件 Interval 后发送一批，批次按顺序发送。
+// 字段需在第一次 Add 之前设置
+type Forwarder struct {
+	pub 		Publisher
+	topic 		string
+
+	BatchSize 	int
+	Interval 	time.Duration
+	// Retries 失败后的重试次数，每次等待 Backoff 并翻倍，不超过 MaxBackoff
+	Retries 	int
+	Backoff 	time.Duration
+	MaxBackoff 	time.Duration
+	// Encode 默认为 json.Marshal(Event.MarshalJSON)
+	Encode 		func(inotify.Event) ([]byte, error)
+	// OnError 一批事件重试后仍然失败时调用，这批事件被丢弃
+	OnError 	func(batch []inotify.Event, err error)
+
+	mutex 		sync.Mutex
+	events 		[]inotify.Event
+	timer 		*time.Timer
+	// take 时按顺序领取 ticket，publish 等到 turn 为自己的 ticket 才发送，保证批次按取出的顺序发送
+	ticket 		uint64
+	turn 		uint64
+	done 		*sync.Cond
+}
+
+func NewForwarder(pub Publisher, topic string) *Forwarder {
+	f := &Forwarder{pub: pub, topic: topic, BatchSize: DefaultBatchSize, Interval: DefaultInterval,
+		Retries: DefaultRetries, Backoff: DefaultBackoff, MaxBackoff: DefaultMaxBackoff}
+	f.done = sync.NewCond(&f.mutex)
+	return f
+}
+
+// Add 加入一个事件，批次已满时在当前 goroutine 中发送
+func (f *Forwarder) Add(e inotify.Event) {
+	f.mutex.Lock()
+	f.events = append(f.events, e)
+	if len(f.events) < f.BatchSize {
+		if f.timer == nil && f.Interval > 0 {
+			f.timer = time.AfterFunc(f.Interval, func() { f.Flush() })
+		}
+		f.mutex.Unlock()
+		return
+	}
+	batch, ticket := f.take()
+	f.mutex.Unlock()
+	f.publish(batch, ticket)
+}
+
+// Run 转发 events 直到 events 关闭或 ctx 取消，之后发送剩余的事件
+func (f *Forwarder) Run(ctx context.Context, events <-chan inotify.Event) {
+	defer f.Flush()
+	for {
+		select {
+		case e, ok := <-events:
+			if !ok {
+				return
+			}
+			f.Add(e)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// Flush 立即发送已收集的事件
+func (f *Forwarder) Flush() {
+	f.mutex.Lock()
+	batch, ticket := f.take()
+	f.mutex.Unlock()
+	f.publish(batch, ticket)
+}
+
+// take 调用者需持有 mutex，非空的批次领取一个 ticket
+func (f *Forwarder) take() ([]inotify.Event, uint64) {
+	batch := f.events
+	f.events = nil
+	if f.timer != nil {
+		f.timer.Stop()
+		f.timer = nil
+	}
+	if len(batch) == 0 {
+		return nil, 0
+	}
+	f.ticket++
+	return batch, f.ticket
+}
+
+// publish 等待之前领取 ticket 的批次发送完(包括重试)后再发送
+func (f *Forwarder) publish(batch []inotify.Event, ticket uint64) {
+	if len(batch) == 0 {
+		return
+	}
+	f.mutex.Lock()
+	for f.turn+1 != ticket {
+		f.done.Wait()
+	}
+	f.mutex.Unlock()
+	defer func() {
+		f.mutex.Lock()
+		f.turn = ticket
+		f.done.Broadcast()
+		f.mutex.Unlock()
+	}()
+	encode := f.Encode
+	if encode == nil {
+		encode = func(e inotify.Event) ([]byte, error) { return json.Marshal(e) }
+	}
+	msgs := make([][]byte, 0, len(batch))
+	for _, e := range batch {
+		data, err := encode(e)
+		if err != nil {
+			f.fail(batch, err)
+			return
+		}
+		msgs = append(msgs, data)
+	}
+	backoff := f.Backoff
+	for i := 0; ; i++ {
+		err := f.pub.Publish(context.Background(), f.topic, msgs)
+		if err == nil {
+			return
+		}
+		if i >= f.Retries {
+			f.fail(batch, err)
+			return
+		}
+		time.Sleep(backoff)
+		if backoff *= 2; f.MaxBackoff > 0 && backoff > f.MaxBackoff {
+			backoff = f.MaxBackoff
+		}
+	}
+}
+
+func (f *Forwarder) fail(batch []inotify.Event, err error) {
+	if f.OnError != nil {
+		f.OnError(batch, err)
+	}
+}
+
+// Close 发送剩余的事件(包括重试)后关闭 Publisher
+func (f *Forwarder) Close() error {
+	f.Flush()
+	f.mutex.Lock()
+	for f.turn != f.ticket {
+		f.done.Wait()
+	}
+	f.mutex.Unlock()
+	return f.pub.Close()
+}
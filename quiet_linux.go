@@ -0,0 +1,77 @@
+// @@
+// @ Author       : Eacher
+// @ Date         : 2026-10-20 15:51:21
+// @ LastEditTime : 2026-10-20 16:26:20
+// @ LastEditors  : Eacher
+// @ --------------------------------------------------------------------------------<
+// @ Description  : 目录一段时间没有变化时通知，用于一批文件到达后再开始处理
+// @ --------------------------------------------------------------------------------<
+// @ FilePath     : /inotify/quiet_linux.go
+// @@
+package inotify
+
+import (
+	"time"
+	"path/filepath"
+)
+
+// 目录及其子目录中的变化，读取(OPEN、ACCESS、CLOSE_NOWRITE)不算
+const quietMask = IN_CREATE|IN_DELETE|IN_MODIFY|IN_CLOSE_WRITE|IN_MOVE|IN_ATTRIB
+
+// QuietWatcher WatchQuiet 返回的监听
+type QuietWatcher struct {
+	// C 每一批变化之后目录安静了 quiet 时发送一次这批的最后一个事件的时间，没有及时读取时合并，Close 后关闭
+	C 		<-chan time.Time
+	c 		chan time.Time
+	w 		*Watcher
+	done 	chan struct{}
+}
+
+// WatchQuiet 递归监听 dir，目录中有变化且之后 quiet 内没有新的变化时由 C 通知，如 FTP 上传目录一批文件都已到达。
+// 只通知调用之后的变化，处理期间的变化在处理完成后的下一次读取 C 时得到
+func WatchQuiet(dir string, quiet time.Duration) (*QuietWatcher, error) {
+	var err error
+	if dir, err = filepath.Abs(dir); err != nil {
+		return nil, err
+	}
+	q := &QuietWatcher{c: make(chan time.Time, 1), done: make(chan struct{})}
+	q.C = q.c
+	if q.w, err = NewWatcher(); err != nil {
+		return nil, err
+	}
+	if err = q.w.AddRecursiveWatch(dir, quietMask); err != nil {
+		q.w.Close()
+		return nil, err
+	}
+	go q.loop(quiet)
+	return q, nil
+}
+
+func (q *QuietWatcher) loop(quiet time.Duration) {
+	defer close(q.done)
+	defer close(q.c)
+	var timer <-chan time.Time
+	var last time.Time
+	for {
+		select {
+		case e, ok := <-q.w.Events():
+			if !ok {
+				return
+			}
+			last, timer = e.Time, time.After(quiet)
+		case <-timer:
+			timer = nil
+			select {
+			case q.c <- last:
+			default:
+			}
+		}
+	}
+}
+
+// Close 停止监听并关闭 C
+func (q *QuietWatcher) Close() error {
+	err := q.w.Close()
+	<-q.done
+	return err
+}
@@ -0,0 +1,113 @@
+// @@
+// @ Author       : Eacher
+// @ Date         : 2026-10-15 16:10:30
+// @ LastEditTime : 2026-10-23 13:49:10
+// @ LastEditors  : Eacher
+// @ --------------------------------------------------------------------------------<
+// @ Description  : 按文件限制事件速率，超出的事件合并为一个汇总事件
+// @ --------------------------------------------------------------------------------<
+// @ FilePath     : /inotify/ratelimit.go
+// @@
+package inotify
+
+import (
+	"sync"
+	"time"
+)
+
+type rateLimit struct {
+	w 		*Watcher
+	rate 	float64
+	burst 	float64
+	mutex 	sync.Mutex
+	paths 	map[string]*tokens
+}
+
+// tokens 单个分组的令牌桶
+type tokens struct {
+	n 		float64
+	last 	time.Time
+	// 被合并的事件，count 大于 0 时 timer 已在等待
+	count 	int
+	mask 	uint32
+	e 		Event
+	timer 	*time.Timer
+}
+
+// WithRateLimit 每个文件每秒最多 rate 个事件，允许突发 burst 个。超出的事件不进入缓存，
+// 该文件重新有令牌时产生一个汇总事件，Count 为合并的数量，Raw 为这些事件 Raw 的并集，分组由 WithCoalesceKey 设置。
+// rate 不大于 0(或为 NaN)时无法补充令牌，不限速
+func WithRateLimit(rate float64, burst int) Option {
+	return func(w *Watcher) {
+		if !(rate > 0) {
+			return
+		}
+		if burst < 1 {
+			burst = 1
+		}
+		l := &rateLimit{w: w, rate: rate, burst: float64(burst), paths: make(map[string]*tokens)}
+		WithEventFilter(l.allow)(w)
+	}
+}
+
+// refill 调用者需持有 mutex
+func (l *rateLimit) refill(t *tokens, now time.Time) {
+	if t.n += now.Sub(t.last).Seconds()*l.rate; t.n > l.burst {
+		t.n = l.burst
+	}
+	t.last = now
+}
+
+func (l *rateLimit) allow(e Event) bool {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	now := time.Now()
+	key := l.w.key(e)
+	t, ok := l.paths[key]
+	if !ok {
+		if len(l.paths) > dedupPrune {
+			l.prune(now)
+		}
+		t = &tokens{n: l.burst, last: now}
+		l.paths[key] = t
+	}
+	l.refill(t, now)
+	if t.count == 0 && t.n >= 1 {
+		t.n--
+		return true
+	}
+	t.count++
+	t.mask |= e.Raw
+	t.e = e
+	if t.count == 1 {
+		wait := time.Duration((1 - t.n)/l.rate*float64(time.Second))
+		t.timer = time.AfterFunc(wait, func() { l.flush(key) })
+	}
+	return false
+}
+
+// flush 产生 key 的汇总事件
+func (l *rateLimit) flush(key string) {
+	l.mutex.Lock()
+	t, ok := l.paths[key]
+	if !ok || t.count == 0 {
+		l.mutex.Unlock()
+		return
+	}
+	l.refill(t, time.Now())
+	t.n--
+	e := t.e
+	e.Count, e.Raw, e.Op = t.count, t.mask, opOf(t.mask)
+	t.count, t.mask, t.e, t.timer = 0, 0, Event{}, nil
+	l.mutex.Unlock()
+	l.w.inject(e)
+}
+
+// prune 清理令牌已满的分组，调用者需持有 mutex
+func (l *rateLimit) prune(now time.Time) {
+	for key, t := range l.paths {
+		if l.refill(t, now); t.count == 0 && t.n >= l.burst {
+			delete(l.paths, key)
+		}
+	}
+}
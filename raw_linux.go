@@ -0,0 +1,66 @@
+// @@
+// @ Author       : Eacher
+// @ Date         : 2026-10-20 09:04:54
+// @ LastEditTime : 2026-10-20 09:37:31
+// @ LastEditors  : Eacher
+// @ --------------------------------------------------------------------------------<
+// @ Description  : 读取未解析的 inotify 事件
+// @ --------------------------------------------------------------------------------<
+// @ FilePath     : /inotify/raw_linux.go
+// @@
+package inotify
+
+import (
+	"errors"
+	"unsafe"
+	"golang.org/x/sys/unix"
+)
+
+// RawEvent 内核返回的 inotify_event，Name 为内核的名字，包含填充的 NUL，长度为 Len
+type RawEvent struct {
+	Wd 		int32
+	Mask 	uint32
+	Cookie 	uint32
+	Len 	uint32
+	Name 	[]byte
+}
+
+// ReadRaw 等待并取出缓存中所有从 inotify fd 读到的事件，不拼接路径也不转换 Op，由调用者按 Wd 自行处理。
+// 事件已经过 WithEventFilter 与暂停的分组，监听的状态照常更新(如 IN_IGNORED 之后移除该监听)，
+// 不来自 inotify fd 的事件(如 WithRateLimit 的汇总事件)仍由 WaitEvent 等取出。与 WaitEvent 等共用同一个缓存
+func (w *Watcher) ReadRaw() ([]RawEvent, error) {
+	if !w.initialized() {
+		return nil, ErrNotInitialized
+	}
+	if w.external {
+		return nil, errors.New("The Watcher is in external loop mode")
+	}
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	if w.dropped {
+		w.dropped = false
+		return nil, ErrDropped
+	}
+	for w.bufferItem == 0 {
+		if w.closes {
+			return nil, ErrClosed
+		}
+		w.waiters++
+		w.cond.Wait()
+		w.waiters--
+	}
+	var raws []RawEvent
+	for uint32(unix.SizeofInotifyEvent) <= w.buffered() {
+		event := (*unix.InotifyEvent)(unsafe.Pointer(&w.eventBuffer[w.bufferHead]))
+		offset := w.bufferHead+unix.SizeofInotifyEvent
+		r := RawEvent{Wd: event.Wd, Mask: event.Mask, Cookie: event.Cookie, Len: event.Len, Name: append([]byte(nil), w.eventBuffer[offset:offset+event.Len]...)}
+		// take 更新监听的状态，找不到监听时清空缓存
+		if ws, _, _ := w.take(); ws == nil {
+			break
+		}
+		// 与交给调用者的事件相同，占用序号并计入 Delivered
+		w.stamp(Event{})
+		raws = append(raws, r)
+	}
+	return raws, nil
+}
@@ -0,0 +1,146 @@
+// @@
+// @ Author       : Eacher
+// @ Date         : 2026-10-16 13:51:45
+// @ LastEditTime : 2026-10-20 14:55:27
+// @ LastEditors  : Eacher
+// @ --------------------------------------------------------------------------------<
+// @ Description  : 递归监听目录树，新建的子目录自动加入监听
+// @ --------------------------------------------------------------------------------<
+// @ FilePath     : /inotify/recursive_linux.go
+// @@
+package inotify
+
+import (
+	"io/fs"
+	"errors"
+	"unsafe"
+	"strings"
+	"path/filepath"
+	"golang.org/x/sys/unix"
+)
+
+type recursionLimit struct {
+	maxDepth 	int
+	maxDirs 	int
+	onLimit 	func(path string, err error)
+}
+
+// WithRecursionLimit 限制递归监听的深度(根目录为 0)与目录总数，0 不限制。
+// 超过深度的目录不监听；目录数量达到上限或内核监听数量不足(ENOSPC)时停止添加，
+// AddRecursiveWatch 返回错误，已添加的监听保留。onLimit 可以为 nil，自动监听新目录时在读取者 goroutine 中调用，不应阻塞
+func WithRecursionLimit(maxDepth, maxDirs int, onLimit func(path string, err error)) Option {
+	return func(w *Watcher) {
+		w.limit = recursionLimit{maxDepth: maxDepth, maxDirs: maxDirs, onLimit: onLimit}
+	}
+}
+
+// limited 调用 onLimit 并返回 err
+func (w *Watcher) limited(path string, err error) error {
+	if w.limit.onLimit != nil {
+		w.limit.onLimit(path, err)
+	}
+	return err
+}
+
+// newDir 递归监听中新出现的目录
+type newDir struct {
+	path 	string
+	flags 	uint32
+	group 	string
+	data 	any
+	depth 	int
+	// 子目录继承父目录的分组与数据
+	inherit bool
+	// 新建的目录补发其中已有内容的 CREATE，移入的目录只监听
+	emit 	bool
+}
+
+// AddRecursiveWatch 监听 path 及其下所有目录，之后新建或移入的子目录自动加入监听，flags 总是包含 IN_CREATE 与 IN_MOVED_TO。
+// 新建目录后、加入监听前在其中产生的文件以 CREATE 事件补发，补发的事件可能先于该目录自身的 CREATE 被读取
+func (w *Watcher) AddRecursiveWatch(path string, flags uint32) error {
+	if !w.initialized() {
+		return ErrNotInitialized
+	}
+	var err error
+	if path, err = filepath.Abs(path); err != nil {
+		return err
+	}
+	return w.watchTree(newDir{path: path, flags: flags|IN_CREATE|IN_MOVED_TO})
+}
+
+// watchTree 监听 d.path 下所有目录，子目录的错误(如已被删除)忽略
+func (w *Watcher) watchTree(d newDir) error {
+	return filepath.WalkDir(d.path, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			if path == d.path {
+				return err
+			}
+			return nil
+		}
+		depth := d.depth
+		if path != d.path {
+			depth += strings.Count(path[len(d.path):], string(filepath.Separator))
+		}
+		if entry.IsDir() && w.limit.maxDepth > 0 && depth > w.limit.maxDepth {
+			w.limited(path, ErrLimit)
+			return fs.SkipDir
+		}
+		if d.emit && path != d.path {
+			mask := IN_CREATE
+			if entry.IsDir() {
+				mask |= IN_ISDIR
+			}
+			w.inject(Event{FileName: path, Raw: mask, Op: Create, Group: d.group, Data: d.data})
+		}
+		if !entry.IsDir() {
+			return nil
+		}
+		w.mutex.Lock()
+		full := w.limit.maxDirs > 0 && w.recursiveDirs >= w.limit.maxDirs
+		w.mutex.Unlock()
+		if full {
+			return w.limited(path, ErrLimit)
+		}
+		err = w.watchPath(path, d.flags, func(ws *WatchSingle) {
+			if !ws.recursive {
+				ws.recursive = true
+				w.recursiveDirs++
+			}
+			ws.depth = depth
+			if d.inherit {
+				ws.group, ws.data = d.group, d.data
+			}
+		}, false)
+		if errors.Is(err, unix.ENOSPC) {
+			return w.limited(path, err)
+		}
+		if err != nil && path == d.path {
+			return err
+		}
+		return nil
+	})
+}
+
+// watchDirs 监听 newDirs 找到的目录，调用者不能持有 mutex
+func (w *Watcher) watchDirs(dirs []newDir) {
+	for _, d := range dirs {
+		w.watchTree(d)
+	}
+}
+
+// newDirs 找出从 start 开始新读到的事件中递归监听下新建或移入的目录，调用者需持有 mutex
+func (w *Watcher) newDirs(start uint32) []newDir {
+	var dirs []newDir
+	for offset := start; offset+unix.SizeofInotifyEvent <= w.bufferItem; {
+		event := (*unix.InotifyEvent)(unsafe.Pointer(&w.eventBuffer[offset]))
+		size := uint32(unix.SizeofInotifyEvent) + event.Len
+		if event.Mask&unix.IN_ISDIR != 0 && event.Mask&(unix.IN_CREATE|unix.IN_MOVED_TO) != 0 {
+			if ws, ok := w.owner(event.Wd, offset); ok && ws.recursive && 0 < event.Len {
+				name := string(nameOf(w.eventBuffer[offset+unix.SizeofInotifyEvent:offset+size]))
+				dirs = append(dirs, newDir{path: ws.path+name, flags: ws.flags, group: ws.group, data: ws.data, depth: ws.depth+1, inherit: true, emit: event.Mask&unix.IN_CREATE != 0})
+			}
+		}
+		offset += size
+	}
+	return dirs
+}
@@ -0,0 +1,21 @@
+// @@
+// @ Author       : Eacher
+// @ Date         : 2026-10-16 13:51:45
+// @ LastEditTime : 2026-10-16 15:08:30
+// @ LastEditors  : Eacher
+// @ --------------------------------------------------------------------------------<
+// @ Description  : 递归监听目录树
+// @ --------------------------------------------------------------------------------<
+// @ FilePath     : /inotify/recursive_windows.go
+// @@
+package inotify
+
+// WithRecursionLimit windows 不逐个目录监听，没有限制
+func WithRecursionLimit(maxDepth, maxDirs int, onLimit func(path string, err error)) Option {
+	return func(w *Watcher) {}
+}
+
+// AddRecursiveWatch ReadDirectoryChanges 本身已包含子目录，与 AddWatch 相同
+func (w *Watcher) AddRecursiveWatch(path string, flags uint32) error {
+	return w.AddWatch(path, flags)
+}
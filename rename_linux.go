@@ -0,0 +1,59 @@
+// @@
+// @ Author       : Eacher
+// @ Date         : 2026-10-18 18:40:51
+// @ LastEditTime : 2026-10-20 14:55:27
+// @ LastEditors  : Eacher
+// @ --------------------------------------------------------------------------------<
+// @ Description  : 在监听的目录之间 rename 时更新被移动的监听的路径
+// @ --------------------------------------------------------------------------------<
+// @ FilePath     : /inotify/rename_linux.go
+// @@
+package inotify
+
+import (
+	"os"
+	"unsafe"
+	"strings"
+	"golang.org/x/sys/unix"
+)
+
+// moveFrom 最后读到的 MOVED_FROM，等待相同 cookie 的 MOVED_TO
+type moveFrom struct {
+	cookie 	uint32
+	path 	string
+}
+
+// renames 从 start 开始新读到的事件中找出同一次 rename 的 MOVED_FROM 与 MOVED_TO，调用者需持有 mutex。
+// 只有两个目录都在监听中且监听了 IN_MOVE 时才能知道新的路径，移出监听范围的仍由 MOVE_SELF 移除
+func (w *Watcher) renames(start uint32) {
+	for offset := start; offset+unix.SizeofInotifyEvent <= w.bufferItem; {
+		event := (*unix.InotifyEvent)(unsafe.Pointer(&w.eventBuffer[offset]))
+		size := uint32(unix.SizeofInotifyEvent) + event.Len
+		if ws, ok := w.owner(event.Wd, offset); ok && 0 < event.Len && event.Mask&unix.IN_MOVE != 0 {
+			path := ws.path + string(nameOf(w.eventBuffer[offset+unix.SizeofInotifyEvent:offset+size]))
+			switch {
+			case event.Mask&unix.IN_MOVED_FROM != 0:
+				w.moveFrom = moveFrom{cookie: event.Cookie, path: path}
+			case event.Mask&unix.IN_MOVED_TO != 0 && event.Cookie == w.moveFrom.cookie && w.moveFrom.path != "":
+				w.rename(w.moveFrom.path, path)
+				w.moveFrom = moveFrom{}
+			}
+		}
+		offset += size
+	}
+}
+
+// rename 将 from 及其下所有监听的路径改为 to 下的路径，之后被移动的监听自身的 MOVE_SELF 不再移除监听，调用者需持有 mutex。
+// 缓存中还未取走的事件也使用新的路径
+func (w *Watcher) rename(from, to string) {
+	sep := string(os.PathSeparator)
+	for _, ws := range w.watchMap {
+		switch {
+		case ws.path == from || ws.path == from+sep:
+			ws.path = to + ws.path[len(from):]
+			ws.moved = ws.flags&unix.IN_MOVE_SELF != 0
+		case strings.HasPrefix(ws.path, from+sep):
+			ws.path = to + ws.path[len(from):]
+		}
+	}
+}
@@ -0,0 +1,195 @@
+// @@
+// @ Author       : Eacher
+// @ Date         : 2026-10-16 15:08:30
+// @ LastEditTime : 2026-10-16 16:24:05
+// @ LastEditors  : Eacher
+// @ --------------------------------------------------------------------------------<
+// @ Description  : 定期重新扫描监听的目录，补发 inotify 遗漏的事件
+// @ --------------------------------------------------------------------------------<
+// @ FilePath     : /inotify/rescan_linux.go
+// @@
+package inotify
+
+import (
+	"os"
+	"sort"
+	"sync"
+	"time"
+	"io/fs"
+	"path/filepath"
+)
+
+type fileState struct {
+	isDir 	bool
+	size 	int64
+	mod 	time.Time
+	mode 	fs.FileMode
+}
+
+// target 一次扫描中需要检查的监听
+type target struct {
+	path 		string
+	isDir 		bool
+	recursive 	bool
+	group 		string
+	data 		any
+}
+
+type rescanner struct {
+	interval 	time.Duration
+	mutex 		sync.Mutex
+	// 上一次扫描的结果与扫描过的监听，新加入的监听第一次扫描只记录不补发
+	snapshot 	map[string]fileState
+	scanned 	map[string]bool
+	// 两次扫描之间已收到事件的文件，这些文件的变化不再补发
+	touched 	map[string]bool
+	// 被内核移除的监听(如卸载)，继续扫描
+	lost 		map[string]target
+}
+
+// WithRescan 每隔 interval 扫描一次所有监听的目录与文件，与上一次的结果对比，
+// 为 inotify 没有报告的变化(队列溢出、卸载、子目录未能加入监听等)补发 CREATE、DELETE、MODIFY、ATTRIB 事件。
+// 补发的事件不经过 WithEventFilter，两次扫描之间已收到事件的文件不补发
+func WithRescan(interval time.Duration) Option {
+	return func(w *Watcher) {
+		r := &rescanner{interval: interval, touched: make(map[string]bool), lost: make(map[string]target)}
+		w.rescan = r
+		w.observers = append(w.observers, r.touch)
+	}
+}
+
+// touch 读取者中记录收到事件的文件
+func (r *rescanner) touch(e Event) {
+	r.mutex.Lock()
+	r.touched[filepath.Clean(e.FileName)] = true
+	r.mutex.Unlock()
+}
+
+// lose 记录被内核移除的监听，调用者需持有 Watcher 的 mutex
+func (r *rescanner) lose(ws *WatchSingle) {
+	r.mutex.Lock()
+	p := filepath.Clean(ws.path)
+	r.lost[p] = target{path: p, isDir: ws.isDir, recursive: ws.recursive, group: ws.group, data: ws.data}
+	r.mutex.Unlock()
+}
+
+func (w *Watcher) rescanLoop() {
+	ticker := time.NewTicker(w.rescan.interval)
+	defer ticker.Stop()
+	w.rescanOnce()
+	for {
+		select {
+		case <-ticker.C:
+			w.rescanOnce()
+		case <-w.closed():
+			return
+		}
+	}
+}
+
+// rescanOnce 扫描一次并补发事件
+func (w *Watcher) rescanOnce() {
+	r := w.rescan
+	w.mutex.Lock()
+	targets := make(map[string]target, len(w.watchMap))
+	for _, ws := range w.watchMap {
+		if !ws.remove {
+			p := filepath.Clean(ws.path)
+			targets[p] = target{path: p, isDir: ws.isDir, recursive: ws.recursive, group: ws.group, data: ws.data}
+		}
+	}
+	w.mutex.Unlock()
+	r.mutex.Lock()
+	for p, t := range r.lost {
+		if _, ok := targets[p]; !ok {
+			targets[p] = t
+		}
+	}
+	r.mutex.Unlock()
+
+	// listed 本次列出的目录，checked 本次检查过的文件
+	cur, listed, checked := make(map[string]fileState), make(map[string]target), make(map[string]target)
+	for _, t := range targets {
+		if !t.isDir {
+			checked[t.path] = t
+			if info, err := os.Lstat(t.path); err == nil {
+				cur[t.path] = stateOf(info)
+			}
+			continue
+		}
+		filepath.WalkDir(t.path, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return nil
+			}
+			if d.IsDir() {
+				if path != t.path && !t.recursive {
+					return fs.SkipDir
+				}
+				if _, ok := listed[path]; !ok {
+					listed[path] = t
+				}
+			}
+			if path != t.path {
+				if info, err := d.Info(); err == nil {
+					cur[path] = stateOf(info)
+				}
+			}
+			return nil
+		})
+	}
+
+	r.mutex.Lock()
+	old, touched, scanned := r.snapshot, r.touched, r.scanned
+	r.snapshot, r.touched, r.scanned = cur, make(map[string]bool), make(map[string]bool, len(targets))
+	for p := range targets {
+		r.scanned[p] = true
+	}
+	r.mutex.Unlock()
+	var events []Event
+	scope := func(p string) (target, bool) {
+		t, ok := checked[p]
+		if !ok {
+			t, ok = listed[filepath.Dir(p)]
+		}
+		return t, ok && scanned[t.path]
+	}
+	for p, s := range cur {
+		t, in := scope(p)
+		o, ok := old[p]
+		switch {
+		case !in || touched[p]:
+		case !ok:
+			events = append(events, t.event(p, IN_CREATE, Create, s.isDir))
+		case s.isDir != o.isDir:
+			events = append(events, t.event(p, IN_DELETE, Remove, o.isDir), t.event(p, IN_CREATE, Create, s.isDir))
+		case !s.isDir && (s.size != o.size || !s.mod.Equal(o.mod)):
+			events = append(events, t.event(p, IN_MODIFY, Write, false))
+		case s.mode != o.mode:
+			events = append(events, t.event(p, IN_ATTRIB, Chmod, s.isDir))
+		}
+	}
+	for p, o := range old {
+		if _, ok := cur[p]; ok || touched[p] {
+			continue
+		}
+		// 不在本次扫描范围内的(监听已移除)不补发
+		if t, ok := scope(p); ok {
+			events = append(events, t.event(p, IN_DELETE, Remove, o.isDir))
+		}
+	}
+	sort.SliceStable(events, func(i, j int) bool { return events[i].FileName < events[j].FileName })
+	for _, e := range events {
+		w.inject(e)
+	}
+}
+
+func (t target) event(path string, mask uint32, op Op, isDir bool) Event {
+	if isDir {
+		mask |= IN_ISDIR
+	}
+	return Event{FileName: path, Raw: mask, Op: op, Group: t.group, Data: t.data}
+}
+
+func stateOf(info fs.FileInfo) fileState {
+	return fileState{isDir: info.IsDir(), size: info.Size(), mod: info.ModTime(), mode: info.Mode()}
+}
@@ -0,0 +1,20 @@
+// @@
+// @ Author       : Eacher
+// @ Date         : 2026-10-16 15:08:30
+// @ LastEditTime : 2026-10-16 16:24:05
+// @ LastEditors  : Eacher
+// @ --------------------------------------------------------------------------------<
+// @ Description  : 定期重新扫描监听的目录，补发 inotify 遗漏的事件
+// @ --------------------------------------------------------------------------------<
+// @ FilePath     : /inotify/rescan_windows.go
+// @@
+package inotify
+
+import (
+	"time"
+)
+
+// WithRescan windows 暂不支持，不做任何处理
+func WithRescan(interval time.Duration) Option {
+	return func(w *Watcher) {}
+}
@@ -0,0 +1,89 @@
+// @@
+// @ Author       : Eacher
+// @ Date         : 2026-10-15 12:21:59
+// @ LastEditTime : 2026-10-23 09:39:44
+// @ LastEditors  : Eacher
+// @ --------------------------------------------------------------------------------<
+// @ Description  : 命令失败后按文件指数退避，避免单个问题文件反复触发
+// @ --------------------------------------------------------------------------------<
+// @ FilePath     : /inotify/runner/cooldown.go
+// @@
+package runner
+
+import (
+	"math"
+	"sort"
+	"time"
+	"github.com/20yyq/inotify"
+)
+
+// FailingPath 触发的命令连续失败的文件
+type FailingPath struct {
+	Path 		string
+	Failures 	int
+	// 在此之前该文件的事件不会触发规则
+	Until 		time.Time
+	Err 		error
+}
+
+// record 命令结束后更新 batch 中文件的失败状态，成功时清除
+func (eg *Engine) record(batch []inotify.Event, err error) {
+	if eg.Cooldown <= 0 {
+		return
+	}
+	eg.fmutex.Lock()
+	defer eg.fmutex.Unlock()
+	for _, e := range batch {
+		if err == nil {
+			delete(eg.failing, e.FileName)
+			continue
+		}
+		fp, ok := eg.failing[e.FileName]
+		if !ok {
+			fp = &FailingPath{Path: e.FileName}
+			eg.failing[e.FileName] = fp
+		} else if fp.Until.After(time.Now()) {
+			// 同一批次内重复的文件只计一次
+			continue
+		}
+		fp.Failures++
+		fp.Err = err
+		fp.Until = time.Now().Add(backoff(eg.Cooldown, eg.MaxCooldown, fp.Failures))
+	}
+}
+
+// backoff 第 failures 次连续失败后的暂停时间: base 每次翻倍，超过 time.Duration 的范围时为最大值，max 大于 0 时不超过 max
+func backoff(base, max time.Duration, failures int) time.Duration {
+	d := base
+	for i := 1; i < failures; i++ {
+		if d > math.MaxInt64/2 {
+			d = math.MaxInt64
+			break
+		}
+		d *= 2
+	}
+	if max > 0 && d > max {
+		d = max
+	}
+	return d
+}
+
+// cooling 文件是否处于失败后的暂停期
+func (eg *Engine) cooling(path string) bool {
+	eg.fmutex.Lock()
+	defer eg.fmutex.Unlock()
+	fp, ok := eg.failing[path]
+	return ok && time.Now().Before(fp.Until)
+}
+
+// FailingPaths 当前连续失败的文件，按路径排序
+func (eg *Engine) FailingPaths() []FailingPath {
+	eg.fmutex.Lock()
+	defer eg.fmutex.Unlock()
+	list := make([]FailingPath, 0, len(eg.failing))
+	for _, fp := range eg.failing {
+		list = append(list, *fp)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Path < list[j].Path })
+	return list
+}
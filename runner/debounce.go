@@ -0,0 +1,88 @@
+// @@
+// @ Author       : Eacher
+// @ Date         : 2026-10-17 18:58:51
+// @ LastEditTime : 2026-10-18 09:40:39
+// @ LastEditors  : Eacher
+// @ --------------------------------------------------------------------------------<
+// @ Description  : 与 entr 相同，事件停止一段时间后运行命令，命令仍在运行时结束并重新运行
+// @ --------------------------------------------------------------------------------<
+// @ FilePath     : /inotify/runner/debounce.go
+// @@
+package runner
+
+import (
+	"sync"
+	"time"
+	"github.com/20yyq/inotify"
+)
+
+// DefaultDebounce NewRunner 的默认等待时间
+const DefaultDebounce = time.Millisecond*100
+
+// Runner 匹配的事件停止 Debounce 后运行一次命令，期间的事件合并为一次，环境变量与 Engine 相同。
+// 默认 Policy 为 Restart，长时间运行的命令(如服务)被结束后重新运行。字段需在第一次 Dispatch 之前设置
+type Runner struct {
+	*Engine
+	Debounce 	time.Duration
+
+	rule 		*rule
+	mutex 		sync.Mutex
+	timer 		*time.Timer
+	batch 		[]inotify.Event
+	stopped 	bool
+}
+
+func NewRunner(command ...string) *Runner {
+	eg := NewEngine(Rule{Name: "run", Command: command, Policy: Restart})
+	return &Runner{Engine: eg, Debounce: DefaultDebounce, rule: eg.rules[0]}
+}
+
+// Rule 修改 Mask、Pattern、Policy
+func (r *Runner) Rule() *Rule {
+	return &r.rule.Rule
+}
+
+// Dispatch 事件匹配时重新开始等待
+func (r *Runner) Dispatch(e inotify.Event) {
+	if !r.rule.Match(e) || r.cooling(e.FileName) {
+		return
+	}
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if r.stopped {
+		return
+	}
+	r.batch = append(r.batch, e)
+	if r.timer != nil {
+		r.timer.Stop()
+	}
+	r.timer = time.AfterFunc(r.Debounce, r.fire)
+}
+
+// Run 立即运行一次命令，不等待事件，例如启动时先运行服务
+func (r *Runner) Run() {
+	r.Engine.trigger(r.rule, []inotify.Event{{}})
+}
+
+// fire 持有 mutex 触发，Stop 返回后不会再启动命令
+func (r *Runner) fire() {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	batch := r.batch
+	r.batch, r.timer = nil, nil
+	if len(batch) > 0 && !r.stopped {
+		r.Engine.trigger(r.rule, batch)
+	}
+}
+
+// Stop 丢弃等待中的事件，结束正在运行的命令并等待退出
+func (r *Runner) Stop() {
+	r.mutex.Lock()
+	r.stopped = true
+	if r.timer != nil {
+		r.timer.Stop()
+	}
+	r.batch, r.timer = nil, nil
+	r.mutex.Unlock()
+	r.Engine.Stop()
+}
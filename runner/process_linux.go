@@ -0,0 +1,25 @@
+// @@
+// @ Author       : Eacher
+// @ Date         : 2026-10-17 18:58:51
+// @ LastEditTime : 2026-10-18 09:40:39
+// @ LastEditors  : Eacher
+// @ --------------------------------------------------------------------------------<
+// @ Description  : 命令在自己的进程组中运行，结束时连同子进程一起结束
+// @ --------------------------------------------------------------------------------<
+// @ FilePath     : /inotify/runner/process_linux.go
+// @@
+package runner
+
+import (
+	"syscall"
+	"os/exec"
+)
+
+func setProcessGroup(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+}
+
+// kill 结束整个进程组，只结束 sh -c 时其子进程会继续运行并占用输出，Wait 不会返回
+func kill(cmd *exec.Cmd) {
+	syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+}
@@ -0,0 +1,21 @@
+// @@
+// @ Author       : Eacher
+// @ Date         : 2026-10-17 18:58:51
+// @ LastEditTime : 2026-10-18 09:40:39
+// @ LastEditors  : Eacher
+// @ --------------------------------------------------------------------------------<
+// @ Description  : windows 上只结束命令自身
+// @ --------------------------------------------------------------------------------<
+// @ FilePath     : /inotify/runner/process_windows.go
+// @@
+package runner
+
+import (
+	"os/exec"
+)
+
+func setProcessGroup(cmd *exec.Cmd) {}
+
+func kill(cmd *exec.Cmd) {
+	cmd.Process.Kill()
+}
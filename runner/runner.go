@@ -0,0 +1,275 @@
+// @@
+// @ Author       : Eacher
+// @ Date         : 2026-10-14 14:14:16
+// @ LastEditTime : 2026-10-21 13:40:35
+// @ LastEditors  : Eacher
+// @ --------------------------------------------------------------------------------<
+// @ Description  : 事件触发执行命令的规则引擎
+// @ --------------------------------------------------------------------------------<
+// @ FilePath     : /inotify/runner/runner.go
+// @@
+package runner
+
+import (
+	"io"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+	"strconv"
+	"strings"
+	"os/exec"
+	"path/filepath"
+	"github.com/20yyq/inotify"
+)
+
+// 命令运行时注入的环境变量
+const (
+	// 触发命令的最后一个事件的文件路径
+	EnvPath 	= "INOTIFY_PATH"
+	// 最后一个事件的名称，同 GetEventName
+	EnvOp 		= "INOTIFY_OP"
+	// 最后一个事件的 cookie，非 rename 事件为 0
+	EnvCookie 	= "INOTIFY_COOKIE"
+	// 合并到本次运行的所有文件路径，按触发顺序去重，以换行分隔
+	EnvBatch 	= "INOTIFY_BATCH"
+)
+
+// Policy 规则的命令仍在运行时又有事件触发的处理方式
+type Policy int
+
+const (
+	// Skip 丢弃运行期间的触发
+	Skip Policy = iota
+	// Queue 等待当前命令结束后再运行，期间的多次触发合并为一次
+	Queue
+	// Restart 结束正在运行的命令并立即重新运行
+	Restart
+)
+
+func (p Policy) String() string {
+	switch p {
+	case Skip:
+		return "skip"
+	case Queue:
+		return "queue"
+	case Restart:
+		return "restart"
+	}
+	return "unknown"
+}
+
+// Rule 事件匹配 Mask 与 Pattern 时执行 Command
+type Rule struct {
+	Name 		string
+	// 0 匹配所有事件
+	Mask 		uint32
+	// filepath.Match 匹配文件名，空匹配所有文件
+	Pattern 	string
+	// IgnoreCase Pattern 与文件名都转为小写后匹配，用于 SMB、NFS 导出的不区分大小写的文件系统产生的文件
+	IgnoreCase 	bool
+	// Normalize 不为 nil 时 Pattern 与文件名先经过它再匹配，如 norm.NFC.String 使 macOS 产生的 NFD 文件名与 NFC 的 Pattern 相同
+	Normalize 	func(string) string
+	// 包含 {{ 的参数为 text/template 模板，按事件展开，字段见 Vars
+	Command 	[]string
+	Policy 		Policy
+}
+
+// Match 事件是否触发该规则
+func (r *Rule) Match(e inotify.Event) bool {
+	if r.Mask != 0 && e.Raw&r.Mask == 0 {
+		return false
+	}
+	if r.Pattern != "" {
+		ok, _ := filepath.Match(r.fold(r.Pattern), r.fold(filepath.Base(e.FileName)))
+		return ok
+	}
+	return true
+}
+
+// fold 按 Normalize、IgnoreCase 转换 Pattern 或文件名
+func (r *Rule) fold(s string) string {
+	if r.Normalize != nil {
+		s = r.Normalize(s)
+	}
+	if r.IgnoreCase {
+		s = strings.ToLower(s)
+	}
+	return s
+}
+
+type rule struct {
+	Rule
+	mutex 	sync.Mutex
+	cmd 	*exec.Cmd
+	pending []inotify.Event
+	// 命令由 Restart 或 Stop 结束，不计为失败
+	killed 	bool
+}
+
+// Engine 按规则并发执行命令，每条规则同一时刻只运行一个命令
+type Engine struct {
+	rules 	[]*rule
+	wg 		sync.WaitGroup
+
+	Stdout 	io.Writer
+	Stderr 	io.Writer
+	// DryRun 只把展开后的命令输出到 Stdout，不运行
+	DryRun 	bool
+	// OnStart 每次触发命令时调用，之后总有一次对应的 OnExit
+	OnStart func(r *Rule, e inotify.Event)
+	// OnExit 每次命令结束时调用，err 为启动或运行的错误
+	OnExit 	func(r *Rule, e inotify.Event, err error)
+
+	// Cooldown 命令失败后触发它的文件暂停触发的时间，连续失败时翻倍，0 不启用
+	Cooldown 	time.Duration
+	// MaxCooldown 暂停时间的上限，0 不限制
+	MaxCooldown time.Duration
+	fmutex 		sync.Mutex
+	failing 	map[string]*FailingPath
+}
+
+func NewEngine(rules ...Rule) *Engine {
+	eg := &Engine{Stdout: os.Stdout, Stderr: os.Stderr, failing: make(map[string]*FailingPath)}
+	for _, r := range rules {
+		eg.rules = append(eg.rules, &rule{Rule: r})
+	}
+	return eg
+}
+
+// Dispatch 将事件交给所有匹配的规则
+func (eg *Engine) Dispatch(e inotify.Event) {
+	if eg.cooling(e.FileName) {
+		return
+	}
+	for _, r := range eg.rules {
+		if r.Match(e) {
+			eg.trigger(r, []inotify.Event{e})
+		}
+	}
+}
+
+// trigger batch 中的事件一起触发规则一次
+func (eg *Engine) trigger(r *rule, batch []inotify.Event) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if r.cmd == nil {
+		eg.start(r, batch)
+		return
+	}
+	switch r.Policy {
+	case Queue:
+		r.pending = append(r.pending, batch...)
+	case Restart:
+		r.pending = append(r.pending, batch...)
+		if r.cmd.Process != nil {
+			r.killed = true
+			kill(r.cmd)
+		}
+	}
+}
+
+// start 调用者需持有 r.mutex
+func (eg *Engine) start(r *rule, batch []inotify.Event) {
+	e := batch[len(batch)-1]
+	if len(r.Command) == 0 {
+		return
+	}
+	if eg.OnStart != nil {
+		eg.OnStart(&r.Rule, e)
+	}
+	args, err := expand(r.Command, varsOf(batch))
+	if err == nil && eg.DryRun {
+		quoted := make([]string, len(args))
+		for i, arg := range args {
+			quoted[i] = Quote(arg)
+		}
+		_, err = fmt.Fprintln(eg.Stdout, "dry-run:", strings.Join(quoted, " "))
+	}
+	if err != nil || eg.DryRun {
+		eg.exit(r, e, err)
+		return
+	}
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Stdout, cmd.Stderr = eg.Stdout, eg.Stderr
+	cmd.Env = append(os.Environ(), environ(batch)...)
+	setProcessGroup(cmd)
+	if err = cmd.Start(); err != nil {
+		eg.record(batch, err)
+		eg.exit(r, e, err)
+		return
+	}
+	r.cmd = cmd
+	eg.wg.Add(1)
+	go eg.wait(r, cmd, batch)
+}
+
+func (eg *Engine) wait(r *rule, cmd *exec.Cmd, batch []inotify.Event) {
+	defer eg.wg.Done()
+	err := cmd.Wait()
+	r.mutex.Lock()
+	killed := r.killed
+	r.killed = false
+	r.mutex.Unlock()
+	if !killed {
+		eg.record(batch, err)
+	}
+	eg.exit(r, batch[len(batch)-1], err)
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.cmd = nil
+	if len(r.pending) > 0 {
+		batch := r.pending
+		r.pending = nil
+		eg.start(r, batch)
+	}
+}
+
+// environ 以 batch 中最后一个事件为准，INOTIFY_BATCH 包含全部文件
+func environ(batch []inotify.Event) []string {
+	e := batch[len(batch)-1]
+	return []string{
+		EnvPath + "=" + e.FileName,
+		EnvOp + "=" + e.GetEventName(),
+		EnvCookie + "=" + strconv.FormatUint(uint64(e.Cookie), 10),
+		EnvBatch + "=" + strings.Join(batchNames(batch), "\n"),
+	}
+}
+
+// batchNames batch 中的文件路径，按触发顺序去重
+func batchNames(batch []inotify.Event) []string {
+	seen := make(map[string]bool, len(batch))
+	var names []string
+	for _, v := range batch {
+		if !seen[v.FileName] {
+			seen[v.FileName] = true
+			names = append(names, v.FileName)
+		}
+	}
+	return names
+}
+
+func (eg *Engine) exit(r *rule, e inotify.Event, err error) {
+	if eg.OnExit != nil {
+		eg.OnExit(&r.Rule, e, err)
+	}
+}
+
+// Stop 结束所有正在运行的命令，丢弃排队的触发并等待命令退出
+func (eg *Engine) Stop() {
+	for _, r := range eg.rules {
+		r.mutex.Lock()
+		r.pending = nil
+		if r.cmd != nil && r.cmd.Process != nil {
+			r.killed = true
+			kill(r.cmd)
+		}
+		r.mutex.Unlock()
+	}
+	eg.wg.Wait()
+}
+
+// Wait 等待所有命令(包括排队的)运行结束
+func (eg *Engine) Wait() {
+	eg.wg.Wait()
+}
@@ -0,0 +1,134 @@
+// @@
+// @ Author       : Eacher
+// @ Date         : 2026-10-18 09:40:39
+// @ LastEditTime : 2026-10-18 10:40:05
+// @ LastEditors  : Eacher
+// @ --------------------------------------------------------------------------------<
+// @ Description  : 命令参数中的模板按事件展开
+// @ --------------------------------------------------------------------------------<
+// @ FilePath     : /inotify/runner/template.go
+// @@
+package runner
+
+import (
+	"errors"
+	"strings"
+	"text/template"
+	"path/filepath"
+	"github.com/20yyq/inotify"
+)
+
+// Vars 命令模板中可用的字段，以触发命令的最后一个事件为准，例如 /src/img/a.jpg:
+// Path /src/img/a.jpg，Dir /src/img，Name a.jpg，Base a，Ext .jpg
+type Vars struct {
+	Path 	string
+	Dir 	string
+	Name 	string
+	Base 	string
+	Ext 	string
+	// 同 GetEventName
+	Op 		string
+	Cookie 	uint32
+	// 合并到本次运行的所有文件路径，同 INOTIFY_BATCH
+	Batch 	[]string
+}
+
+func varsOf(batch []inotify.Event) Vars {
+	e, names := batch[len(batch)-1], batchNames(batch)
+	name := filepath.Base(e.FileName)
+	ext := filepath.Ext(name)
+	return Vars{Path: e.FileName, Dir: filepath.Dir(e.FileName), Name: name, Base: strings.TrimSuffix(name, ext), Ext: ext, Op: e.GetEventName(), Cookie: e.Cookie, Batch: names}
+}
+
+var funcs = template.FuncMap{"quote": Quote}
+
+// Expand 展开 Command 中包含 {{ 的参数，每个参数展开后仍是一个参数，不经过 shell，文件名中的空格、引号等不会被解释。
+// 需要交给 sh -c 时用 {{quote .Path}} 转义
+func (r *Rule) Expand(e inotify.Event) ([]string, error) {
+	return expand(r.Command, varsOf([]inotify.Event{e}))
+}
+
+func expand(command []string, vars Vars) ([]string, error) {
+	args := make([]string, len(command))
+	for i, arg := range command {
+		if !strings.Contains(arg, "{{") {
+			args[i] = arg
+			continue
+		}
+		t, err := template.New("").Funcs(funcs).Option("missingkey=error").Parse(arg)
+		if err != nil {
+			return nil, err
+		}
+		var b strings.Builder
+		if err = t.Execute(&b, vars); err != nil {
+			return nil, err
+		}
+		args[i] = b.String()
+	}
+	return args, nil
+}
+
+// Quote 转义为 sh 的一个单引号参数
+func Quote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// SplitCommand 按 sh 的规则把命令拆分为参数，支持单引号、双引号与反斜杠，不支持变量与通配符。
+// 先拆分再展开模板，例如 "convert {{.Path}} out/{{.Base}}.png" 中的 {{.Path}} 总是一个参数
+func SplitCommand(s string) ([]string, error) {
+	var args []string
+	var b strings.Builder
+	word, quote := false, rune(0)
+	for i := 0; i < len(s); i++ {
+		c := rune(s[i])
+		end := -1
+		if quote != '\'' && strings.HasPrefix(s[i:], "{{") {
+			end = strings.Index(s[i:], "}}")
+		}
+		switch {
+		case end > 0:
+			// 模板中的空格与引号原样保留
+			b.WriteString(s[i:i+end+2])
+			i, word = i+end+1, true
+		case quote == '\'':
+			if c == '\'' {
+				quote = 0
+			} else {
+				b.WriteByte(s[i])
+			}
+		case c == '\\' && quote != '\'':
+			if i++; i == len(s) {
+				return nil, errors.New("The command ends with a backslash")
+			}
+			// 双引号中的反斜杠只转义 " \ $ `
+			if quote == '"' && !strings.ContainsRune("\"\\$`", rune(s[i])) {
+				b.WriteByte('\\')
+			}
+			b.WriteByte(s[i])
+			word = true
+		case quote == '"':
+			if c == '"' {
+				quote = 0
+			} else {
+				b.WriteByte(s[i])
+			}
+		case c == '\'' || c == '"':
+			quote, word = c, true
+		case c == ' ' || c == '\t' || c == '\n':
+			if word {
+				args, word = append(args, b.String()), false
+				b.Reset()
+			}
+		default:
+			b.WriteByte(s[i])
+			word = true
+		}
+	}
+	if quote != 0 {
+		return nil, errors.New("The command has an unterminated quote")
+	}
+	if word {
+		args = append(args, b.String())
+	}
+	return args, nil
+}
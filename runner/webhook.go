@@ -0,0 +1,191 @@
+// @@
+// @ Author       : Eacher
+// @ Date         : 2026-10-17 16:51:49
+// @ LastEditTime : 2026-10-21 12:46:23
+// @ LastEditors  : Eacher
+// @ --------------------------------------------------------------------------------<
+// @ Description  : 事件触发 POST JSON 到 webhook，带 HMAC 签名、重试与并发限制
+// @ --------------------------------------------------------------------------------<
+// @ FilePath     : /inotify/runner/webhook.go
+// @@
+package runner
+
+import (
+	"fmt"
+	"sync"
+	"time"
+	"bytes"
+	"errors"
+	"net/http"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"github.com/20yyq/inotify"
+)
+
+// webhook 请求的 header
+const (
+	// HeaderSignature sha256=<hex>，以 Secret 为 key 对请求 body 的 HMAC-SHA256
+	HeaderSignature 	= "X-Inotify-Signature-256"
+	// HeaderDelivery 事件的 Seq
+	HeaderDelivery 		= "X-Inotify-Delivery"
+)
+
+var (
+	// ErrQueueFull Webhook 的队列已满，事件被丢弃
+	ErrQueueFull 		= errors.New("The webhook queue is full")
+	// ErrWebhookClosed Close 之后的事件被丢弃
+	ErrWebhookClosed 	= errors.New("The webhook is closed")
+)
+
+// Webhook 默认值
+const (
+	DefaultConcurrency 	= 4
+	DefaultQueue 		= 100
+	DefaultRetries 		= 3
+	DefaultBackoff 		= time.Millisecond*500
+)
+
+// Webhook 事件匹配 Mask 与 Pattern(同 Rule)时以 JSON(Event.MarshalJSON) POST 到 URL。
+// 网络错误、429 与 5xx 会重试，其他状态码不重试。字段需在第一次 Dispatch 之前设置
+type Webhook struct {
+	URL 		string
+	// Secret 不为空时设置 HeaderSignature
+	Secret 		string
+	// 0 匹配所有事件
+	Mask 		uint32
+	// filepath.Match 匹配文件名，空匹配所有文件
+	Pattern 	string
+	// 同 Rule
+	IgnoreCase 	bool
+	Normalize 	func(string) string
+	Header 		http.Header
+	// nil 使用 http.DefaultClient
+	Client 		*http.Client
+	// Concurrency 同时进行的请求数量
+	Concurrency int
+	// Queue 等待发送的事件数量上限，已满时丢弃新事件
+	Queue 		int
+	// Retries 失败后的重试次数，每次等待 Backoff 并翻倍
+	Retries 	int
+	Backoff 	time.Duration
+	// OnError 事件重试后仍然失败或被丢弃时调用
+	OnError 	func(e inotify.Event, err error)
+
+	once 		sync.Once
+	queue 		chan inotify.Event
+	wg 			sync.WaitGroup
+	mutex 		sync.Mutex
+	closed 		bool
+}
+
+func NewWebhook(url, secret string) *Webhook {
+	return &Webhook{URL: url, Secret: secret, Concurrency: DefaultConcurrency, Queue: DefaultQueue, Retries: DefaultRetries, Backoff: DefaultBackoff}
+}
+
+func (h *Webhook) start() {
+	n := h.Concurrency
+	if n <= 0 {
+		n = 1
+	}
+	h.queue = make(chan inotify.Event, h.Queue)
+	for i := 0; i < n; i++ {
+		h.wg.Add(1)
+		go h.worker()
+	}
+}
+
+// Dispatch 匹配的事件加入队列，不等待发送
+func (h *Webhook) Dispatch(e inotify.Event) {
+	if !(&Rule{Mask: h.Mask, Pattern: h.Pattern, IgnoreCase: h.IgnoreCase, Normalize: h.Normalize}).Match(e) {
+		return
+	}
+	h.once.Do(h.start)
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	if h.closed {
+		h.fail(e, ErrWebhookClosed)
+		return
+	}
+	select {
+	case h.queue <- e:
+	default:
+		h.fail(e, ErrQueueFull)
+	}
+}
+
+func (h *Webhook) worker() {
+	defer h.wg.Done()
+	for e := range h.queue {
+		if err := h.deliver(e); err != nil {
+			h.fail(e, err)
+		}
+	}
+}
+
+func (h *Webhook) deliver(e inotify.Event) error {
+	body, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	backoff := h.Backoff
+	for i := 0; ; i++ {
+		retry, err := h.post(e, body)
+		if err == nil || !retry || i >= h.Retries {
+			return err
+		}
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+}
+
+// post 返回的 retry 表示错误是否可以重试
+func (h *Webhook) post(e inotify.Event, body []byte) (bool, error) {
+	req, err := http.NewRequest(http.MethodPost, h.URL, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	for k, v := range h.Header {
+		req.Header[k] = v
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(HeaderDelivery, fmt.Sprint(e.Seq))
+	if h.Secret != "" {
+		mac := hmac.New(sha256.New, []byte(h.Secret))
+		mac.Write(body)
+		req.Header.Set(HeaderSignature, "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	}
+	client := h.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return true, err
+	}
+	resp.Body.Close()
+	if resp.StatusCode/100 == 2 {
+		return false, nil
+	}
+	err = fmt.Errorf("webhook %s: %s", h.URL, resp.Status)
+	return resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500, err
+}
+
+func (h *Webhook) fail(e inotify.Event, err error) {
+	if h.OnError != nil {
+		h.OnError(e, err)
+	}
+}
+
+// Close 等待队列中的事件发送完成，之后的 Dispatch 丢弃事件
+func (h *Webhook) Close() {
+	h.once.Do(h.start)
+	h.mutex.Lock()
+	if !h.closed {
+		h.closed = true
+		close(h.queue)
+	}
+	h.mutex.Unlock()
+	h.wg.Wait()
+}
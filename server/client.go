@@ -0,0 +1,68 @@
+// @@
+// @ Author       : Eacher
+// @ Date         : 2026-10-17 14:04:17
+// @ LastEditTime : 2026-10-17 14:46:03
+// @ LastEditors  : Eacher
+// @ --------------------------------------------------------------------------------<
+// @ Description  : WatchService 的 Go 客户端
+// @ --------------------------------------------------------------------------------<
+// @ FilePath     : /inotify/server/client.go
+// @@
+package server
+
+import (
+	"context"
+	"google.golang.org/grpc"
+	"github.com/20yyq/inotify"
+	"github.com/20yyq/inotify/eventpb"
+)
+
+// Client cc 由调用者创建与关闭
+type Client struct {
+	cc 	grpc.ClientConnInterface
+}
+
+func NewClient(cc grpc.ClientConnInterface) *Client {
+	return &Client{cc: cc}
+}
+
+var callCodec = grpc.ForceCodec(codec{})
+
+// AddWatch recursive 为 true 时使用 AddRecursiveWatch，group 不为空时使用 AddWatchGroup
+func (c *Client) AddWatch(ctx context.Context, path string, flags uint32, group string, recursive bool) error {
+	return c.cc.Invoke(ctx, "/"+serviceName+"/AddWatch", &eventpb.Watch{Path: path, Flags: flags, Group: group, Recursive: recursive}, &eventpb.Empty{}, callCodec)
+}
+
+func (c *Client) RemoveWatch(ctx context.Context, path string) error {
+	return c.cc.Invoke(ctx, "/"+serviceName+"/RemoveWatch", &eventpb.Watch{Path: path}, &eventpb.Empty{}, callCodec)
+}
+
+// EventStream Events 返回的事件流，ctx 取消后结束
+type EventStream struct {
+	stream 	grpc.ClientStream
+}
+
+// Events 订阅 paths 中的文件或目录(包含子路径)的事件，paths 为服务端的路径，为空时订阅所有事件
+func (c *Client) Events(ctx context.Context, paths ...string) (*EventStream, error) {
+	desc := &grpc.StreamDesc{StreamName: "Events", ServerStreams: true}
+	stream, err := c.cc.NewStream(ctx, desc, "/"+serviceName+"/Events", callCodec)
+	if err != nil {
+		return nil, err
+	}
+	if err = stream.SendMsg(&eventpb.EventsRequest{Paths: paths}); err != nil {
+		return nil, err
+	}
+	if err = stream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return &EventStream{stream: stream}, nil
+}
+
+// Recv 等待下一个事件，事件的 Data 不会传递
+func (s *EventStream) Recv() (inotify.Event, error) {
+	m := &eventpb.Event{}
+	if err := s.stream.RecvMsg(m); err != nil {
+		return inotify.Event{}, err
+	}
+	return m.Event(), nil
+}
@@ -0,0 +1,40 @@
+// @@
+// @ Author       : Eacher
+// @ Date         : 2026-10-17 14:04:17
+// @ LastEditTime : 2026-10-17 14:46:03
+// @ LastEditors  : Eacher
+// @ --------------------------------------------------------------------------------<
+// @ Description  : 使用 eventpb 编码的 gRPC codec
+// @ --------------------------------------------------------------------------------<
+// @ FilePath     : /inotify/server/codec.go
+// @@
+package server
+
+import (
+	"fmt"
+	"github.com/20yyq/inotify/eventpb"
+)
+
+// codec 与 protobuf codec 编码结果相同，名称同为 proto，其他语言的 gRPC 客户端可以直接使用 watch.proto。
+// 只通过 ForceServerCodec、ForceCodec 使用，不注册到全局，不影响进程中其他的 gRPC 服务
+type codec struct{}
+
+func (codec) Marshal(v any) ([]byte, error) {
+	m, ok := v.(eventpb.Message)
+	if !ok {
+		return nil, fmt.Errorf("The message %T is not an eventpb.Message", v)
+	}
+	return m.Marshal(), nil
+}
+
+func (codec) Unmarshal(data []byte, v any) error {
+	m, ok := v.(eventpb.Message)
+	if !ok {
+		return fmt.Errorf("The message %T is not an eventpb.Message", v)
+	}
+	return m.Unmarshal(data)
+}
+
+func (codec) Name() string {
+	return "proto"
+}
@@ -0,0 +1,196 @@
+// @@
+// @ Author       : Eacher
+// @ Date         : 2026-10-17 14:04:17
+// @ LastEditTime : 2026-10-17 14:46:03
+// @ LastEditors  : Eacher
+// @ --------------------------------------------------------------------------------<
+// @ Description  : WatchService gRPC 服务，远程添加、移除监听并订阅事件
+// @ --------------------------------------------------------------------------------<
+// @ FilePath     : /inotify/server/server.go
+// @@
+package server
+
+import (
+	"os"
+	"sync"
+	"errors"
+	"strings"
+	"context"
+	"path/filepath"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"github.com/20yyq/inotify"
+	"github.com/20yyq/inotify/eventpb"
+)
+
+const serviceName = "inotify.WatchService"
+
+// 每个订阅者最多缓存的事件数量，订阅者跟不上时丢弃新事件
+const subscriberBuffer = 64
+
+// Server WatchService 的实现，所有订阅者共用一个 Watcher
+type Server struct {
+	w 		*inotify.Watcher
+	mutex 	sync.Mutex
+	subs 	map[chan inotify.Event][]string
+}
+
+// NewServer w 的事件改由 Server 分发，调用者不应再读取 w 的事件
+func NewServer(w *inotify.Watcher) *Server {
+	s := &Server{w: w, subs: make(map[chan inotify.Event][]string)}
+	go s.loop()
+	return s
+}
+
+// ServerOption NewGRPCServer 需要的选项，使用自己创建的 grpc.Server 时需要加入
+func ServerOption() grpc.ServerOption {
+	return grpc.ForceServerCodec(codec{})
+}
+
+// Register 注册到 gs，gs 需使用 ServerOption 创建
+func (s *Server) Register(gs *grpc.Server) {
+	gs.RegisterService(&serviceDesc, s)
+}
+
+// loop 把 Watcher 的事件分发给订阅者，Watcher 关闭后结束所有订阅
+func (s *Server) loop() {
+	for e := range s.w.Events() {
+		s.mutex.Lock()
+		for ch, paths := range s.subs {
+			if match(paths, e.FileName) {
+				select {
+				case ch <- e:
+				default:
+				}
+			}
+		}
+		s.mutex.Unlock()
+	}
+	s.mutex.Lock()
+	for ch := range s.subs {
+		close(ch)
+		delete(s.subs, ch)
+	}
+	s.subs = nil
+	s.mutex.Unlock()
+}
+
+func match(paths []string, name string) bool {
+	if len(paths) == 0 {
+		return true
+	}
+	name = filepath.Clean(name)
+	for _, p := range paths {
+		if name == p || strings.HasPrefix(name, p+string(os.PathSeparator)) {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Server) addWatch(ctx context.Context, m *eventpb.Watch) (*eventpb.Empty, error) {
+	var err error
+	switch {
+	case m.Recursive && m.Group != "":
+		return nil, status.Error(codes.InvalidArgument, "group and recursive cannot be combined")
+	case m.Recursive:
+		err = s.w.AddRecursiveWatch(m.Path, m.Flags)
+	case m.Group != "":
+		err = s.w.AddWatchGroup(m.Group, m.Path, m.Flags)
+	default:
+		err = s.w.AddWatch(m.Path, m.Flags)
+	}
+	return &eventpb.Empty{}, toStatus(err)
+}
+
+func (s *Server) removeWatch(ctx context.Context, m *eventpb.Watch) (*eventpb.Empty, error) {
+	return &eventpb.Empty{}, toStatus(s.w.RemoveWatch(m.Path))
+}
+
+func (s *Server) events(m *eventpb.EventsRequest, stream grpc.ServerStream) error {
+	paths := make([]string, 0, len(m.Paths))
+	for _, p := range m.Paths {
+		abs, err := filepath.Abs(p)
+		if err != nil {
+			return status.Error(codes.InvalidArgument, err.Error())
+		}
+		paths = append(paths, abs)
+	}
+	ch := make(chan inotify.Event, subscriberBuffer)
+	s.mutex.Lock()
+	if s.subs == nil {
+		s.mutex.Unlock()
+		return toStatus(inotify.ErrClosed)
+	}
+	s.subs[ch] = paths
+	s.mutex.Unlock()
+	defer func() {
+		s.mutex.Lock()
+		if _, ok := s.subs[ch]; ok {
+			delete(s.subs, ch)
+		}
+		s.mutex.Unlock()
+	}()
+	for {
+		select {
+		case e, ok := <-ch:
+			if !ok {
+				return toStatus(inotify.ErrClosed)
+			}
+			if err := stream.SendMsg(eventpb.FromEvent(e)); err != nil {
+				return err
+			}
+		case <-stream.Context().Done():
+			return stream.Context().Err()
+		}
+	}
+}
+
+func toStatus(err error) error {
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, inotify.ErrClosed), errors.Is(err, inotify.ErrNotInitialized):
+		return status.Error(codes.Unavailable, err.Error())
+	case errors.Is(err, inotify.ErrLimit):
+		return status.Error(codes.ResourceExhausted, err.Error())
+	}
+	return status.Error(codes.InvalidArgument, err.Error())
+}
+
+// serviceDesc 对应 watch.proto 的 WatchService
+var serviceDesc = grpc.ServiceDesc{
+	ServiceName: serviceName,
+	HandlerType: (*any)(nil),
+	Methods: []grpc.MethodDesc{
+		{MethodName: "AddWatch", Handler: unaryHandler((*Server).addWatch, "AddWatch")},
+		{MethodName: "RemoveWatch", Handler: unaryHandler((*Server).removeWatch, "RemoveWatch")},
+	},
+	Streams: []grpc.StreamDesc{
+		{StreamName: "Events", ServerStreams: true, Handler: func(srv any, stream grpc.ServerStream) error {
+			m := &eventpb.EventsRequest{}
+			if err := stream.RecvMsg(m); err != nil {
+				return err
+			}
+			return srv.(*Server).events(m, stream)
+		}},
+	},
+	Metadata: "watch.proto",
+}
+
+func unaryHandler(f func(*Server, context.Context, *eventpb.Watch) (*eventpb.Empty, error), method string) grpc.MethodHandler {
+	return func(srv any, ctx context.Context, dec func(any) error, interceptor grpc.UnaryServerInterceptor) (any, error) {
+		m := &eventpb.Watch{}
+		if err := dec(m); err != nil {
+			return nil, err
+		}
+		if interceptor == nil {
+			return f(srv.(*Server), ctx, m)
+		}
+		info := &grpc.UnaryServerInfo{Server: srv, FullMethod: "/" + serviceName + "/" + method}
+		return interceptor(ctx, m, info, func(ctx context.Context, req any) (any, error) {
+			return f(srv.(*Server), ctx, req.(*eventpb.Watch))
+		})
+	}
+}
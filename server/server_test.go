@@ -0,0 +1,85 @@
+//go:build linux
+// +build linux
+
+// @@
+// @ Author       : Eacher
+// @ Date         : 2026-10-17 14:04:17
+// @ LastEditTime : 2026-10-17 14:46:03
+// @ LastEditors  : Eacher
+// @ --------------------------------------------------------------------------------<
+// @ Description  : WatchService 测试
+// @ --------------------------------------------------------------------------------<
+// @ FilePath     : /inotify/server/server_test.go
+// @@
+package server_test
+
+import (
+	"os"
+	"net"
+	"time"
+	"context"
+	"testing"
+	"path/filepath"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/grpc/credentials/insecure"
+	"github.com/20yyq/inotify"
+	"github.com/20yyq/inotify/server"
+)
+
+func TestWatchService(t *testing.T) {
+	w, err := inotify.NewWatcher()
+	if err != nil {
+		t.Fatal("NewWatcher", err)
+	}
+	defer w.Close()
+	l := bufconn.Listen(1 << 16)
+	gs := grpc.NewServer(server.ServerOption())
+	server.NewServer(w).Register(gs)
+	go gs.Serve(l)
+	defer gs.Stop()
+	cc, err := grpc.NewClient("passthrough:///bufconn", grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return l.DialContext(ctx) }))
+	if err != nil {
+		t.Fatal("NewClient", err)
+	}
+	defer cc.Close()
+	c := server.NewClient(cc)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+
+	a, b := t.TempDir(), t.TempDir()
+	for _, dir := range []string{a, b} {
+		if err = c.AddWatch(ctx, dir, inotify.IN_CREATE, "", false); err != nil {
+			t.Fatal("AddWatch", err)
+		}
+	}
+	if err = c.AddWatch(ctx, filepath.Join(a, "missing"), inotify.IN_CREATE, "", false); status.Code(err) != codes.InvalidArgument {
+		t.Fatal("AddWatch missing", err)
+	}
+	stream, err := c.Events(ctx, b)
+	if err != nil {
+		t.Fatal("Events", err)
+	}
+	// 等待订阅在服务端生效
+	time.Sleep(time.Millisecond*100)
+	os.WriteFile(filepath.Join(a, "f"), nil, 0644)
+	os.WriteFile(filepath.Join(b, "f"), nil, 0644)
+	e, err := stream.Recv()
+	if err != nil || e.FileName != filepath.Join(b, "f") || e.Op != inotify.Create || e.Seq == 0 {
+		t.Fatal("Recv", e, err)
+	}
+	if err = c.RemoveWatch(ctx, b); err != nil {
+		t.Fatal("RemoveWatch", err)
+	}
+	w.Close()
+	// RemoveWatch 产生的 IN_IGNORED 之后流结束
+	for err == nil {
+		_, err = stream.Recv()
+	}
+	if status.Code(err) != codes.Unavailable {
+		t.Fatal("Recv after Close", err)
+	}
+}
@@ -0,0 +1,117 @@
+// @@
+// @ Author       : Eacher
+// @ Date         : 2026-10-20 18:45:37
+// @ LastEditTime : 2026-10-21 09:29:34
+// @ LastEditors  : Eacher
+// @ --------------------------------------------------------------------------------<
+// @ Description  : 由读到的事件维护监听路径的快照，查询当前状态不需要重新遍历目录
+// @ --------------------------------------------------------------------------------<
+// @ FilePath     : /inotify/snapshot_linux.go
+// @@
+package inotify
+
+import (
+	"os"
+	"unsafe"
+	"strings"
+	"path/filepath"
+	"golang.org/x/sys/unix"
+)
+
+// WithSnapshot 时每个监听的内核 mask 额外包含的事件，调用者没有监听的由 unwanted 移出
+const snapshotMask = IN_CREATE|IN_DELETE|IN_MOVE|IN_MODIFY|IN_ATTRIB|IN_CLOSE_WRITE|IN_DELETE_SELF|IN_MOVE_SELF
+
+// WithSnapshot 维护监听的路径与目录中文件的快照，由 Snapshot 取得。添加监听时 lstat 该路径与目录中的文件，
+// 之后每读到一个事件 lstat 一次事件的路径，调用者没有监听的 CREATE、DELETE 等事件同样更新快照，不交给调用者。
+// 不递归的目录只包含直接的子项，IN_Q_OVERFLOW 后重新读取所有监听的目录
+func WithSnapshot() Option {
+	return func(w *Watcher) {
+		w.tree = make(map[string]FileAttr)
+	}
+}
+
+// Snapshot 快照的副本，key 为不以分隔符结尾的绝对路径，值的 Ino、Size、Mtime 等为最近一次事件之后 lstat 的结果。
+// 没有 WithSnapshot 时返回 nil
+func (w *Watcher) Snapshot() map[string]FileAttr {
+	if !w.initialized() {
+		return nil
+	}
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	if w.tree == nil {
+		return nil
+	}
+	m := make(map[string]FileAttr, len(w.tree))
+	for path, a := range w.tree {
+		m[path] = a
+	}
+	return m
+}
+
+// track 由从 start 开始新读到的事件更新快照，需在 unwanted 之前，调用者需持有 mutex
+func (w *Watcher) track(start uint32) {
+	for offset := start; offset+unix.SizeofInotifyEvent <= w.bufferItem; {
+		event := (*unix.InotifyEvent)(unsafe.Pointer(&w.eventBuffer[offset]))
+		size := uint32(unix.SizeofInotifyEvent) + event.Len
+		if event.Mask&unix.IN_Q_OVERFLOW != 0 {
+			w.reseed()
+		} else if ws, ok := w.owner(event.Wd, offset); ok && ws.flags != 0 {
+			path := filepath.Clean(ws.path + string(nameOf(w.eventBuffer[offset+unix.SizeofInotifyEvent:offset+size])))
+			switch {
+			case event.Mask&(unix.IN_DELETE_SELF|unix.IN_MOVE_SELF) != 0:
+				w.untrack(path, ws.isDir)
+			case event.Mask&unix.IN_IGNORED != 0:
+				// 监听已移除，仍在监听的目录中的由目录的事件继续更新
+				w.untrack(path, ws.isDir)
+				if w.watched(filepath.Dir(path)) {
+					if a, ok := attrOf(path); ok {
+						w.tree[path] = a
+					}
+				}
+			case event.Mask&(unix.IN_DELETE|unix.IN_MOVED_FROM) != 0:
+				w.untrack(path, event.Mask&unix.IN_ISDIR != 0)
+			case event.Mask&unix.IN_ALL_EVENTS != 0:
+				if a, ok := attrOf(path); ok {
+					w.tree[path] = a
+				} else {
+					w.untrack(path, event.Mask&unix.IN_ISDIR != 0)
+				}
+			}
+		}
+		offset += size
+	}
+}
+
+// untrack 从快照中移除 path，目录同时移除其中的路径，调用者需持有 mutex
+func (w *Watcher) untrack(path string, isDir bool) {
+	delete(w.tree, path)
+	if !isDir {
+		return
+	}
+	prefix := path + string(os.PathSeparator)
+	for p := range w.tree {
+		if strings.HasPrefix(p, prefix) {
+			delete(w.tree, p)
+		}
+	}
+}
+
+// watched dir 是否为调用者的目录监听，调用者需持有 mutex
+func (w *Watcher) watched(dir string) bool {
+	for _, ws := range w.watchMap {
+		if !ws.remove && ws.flags != 0 && ws.path == dir+string(os.PathSeparator) {
+			return true
+		}
+	}
+	return false
+}
+
+// reseed 丢失事件后按当前的监听重新建立快照，调用者需持有 mutex
+func (w *Watcher) reseed() {
+	w.tree = make(map[string]FileAttr, len(w.tree))
+	for _, ws := range w.watchMap {
+		if !ws.remove && ws.flags != 0 {
+			seedAttrs(w.tree, ws.path, ws.isDir)
+		}
+	}
+}
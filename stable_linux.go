@@ -0,0 +1,67 @@
+// @@
+// @ Author       : Eacher
+// @ Date         : 2026-10-20 14:55:27
+// @ LastEditTime : 2026-10-20 15:51:21
+// @ LastEditors  : Eacher
+// @ --------------------------------------------------------------------------------<
+// @ Description  : 等待单个文件写入完成
+// @ --------------------------------------------------------------------------------<
+// @ FilePath     : /inotify/stable_linux.go
+// @@
+package inotify
+
+import (
+	"time"
+	"context"
+)
+
+// WaitStable 同 WaitStableContext，一直等待
+func WaitStable(path string, window time.Duration) error {
+	return WaitStableContext(context.Background(), path, window)
+}
+
+// WaitStableContext 等待 path 写入完成: 收到 CLOSE_WRITE(或 rename 为 path)之后 window 内没有新的写入。
+// 期间再次写入、文件被删除后重新创建时重新等待。只能发现调用之后的写入，需要在上传开始前或进行中调用，
+// ctx 结束时返回 ctx.Err()
+func WaitStableContext(ctx context.Context, path string, window time.Duration) error {
+	w, err := NewWatcher()
+	if err != nil {
+		return err
+	}
+	defer w.Close()
+	if _, err = w.WatchFile(path, IN_MODIFY|IN_CLOSE_WRITE); err != nil {
+		return err
+	}
+	timer := time.NewTimer(window)
+	defer timer.Stop()
+	// 收到 CLOSE_WRITE 之前不计时
+	timer.Stop()
+	var stable <-chan time.Time
+	events := w.Events()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-stable:
+			return nil
+		case e, ok := <-events:
+			if !ok {
+				return ErrClosed
+			}
+			switch {
+			case e.Raw&(IN_CLOSE_WRITE|IN_MOVED_TO) != 0:
+				if !timer.Stop() {
+					select {
+					case <-timer.C:
+					default:
+					}
+				}
+				timer.Reset(window)
+				stable = timer.C
+			case e.Raw&(IN_MODIFY|IN_CREATE|IN_DELETE|IN_MOVED_FROM|IN_Q_OVERFLOW) != 0:
+				timer.Stop()
+				stable = nil
+			}
+		}
+	}
+}
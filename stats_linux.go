@@ -0,0 +1,46 @@
+// @@
+// @ Author       : Eacher
+// @ Date         : 2026-10-18 12:51:01
+// @ LastEditTime : 2026-10-21 15:16:38
+// @ LastEditors  : Eacher
+// @ --------------------------------------------------------------------------------<
+// @ Description  : 读取、交付、丢弃事件的计数
+// @ --------------------------------------------------------------------------------<
+// @ FilePath     : /inotify/stats_linux.go
+// @@
+package inotify
+
+import (
+	"golang.org/x/sys/unix"
+)
+
+// Stats 当前的计数。inject 的事件(如 WithRateLimit 的汇总事件、WithRescan 补发的事件)计入 Delivered 但不计入 Read
+func (w *Watcher) Stats() Stats {
+	if !w.initialized() {
+		return Stats{}
+	}
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	st := w.stats
+	st.BufferSize = len(w.eventBuffer)
+	queued, _ := records(w.eventBuffer[w.bufferHead:w.bufferItem])
+	st.Queued = int(queued)+len(w.injected)
+	for _, ws := range w.watchMap {
+		if !ws.remove {
+			st.Watches++
+		}
+	}
+	return st
+}
+
+// records buf 中的事件数量与其中 IN_Q_OVERFLOW 的数量
+func records(buf []byte) (n, overflows uint64) {
+	d := Decoder{buf: buf}
+	for r, err := d.Next(); err == nil; r, err = d.Next() {
+		if r.Mask&unix.IN_Q_OVERFLOW != 0 {
+			overflows++
+		}
+		n++
+	}
+	return n, overflows
+}
@@ -0,0 +1,25 @@
+// @@
+// @ Author       : Eacher
+// @ Date         : 2026-10-18 12:51:01
+// @ LastEditTime : 2026-10-21 13:40:35
+// @ LastEditors  : Eacher
+// @ --------------------------------------------------------------------------------<
+// @ Description  : windows 只统计交付的事件与监听数量
+// @ --------------------------------------------------------------------------------<
+// @ FilePath     : /inotify/stats_windows.go
+// @@
+package inotify
+
+import (
+	"sync/atomic"
+)
+
+// Stats 当前的计数，windows 只有 Delivered、Watches 与 Queued
+func (w *Watcher) Stats() Stats {
+	if !w.initialized() {
+		return Stats{}
+	}
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	return Stats{Delivered: atomic.LoadUint64(&w.delivered), Watches: len(w.watchMap), Queued: len(w.e)}
+}
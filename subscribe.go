@@ -0,0 +1,150 @@
+// @@
+// @ Author       : Eacher
+// @ Date         : 2026-10-21 18:49:12
+// @ LastEditTime : 2026-10-23 16:39:53
+// @ LastEditors  : Eacher
+// @ --------------------------------------------------------------------------------<
+// @ Description  : 一个 Watcher 的事件按 mask 与文件名分发给多个订阅者
+// @ --------------------------------------------------------------------------------<
+// @ FilePath     : /inotify/subscribe.go
+// @@
+package inotify
+
+import (
+	"sync"
+	"strings"
+	"path/filepath"
+)
+
+// Subscribe 每个订阅者的队列长度，订阅者跟不上时丢弃新事件
+const subscribeQueue = 64
+
+type subscriber struct {
+	mask 		uint32
+	patterns 	[]string
+	// WatchChan 的所有监听，不为 nil 时只接收这些监听的事件，都 IN_IGNORED 之后关闭
+	wds 		map[uint32]bool
+	done 		bool
+	ch 			chan Event
+}
+
+// match 事件是否交给该订阅者
+func (s *subscriber) match(e Event) bool {
+	if s.wds != nil && !s.wds[e.wd] {
+		return false
+	}
+	if s.mask != 0 && e.Raw&s.mask == 0 {
+		return false
+	}
+	if len(s.patterns) == 0 {
+		return true
+	}
+	for _, p := range s.patterns {
+		name := filepath.Base(e.FileName)
+		if strings.ContainsRune(p, filepath.Separator) {
+			name = e.FileName
+		}
+		if ok, _ := filepath.Match(p, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// subscribers 第一次 Subscribe 时启动 goroutine 读取事件，Close 后关闭所有订阅者的 chan
+type subscribers struct {
+	once 	sync.Once
+	mutex 	sync.Mutex
+	list 	map[<-chan Event]*subscriber
+	closed 	bool
+}
+
+// Subscribe 返回只收到 Raw 包含 mask 中任一位(0 为所有事件)且匹配 patterns 中任一个(空为所有文件)的事件的 chan。
+// pattern 为 filepath.Match 的格式，不含分隔符时匹配文件名，含分隔符时匹配完整路径。多个订阅者共用一个 inotify fd，
+// 每个订阅者各自缓存 64 个事件，跟不上时丢弃该订阅者的新事件，由 Seq 不连续发现。Unsubscribe 或 Close 后 chan 被关闭。
+// 与 Events 相同，使用后不要再调用 WaitEvent 等方法，否则事件会被分走
+func (w *Watcher) Subscribe(mask uint32, patterns ...string) (<-chan Event, error) {
+	if !w.initialized() {
+		return nil, ErrNotInitialized
+	}
+	for _, p := range patterns {
+		if _, err := filepath.Match(p, ""); err != nil {
+			return nil, err
+		}
+	}
+	s := &subscriber{mask: mask, patterns: patterns, ch: make(chan Event, subscribeQueue)}
+	w.subscribe(s, 0)
+	return s.ch, nil
+}
+
+// subscribe 加入 s，wd 不为 0 时加入 s 的监听，已关闭的 s 忽略。第一次调用时启动 fanout
+func (w *Watcher) subscribe(s *subscriber, wd uint32) {
+	w.subs.once.Do(func() {
+		w.subs.list = make(map[<-chan Event]*subscriber)
+		go w.fanout()
+	})
+	w.subs.mutex.Lock()
+	defer w.subs.mutex.Unlock()
+	switch {
+	case s.done:
+	case w.subs.closed:
+		s.done = true
+		close(s.ch)
+	default:
+		if wd != 0 {
+			s.wds[wd] = true
+		}
+		w.subs.list[s.ch] = s
+	}
+}
+
+// Unsubscribe 取消 Subscribe 返回的 ch 并关闭它，已取消的 ch 忽略
+func (w *Watcher) Unsubscribe(ch <-chan Event) {
+	w.subs.mutex.Lock()
+	defer w.subs.mutex.Unlock()
+	if s, ok := w.subs.list[ch]; ok {
+		delete(w.subs.list, ch)
+		s.done = true
+		close(s.ch)
+	}
+}
+
+func (w *Watcher) fanout() {
+	for {
+		e, err := w.next()
+		if err == ErrClosed {
+			break
+		}
+		if err != nil {
+			// ErrDropped 等由 Seq 不连续发现
+			continue
+		}
+		w.subs.mutex.Lock()
+		for _, s := range w.subs.list {
+			if !s.match(e) {
+				continue
+			}
+			select {
+			case s.ch <- e:
+			default:
+			}
+			if s.wds != nil && e.Raw&IN_IGNORED != 0 {
+				// 监听已移除，之后不会再有该监听的事件
+				if delete(s.wds, e.wd); len(s.wds) == 0 {
+					delete(w.subs.list, s.ch)
+					s.done = true
+					close(s.ch)
+				}
+			}
+		}
+		w.subs.mutex.Unlock()
+	}
+	w.subs.mutex.Lock()
+	w.subs.closed = true
+	for ch, s := range w.subs.list {
+		delete(w.subs.list, ch)
+		s.done = true
+		close(s.ch)
+	}
+	w.subs.mutex.Unlock()
+}
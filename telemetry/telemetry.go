@@ -0,0 +1,118 @@
+// @@
+// @ Author       : Eacher
+// @ Date         : 2026-10-21 12:46:23
+// @ LastEditTime : 2026-10-24 10:04:25
+// @ LastEditors  : Eacher
+// @ --------------------------------------------------------------------------------<
+// @ Description  : OpenTelemetry 指标与 trace，单独的模块，只有使用时才需要 otel 依赖
+// @ --------------------------------------------------------------------------------<
+// @ FilePath     : /inotify/telemetry/telemetry.go
+// @@
+package telemetry
+
+import (
+	"sync"
+	"context"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/attribute"
+	"github.com/20yyq/inotify"
+	"github.com/20yyq/inotify/runner"
+)
+
+// ScopeName Meter 与 Tracer 的名称
+const ScopeName = "github.com/20yyq/inotify/telemetry"
+
+// RegisterMetrics 注册 w.Stats() 的异步指标，每次采集时读取一次 Stats:
+// inotify.events.read、inotify.events.delivered、inotify.events.dropped、inotify.events.filtered、inotify.overflows 为累计值，
+// inotify.queue.depth、inotify.watches 为当前值。Watcher 关闭前调用返回值的 Unregister
+func RegisterMetrics(w *inotify.Watcher, mp metric.MeterProvider) (metric.Registration, error) {
+	m := mp.Meter(ScopeName)
+	var counters [5]metric.Int64ObservableCounter
+	for i, v := range []struct{ name, desc string }{
+		{"inotify.events.read", "events read from the kernel"},
+		{"inotify.events.delivered", "events handed to the caller"},
+		{"inotify.events.dropped", "events dropped because the buffer was full"},
+		{"inotify.events.filtered", "events filtered, coalesced or discarded"},
+		{"inotify.overflows", "IN_Q_OVERFLOW events"},
+	} {
+		c, err := m.Int64ObservableCounter(v.name, metric.WithDescription(v.desc), metric.WithUnit("{event}"))
+		if err != nil {
+			return nil, err
+		}
+		counters[i] = c
+	}
+	depth, err := m.Int64ObservableGauge("inotify.queue.depth", metric.WithDescription("events buffered but not yet delivered"), metric.WithUnit("{event}"))
+	if err != nil {
+		return nil, err
+	}
+	watches, err := m.Int64ObservableGauge("inotify.watches", metric.WithDescription("active watches"), metric.WithUnit("{watch}"))
+	if err != nil {
+		return nil, err
+	}
+	return m.RegisterCallback(func(_ context.Context, o metric.Observer) error {
+		st := w.Stats()
+		for i, v := range []uint64{st.Read, st.Delivered, st.Dropped, st.Filtered, st.Overflows} {
+			o.ObserveInt64(counters[i], int64(v))
+		}
+		o.ObserveInt64(depth, int64(st.Queued))
+		o.ObserveInt64(watches, int64(st.Watches))
+		return nil
+	}, counters[0], counters[1], counters[2], counters[3], counters[4], depth, watches)
+}
+
+// eventAttributes 事件的 span 属性
+func eventAttributes(e inotify.Event) []attribute.KeyValue {
+	return []attribute.KeyValue{
+		attribute.String("inotify.path", e.Path()),
+		attribute.String("inotify.op", e.Op.String()),
+		attribute.Int64("inotify.seq", int64(e.Seq)),
+	}
+}
+
+// Handler 返回在 span "inotify.event" 中调用 handle 的函数，可以交给 Watcher.Dispatch 或在读取 Events 时调用。
+// handle 的 ctx 带有该 span，其中的操作(如 Webhook 请求)成为它的子 span
+func Handler(tp trace.TracerProvider, handle func(context.Context, inotify.Event)) func(inotify.Event) {
+	tracer := tp.Tracer(ScopeName)
+	return func(e inotify.Event) {
+		ctx, span := tracer.Start(context.Background(), "inotify.event", trace.WithAttributes(eventAttributes(e)...))
+		defer span.End()
+		handle(ctx, e)
+	}
+}
+
+// TraceEngine 为 eg 每次执行命令创建 span "inotify.action"，从 OnStart 到 OnExit，命令失败时 span 的状态为 Error。
+// 保留 eg 原有的 OnStart、OnExit，需在第一次 Dispatch 之前调用
+func TraceEngine(eg *runner.Engine, tp trace.TracerProvider) {
+	tracer := tp.Tracer(ScopeName)
+	var mutex sync.Mutex
+	// 每条规则同一时刻只运行一个命令
+	spans := make(map[*runner.Rule]trace.Span)
+	start, exit := eg.OnStart, eg.OnExit
+	eg.OnStart = func(r *runner.Rule, e inotify.Event) {
+		_, span := tracer.Start(context.Background(), "inotify.action", trace.WithAttributes(append(eventAttributes(e), attribute.String("inotify.rule", r.Name))...))
+		mutex.Lock()
+		spans[r] = span
+		mutex.Unlock()
+		if start != nil {
+			start(r, e)
+		}
+	}
+	eg.OnExit = func(r *runner.Rule, e inotify.Event, err error) {
+		mutex.Lock()
+		span, ok := spans[r]
+		delete(spans, r)
+		mutex.Unlock()
+		if ok {
+			if err != nil {
+				span.RecordError(err)
+				span.SetStatus(codes.Error, err.Error())
+			}
+			span.End()
+		}
+		if exit != nil {
+			exit(r, e, err)
+		}
+	}
+}
@@ -0,0 +1,102 @@
+//go:build linux
+// +build linux
+
+// @@
+// @ Author       : Eacher
+// @ Date         : 2026-10-21 12:46:23
+// @ LastEditTime : 2026-10-21 13:40:35
+// @ LastEditors  : Eacher
+// @ --------------------------------------------------------------------------------<
+// @ Description  : OpenTelemetry 指标与 trace 测试
+// @ --------------------------------------------------------------------------------<
+// @ FilePath     : /inotify/telemetry/telemetry_test.go
+// @@
+package telemetry_test
+
+import (
+	"os"
+	"time"
+	"errors"
+	"os/exec"
+	"context"
+	"testing"
+	"path/filepath"
+	"go.opentelemetry.io/otel/codes"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"github.com/20yyq/inotify"
+	"github.com/20yyq/inotify/runner"
+	"github.com/20yyq/inotify/telemetry"
+)
+
+func TestRegisterMetrics(t *testing.T) {
+	w := inotify.MustNewWatcher()
+	defer w.Close()
+	dir := t.TempDir()
+	if err := w.AddWatch(dir, inotify.IN_CREATE); err != nil {
+		t.Fatal("AddWatch", err)
+	}
+	reader := sdkmetric.NewManualReader()
+	reg, err := telemetry.RegisterMetrics(w, sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader)))
+	if err != nil {
+		t.Fatal("RegisterMetrics", err)
+	}
+	defer reg.Unregister()
+	os.WriteFile(filepath.Join(dir, "a"), nil, 0644)
+	os.WriteFile(filepath.Join(dir, "b"), nil, 0644)
+	w.WaitEventTimeout(time.Second)
+	// 第二个事件已读到，还未取出
+	time.Sleep(50 * time.Millisecond)
+	var rm metricdata.ResourceMetrics
+	if err = reader.Collect(context.Background(), &rm); err != nil {
+		t.Fatal("Collect", err)
+	}
+	got := make(map[string]int64)
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			switch data := m.Data.(type) {
+			case metricdata.Sum[int64]:
+				got[m.Name] = data.DataPoints[0].Value
+			case metricdata.Gauge[int64]:
+				got[m.Name] = data.DataPoints[0].Value
+			}
+		}
+	}
+	if got["inotify.events.read"] != 2 || got["inotify.events.delivered"] != 1 || got["inotify.queue.depth"] != 1 || got["inotify.watches"] != 1 {
+		t.Fatal("metrics", got)
+	}
+}
+
+func TestTrace(t *testing.T) {
+	sr := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(sr))
+	telemetry.Handler(tp, func(ctx context.Context, e inotify.Event) {
+		_, span := tp.Tracer("test").Start(ctx, "child")
+		span.End()
+	})(inotify.Event{FileName: "/tmp/a", Op: inotify.Create})
+
+	eg := runner.NewEngine(runner.Rule{Name: "fail", Command: []string{"false"}})
+	exited := make(chan error, 1)
+	eg.OnExit = func(_ *runner.Rule, _ inotify.Event, err error) { exited <- err }
+	telemetry.TraceEngine(eg, tp)
+	eg.Dispatch(inotify.Event{FileName: "/tmp/a", Op: inotify.Write})
+	var exit *exec.ExitError
+	if err := <-exited; !errors.As(err, &exit) {
+		t.Fatal("OnExit", err)
+	}
+	eg.Stop()
+
+	spans := sr.Ended()
+	if len(spans) != 3 {
+		t.Fatal("spans", len(spans))
+	}
+	child, event, action := spans[0], spans[1], spans[2]
+	if event.Name() != "inotify.event" || child.Parent().SpanID() != event.SpanContext().SpanID() {
+		t.Fatal("inotify.event", event.Name(), child.Parent())
+	}
+	if action.Name() != "inotify.action" || action.Status().Code != codes.Error {
+		t.Fatal("inotify.action", action.Name(), action.Status())
+	}
+}
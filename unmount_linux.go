@@ -0,0 +1,161 @@
+// @@
+// @ Author       : Eacher
+// @ Date         : 2026-10-22 11:31:10
+// @ LastEditTime : 2026-10-22 12:34:32
+// @ LastEditors  : Eacher
+// @ --------------------------------------------------------------------------------<
+// @ Description  : 监听所在的文件系统被卸载后，重新挂载时自动恢复监听
+// @ --------------------------------------------------------------------------------<
+// @ FilePath     : /inotify/unmount_linux.go
+// @@
+package inotify
+
+import (
+	"os"
+	"sort"
+	"time"
+	"unsafe"
+	"strings"
+	"path/filepath"
+	"golang.org/x/sys/unix"
+)
+
+type remounter struct {
+	interval 	time.Duration
+	remounted 	func(path string)
+	// 已卸载、等待重新挂载的监听
+	lost 		[]unmounted
+}
+
+// unmounted 卸载时的监听，dev 为卸载后该路径(挂载点下原来的目录)的设备号
+type unmounted struct {
+	path 		string
+	flags 		uint32
+	recursive 	bool
+	depth 		int
+	group 		string
+	data 		any
+	dev 		uint64
+	exists 		bool
+}
+
+// WithRemount 监听所在的文件系统被卸载(事件的 Op 为 Unmount，之后是 IN_IGNORED，监听已移除)后，每 interval 检查一次该路径，
+// 路径的设备号与卸载后不同(重新挂载)时以原来的 flags、分组与数据重新添加监听，递归监听重新监听整个目录树，之后调用 remounted。
+// remounted 可以为 nil，不持有锁；重新挂载前 RemoveWatch 该路径时不再恢复
+func WithRemount(interval time.Duration, remounted func(path string)) Option {
+	return func(w *Watcher) {
+		if interval > 0 {
+			w.remount = &remounter{interval: interval, remounted: remounted}
+		}
+	}
+}
+
+// unmounts 记录从 start 开始新读到的 IN_UNMOUNT 的监听，调用者需持有 mutex
+func (w *Watcher) unmounts(start uint32) {
+	for offset := start; offset+unix.SizeofInotifyEvent <= w.bufferItem; {
+		event := (*unix.InotifyEvent)(unsafe.Pointer(&w.eventBuffer[offset]))
+		if event.Mask&unix.IN_UNMOUNT != 0 {
+			if ws, ok := w.owner(event.Wd, offset); ok && ws.flags != 0 {
+				u := unmounted{path: filepath.Clean(ws.path), flags: ws.flags, recursive: ws.recursive, depth: ws.depth, group: ws.group, data: ws.data}
+				var st unix.Stat_t
+				if unix.Lstat(u.path, &st) == nil {
+					u.dev, u.exists = st.Dev, true
+				}
+				w.remount.lost = append(w.remount.lost, u)
+			}
+		}
+		offset += uint32(unix.SizeofInotifyEvent) + event.Len
+	}
+}
+
+// unlose 不再恢复 path 的监听，调用者需持有 mutex
+func (w *Watcher) unlose(path string) bool {
+	found := false
+	lost := w.remount.lost[:0]
+	for _, u := range w.remount.lost {
+		if u.path == path {
+			found = true
+			continue
+		}
+		lost = append(lost, u)
+	}
+	w.remount.lost = lost
+	return found
+}
+
+func (w *Watcher) remountLoop() {
+	ticker := time.NewTicker(w.remount.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			w.reattach()
+		case <-w.closed():
+			return
+		}
+	}
+}
+
+// reattach 恢复已重新挂载的监听，递归监听中的子目录由最上层的目录一起恢复
+func (w *Watcher) reattach() {
+	w.mutex.Lock()
+	if w.closes {
+		w.mutex.Unlock()
+		return
+	}
+	var ready []unmounted
+	lost := w.remount.lost[:0]
+	for _, u := range w.remount.lost {
+		var st unix.Stat_t
+		if unix.Lstat(u.path, &st) == nil && (!u.exists || st.Dev != u.dev) {
+			ready = append(ready, u)
+		} else {
+			lost = append(lost, u)
+		}
+	}
+	w.remount.lost = lost
+	w.mutex.Unlock()
+	sort.Slice(ready, func(i, j int) bool { return len(ready[i].path) < len(ready[j].path) })
+	var trees []string
+	for _, u := range ready {
+		covered := false
+		for _, p := range trees {
+			if u.path == p || strings.HasPrefix(u.path, p+string(os.PathSeparator)) {
+				covered = true
+				break
+			}
+		}
+		if covered {
+			continue
+		}
+		var err error
+		if u.recursive {
+			err = w.watchTree(newDir{path: u.path, flags: u.flags, group: u.group, data: u.data, depth: u.depth, inherit: true})
+		} else {
+			err = w.watchPath(u.path, u.flags, func(ws *WatchSingle) { ws.group, ws.data = u.group, u.data }, false)
+		}
+		if err != nil {
+			// 下一次再试
+			w.mutex.Lock()
+			w.remount.lost = append(w.remount.lost, u)
+			w.mutex.Unlock()
+			continue
+		}
+		if u.recursive {
+			trees = append(trees, u.path)
+			// 新的文件系统中已不存在的子目录不再等待
+			w.mutex.Lock()
+			lost := w.remount.lost[:0]
+			for _, l := range w.remount.lost {
+				if !strings.HasPrefix(l.path, u.path+string(os.PathSeparator)) {
+					lost = append(lost, l)
+				}
+			}
+			w.remount.lost = lost
+			w.mutex.Unlock()
+		}
+		if w.remount.remounted != nil {
+			w.remount.remounted(u.path)
+		}
+	}
+}
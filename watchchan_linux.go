@@ -0,0 +1,29 @@
+// @@
+// @ Author       : Eacher
+// @ Date         : 2026-10-22 09:31:40
+// @ LastEditTime : 2026-10-23 16:39:53
+// @ LastEditors  : Eacher
+// @ --------------------------------------------------------------------------------<
+// @ Description  : 每个路径一个 chan，监听移除或路径删除后关闭，用于配置文件、pid 文件等
+// @ --------------------------------------------------------------------------------<
+// @ FilePath     : /inotify/watchchan_linux.go
+// @@
+package inotify
+
+// WatchChan 同 AddWatch，返回只收到该监听的事件的 chan。RemoveWatch、IN_ONESHOT 触发后，或路径被删除、移走
+// (DELETE_SELF、MOVE_SELF)后，收到最后的 IN_IGNORED 事件后关闭，Close 后同样关闭。路径必须已存在，不等待 WithPendingWatches；
+// 通配符的路径收到所有匹配的监听(包括之后出现的匹配)的事件，这些监听都移除后关闭。
+// 已监听的路径与 AddWatch 相同合并 flags，chan 只收到 flags 中的事件；不递归，目录只有直接子项的事件。与 Subscribe 相同，使用后不要再调用 WaitEvent 等方法
+func (w *Watcher) WatchChan(path string, flags uint32) (<-chan Event, error) {
+	s := &subscriber{mask: flags|IN_IGNORED, wds: make(map[uint32]bool), ch: make(chan Event, subscribeQueue)}
+	// 持有 mutex 时加入订阅者，添加之后读到的事件都不会漏掉
+	err := w.watchPath(path, flags, func(ws *WatchSingle) {
+		w.subscribe(s, ws.watchId)
+	}, false)
+	if err != nil {
+		return nil, err
+	}
+	// 通配符当前没有匹配时 set 不会被调用，Close 后同样要关闭
+	w.subscribe(s, 0)
+	return s.ch, nil
+}
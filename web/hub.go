@@ -0,0 +1,190 @@
+// @@
+// @ Author       : Eacher
+// @ Date         : 2026-10-17 14:46:03
+// @ LastEditTime : 2026-10-25 09:24:55
+// @ LastEditors  : Eacher
+// @ --------------------------------------------------------------------------------<
+// @ Description  : 从一个 Watcher 读取事件并分发给所有 http 连接
+// @ --------------------------------------------------------------------------------<
+// @ FilePath     : /inotify/web/hub.go
+// @@
+package web
+
+import (
+	"os"
+	"sort"
+	"sync"
+	"strings"
+	"path/filepath"
+	"github.com/20yyq/inotify"
+)
+
+// 每个连接最多缓存的事件数量，连接跟不上时丢弃新事件或关闭连接(closeSlow)
+const connBuffer = 64
+
+// Filter 连接的过滤条件，空的条件不过滤
+type Filter struct {
+	// 文件或目录(包含子路径)的绝对路径
+	Paths 	[]string 	`json:"paths"`
+	// Op.String 的名称，如 CREATE、WRITE
+	Ops 	[]string 	`json:"ops"`
+}
+
+// filter 解析后的 Filter
+type filter struct {
+	paths 	[]string
+	op 		inotify.Op
+}
+
+func (f Filter) parse() (filter, error) {
+	var v filter
+	for _, p := range f.Paths {
+		if p != "" {
+			v.paths = append(v.paths, filepath.Clean(p))
+		}
+	}
+	for _, name := range f.Ops {
+		op, err := inotify.ParseOp(strings.ToUpper(name))
+		if err != nil {
+			return filter{}, err
+		}
+		v.op |= op
+	}
+	return v, nil
+}
+
+func (f filter) match(e inotify.Event) bool {
+	if f.op != 0 && e.Op&f.op == 0 {
+		return false
+	}
+	return under(f.paths, e.Path())
+}
+
+// under name 是否为 paths 中的路径或其子路径，paths 为空时总是 true
+func under(paths []string, name string) bool {
+	if len(paths) == 0 {
+		return true
+	}
+	name = filepath.Clean(name)
+	for _, p := range paths {
+		if name == p || strings.HasPrefix(name, p+string(os.PathSeparator)) {
+			return true
+		}
+	}
+	return false
+}
+
+type hub struct {
+	w 		*inotify.Watcher
+	paths 	[]string
+	once 	sync.Once
+	mutex 	sync.Mutex
+	subs 	map[chan inotify.Event]*filter
+	closed 	bool
+	// 保留最近 keep 个事件用于断线重连，trimmed 为已移出 history 的最大 Seq，last 为最后一个事件的 Seq。
+	// history 满后为环形缓存，head 为最早的事件，事件按 Seq 递增
+	keep 	int
+	history []inotify.Event
+	head 	int
+	trimmed uint64
+	last 	uint64
+	// 连接跟不上时关闭它的 chan 而不是丢弃事件，客户端由 Last-Event-ID 重连补发，不会漏掉事件
+	closeSlow bool
+}
+
+func newHub(w *inotify.Watcher, paths []string) *hub {
+	h := &hub{w: w, subs: make(map[chan inotify.Event]*filter)}
+	for _, p := range paths {
+		h.paths = append(h.paths, filepath.Clean(p))
+	}
+	return h
+}
+
+// subscribe 第一个连接时开始读取 Watcher，Watcher 关闭后返回的 chan 被关闭
+func (h *hub) subscribe(f filter) chan inotify.Event {
+	ch, _, _ := h.subscribeAfter(f, 0)
+	return ch
+}
+
+// subscribeAfter 同 subscribe，同时返回 history 中 Seq 大于 after 且通过 f 的事件。
+// after 之后的事件已有被移出 history 的(或 after 不是本 Watcher 的 Seq)时 complete 为 false
+func (h *hub) subscribeAfter(f filter, after uint64) (ch chan inotify.Event, replay []inotify.Event, complete bool) {
+	h.once.Do(func() { go h.loop() })
+	ch = make(chan inotify.Event, connBuffer)
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	complete = after == 0 || after >= h.trimmed && after <= h.last
+	if after > 0 {
+		n := len(h.history)
+		i := sort.Search(n, func(i int) bool { return h.at(i).Seq > after })
+		for ; i < n; i++ {
+			if e := h.at(i); f.match(e) {
+				replay = append(replay, e)
+			}
+		}
+	}
+	if h.closed {
+		close(ch)
+		return ch, replay, complete
+	}
+	h.subs[ch] = &f
+	return ch, replay, complete
+}
+
+// at history 中第 i 早的事件，调用者需持有 mutex
+func (h *hub) at(i int) inotify.Event {
+	return h.history[(h.head+i)%len(h.history)]
+}
+
+// update 修改连接的过滤条件
+func (h *hub) update(ch chan inotify.Event, f filter) {
+	h.mutex.Lock()
+	if v, ok := h.subs[ch]; ok {
+		*v = f
+	}
+	h.mutex.Unlock()
+}
+
+func (h *hub) unsubscribe(ch chan inotify.Event) {
+	h.mutex.Lock()
+	delete(h.subs, ch)
+	h.mutex.Unlock()
+}
+
+func (h *hub) loop() {
+	for e := range h.w.Events() {
+		if !under(h.paths, e.Path()) {
+			continue
+		}
+		h.mutex.Lock()
+		h.last = e.Seq
+		if len(h.history) < h.keep {
+			h.history = append(h.history, e)
+		} else if h.keep > 0 {
+			h.trimmed = h.history[h.head].Seq
+			h.history[h.head] = e
+			h.head = (h.head+1)%h.keep
+		}
+		for ch, f := range h.subs {
+			if !f.match(e) {
+				continue
+			}
+			select {
+			case ch <- e:
+			default:
+				if h.closeSlow {
+					close(ch)
+					delete(h.subs, ch)
+				}
+			}
+		}
+		h.mutex.Unlock()
+	}
+	h.mutex.Lock()
+	h.closed = true
+	for ch := range h.subs {
+		close(ch)
+		delete(h.subs, ch)
+	}
+	h.mutex.Unlock()
+}
@@ -0,0 +1,112 @@
+// @@
+// @ Author       : Eacher
+// @ Date         : 2026-10-17 15:13:19
+// @ LastEditTime : 2026-10-22 18:25:51
+// @ LastEditors  : Eacher
+// @ --------------------------------------------------------------------------------<
+// @ Description  : 通过 Server-Sent Events 推送 JSON 事件，支持 Last-Event-ID 断线重连
+// @ --------------------------------------------------------------------------------<
+// @ FilePath     : /inotify/web/sse.go
+// @@
+package web
+
+import (
+	"fmt"
+	"time"
+	"strconv"
+	"net/http"
+	"encoding/json"
+	"github.com/20yyq/inotify"
+)
+
+const (
+	// DefaultHistory SSEHandler 默认保留用于重连的事件数量
+	DefaultHistory 	= 1024
+	// 没有事件时发送注释保持连接
+	sseHeartbeat 	= time.Second*15
+	// 浏览器断线后重连的等待时间，毫秒
+	sseRetry 		= 3000
+)
+
+// SSEHandler 以 text/event-stream 推送事件，每个事件的 id 为 Event.Seq，data 为 Event.MarshalJSON。
+// 重连时浏览器发送的 Last-Event-ID(或 query 参数 lastEventId)之后的事件会先补发，
+// 需要的事件已不在保留范围内时先发送一个 reset 事件，客户端应重新加载完整状态。
+// 连接跟不上(缓存的事件超过 64 个)时发送完已缓存的事件后断开，由浏览器以 Last-Event-ID 重连补发。
+// query 参数 path、op 可重复设置过滤条件
+type SSEHandler struct {
+	hub 	*hub
+}
+
+// NewSSEHandler 只推送 paths 中的文件或目录(包含子路径)的事件，paths 为空时推送所有事件，保留最近 history 个事件用于重连，
+// history 不大于 0 时使用 DefaultHistory。w 的事件改由 Handler 读取，调用者不应再读取 w 的事件
+func NewSSEHandler(w *inotify.Watcher, history int, paths ...string) *SSEHandler {
+	if history <= 0 {
+		history = DefaultHistory
+	}
+	h := newHub(w, paths)
+	h.keep, h.closeSlow = history, true
+	// 开始保留事件，不必等到第一个连接
+	h.once.Do(func() { go h.loop() })
+	return &SSEHandler{hub: h}
+}
+
+func (h *SSEHandler) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
+	f, err := Filter{Paths: r.URL.Query()["path"], Ops: r.URL.Query()["op"]}.parse()
+	if err != nil {
+		http.Error(rw, err.Error(), http.StatusBadRequest)
+		return
+	}
+	id := r.Header.Get("Last-Event-ID")
+	if id == "" {
+		id = r.URL.Query().Get("lastEventId")
+	}
+	var after uint64
+	if id != "" {
+		if after, err = strconv.ParseUint(id, 10, 64); err != nil {
+			http.Error(rw, "invalid Last-Event-ID", http.StatusBadRequest)
+			return
+		}
+	}
+	flusher, ok := rw.(http.Flusher)
+	if !ok {
+		http.Error(rw, "streaming not supported", http.StatusInternalServerError)
+		return
+	}
+	ch, replay, complete := h.hub.subscribeAfter(f, after)
+	defer h.hub.unsubscribe(ch)
+
+	rw.Header().Set("Content-Type", "text/event-stream")
+	rw.Header().Set("Cache-Control", "no-cache")
+	rw.Header().Set("X-Accel-Buffering", "no")
+	rw.WriteHeader(http.StatusOK)
+	fmt.Fprintf(rw, "retry: %d\n\n", sseRetry)
+	if !complete {
+		fmt.Fprint(rw, "event: reset\ndata: {}\n\n")
+	}
+	for _, e := range replay {
+		writeSSE(rw, e)
+	}
+	flusher.Flush()
+	heartbeat := time.NewTicker(sseHeartbeat)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case e, ok := <-ch:
+			if !ok {
+				return
+			}
+			writeSSE(rw, e)
+			flusher.Flush()
+		case <-heartbeat.C:
+			fmt.Fprint(rw, ": ping\n\n")
+			flusher.Flush()
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+func writeSSE(rw http.ResponseWriter, e inotify.Event) {
+	data, _ := json.Marshal(e)
+	fmt.Fprintf(rw, "id: %d\ndata: %s\n\n", e.Seq, data)
+}
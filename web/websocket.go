@@ -0,0 +1,249 @@
+// @@
+// @ Author       : Eacher
+// @ Date         : 2026-10-17 14:46:03
+// @ LastEditTime : 2026-10-17 15:13:19
+// @ LastEditors  : Eacher
+// @ --------------------------------------------------------------------------------<
+// @ Description  : 通过 WebSocket 推送 JSON 事件，只实现推送事件需要的 RFC 6455 部分
+// @ --------------------------------------------------------------------------------<
+// @ FilePath     : /inotify/web/websocket.go
+// @@
+package web
+
+import (
+	"io"
+	"net"
+	"sync"
+	"time"
+	"bufio"
+	"errors"
+	"strings"
+	"net/http"
+	"crypto/sha1"
+	"encoding/json"
+	"encoding/base64"
+	"encoding/binary"
+	"github.com/20yyq/inotify"
+)
+
+const wsGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+// opcode
+const (
+	opContinuation 	= 0x0
+	opText 			= 0x1
+	opBinary 		= 0x2
+	opClose 		= 0x8
+	opPing 			= 0x9
+	opPong 			= 0xa
+)
+
+// 客户端消息的长度上限，只用于修改过滤条件
+const maxMessage = 64 << 10
+
+// WebSocketHandler 每个事件以一个 JSON 文本消息(Event.MarshalJSON)推送。
+// 连接的 query 参数 path、op 可重复设置过滤条件，之后客户端发送 JSON 的 Filter 时替换原来的条件
+type WebSocketHandler struct {
+	hub 	*hub
+	// CheckOrigin 返回 false 时拒绝连接，nil 时只允许与 Host 相同的 Origin 或没有 Origin
+	CheckOrigin func(r *http.Request) bool
+}
+
+// NewWebSocketHandler 只推送 paths 中的文件或目录(包含子路径)的事件，paths 为空时推送所有事件。
+// w 的事件改由 Handler 读取，调用者不应再读取 w 的事件
+func NewWebSocketHandler(w *inotify.Watcher, paths ...string) *WebSocketHandler {
+	return &WebSocketHandler{hub: newHub(w, paths)}
+}
+
+func (h *WebSocketHandler) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
+	f, err := Filter{Paths: r.URL.Query()["path"], Ops: r.URL.Query()["op"]}.parse()
+	if err != nil {
+		http.Error(rw, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if !h.checkOrigin(r) {
+		http.Error(rw, "origin not allowed", http.StatusForbidden)
+		return
+	}
+	conn, err := upgrade(rw, r)
+	if err != nil {
+		return
+	}
+	defer conn.close()
+	ch := h.hub.subscribe(f)
+	defer h.hub.unsubscribe(ch)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		conn.readLoop(func(data []byte) {
+			var v Filter
+			if json.Unmarshal(data, &v) != nil {
+				return
+			}
+			if f, err := v.parse(); err == nil {
+				h.hub.update(ch, f)
+			}
+		})
+	}()
+	for {
+		select {
+		case e, ok := <-ch:
+			if !ok {
+				conn.write(opClose, closePayload(1001, "watcher closed"))
+				return
+			}
+			data, _ := json.Marshal(e)
+			if conn.write(opText, data) != nil {
+				return
+			}
+		case <-done:
+			return
+		}
+	}
+}
+
+func (h *WebSocketHandler) checkOrigin(r *http.Request) bool {
+	if h.CheckOrigin != nil {
+		return h.CheckOrigin(r)
+	}
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	i := strings.Index(origin, "://")
+	return i >= 0 && strings.EqualFold(origin[i+3:], r.Host)
+}
+
+type wsConn struct {
+	c 		net.Conn
+	r 		*bufio.Reader
+	mutex 	sync.Mutex
+}
+
+func upgrade(rw http.ResponseWriter, r *http.Request) (*wsConn, error) {
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if r.Method != http.MethodGet || !headerHas(r.Header, "Connection", "upgrade") || !headerHas(r.Header, "Upgrade", "websocket") ||
+		r.Header.Get("Sec-WebSocket-Version") != "13" || key == "" {
+		rw.Header().Set("Sec-WebSocket-Version", "13")
+		http.Error(rw, "websocket upgrade required", http.StatusUpgradeRequired)
+		return nil, errors.New("not a websocket request")
+	}
+	hj, ok := rw.(http.Hijacker)
+	if !ok {
+		http.Error(rw, "websocket not supported", http.StatusInternalServerError)
+		return nil, errors.New("hijack not supported")
+	}
+	c, brw, err := hj.Hijack()
+	if err != nil {
+		return nil, err
+	}
+	sum := sha1.Sum([]byte(key + wsGUID))
+	brw.WriteString("HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\nSec-WebSocket-Accept: ")
+	brw.WriteString(base64.StdEncoding.EncodeToString(sum[:]) + "\r\n\r\n")
+	if err = brw.Flush(); err != nil {
+		c.Close()
+		return nil, err
+	}
+	return &wsConn{c: c, r: brw.Reader}, nil
+}
+
+func headerHas(h http.Header, name, token string) bool {
+	for _, v := range h.Values(name) {
+		for _, s := range strings.Split(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(s), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// write 服务端发送的帧不加掩码
+func (c *wsConn) write(op byte, data []byte) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	head := []byte{0x80 | op, 0}
+	switch n := len(data); {
+	case n < 126:
+		head[1] = byte(n)
+	case n <= 0xffff:
+		head[1] = 126
+		head = binary.BigEndian.AppendUint16(head, uint16(n))
+	default:
+		head[1] = 127
+		head = binary.BigEndian.AppendUint64(head, uint64(n))
+	}
+	c.c.SetWriteDeadline(time.Now().Add(time.Second*10))
+	_, err := c.c.Write(append(head, data...))
+	return err
+}
+
+// readLoop 读取客户端的帧直到连接关闭，每个完整的文本消息调用一次 f
+func (c *wsConn) readLoop(f func(data []byte)) {
+	var msg []byte
+	var msgOp byte
+	head := make([]byte, 2)
+	for {
+		if _, err := io.ReadFull(c.r, head); err != nil {
+			return
+		}
+		fin, op, masked, n := head[0]&0x80 != 0, head[0]&0x0f, head[1]&0x80 != 0, uint64(head[1]&0x7f)
+		switch n {
+		case 126:
+			var b [2]byte
+			if _, err := io.ReadFull(c.r, b[:]); err != nil {
+				return
+			}
+			n = uint64(binary.BigEndian.Uint16(b[:]))
+		case 127:
+			var b [8]byte
+			if _, err := io.ReadFull(c.r, b[:]); err != nil {
+				return
+			}
+			n = binary.BigEndian.Uint64(b[:])
+		}
+		// 客户端的帧必须加掩码
+		if !masked || n > maxMessage || uint64(len(msg))+n > maxMessage {
+			c.write(opClose, closePayload(1002, "protocol error"))
+			return
+		}
+		var mask [4]byte
+		if _, err := io.ReadFull(c.r, mask[:]); err != nil {
+			return
+		}
+		data := make([]byte, n)
+		if _, err := io.ReadFull(c.r, data); err != nil {
+			return
+		}
+		for i := range data {
+			data[i] ^= mask[i%4]
+		}
+		switch op {
+		case opClose:
+			c.write(opClose, data)
+			return
+		case opPing:
+			c.write(opPong, data)
+		case opPong:
+		case opText, opBinary, opContinuation:
+			if op != opContinuation {
+				msg, msgOp = nil, op
+			}
+			msg = append(msg, data...)
+			if fin {
+				if msgOp == opText {
+					f(msg)
+				}
+				msg = nil
+			}
+		}
+	}
+}
+
+func (c *wsConn) close() {
+	c.c.Close()
+}
+
+func closePayload(code uint16, reason string) []byte {
+	return append(binary.BigEndian.AppendUint16(nil, code), reason...)
+}